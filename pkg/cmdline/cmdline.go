@@ -0,0 +1,212 @@
+/*
+	Package cmdline converts between argument lists and Windows command lines.
+
+	A Windows process receives its command line as a single string, which the
+	C runtime or CommandLineToArgvW splits into arguments. SplitCommandLine
+	follows the rules of CommandLineToArgvW, and QuoteArgs produces command
+	lines that it splits back into the original arguments.
+*/
+package cmdline
+
+import (
+	"errors"
+	"strings"
+)
+
+/*
+	ErrQuoteInProgram is returned for a program name containing a quote, which
+	cannot be represented on a command line and is not valid in file names.
+*/
+var ErrQuoteInProgram = errors.New("cmdline: program name contains a quote")
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+/*
+	SplitCommandLine splits a command line into arguments like CommandLineToArgvW.
+
+	The program name follows its own rules: when it starts with a quote it ends
+	at the next quote, otherwise at the first space or tab. Backslashes and
+	quotes have no special meaning in it.
+
+	In the other arguments, spaces and tabs separate arguments outside of quotes,
+	and quotes are removed. Backslashes are literal unless they precede a quote:
+	2n backslashes followed by a quote produce n backslashes and the quote begins
+	or ends a quoted part, 2n+1 backslashes followed by a quote produce n
+	backslashes and a literal quote. Inside a quoted part, two quotes produce
+	a literal quote and end the quoted part, and three quotes produce a literal
+	quote and keep it open.
+
+	Unlike CommandLineToArgvW, an empty command line results in no arguments
+	rather than in the path of the current executable.
+*/
+func SplitCommandLine(cmd string) []string {
+	if cmd == "" {
+		return nil
+	}
+
+	var i int
+	var name string
+	if cmd[0] == '"' {
+		end := strings.IndexByte(cmd[1:], '"')
+		if end < 0 {
+			name, i = cmd[1:], len(cmd)
+		} else {
+			name, i = cmd[1:end+1], end+2
+		}
+	} else {
+		for i < len(cmd) && !isBlank(cmd[i]) {
+			i++
+		}
+		name = cmd[:i]
+	}
+
+	args := []string{name}
+
+	for i < len(cmd) && isBlank(cmd[i]) {
+		i++
+	}
+
+	if i == len(cmd) {
+		return args
+	}
+
+	var arg []byte
+	backslashes, quotes := 0, 0
+
+	for i < len(cmd) {
+		c := cmd[i]
+		switch {
+		case isBlank(c) && quotes == 0:
+			args = append(args, string(arg))
+			arg = arg[:0]
+			backslashes = 0
+
+			for i < len(cmd) && isBlank(cmd[i]) {
+				i++
+			}
+
+			if i == len(cmd) {
+				return args
+			}
+
+		case c == '\\':
+			arg = append(arg, c)
+			backslashes++
+			i++
+
+		case c == '"':
+			if backslashes%2 == 0 {
+				arg = arg[:len(arg)-backslashes/2]
+				quotes++
+			} else {
+				arg = append(arg[:len(arg)-backslashes/2-1], '"')
+			}
+
+			backslashes = 0
+			i++
+
+			// quotes counts the opening quote if any, the one just read,
+			// and the ones that follow.
+			for i < len(cmd) && cmd[i] == '"' {
+				if quotes++; quotes == 3 {
+					arg = append(arg, '"')
+					quotes = 0
+				}
+				i++
+			}
+
+			if quotes == 2 {
+				quotes = 0
+			}
+
+		default:
+			arg = append(arg, c)
+			backslashes = 0
+			i++
+		}
+	}
+
+	return append(args, string(arg))
+}
+
+/*
+	QuoteArg quotes an argument other than the program name, so that
+	SplitCommandLine and the C runtime parse it back unchanged.
+	Arguments without spaces, tabs, newlines or quotes are returned as they are.
+*/
+func QuoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\v\"") {
+		return arg
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+
+	backslashes := 0
+	for i := 0; i < len(arg); i++ {
+		c := arg[i]
+		if c == '\\' {
+			backslashes++
+			continue
+		}
+
+		if c == '"' {
+			// Escape the backslashes and the quote itself.
+			backslashes = 2*backslashes + 1
+		}
+
+		sb.WriteString(strings.Repeat(`\`, backslashes))
+		sb.WriteByte(c)
+		backslashes = 0
+	}
+
+	// Backslashes before the closing quote are escaped.
+	sb.WriteString(strings.Repeat(`\`, 2*backslashes))
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+/*
+	QuoteProgram quotes a program name for the start of a command line.
+
+	The program name is quoted when it is empty or contains spaces or tabs.
+	It cannot contain quotes, since nothing escapes them in the program name,
+	and ErrQuoteInProgram is returned for those.
+*/
+func QuoteProgram(name string) (string, error) {
+	if strings.Contains(name, `"`) {
+		return "", ErrQuoteInProgram
+	}
+
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return `"` + name + `"`, nil
+	}
+
+	return name, nil
+}
+
+/*
+	QuoteArgs builds a command line from args, where args[0] is the program name.
+	It fails like QuoteProgram when the program name contains a quote.
+*/
+func QuoteArgs(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	program, err := QuoteProgram(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, len(args))
+	parts[0] = program
+	for i, arg := range args[1:] {
+		parts[i+1] = QuoteArg(arg)
+	}
+
+	return strings.Join(parts, " "), nil
+}
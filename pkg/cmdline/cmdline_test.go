@@ -0,0 +1,194 @@
+package cmdline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want []string
+	}{
+		{``, nil},
+		{`a`, []string{`a`}},
+		{`a b  c`, []string{`a`, `b`, `c`}},
+		{"a\tb \t c  ", []string{`a`, `b`, `c`}},
+		{`a "b c" d`, []string{`a`, `b c`, `d`}},
+		{`a "" b`, []string{`a`, ``, `b`}},
+		{`a "`, []string{`a`, ``}},
+		{`a b"c d"e`, []string{`a`, `bc de`}},
+		{`a "unterminated b`, []string{`a`, `unterminated b`}},
+
+		// The program name ends at the next quote, or at a blank if it is not quoted.
+		{`"C:\Program Files\a.exe" x`, []string{`C:\Program Files\a.exe`, `x`}},
+		{`"C:\Program Files\a.exe`, []string{`C:\Program Files\a.exe`}},
+		{`"abc"def g`, []string{`abc`, `def`, `g`}},
+		{`"" a`, []string{``, `a`}},
+		{` a b`, []string{``, `a`, `b`}},
+
+		// Backslashes and quotes are literal in the program name.
+		{`C:\dir\a.exe x`, []string{`C:\dir\a.exe`, `x`}},
+		{`C:\a\"b c`, []string{`C:\a\"b`, `c`}},
+		{`a"b c"d e`, []string{`a"b`, `cd e`}},
+		{`"a\" b`, []string{`a\`, `b`}},
+
+		// Backslashes are literal unless they precede a quote.
+		{`a b\c`, []string{`a`, `b\c`}},
+		{`a b\\c\\\d`, []string{`a`, `b\\c\\\d`}},
+		{`a b\`, []string{`a`, `b\`}},
+		{`a \\ b`, []string{`a`, `\\`, `b`}},
+		{`a b\"c`, []string{`a`, `b"c`}},
+		{`a b\\"c d"`, []string{`a`, `b\c d`}},
+		{`a b\\\"c`, []string{`a`, `b\"c`}},
+		{`a b\\\\"c d"`, []string{`a`, `b\\c d`}},
+		{`a b\\\\\"c`, []string{`a`, `b\\"c`}},
+		{`a "b\\" c`, []string{`a`, `b\`, `c`}},
+		{`a "b\" c"`, []string{`a`, `b" c`}},
+		{`a "\\\\"`, []string{`a`, `\\`}},
+		{`a \"b c\"`, []string{`a`, `"b`, `c"`}},
+
+		// Two quotes inside a quoted part produce a quote and end the part,
+		// three produce a quote and keep it open.
+		{`a "b""c d"`, []string{`a`, `b"c`, `d`}},
+		{`a "b"""c d"`, []string{`a`, `b"c d`}},
+		{`a "b""""c d"`, []string{`a`, `b"c`, `d`}},
+		{`a """"`, []string{`a`, `"`}},
+		{`a """b c"`, []string{`a`, `"b`, `c`}},
+
+		// Outside of quotes, two quotes are an empty quoted part.
+		{`a b""c`, []string{`a`, `bc`}},
+		{`a ""`, []string{`a`, ``}},
+		{`a """`, []string{`a`, `"`}},
+		{`a \""b c"`, []string{`a`, `"b c`}},
+	}
+
+	for _, tc := range tests {
+		if got := SplitCommandLine(tc.cmd); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.cmd, got, tc.want)
+		}
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		arg, want string
+	}{
+		{``, `""`},
+		{`a`, `a`},
+		{`a\b`, `a\b`},
+		{`a\`, `a\`},
+		{`a b`, `"a b"`},
+		{"a\tb", "\"a\tb\""},
+		{"a\nb", "\"a\nb\""},
+		{"a\vb", "\"a\vb\""},
+		{`a b\`, `"a b\\"`},
+		{`a b\\`, `"a b\\\\"`},
+		{`a\b c`, `"a\b c"`},
+		{`"`, `"\""`},
+		{`a"b`, `"a\"b"`},
+		{`a\"b`, `"a\\\"b"`},
+		{`a\\"b`, `"a\\\\\"b"`},
+		{`\"`, `"\\\""`},
+		{`""`, `"\"\""`},
+	}
+
+	for _, tc := range tests {
+		if got := QuoteArg(tc.arg); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestQuoteProgram(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{``, `""`},
+		{`a.exe`, `a.exe`},
+		{`C:\dir\a.exe`, `C:\dir\a.exe`},
+		{`C:\Program Files\a.exe`, `"C:\Program Files\a.exe"`},
+		{"a\tb", "\"a\tb\""},
+		{`C:\dir with space\`, `"C:\dir with space\"`},
+	}
+
+	for _, tc := range tests {
+		if got, err := QuoteProgram(tc.name); err != nil || got != tc.want {
+			t.Errorf("%s: got %s, %v; want %s", tc.name, got, err, tc.want)
+		}
+	}
+
+	if _, err := QuoteProgram(`a"b`); !errors.Is(err, ErrQuoteInProgram) {
+		t.Errorf("got %v, want ErrQuoteInProgram", err)
+	}
+
+	if _, err := QuoteArgs([]string{`"a"`, `b`}); !errors.Is(err, ErrQuoteInProgram) {
+		t.Errorf("QuoteArgs: got %v, want ErrQuoteInProgram", err)
+	}
+}
+
+func TestQuoteArgsRoundTrip(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{``},
+		{`a.exe`},
+		{`C:\Program Files\a.exe`, `-x`},
+		{`a`, ``},
+		{`a`, ``, ``},
+		{`a`, `b c`, "d\te", "f\ng"},
+		{`a`, `\`, `\\`, `\\\`},
+		{`a`, `b\`, `b c\`, `b c\\`},
+		{`a`, `"`, `""`, `"""`},
+		{`a`, `\"`, `\\"`, `\\\"`, `\\\\"`},
+		{`a`, `b\"c d`, `b\\"c d`, `b\\\"c d`},
+		{`a`, `"b c"`, `"b c\"`, `\"b c\\"`},
+		{`a`, `b""c`, `b"""c`, ` "" `},
+		{`C:\dir with space\`, `x`},
+		{`C:\a\b.exe`, `C:\Program Files\`},
+		{`a`, `é`, `𝄞 x`},
+	}
+
+	for _, args := range tests {
+		cmd, err := QuoteArgs(args)
+		if err != nil {
+			t.Errorf("%q: %v", args, err)
+			continue
+		}
+
+		if got := SplitCommandLine(cmd); !reflect.DeepEqual(got, args) {
+			t.Errorf("%q: quoted as %s, split into %q", args, cmd, got)
+		}
+	}
+}
+
+/*
+	TestQuoteArgRoundTripExhaustive round trips every argument of up to five
+	characters made of the characters that matter to the rules.
+*/
+func TestQuoteArgRoundTripExhaustive(t *testing.T) {
+	const alphabet = "a \t\\\""
+
+	var gen func(prefix string, n int)
+	gen = func(prefix string, n int) {
+		args := []string{`p`, prefix, `z`}
+		cmd, err := QuoteArgs(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := SplitCommandLine(cmd); !reflect.DeepEqual(got, args) {
+			t.Fatalf("%q: quoted as %s, split into %q", prefix, cmd, got)
+		}
+
+		if n == 0 {
+			return
+		}
+
+		for i := 0; i < len(alphabet); i++ {
+			gen(prefix+alphabet[i:i+1], n-1)
+		}
+	}
+
+	gen("", 5)
+}
@@ -0,0 +1,390 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+)
+
+/*
+	int3 is the opcode of the software breakpoint instruction.
+*/
+const int3 = 0xCC
+
+/*
+	EFLAGS_TF is the trap flag, which raises a single step exception
+	after the next instruction.
+*/
+const EFLAGS_TF = 0x100
+
+var (
+	ErrBreakpointExists   = errors.New("debugger: breakpoint already exists")
+	ErrNoBreakpoint       = errors.New("debugger: no breakpoint at address")
+	ErrBreakpointsClosed  = errors.New("debugger: breakpoint manager is closed")
+	ErrBreakpointModified = errors.New("debugger: breakpoint byte was overwritten")
+)
+
+/*
+	Breakpoint is a software breakpoint managed by Breakpoints.
+*/
+type Breakpoint struct {
+	Address uintptr
+	Enabled bool
+	Hits    int
+
+	/*
+		Original is the byte the breakpoint replaced.
+	*/
+	Original byte
+
+	// armed reports whether 0xCC is currently written at Address.
+	// A breakpoint that was just hit stays disarmed until its thread
+	// has single-stepped over the original instruction.
+	armed bool
+}
+
+/*
+	Registers is the part of a thread context that breakpoint handling needs.
+	PC is RIP or EIP and Flags is EFLAGS.
+*/
+type Registers struct {
+	PC    uint64
+	Flags uint32
+}
+
+/*
+	ThreadRegisters reads and writes the Registers of the threads of a debuggee.
+
+	wow64 selects the WOW64 context of a thread instead of its native one. It is
+	set for the exceptions raised by the 32-bit code of a WOW64 process, whose
+	program counter and flags are only found in the WOW64 context.
+*/
+type ThreadRegisters interface {
+	GetRegisters(thread uint32, wow64 bool) (Registers, error)
+	SetRegisters(thread uint32, wow64 bool, regs Registers) error
+}
+
+/*
+	Breakpoints manages software breakpoints in the memory of a process.
+
+	The original byte is saved when a breakpoint is added and written back
+	whenever the breakpoint is disabled, removed, or the manager is closed,
+	so the debuggee never keeps a stale 0xCC. Breakpoints is not safe for
+	concurrent use; it is meant to be driven from the debug event loop.
+*/
+type Breakpoints struct {
+	mem    process.Memory
+	bps    map[uintptr]*Breakpoint
+	rearm  map[uint32]uintptr
+	closed bool
+}
+
+/*
+	NewBreakpoints creates a breakpoint manager patching mem.
+	Code is written with process.WriteCode, so the instruction cache is
+	flushed when mem supports it.
+*/
+func NewBreakpoints(mem process.Memory) *Breakpoints {
+	return &Breakpoints{
+		mem:   mem,
+		bps:   make(map[uintptr]*Breakpoint),
+		rearm: make(map[uint32]uintptr),
+	}
+}
+
+/*
+	Add sets an enabled breakpoint at addr.
+*/
+func (b *Breakpoints) Add(addr uintptr) (*Breakpoint, error) {
+	if b.closed {
+		return nil, ErrBreakpointsClosed
+	}
+
+	if _, ok := b.bps[addr]; ok {
+		return nil, ErrBreakpointExists
+	}
+
+	var orig [1]byte
+	if err := b.mem.ReadMemory(addr, orig[:]); err != nil {
+		return nil, fmt.Errorf("debugger: reading breakpoint address %#x: %w", addr, err)
+	}
+
+	bp := &Breakpoint{Address: addr, Original: orig[0]}
+	if err := b.arm(bp); err != nil {
+		return nil, err
+	}
+
+	bp.Enabled = true
+	b.bps[addr] = bp
+	return bp, nil
+}
+
+/*
+	Remove deletes the breakpoint at addr and restores the original byte.
+*/
+func (b *Breakpoints) Remove(addr uintptr) error {
+	bp, ok := b.bps[addr]
+	if !ok {
+		return ErrNoBreakpoint
+	}
+
+	err := b.disarm(bp)
+	if err != nil && !errors.Is(err, ErrBreakpointModified) {
+		return err
+	}
+
+	delete(b.bps, addr)
+	return err
+}
+
+/*
+	Enable re-arms a disabled breakpoint.
+*/
+func (b *Breakpoints) Enable(addr uintptr) error {
+	bp, ok := b.bps[addr]
+	if !ok {
+		return ErrNoBreakpoint
+	}
+
+	if bp.Enabled {
+		return nil
+	}
+
+	if !b.pending(addr) {
+		if err := b.arm(bp); err != nil {
+			return err
+		}
+	}
+
+	bp.Enabled = true
+	return nil
+}
+
+/*
+	Disable restores the original byte but keeps the breakpoint so it can be enabled again.
+*/
+func (b *Breakpoints) Disable(addr uintptr) error {
+	bp, ok := b.bps[addr]
+	if !ok {
+		return ErrNoBreakpoint
+	}
+
+	if err := b.disarm(bp); err != nil {
+		return err
+	}
+
+	bp.Enabled = false
+	return nil
+}
+
+/*
+	Lookup returns the breakpoint at addr.
+*/
+func (b *Breakpoints) Lookup(addr uintptr) (*Breakpoint, bool) {
+	bp, ok := b.bps[addr]
+	return bp, ok
+}
+
+/*
+	List returns all breakpoints sorted by address.
+*/
+func (b *Breakpoints) List() []*Breakpoint {
+	list := make([]*Breakpoint, 0, len(b.bps))
+	for _, bp := range b.bps {
+		list = append(list, bp)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+
+	return list
+}
+
+/*
+	HandleBreakpoint handles an EXCEPTION_BREAKPOINT raised by thread at addr.
+
+	If addr is an armed breakpoint, the original byte is restored, PC is
+	rewound to addr and the trap flag is set so that the breakpoint is
+	re-armed by HandleSingleStep once the original instruction has executed.
+	It reports false for breakpoints it does not own, which should be passed
+	on to the debuggee.
+*/
+func (b *Breakpoints) HandleBreakpoint(thread uint32, addr uintptr, regs *Registers) (*Breakpoint, bool, error) {
+	bp, ok := b.bps[addr]
+	if !ok || !bp.armed {
+		return nil, false, nil
+	}
+
+	if err := b.disarm(bp); err != nil {
+		return nil, false, err
+	}
+
+	bp.Hits++
+	regs.PC = uint64(addr)
+	regs.Flags |= EFLAGS_TF
+	b.rearm[thread] = addr
+
+	return bp, true, nil
+}
+
+/*
+	HandleSingleStep handles an EXCEPTION_SINGLE_STEP raised by thread.
+	If the thread was stepping over a breakpoint, the breakpoint is re-armed
+	when still enabled and the trap flag is cleared. It reports false for
+	single steps it did not request.
+*/
+func (b *Breakpoints) HandleSingleStep(thread uint32, regs *Registers) (bool, error) {
+	addr, ok := b.rearm[thread]
+	if !ok {
+		return false, nil
+	}
+
+	delete(b.rearm, thread)
+	regs.Flags &^= EFLAGS_TF
+
+	bp, ok := b.bps[addr]
+	if !ok || !bp.Enabled || b.pending(addr) || b.closed {
+		return true, nil
+	}
+
+	return true, b.arm(bp)
+}
+
+/*
+	Dispatch passes a breakpoint or single step exception to HandleBreakpoint or
+	HandleSingleStep, reading and updating the registers of the faulting thread
+	through threads. STATUS_WX86_BREAKPOINT and STATUS_WX86_SINGLE_STEP, raised by
+	32-bit code under WOW64, are handled with the WOW64 registers of the thread.
+
+	It reports whether the exception was caused by b, in which case the event
+	should be continued with DBG_CONTINUE rather than passed to the debuggee.
+*/
+func (b *Breakpoints) Dispatch(ev *ExceptionEvent, threads ThreadRegisters) (bool, error) {
+	var breakpoint, wow64 bool
+	switch ev.Record.Code {
+	case EXCEPTION_BREAKPOINT:
+		breakpoint = true
+	case STATUS_WX86_BREAKPOINT:
+		breakpoint, wow64 = true, true
+	case EXCEPTION_SINGLE_STEP:
+	case STATUS_WX86_SINGLE_STEP:
+		wow64 = true
+	default:
+		return false, nil
+	}
+
+	if breakpoint {
+		if bp, ok := b.bps[uintptr(ev.Record.Address)]; !ok || !bp.armed {
+			return false, nil
+		}
+	} else if _, ok := b.rearm[ev.ThreadID]; !ok {
+		return false, nil
+	}
+
+	regs, err := threads.GetRegisters(ev.ThreadID, wow64)
+	if err != nil {
+		return false, err
+	}
+
+	var handled bool
+	if breakpoint {
+		_, handled, err = b.HandleBreakpoint(ev.ThreadID, uintptr(ev.Record.Address), &regs)
+	} else {
+		handled, err = b.HandleSingleStep(ev.ThreadID, &regs)
+	}
+
+	if err != nil || !handled {
+		return handled, err
+	}
+
+	return true, threads.SetRegisters(ev.ThreadID, wow64, regs)
+}
+
+/*
+	ReadMemory reads the memory of the process with the original bytes
+	in place of armed breakpoints.
+*/
+func (b *Breakpoints) ReadMemory(addr uintptr, buf []byte) error {
+	if err := b.mem.ReadMemory(addr, buf); err != nil {
+		return err
+	}
+
+	for _, bp := range b.bps {
+		if bp.armed && bp.Address >= addr && bp.Address-addr < uintptr(len(buf)) {
+			buf[bp.Address-addr] = bp.Original
+		}
+	}
+
+	return nil
+}
+
+/*
+	Close restores the original bytes of all breakpoints and forgets them.
+	The manager cannot be used to add breakpoints afterwards. All breakpoints
+	are attempted even if some fail, and the first error is returned.
+*/
+func (b *Breakpoints) Close() error {
+	var first error
+	for _, bp := range b.List() {
+		if err := b.disarm(bp); err != nil && first == nil {
+			first = err
+		}
+		delete(b.bps, bp.Address)
+	}
+
+	b.closed = true
+	return first
+}
+
+/*
+	pending reports whether a thread is currently stepping over the breakpoint at addr.
+*/
+func (b *Breakpoints) pending(addr uintptr) bool {
+	for _, a := range b.rearm {
+		if a == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Breakpoints) arm(bp *Breakpoint) error {
+	if bp.armed {
+		return nil
+	}
+
+	if err := process.WriteCode(b.mem, bp.Address, []byte{int3}); err != nil {
+		return fmt.Errorf("debugger: writing breakpoint at %#x: %w", bp.Address, err)
+	}
+
+	bp.armed = true
+	return nil
+}
+
+func (b *Breakpoints) disarm(bp *Breakpoint) error {
+	if !bp.armed {
+		return nil
+	}
+
+	var cur [1]byte
+	if err := b.mem.ReadMemory(bp.Address, cur[:]); err != nil {
+		return fmt.Errorf("debugger: reading breakpoint at %#x: %w", bp.Address, err)
+	}
+
+	if cur[0] != int3 {
+		// Someone else rewrote the byte; restoring ours would corrupt it.
+		bp.armed = false
+		return fmt.Errorf("%w at %#x", ErrBreakpointModified, bp.Address)
+	}
+
+	if err := process.WriteCode(b.mem, bp.Address, []byte{bp.Original}); err != nil {
+		return fmt.Errorf("debugger: restoring breakpoint at %#x: %w", bp.Address, err)
+	}
+
+	bp.armed = false
+	return nil
+}
@@ -0,0 +1,284 @@
+package debugger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/process/processtest"
+)
+
+const codeBase = 0x401000
+
+/*
+	fakeMemory counts instruction cache flushes on top of a processtest.Memory.
+*/
+type fakeMemory struct {
+	*processtest.Memory
+	flushes int
+}
+
+func (m *fakeMemory) FlushInstructionCache(addr uintptr, size int) error {
+	m.flushes++
+	return nil
+}
+
+/*
+	fakeThreads holds the native and WOW64 registers of every thread.
+*/
+type fakeThreads struct {
+	native map[uint32]*Registers
+	wow64  map[uint32]*Registers
+	sets   int
+}
+
+func newFakeThreads() *fakeThreads {
+	return &fakeThreads{native: make(map[uint32]*Registers), wow64: make(map[uint32]*Registers)}
+}
+
+func (f *fakeThreads) regs(thread uint32, wow64 bool) *Registers {
+	m := f.native
+	if wow64 {
+		m = f.wow64
+	}
+
+	if m[thread] == nil {
+		m[thread] = &Registers{Flags: 0x202}
+	}
+
+	return m[thread]
+}
+
+func (f *fakeThreads) GetRegisters(thread uint32, wow64 bool) (Registers, error) {
+	return *f.regs(thread, wow64), nil
+}
+
+func (f *fakeThreads) SetRegisters(thread uint32, wow64 bool, regs Registers) error {
+	f.sets++
+	*f.regs(thread, wow64) = regs
+	return nil
+}
+
+func newBreakpoints(t *testing.T) (*Breakpoints, *fakeMemory, []byte) {
+	t.Helper()
+
+	mem := &fakeMemory{Memory: processtest.NewMemory()}
+	code := mem.Map(codeBase, []byte{0x55, 0x8B, 0xEC, 0x90, 0xC3})
+	return NewBreakpoints(mem), mem, code
+}
+
+func exception(code uint32, thread uint32, addr uint64) *ExceptionEvent {
+	return &ExceptionEvent{
+		EventHeader: EventHeader{Code: EXCEPTION_DEBUG_EVENT, ProcessID: 1, ThreadID: thread},
+		Record:      ExceptionRecord{Code: code, Address: addr},
+		FirstChance: true,
+	}
+}
+
+func TestBreakpointAddRemove(t *testing.T) {
+	b, mem, code := newBreakpoints(t)
+
+	bp, err := b.Add(codeBase + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code[1] != int3 || bp.Original != 0x8B || !bp.Enabled || mem.flushes != 1 {
+		t.Fatalf("after Add: code % x, breakpoint %+v, %d flushes", code, bp, mem.flushes)
+	}
+
+	if _, err := b.Add(codeBase + 1); !errors.Is(err, ErrBreakpointExists) {
+		t.Errorf("second Add: got %v, want ErrBreakpointExists", err)
+	}
+
+	buf := make([]byte, 5)
+	if err := b.ReadMemory(codeBase, buf); err != nil || buf[1] != 0x8B {
+		t.Errorf("ReadMemory returned % x, %v; want the original byte", buf, err)
+	}
+
+	if err := b.Disable(codeBase + 1); err != nil || code[1] != 0x8B {
+		t.Errorf("Disable: %v, code % x", err, code)
+	}
+
+	if err := b.Enable(codeBase + 1); err != nil || code[1] != int3 {
+		t.Errorf("Enable: %v, code % x", err, code)
+	}
+
+	if err := b.Remove(codeBase + 1); err != nil || code[1] != 0x8B {
+		t.Errorf("Remove: %v, code % x", err, code)
+	}
+
+	for _, err := range []error{b.Remove(codeBase + 1), b.Enable(codeBase + 1), b.Disable(codeBase + 1)} {
+		if !errors.Is(err, ErrNoBreakpoint) {
+			t.Errorf("got %v, want ErrNoBreakpoint", err)
+		}
+	}
+
+	if _, err := b.Add(0x1000); err == nil {
+		t.Error("Add succeeded on unmapped memory")
+	}
+}
+
+func TestBreakpointHit(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		breakpoint, step uint32
+		wow64            bool
+	}{
+		{"native", EXCEPTION_BREAKPOINT, EXCEPTION_SINGLE_STEP, false},
+		{"wow64", STATUS_WX86_BREAKPOINT, STATUS_WX86_SINGLE_STEP, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _, code := newBreakpoints(t)
+			threads := newFakeThreads()
+
+			if _, err := b.Add(codeBase + 3); err != nil {
+				t.Fatal(err)
+			}
+
+			// The processor reports the int3 and leaves PC after it.
+			threads.regs(7, tt.wow64).PC = codeBase + 4
+
+			handled, err := b.Dispatch(exception(tt.breakpoint, 7, codeBase+3), threads)
+			if err != nil || !handled {
+				t.Fatalf("breakpoint: handled %v, %v", handled, err)
+			}
+
+			regs := threads.regs(7, tt.wow64)
+			if regs.PC != codeBase+3 || regs.Flags&EFLAGS_TF == 0 || code[3] != 0x90 {
+				t.Fatalf("after breakpoint: registers %+v, code % x", regs, code)
+			}
+
+			if other := threads.regs(7, !tt.wow64); other.PC != 0 || other.Flags != 0x202 {
+				t.Errorf("the other context was modified: %+v", other)
+			}
+
+			if bp, _ := b.Lookup(codeBase + 3); bp.Hits != 1 {
+				t.Errorf("got %d hits", bp.Hits)
+			}
+
+			regs.PC = codeBase + 4
+			handled, err = b.Dispatch(exception(tt.step, 7, codeBase+4), threads)
+			if err != nil || !handled {
+				t.Fatalf("single step: handled %v, %v", handled, err)
+			}
+
+			if regs.Flags != 0x202 || code[3] != int3 {
+				t.Errorf("after single step: registers %+v, code % x", regs, code)
+			}
+		})
+	}
+}
+
+func TestBreakpointNotOwned(t *testing.T) {
+	b, _, _ := newBreakpoints(t)
+	threads := newFakeThreads()
+
+	if _, err := b.Add(codeBase); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ev := range []*ExceptionEvent{
+		exception(EXCEPTION_BREAKPOINT, 1, codeBase+2),
+		exception(STATUS_WX86_BREAKPOINT, 1, codeBase+2),
+		exception(EXCEPTION_SINGLE_STEP, 1, codeBase+2),
+		exception(STATUS_WX86_SINGLE_STEP, 1, codeBase+2),
+		exception(EXCEPTION_ACCESS_VIOLATION, 1, codeBase),
+	} {
+		if handled, err := b.Dispatch(ev, threads); handled || err != nil {
+			t.Errorf("exception %#x: handled %v, %v", ev.Record.Code, handled, err)
+		}
+	}
+
+	if threads.sets != 0 || len(threads.native)+len(threads.wow64) != 0 {
+		t.Errorf("registers were accessed for exceptions that are not ours")
+	}
+}
+
+func TestBreakpointDisabledWhileStepping(t *testing.T) {
+	b, _, code := newBreakpoints(t)
+	threads := newFakeThreads()
+
+	if _, err := b.Add(codeBase); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Dispatch(exception(EXCEPTION_BREAKPOINT, 1, codeBase), threads); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Disable(codeBase); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enabling while thread 1 still steps over the original instruction
+	// must not write the int3 under it.
+	if err := b.Enable(codeBase); err != nil || code[0] != 0x55 {
+		t.Fatalf("Enable while stepping: %v, code % x", err, code)
+	}
+
+	if _, err := b.Dispatch(exception(EXCEPTION_SINGLE_STEP, 1, codeBase+1), threads); err != nil {
+		t.Fatal(err)
+	}
+
+	if code[0] != int3 {
+		t.Errorf("breakpoint was not re-armed after the step: code % x", code)
+	}
+
+	if err := b.Disable(codeBase); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Dispatch(exception(EXCEPTION_SINGLE_STEP, 1, codeBase+1), threads)
+	if code[0] != 0x55 {
+		t.Errorf("disabled breakpoint was re-armed: code % x", code)
+	}
+}
+
+func TestBreakpointModified(t *testing.T) {
+	b, _, code := newBreakpoints(t)
+
+	if _, err := b.Add(codeBase); err != nil {
+		t.Fatal(err)
+	}
+
+	// The debuggee rewrote its own code over the breakpoint.
+	code[0] = 0xE9
+
+	if err := b.Remove(codeBase); !errors.Is(err, ErrBreakpointModified) {
+		t.Errorf("got %v, want ErrBreakpointModified", err)
+	}
+
+	if code[0] != 0xE9 {
+		t.Errorf("the new code was overwritten: % x", code)
+	}
+
+	if _, ok := b.Lookup(codeBase); ok {
+		t.Error("the modified breakpoint was kept")
+	}
+}
+
+func TestBreakpointClose(t *testing.T) {
+	b, _, code := newBreakpoints(t)
+
+	for _, addr := range []uintptr{codeBase, codeBase + 2, codeBase + 4} {
+		if _, err := b.Add(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if list := b.List(); len(list) != 3 || list[0].Address != codeBase || list[2].Address != codeBase+4 {
+		t.Errorf("List returned %+v", list)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(code) != string([]byte{0x55, 0x8B, 0xEC, 0x90, 0xC3}) {
+		t.Errorf("Close left code % x", code)
+	}
+
+	if _, err := b.Add(codeBase); !errors.Is(err, ErrBreakpointsClosed) {
+		t.Errorf("Add after Close: got %v, want ErrBreakpointsClosed", err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build amd64 || 386
+
+package debugger
+
+import (
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	HandleException dispatches a breakpoint or single step exception to b,
+	updating the context of the faulting thread when b handled it.
+	Exceptions raised by 32-bit code under WOW64 update the WOW64 context,
+	which is only possible from a 64-bit debugger.
+
+	It reports whether the exception was caused by b, in which case the event
+	should be continued with DBG_CONTINUE rather than passed to the debuggee.
+*/
+func (b *Breakpoints) HandleException(ev *ExceptionEvent) (bool, error) {
+	return b.Dispatch(ev, threadRegisters{})
+}
+
+/*
+	threadRegisters implements ThreadRegisters with the thread context API.
+*/
+type threadRegisters struct{}
+
+func (threadRegisters) GetRegisters(tid uint32, wow64 bool) (Registers, error) {
+	thread, err := kernel32.OpenThread(kernel32.THREAD_GET_CONTEXT, false, tid)
+	if err != nil {
+		return Registers{}, err
+	}
+	defer kernel32.CloseHandle(thread)
+
+	if wow64 {
+		return getWow64Registers(thread)
+	}
+
+	ctx := win32.Context{ContextFlags: win32.CONTEXT_CONTROL}
+	if err := kernel32.GetThreadContext(thread, &ctx); err != nil {
+		return Registers{}, err
+	}
+
+	return contextRegisters(&ctx), nil
+}
+
+func (threadRegisters) SetRegisters(tid uint32, wow64 bool, regs Registers) error {
+	thread, err := kernel32.OpenThread(kernel32.THREAD_GET_CONTEXT|kernel32.THREAD_SET_CONTEXT, false, tid)
+	if err != nil {
+		return err
+	}
+	defer kernel32.CloseHandle(thread)
+
+	if wow64 {
+		return setWow64Registers(thread, regs)
+	}
+
+	// Read the control registers back so that only PC and flags change.
+	ctx := win32.Context{ContextFlags: win32.CONTEXT_CONTROL}
+	if err := kernel32.GetThreadContext(thread, &ctx); err != nil {
+		return err
+	}
+
+	setContextRegisters(&ctx, regs)
+	return kernel32.SetThreadContext(thread, &ctx)
+}
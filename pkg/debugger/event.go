@@ -0,0 +1,296 @@
+/*
+	Package debugger implements a Win32 debug loop on top of the debug API.
+
+	Debug events are decoded from the raw DEBUG_EVENT layout into typed Go
+	values, so decoding can be exercised against byte fixtures of either
+	pointer size on any platform.
+*/
+package debugger
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	Debug event codes, as found in the dwDebugEventCode field of DEBUG_EVENT.
+*/
+const (
+	EXCEPTION_DEBUG_EVENT      uint32 = 1
+	CREATE_THREAD_DEBUG_EVENT  uint32 = 2
+	CREATE_PROCESS_DEBUG_EVENT uint32 = 3
+	EXIT_THREAD_DEBUG_EVENT    uint32 = 4
+	EXIT_PROCESS_DEBUG_EVENT   uint32 = 5
+	LOAD_DLL_DEBUG_EVENT       uint32 = 6
+	UNLOAD_DLL_DEBUG_EVENT     uint32 = 7
+	OUTPUT_DEBUG_STRING_EVENT  uint32 = 8
+	RIP_EVENT                  uint32 = 9
+)
+
+/*
+	Exception codes commonly seen by debuggers.
+*/
+const (
+	EXCEPTION_ACCESS_VIOLATION    uint32 = 0xC0000005
+	EXCEPTION_BREAKPOINT          uint32 = 0x80000003
+	EXCEPTION_SINGLE_STEP         uint32 = 0x80000004
+	EXCEPTION_GUARD_PAGE          uint32 = 0x80000001
+	EXCEPTION_ILLEGAL_INSTRUCTION uint32 = 0xC000001D
+	STATUS_WX86_BREAKPOINT        uint32 = 0x4000001F
+	STATUS_WX86_SINGLE_STEP       uint32 = 0x4000001E
+)
+
+/*
+	EXCEPTION_MAXIMUM_PARAMETERS is the capacity of ExceptionInformation.
+*/
+const EXCEPTION_MAXIMUM_PARAMETERS = 15
+
+/*
+	Event is a decoded debug event. The concrete type is one of the *Event
+	types of this package.
+*/
+type Event interface {
+	Header() EventHeader
+}
+
+/*
+	EventHeader holds the fields common to every debug event.
+*/
+type EventHeader struct {
+	Code      uint32
+	ProcessID uint32
+	ThreadID  uint32
+}
+
+func (h EventHeader) Header() EventHeader {
+	return h
+}
+
+/*
+	ExceptionRecord is a decoded EXCEPTION_RECORD. Record is the address
+	of the chained record in the debuggee, if any.
+*/
+type ExceptionRecord struct {
+	Code       uint32
+	Flags      uint32
+	Record     uint64
+	Address    uint64
+	Parameters []uint64
+}
+
+type ExceptionEvent struct {
+	EventHeader
+	Record      ExceptionRecord
+	FirstChance bool
+}
+
+type CreateThreadEvent struct {
+	EventHeader
+	Thread          win32.Handle
+	ThreadLocalBase uint64
+	StartAddress    uint64
+}
+
+/*
+	CreateProcessEvent reports the first event of a debugged process.
+	File is a handle to the image file that the debugger is expected to close.
+	ImageName is an address in the debuggee that may point to the image path.
+*/
+type CreateProcessEvent struct {
+	EventHeader
+	File                win32.Handle
+	Process             win32.Handle
+	Thread              win32.Handle
+	BaseOfImage         uint64
+	DebugInfoFileOffset uint32
+	DebugInfoSize       uint32
+	ThreadLocalBase     uint64
+	StartAddress        uint64
+	ImageName           uint64
+	Unicode             bool
+}
+
+type ExitThreadEvent struct {
+	EventHeader
+	ExitCode uint32
+}
+
+type ExitProcessEvent struct {
+	EventHeader
+	ExitCode uint32
+}
+
+/*
+	LoadDLLEvent reports a DLL mapped into the debuggee.
+	File is a handle to the DLL that the debugger is expected to close.
+*/
+type LoadDLLEvent struct {
+	EventHeader
+	File                win32.Handle
+	BaseOfDll           uint64
+	DebugInfoFileOffset uint32
+	DebugInfoSize       uint32
+	ImageName           uint64
+	Unicode             bool
+}
+
+type UnloadDLLEvent struct {
+	EventHeader
+	BaseOfDll uint64
+}
+
+/*
+	OutputDebugStringEvent reports a call to OutputDebugString. The string
+	is located in the debuggee at DebugStringData and is Length characters long,
+	including the terminating NUL.
+*/
+type OutputDebugStringEvent struct {
+	EventHeader
+	DebugStringData uint64
+	Unicode         bool
+	Length          uint16
+}
+
+type RIPEvent struct {
+	EventHeader
+	Error uint32
+	Type  uint32
+}
+
+/*
+	DebugEventSize returns the size of DEBUG_EVENT for the given pointer size.
+*/
+func DebugEventSize(ptrSize int) int {
+	if ptrSize == 8 {
+		return 176
+	}
+	return 96
+}
+
+/*
+	DecodeEvent decodes a DEBUG_EVENT laid out for a debugger with the given
+	pointer size, which must be 4 or 8. Unknown event codes are an error.
+*/
+func DecodeEvent(raw []byte, ptrSize int) (Event, error) {
+	if ptrSize != 4 && ptrSize != 8 {
+		return nil, fmt.Errorf("debugger: invalid pointer size %d", ptrSize)
+	}
+
+	if len(raw) < DebugEventSize(ptrSize) {
+		return nil, fmt.Errorf("debugger: DEBUG_EVENT is %d bytes, want %d", len(raw), DebugEventSize(ptrSize))
+	}
+
+	hdr := EventHeader{
+		Code:      binary.LittleEndian.Uint32(raw[0:]),
+		ProcessID: binary.LittleEndian.Uint32(raw[4:]),
+		ThreadID:  binary.LittleEndian.Uint32(raw[8:]),
+	}
+
+	// The union is aligned to the pointer size.
+	d := decoder{b: raw[(12+ptrSize-1)&^(ptrSize-1):], ptrSize: ptrSize}
+	p := ptrSize
+
+	switch hdr.Code {
+	case EXCEPTION_DEBUG_EVENT:
+		// NumberParameters is followed by padding up to pointer alignment.
+		params := (2*p + 12 + p - 1) &^ (p - 1)
+		count := d.u32(2*p + 8)
+		if count > EXCEPTION_MAXIMUM_PARAMETERS {
+			count = EXCEPTION_MAXIMUM_PARAMETERS
+		}
+
+		rec := ExceptionRecord{
+			Code:    d.u32(0),
+			Flags:   d.u32(4),
+			Record:  d.ptr(8),
+			Address: d.ptr(8 + p),
+		}
+		for i := 0; i < int(count); i++ {
+			rec.Parameters = append(rec.Parameters, d.ptr(params+i*p))
+		}
+
+		return &ExceptionEvent{
+			EventHeader: hdr,
+			Record:      rec,
+			FirstChance: d.u32(params+EXCEPTION_MAXIMUM_PARAMETERS*p) != 0,
+		}, nil
+
+	case CREATE_THREAD_DEBUG_EVENT:
+		return &CreateThreadEvent{
+			EventHeader:     hdr,
+			Thread:          win32.Handle(d.ptr(0)),
+			ThreadLocalBase: d.ptr(p),
+			StartAddress:    d.ptr(2 * p),
+		}, nil
+
+	case CREATE_PROCESS_DEBUG_EVENT:
+		return &CreateProcessEvent{
+			EventHeader:         hdr,
+			File:                win32.Handle(d.ptr(0)),
+			Process:             win32.Handle(d.ptr(p)),
+			Thread:              win32.Handle(d.ptr(2 * p)),
+			BaseOfImage:         d.ptr(3 * p),
+			DebugInfoFileOffset: d.u32(4 * p),
+			DebugInfoSize:       d.u32(4*p + 4),
+			ThreadLocalBase:     d.ptr(4*p + 8),
+			StartAddress:        d.ptr(5*p + 8),
+			ImageName:           d.ptr(6*p + 8),
+			Unicode:             d.u16(7*p+8) != 0,
+		}, nil
+
+	case EXIT_THREAD_DEBUG_EVENT:
+		return &ExitThreadEvent{EventHeader: hdr, ExitCode: d.u32(0)}, nil
+
+	case EXIT_PROCESS_DEBUG_EVENT:
+		return &ExitProcessEvent{EventHeader: hdr, ExitCode: d.u32(0)}, nil
+
+	case LOAD_DLL_DEBUG_EVENT:
+		return &LoadDLLEvent{
+			EventHeader:         hdr,
+			File:                win32.Handle(d.ptr(0)),
+			BaseOfDll:           d.ptr(p),
+			DebugInfoFileOffset: d.u32(2 * p),
+			DebugInfoSize:       d.u32(2*p + 4),
+			ImageName:           d.ptr(2*p + 8),
+			Unicode:             d.u16(3*p+8) != 0,
+		}, nil
+
+	case UNLOAD_DLL_DEBUG_EVENT:
+		return &UnloadDLLEvent{EventHeader: hdr, BaseOfDll: d.ptr(0)}, nil
+
+	case OUTPUT_DEBUG_STRING_EVENT:
+		return &OutputDebugStringEvent{
+			EventHeader:     hdr,
+			DebugStringData: d.ptr(0),
+			Unicode:         d.u16(p) != 0,
+			Length:          d.u16(p + 2),
+		}, nil
+
+	case RIP_EVENT:
+		return &RIPEvent{EventHeader: hdr, Error: d.u32(0), Type: d.u32(4)}, nil
+	}
+
+	return nil, fmt.Errorf("debugger: unknown debug event code %d", hdr.Code)
+}
+
+type decoder struct {
+	b       []byte
+	ptrSize int
+}
+
+func (d decoder) u16(off int) uint16 {
+	return binary.LittleEndian.Uint16(d.b[off:])
+}
+
+func (d decoder) u32(off int) uint32 {
+	return binary.LittleEndian.Uint32(d.b[off:])
+}
+
+func (d decoder) ptr(off int) uint64 {
+	if d.ptrSize == 8 {
+		return binary.LittleEndian.Uint64(d.b[off:])
+	}
+	return uint64(d.u32(off))
+}
@@ -0,0 +1,333 @@
+package debugger
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	field is a value stored at an absolute offset of a DEBUG_EVENT fixture.
+	The offsets below are those of the DEBUG_EVENT union members in minwinbase.h.
+*/
+type field struct {
+	off  int
+	size int
+	v    uint64
+}
+
+func fixture(ptrSize int, code uint32, fields ...field) []byte {
+	b := make([]byte, DebugEventSize(ptrSize))
+	binary.LittleEndian.PutUint32(b[0:], code)
+	binary.LittleEndian.PutUint32(b[4:], 0x1234)
+	binary.LittleEndian.PutUint32(b[8:], 0x5678)
+
+	for _, f := range fields {
+		switch f.size {
+		case 2:
+			binary.LittleEndian.PutUint16(b[f.off:], uint16(f.v))
+		case 4:
+			binary.LittleEndian.PutUint32(b[f.off:], uint32(f.v))
+		case 8:
+			binary.LittleEndian.PutUint64(b[f.off:], f.v)
+		}
+	}
+
+	return b
+}
+
+func header(code uint32) EventHeader {
+	return EventHeader{Code: code, ProcessID: 0x1234, ThreadID: 0x5678}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		ptrSize int
+		raw     []byte
+		want    Event
+	}{
+		{
+			name:    "exception x64",
+			ptrSize: 8,
+			raw: fixture(8, EXCEPTION_DEBUG_EVENT,
+				field{16, 4, uint64(EXCEPTION_ACCESS_VIOLATION)},
+				field{20, 4, 1},
+				field{24, 8, 0x7ff0000000a0},
+				field{32, 8, 0x7ff612341000},
+				field{40, 4, 2},
+				field{48, 8, 1},
+				field{56, 8, 0xdeadbeef0000},
+				field{168, 4, 1},
+			),
+			want: &ExceptionEvent{
+				EventHeader: header(EXCEPTION_DEBUG_EVENT),
+				Record: ExceptionRecord{
+					Code:       EXCEPTION_ACCESS_VIOLATION,
+					Flags:      1,
+					Record:     0x7ff0000000a0,
+					Address:    0x7ff612341000,
+					Parameters: []uint64{1, 0xdeadbeef0000},
+				},
+				FirstChance: true,
+			},
+		},
+		{
+			name:    "exception x86",
+			ptrSize: 4,
+			raw: fixture(4, EXCEPTION_DEBUG_EVENT,
+				field{12, 4, uint64(EXCEPTION_BREAKPOINT)},
+				field{24, 4, 0x401000},
+				field{28, 4, 1},
+				field{32, 4, 0x77},
+				field{92, 4, 0},
+			),
+			want: &ExceptionEvent{
+				EventHeader: header(EXCEPTION_DEBUG_EVENT),
+				Record: ExceptionRecord{
+					Code:       EXCEPTION_BREAKPOINT,
+					Address:    0x401000,
+					Parameters: []uint64{0x77},
+				},
+			},
+		},
+		{
+			name:    "exception x86 with every parameter",
+			ptrSize: 4,
+			raw: fixture(4, EXCEPTION_DEBUG_EVENT,
+				field{12, 4, uint64(EXCEPTION_SINGLE_STEP)},
+				field{28, 4, 99},
+				field{32, 4, 1},
+				field{88, 4, 15},
+				field{92, 4, 1},
+			),
+			want: &ExceptionEvent{
+				EventHeader: header(EXCEPTION_DEBUG_EVENT),
+				Record: ExceptionRecord{
+					Code:       EXCEPTION_SINGLE_STEP,
+					Parameters: []uint64{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 15},
+				},
+				FirstChance: true,
+			},
+		},
+		{
+			name:    "create thread x64",
+			ptrSize: 8,
+			raw:     fixture(8, CREATE_THREAD_DEBUG_EVENT, field{16, 8, 0x1c4}, field{24, 8, 0x3ff000}, field{32, 8, 0x7ff612340000}),
+			want: &CreateThreadEvent{
+				EventHeader:     header(CREATE_THREAD_DEBUG_EVENT),
+				Thread:          0x1c4,
+				ThreadLocalBase: 0x3ff000,
+				StartAddress:    0x7ff612340000,
+			},
+		},
+		{
+			name:    "create thread x86",
+			ptrSize: 4,
+			raw:     fixture(4, CREATE_THREAD_DEBUG_EVENT, field{12, 4, 0x1c4}, field{16, 4, 0x7ffd000}, field{20, 4, 0x401000}),
+			want: &CreateThreadEvent{
+				EventHeader:     header(CREATE_THREAD_DEBUG_EVENT),
+				Thread:          0x1c4,
+				ThreadLocalBase: 0x7ffd000,
+				StartAddress:    0x401000,
+			},
+		},
+		{
+			name:    "create process x64",
+			ptrSize: 8,
+			raw: fixture(8, CREATE_PROCESS_DEBUG_EVENT,
+				field{16, 8, 0x10}, field{24, 8, 0x14}, field{32, 8, 0x18}, field{40, 8, 0x7ff612340000},
+				field{48, 4, 0x400}, field{52, 4, 0x20}, field{56, 8, 0x3ff000}, field{64, 8, 0x7ff612341000},
+				field{72, 8, 0x7ff6ff000000}, field{80, 2, 1},
+			),
+			want: &CreateProcessEvent{
+				EventHeader:         header(CREATE_PROCESS_DEBUG_EVENT),
+				File:                0x10,
+				Process:             0x14,
+				Thread:              0x18,
+				BaseOfImage:         0x7ff612340000,
+				DebugInfoFileOffset: 0x400,
+				DebugInfoSize:       0x20,
+				ThreadLocalBase:     0x3ff000,
+				StartAddress:        0x7ff612341000,
+				ImageName:           0x7ff6ff000000,
+				Unicode:             true,
+			},
+		},
+		{
+			name:    "create process x86",
+			ptrSize: 4,
+			raw: fixture(4, CREATE_PROCESS_DEBUG_EVENT,
+				field{12, 4, 0x10}, field{16, 4, 0x14}, field{20, 4, 0x18}, field{24, 4, 0x400000},
+				field{28, 4, 0x400}, field{32, 4, 0x20}, field{36, 4, 0x7ffdf000}, field{40, 4, 0x401000},
+				field{44, 4, 0x7ffe0000}, field{48, 2, 0},
+			),
+			want: &CreateProcessEvent{
+				EventHeader:         header(CREATE_PROCESS_DEBUG_EVENT),
+				File:                0x10,
+				Process:             0x14,
+				Thread:              0x18,
+				BaseOfImage:         0x400000,
+				DebugInfoFileOffset: 0x400,
+				DebugInfoSize:       0x20,
+				ThreadLocalBase:     0x7ffdf000,
+				StartAddress:        0x401000,
+				ImageName:           0x7ffe0000,
+			},
+		},
+		{
+			name:    "exit thread x64",
+			ptrSize: 8,
+			raw:     fixture(8, EXIT_THREAD_DEBUG_EVENT, field{16, 4, 3}),
+			want:    &ExitThreadEvent{EventHeader: header(EXIT_THREAD_DEBUG_EVENT), ExitCode: 3},
+		},
+		{
+			name:    "exit thread x86",
+			ptrSize: 4,
+			raw:     fixture(4, EXIT_THREAD_DEBUG_EVENT, field{12, 4, 3}),
+			want:    &ExitThreadEvent{EventHeader: header(EXIT_THREAD_DEBUG_EVENT), ExitCode: 3},
+		},
+		{
+			name:    "exit process x64",
+			ptrSize: 8,
+			raw:     fixture(8, EXIT_PROCESS_DEBUG_EVENT, field{16, 4, 0xC0000005}),
+			want:    &ExitProcessEvent{EventHeader: header(EXIT_PROCESS_DEBUG_EVENT), ExitCode: 0xC0000005},
+		},
+		{
+			name:    "exit process x86",
+			ptrSize: 4,
+			raw:     fixture(4, EXIT_PROCESS_DEBUG_EVENT, field{12, 4, 1}),
+			want:    &ExitProcessEvent{EventHeader: header(EXIT_PROCESS_DEBUG_EVENT), ExitCode: 1},
+		},
+		{
+			name:    "load dll x64",
+			ptrSize: 8,
+			raw: fixture(8, LOAD_DLL_DEBUG_EVENT,
+				field{16, 8, 0x2c}, field{24, 8, 0x7ff900000000}, field{32, 4, 0x600}, field{36, 4, 0x1c},
+				field{40, 8, 0x7ff6ff000100}, field{48, 2, 1},
+			),
+			want: &LoadDLLEvent{
+				EventHeader:         header(LOAD_DLL_DEBUG_EVENT),
+				File:                0x2c,
+				BaseOfDll:           0x7ff900000000,
+				DebugInfoFileOffset: 0x600,
+				DebugInfoSize:       0x1c,
+				ImageName:           0x7ff6ff000100,
+				Unicode:             true,
+			},
+		},
+		{
+			name:    "load dll x86",
+			ptrSize: 4,
+			raw: fixture(4, LOAD_DLL_DEBUG_EVENT,
+				field{12, 4, 0x2c}, field{16, 4, 0x77000000}, field{20, 4, 0x600}, field{24, 4, 0x1c},
+				field{28, 4, 0x7ffe0100}, field{32, 2, 1},
+			),
+			want: &LoadDLLEvent{
+				EventHeader:         header(LOAD_DLL_DEBUG_EVENT),
+				File:                0x2c,
+				BaseOfDll:           0x77000000,
+				DebugInfoFileOffset: 0x600,
+				DebugInfoSize:       0x1c,
+				ImageName:           0x7ffe0100,
+				Unicode:             true,
+			},
+		},
+		{
+			name:    "unload dll x64",
+			ptrSize: 8,
+			raw:     fixture(8, UNLOAD_DLL_DEBUG_EVENT, field{16, 8, 0x7ff900000000}),
+			want:    &UnloadDLLEvent{EventHeader: header(UNLOAD_DLL_DEBUG_EVENT), BaseOfDll: 0x7ff900000000},
+		},
+		{
+			name:    "unload dll x86",
+			ptrSize: 4,
+			raw:     fixture(4, UNLOAD_DLL_DEBUG_EVENT, field{12, 4, 0x77000000}),
+			want:    &UnloadDLLEvent{EventHeader: header(UNLOAD_DLL_DEBUG_EVENT), BaseOfDll: 0x77000000},
+		},
+		{
+			name:    "output debug string x64",
+			ptrSize: 8,
+			raw:     fixture(8, OUTPUT_DEBUG_STRING_EVENT, field{16, 8, 0x1f0000}, field{24, 2, 0}, field{26, 2, 12}),
+			want: &OutputDebugStringEvent{
+				EventHeader:     header(OUTPUT_DEBUG_STRING_EVENT),
+				DebugStringData: 0x1f0000,
+				Length:          12,
+			},
+		},
+		{
+			name:    "output debug string x86",
+			ptrSize: 4,
+			raw:     fixture(4, OUTPUT_DEBUG_STRING_EVENT, field{12, 4, 0x1f0000}, field{16, 2, 1}, field{18, 2, 6}),
+			want: &OutputDebugStringEvent{
+				EventHeader:     header(OUTPUT_DEBUG_STRING_EVENT),
+				DebugStringData: 0x1f0000,
+				Unicode:         true,
+				Length:          6,
+			},
+		},
+		{
+			name:    "rip x64",
+			ptrSize: 8,
+			raw:     fixture(8, RIP_EVENT, field{16, 4, 5}, field{20, 4, 1}),
+			want:    &RIPEvent{EventHeader: header(RIP_EVENT), Error: 5, Type: 1},
+		},
+		{
+			name:    "rip x86",
+			ptrSize: 4,
+			raw:     fixture(4, RIP_EVENT, field{12, 4, 5}, field{16, 4, 2}),
+			want:    &RIPEvent{EventHeader: header(RIP_EVENT), Error: 5, Type: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeEvent(tt.raw, tt.ptrSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEventErrors(t *testing.T) {
+	if _, err := DecodeEvent(make([]byte, 176), 2); err == nil {
+		t.Error("pointer size 2 accepted")
+	}
+
+	if _, err := DecodeEvent(fixture(4, RIP_EVENT), 8); err == nil {
+		t.Error("96 byte event accepted as a 64-bit event")
+	}
+
+	if _, err := DecodeEvent(fixture(8, 10), 8); err == nil {
+		t.Error("unknown event code accepted")
+	}
+}
+
+func TestDebugEventSize(t *testing.T) {
+	if got, want := int(unsafe.Sizeof(win32.DebugEvent{})), DebugEventSize(int(unsafe.Sizeof(uintptr(0)))); got != want {
+		t.Errorf("win32.DebugEvent is %d bytes, want %d", got, want)
+	}
+
+	var e win32.DebugEvent
+	e.DebugEventCode = EXIT_PROCESS_DEBUG_EVENT
+	e.ProcessID = 0x1234
+	e.ThreadID = 0x5678
+	e.Info[0] = 7
+
+	got, err := DecodeEvent(e.Bytes(), int(unsafe.Sizeof(uintptr(0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (&ExitProcessEvent{EventHeader: header(EXIT_PROCESS_DEBUG_EVENT), ExitCode: 7}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
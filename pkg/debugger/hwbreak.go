@@ -0,0 +1,304 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+/*
+	Condition is the access that triggers a hardware breakpoint,
+	encoded as in the R/W fields of DR7.
+*/
+type Condition uint8
+
+const (
+	CondExecute   Condition = 0
+	CondWrite     Condition = 1
+	CondIO        Condition = 2
+	CondReadWrite Condition = 3
+)
+
+func (c Condition) String() string {
+	switch c {
+	case CondExecute:
+		return "execute"
+	case CondWrite:
+		return "write"
+	case CondIO:
+		return "io"
+	case CondReadWrite:
+		return "readwrite"
+	}
+
+	return "Condition(" + strconv.Itoa(int(c)) + ")"
+}
+
+/*
+	DebugRegisterSlots is the number of address debug registers, DR0 to DR3.
+*/
+const DebugRegisterSlots = 4
+
+/*
+	DR6 and DR7 bits that are not per slot.
+*/
+const (
+	DR6_BD = 1 << 13 // debug register access detected
+	DR6_BS = 1 << 14 // single step
+	DR6_BT = 1 << 15 // task switch
+
+	DR7_LE = 1 << 8
+	DR7_GE = 1 << 9
+	DR7_GD = 1 << 13
+)
+
+var (
+	ErrNoFreeSlot     = errors.New("debugger: all debug registers are in use")
+	ErrInvalidSlot    = errors.New("debugger: invalid debug register slot")
+	ErrSlotNotInUse   = errors.New("debugger: debug register slot is not in use")
+	ErrBadWatchLength = errors.New("debugger: watchpoint length must be 1, 2, 4 or 8")
+)
+
+/*
+	Watchpoint is a hardware breakpoint. Length is the size of the watched
+	range in bytes and must be 1 for CondExecute. Address must be aligned to Length.
+*/
+type Watchpoint struct {
+	Address   uint64
+	Condition Condition
+	Length    int
+}
+
+/*
+	Validate reports whether w can be programmed into a debug register.
+	8 byte ranges are only supported by x64 processors.
+*/
+func (w Watchpoint) Validate() error {
+	if _, err := lengthBits(w.Length); err != nil {
+		return err
+	}
+
+	if w.Condition > CondReadWrite {
+		return fmt.Errorf("debugger: invalid watchpoint condition %d", w.Condition)
+	}
+
+	if w.Condition == CondIO {
+		return errors.New("debugger: I/O watchpoints cannot be set from user mode")
+	}
+
+	if w.Condition == CondExecute && w.Length != 1 {
+		return errors.New("debugger: execute watchpoints must have length 1")
+	}
+
+	if w.Address%uint64(w.Length) != 0 {
+		return fmt.Errorf("debugger: watchpoint address %#x is not aligned to %d bytes", w.Address, w.Length)
+	}
+
+	return nil
+}
+
+/*
+	lengthBits returns the LEN field encoding of a length.
+	Note that 8 bytes is encoded as 2 and 4 bytes as 3.
+*/
+func lengthBits(length int) (uint64, error) {
+	switch length {
+	case 1:
+		return 0, nil
+	case 2:
+		return 1, nil
+	case 4:
+		return 3, nil
+	case 8:
+		return 2, nil
+	}
+
+	return 0, ErrBadWatchLength
+}
+
+/*
+	EncodeDR7 returns dr7 with slot locally enabled for w.
+	Bits belonging to other slots are preserved.
+*/
+func EncodeDR7(dr7 uint64, slot int, w Watchpoint) (uint64, error) {
+	if slot < 0 || slot >= DebugRegisterSlots {
+		return dr7, ErrInvalidSlot
+	}
+
+	if err := w.Validate(); err != nil {
+		return dr7, err
+	}
+
+	length, _ := lengthBits(w.Length)
+
+	dr7 = ClearDR7(dr7, slot)
+	dr7 |= 1 << (2 * slot)
+	dr7 |= (uint64(w.Condition) | length<<2) << (16 + 4*slot)
+
+	return dr7, nil
+}
+
+/*
+	ClearDR7 returns dr7 with the enable, condition and length bits of slot cleared.
+*/
+func ClearDR7(dr7 uint64, slot int) uint64 {
+	if slot < 0 || slot >= DebugRegisterSlots {
+		return dr7
+	}
+
+	return dr7 &^ (3<<(2*slot) | 0xF<<(16+4*slot))
+}
+
+/*
+	DecodeDR7 returns the watchpoint programmed in slot given its address
+	register, and whether the slot is enabled locally or globally.
+*/
+func DecodeDR7(dr7 uint64, slot int, addr uint64) (Watchpoint, bool) {
+	if slot < 0 || slot >= DebugRegisterSlots {
+		return Watchpoint{}, false
+	}
+
+	enabled := dr7>>(2*slot)&3 != 0
+	field := dr7 >> (16 + 4*slot) & 0xF
+
+	w := Watchpoint{Address: addr, Condition: Condition(field & 3)}
+	switch field >> 2 {
+	case 0:
+		w.Length = 1
+	case 1:
+		w.Length = 2
+	case 2:
+		w.Length = 8
+	case 3:
+		w.Length = 4
+	}
+
+	return w, enabled
+}
+
+/*
+	DecodeDR6 returns the slots whose conditions were met, in ascending order.
+*/
+func DecodeDR6(dr6 uint64) []int {
+	var slots []int
+	for slot := 0; slot < DebugRegisterSlots; slot++ {
+		if dr6&(1<<slot) != 0 {
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots
+}
+
+/*
+	DebugRegisters is the debug register state of a thread.
+*/
+type DebugRegisters struct {
+	Dr  [DebugRegisterSlots]uint64
+	Dr6 uint64
+	Dr7 uint64
+}
+
+/*
+	Watchpoints allocates the four debug register slots. Apply only touches
+	slots that were allocated at some point, so debug registers programmed
+	by someone else in the other slots survive.
+*/
+type Watchpoints struct {
+	slots [DebugRegisterSlots]*Watchpoint
+	owned [DebugRegisterSlots]bool
+}
+
+/*
+	Set allocates the lowest free slot for w.
+*/
+func (ws *Watchpoints) Set(w Watchpoint) (int, error) {
+	if err := w.Validate(); err != nil {
+		return -1, err
+	}
+
+	for slot, cur := range ws.slots {
+		if cur == nil {
+			ws.slots[slot] = &w
+			ws.owned[slot] = true
+			return slot, nil
+		}
+	}
+
+	return -1, ErrNoFreeSlot
+}
+
+/*
+	Clear frees slot.
+*/
+func (ws *Watchpoints) Clear(slot int) error {
+	if slot < 0 || slot >= DebugRegisterSlots {
+		return ErrInvalidSlot
+	}
+
+	if ws.slots[slot] == nil {
+		return ErrSlotNotInUse
+	}
+
+	ws.slots[slot] = nil
+	return nil
+}
+
+/*
+	Get returns the watchpoint allocated to slot.
+*/
+func (ws *Watchpoints) Get(slot int) (Watchpoint, bool) {
+	if slot < 0 || slot >= DebugRegisterSlots || ws.slots[slot] == nil {
+		return Watchpoint{}, false
+	}
+
+	return *ws.slots[slot], true
+}
+
+/*
+	Free returns the number of unallocated slots.
+*/
+func (ws *Watchpoints) Free() int {
+	n := 0
+	for _, w := range ws.slots {
+		if w == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+/*
+	Apply programs the allocated watchpoints into regs and disables
+	the slots that were freed.
+*/
+func (ws *Watchpoints) Apply(regs *DebugRegisters) {
+	for slot, w := range ws.slots {
+		if w == nil {
+			if ws.owned[slot] {
+				regs.Dr[slot] = 0
+				regs.Dr7 = ClearDR7(regs.Dr7, slot)
+			}
+			continue
+		}
+
+		// Validated by Set, so encoding cannot fail.
+		regs.Dr[slot] = w.Address
+		regs.Dr7, _ = EncodeDR7(regs.Dr7, slot, *w)
+	}
+}
+
+/*
+	Hit returns the allocated watchpoints that fired according to dr6.
+*/
+func (ws *Watchpoints) Hit(dr6 uint64) []int {
+	var hits []int
+	for _, slot := range DecodeDR6(dr6) {
+		if ws.slots[slot] != nil {
+			hits = append(hits, slot)
+		}
+	}
+
+	return hits
+}
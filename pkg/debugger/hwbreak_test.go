@@ -0,0 +1,218 @@
+package debugger
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+/*
+	lenField is the DR7 LEN encoding of each watch length, from the
+	Intel SDM Vol. 3B 17.2.4: 8 bytes is 10b and 4 bytes is 11b.
+*/
+var lenField = map[int]uint64{1: 0, 2: 1, 4: 3, 8: 2}
+
+func TestEncodeDecodeDR7(t *testing.T) {
+	conditions := []Condition{CondExecute, CondWrite, CondIO, CondReadWrite}
+
+	// Background values of the other slots' bits and of the global control bits.
+	backgrounds := []uint64{0, DR7_LE | DR7_GE, 0xFFFFFFFF &^ DR7_GD}
+
+	for _, bg := range backgrounds {
+		for slot := 0; slot < DebugRegisterSlots; slot++ {
+			slotBits := uint64(3<<(2*slot) | 0xF<<(16+4*slot))
+
+			for length, field := range lenField {
+				for _, cond := range conditions {
+					w := Watchpoint{Address: 0x7ff612340000, Condition: cond, Length: length}
+					valid := cond == CondWrite || cond == CondReadWrite || (cond == CondExecute && length == 1)
+
+					dr7, err := EncodeDR7(bg, slot, w)
+					if !valid {
+						if err == nil || dr7 != bg {
+							t.Errorf("slot %d %v/%d: got %#x, %v; want an error and unchanged DR7", slot, cond, length, dr7, err)
+						}
+						continue
+					}
+
+					if err != nil {
+						t.Errorf("slot %d %v/%d: %v", slot, cond, length, err)
+						continue
+					}
+
+					want := bg&^slotBits | 1<<(2*slot) | uint64(cond)<<(16+4*slot) | field<<(18+4*slot)
+					if dr7 != want {
+						t.Errorf("slot %d %v/%d: got DR7 %#x, want %#x", slot, cond, length, dr7, want)
+					}
+
+					got, enabled := DecodeDR7(dr7, slot, w.Address)
+					if !enabled || got != w {
+						t.Errorf("slot %d %v/%d: decoded %+v, enabled %v", slot, cond, length, got, enabled)
+					}
+
+					cleared := ClearDR7(dr7, slot)
+					if cleared != bg&^slotBits {
+						t.Errorf("slot %d %v/%d: cleared DR7 %#x, want %#x", slot, cond, length, cleared, bg&^slotBits)
+					}
+
+					if _, enabled := DecodeDR7(cleared, slot, 0); enabled {
+						t.Errorf("slot %d %v/%d: still enabled after ClearDR7", slot, cond, length)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeDR7GlobalEnable(t *testing.T) {
+	for slot := 0; slot < DebugRegisterSlots; slot++ {
+		dr7 := uint64(2<<(2*slot)) | uint64(CondReadWrite|3<<2)<<(16+4*slot)
+
+		w, enabled := DecodeDR7(dr7, slot, 0x1000)
+		if !enabled || w != (Watchpoint{Address: 0x1000, Condition: CondReadWrite, Length: 4}) {
+			t.Errorf("slot %d: got %+v, enabled %v", slot, w, enabled)
+		}
+	}
+}
+
+func TestDR7InvalidSlot(t *testing.T) {
+	w := Watchpoint{Address: 0x1000, Condition: CondWrite, Length: 4}
+
+	for _, slot := range []int{-1, DebugRegisterSlots, 64} {
+		if dr7, err := EncodeDR7(0x55, slot, w); !errors.Is(err, ErrInvalidSlot) || dr7 != 0x55 {
+			t.Errorf("EncodeDR7 slot %d: got %#x, %v", slot, dr7, err)
+		}
+
+		if got := ClearDR7(0xFFFFFFFF, slot); got != 0xFFFFFFFF {
+			t.Errorf("ClearDR7 slot %d: got %#x", slot, got)
+		}
+
+		if _, enabled := DecodeDR7(0xFFFFFFFF, slot, 0); enabled {
+			t.Errorf("DecodeDR7 slot %d reported an enabled slot", slot)
+		}
+	}
+}
+
+func TestWatchpointValidate(t *testing.T) {
+	for length := range lenField {
+		for addr := uint64(0x1000); addr < 0x1000+8; addr++ {
+			err := Watchpoint{Address: addr, Condition: CondWrite, Length: length}.Validate()
+			if aligned := addr%uint64(length) == 0; aligned != (err == nil) {
+				t.Errorf("address %#x length %d: got %v", addr, length, err)
+			}
+		}
+	}
+
+	for _, length := range []int{0, 3, 5, 16, -1} {
+		if err := (Watchpoint{Condition: CondWrite, Length: length}).Validate(); !errors.Is(err, ErrBadWatchLength) {
+			t.Errorf("length %d: got %v, want ErrBadWatchLength", length, err)
+		}
+	}
+
+	if err := (Watchpoint{Condition: 4, Length: 1}).Validate(); err == nil {
+		t.Error("condition 4 accepted")
+	}
+}
+
+func TestDecodeDR6(t *testing.T) {
+	for bits := uint64(0); bits < 16; bits++ {
+		var want []int
+		for slot := 0; slot < DebugRegisterSlots; slot++ {
+			if bits&(1<<slot) != 0 {
+				want = append(want, slot)
+			}
+		}
+
+		for _, extra := range []uint64{0, DR6_BD, DR6_BS, DR6_BT, 0xFFFF0FF0} {
+			if got := DecodeDR6(bits | extra); !reflect.DeepEqual(got, want) {
+				t.Errorf("DR6 %#x: got %v, want %v", bits|extra, got, want)
+			}
+		}
+	}
+}
+
+func TestWatchpoints(t *testing.T) {
+	var ws Watchpoints
+
+	// Slot 3 is programmed by someone else and must survive Apply.
+	regs := DebugRegisters{Dr: [4]uint64{3: 0xDEAD0000}}
+	regs.Dr7, _ = EncodeDR7(0, 3, Watchpoint{Address: 0xDEAD0000, Condition: CondWrite, Length: 8})
+	foreign := regs.Dr7
+
+	watches := []Watchpoint{
+		{Address: 0x1000, Condition: CondExecute, Length: 1},
+		{Address: 0x2000, Condition: CondWrite, Length: 4},
+		{Address: 0x3000, Condition: CondReadWrite, Length: 8},
+	}
+
+	for i, w := range watches {
+		if slot, err := ws.Set(w); err != nil || slot != i {
+			t.Fatalf("Set(%+v) = %d, %v", w, slot, err)
+		}
+	}
+
+	if ws.Free() != 1 {
+		t.Errorf("got %d free slots", ws.Free())
+	}
+
+	if _, err := ws.Set(Watchpoint{Condition: CondWrite, Length: 3}); !errors.Is(err, ErrBadWatchLength) {
+		t.Errorf("Set with length 3: %v", err)
+	}
+
+	ws.Apply(&regs)
+	for i, w := range watches {
+		got, enabled := DecodeDR7(regs.Dr7, i, regs.Dr[i])
+		if !enabled || got != w {
+			t.Errorf("slot %d: got %+v, enabled %v", i, got, enabled)
+		}
+	}
+
+	if got, enabled := DecodeDR7(regs.Dr7, 3, regs.Dr[3]); !enabled || got.Address != 0xDEAD0000 || got.Length != 8 {
+		t.Errorf("foreign slot 3 was modified: %+v, %v", got, enabled)
+	}
+
+	if hits := ws.Hit(DR6_BS | 1<<1 | 1<<3); !reflect.DeepEqual(hits, []int{1}) {
+		t.Errorf("Hit returned %v, want [1]", hits)
+	}
+
+	if err := ws.Clear(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.Clear(1); !errors.Is(err, ErrSlotNotInUse) {
+		t.Errorf("second Clear: %v", err)
+	}
+
+	if err := ws.Clear(4); !errors.Is(err, ErrInvalidSlot) {
+		t.Errorf("Clear(4): %v", err)
+	}
+
+	ws.Apply(&regs)
+	if _, enabled := DecodeDR7(regs.Dr7, 1, 0); enabled || regs.Dr[1] != 0 {
+		t.Errorf("cleared slot is still programmed: DR1 %#x, DR7 %#x", regs.Dr[1], regs.Dr7)
+	}
+
+	if regs.Dr7&(0xF<<28|3<<6) != foreign&(0xF<<28|3<<6) {
+		t.Errorf("foreign slot 3 was modified: DR7 %#x", regs.Dr7)
+	}
+
+	if _, ok := ws.Get(1); ok {
+		t.Error("Get returned a cleared slot")
+	}
+
+	if w, ok := ws.Get(2); !ok || w != watches[2] {
+		t.Errorf("Get(2) = %+v, %v", w, ok)
+	}
+
+	if slot, _ := ws.Set(watches[1]); slot != 1 {
+		t.Errorf("Set reused slot %d, want 1", slot)
+	}
+
+	if slot, _ := ws.Set(watches[0]); slot != 3 {
+		t.Errorf("Set used slot %d, want 3", slot)
+	}
+
+	if _, err := ws.Set(watches[1]); !errors.Is(err, ErrNoFreeSlot) {
+		t.Errorf("Set with all slots used: %v", err)
+	}
+}
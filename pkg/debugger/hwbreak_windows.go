@@ -0,0 +1,109 @@
+//go:build amd64 || 386
+
+package debugger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	ErrCurrentThread is returned by ApplyToThread for the calling thread,
+	which cannot suspend itself to have its context updated.
+*/
+var ErrCurrentThread = errors.New("debugger: cannot apply watchpoints to the calling thread")
+
+/*
+	ApplyToThread programs ws into the debug registers of the thread identified by tid.
+	A running thread is suspended while its context is updated, so tid must not
+	be the calling thread.
+	Call it from CreateThreadEvent so that new threads also get the watchpoints.
+*/
+func (ws *Watchpoints) ApplyToThread(tid uint32) error {
+	if tid == kernel32.GetCurrentThreadId() {
+		return ErrCurrentThread
+	}
+
+	access := kernel32.THREAD_GET_CONTEXT | kernel32.THREAD_SET_CONTEXT | kernel32.THREAD_SUSPEND_RESUME
+	thread, err := kernel32.OpenThread(access, false, tid)
+	if err != nil {
+		return err
+	}
+	defer kernel32.CloseHandle(thread)
+
+	if _, err := kernel32.SuspendThread(thread); err != nil {
+		return err
+	}
+	defer kernel32.ResumeThread(thread)
+
+	ctx := win32.Context{ContextFlags: win32.CONTEXT_DEBUG_REGISTERS}
+	if err := kernel32.GetThreadContext(thread, &ctx); err != nil {
+		return err
+	}
+
+	regs := contextDebugRegisters(&ctx)
+	ws.Apply(&regs)
+	setContextDebugRegisters(&ctx, regs)
+
+	return kernel32.SetThreadContext(thread, &ctx)
+}
+
+/*
+	ApplyToProcess programs ws into every thread of the process identified by pid.
+	Threads that exit before they are opened are skipped; other failures are
+	reported after all threads have been attempted.
+*/
+func (ws *Watchpoints) ApplyToProcess(pid uint32) error {
+	threads, err := process.Threads(pid)
+	if err != nil {
+		return err
+	}
+
+	var first error
+	for _, tid := range threads {
+		err := ws.ApplyToThread(tid)
+		if err == nil || errors.Is(err, kernel32.ERROR_INVALID_PARAMETER) {
+			continue
+		}
+
+		if first == nil {
+			first = fmt.Errorf("debugger: thread %d: %w", tid, err)
+		}
+	}
+
+	return first
+}
+
+/*
+	HitWatchpoints reads DR6 of the thread that raised ev and returns the
+	slots of ws that fired. DR6 is cleared afterwards, as the processor never
+	clears it by itself.
+*/
+func (ws *Watchpoints) HitWatchpoints(ev *ExceptionEvent) ([]int, error) {
+	if ev.Record.Code != EXCEPTION_SINGLE_STEP {
+		return nil, nil
+	}
+
+	thread, err := kernel32.OpenThread(kernel32.THREAD_GET_CONTEXT|kernel32.THREAD_SET_CONTEXT, false, ev.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	defer kernel32.CloseHandle(thread)
+
+	ctx := win32.Context{ContextFlags: win32.CONTEXT_DEBUG_REGISTERS}
+	if err := kernel32.GetThreadContext(thread, &ctx); err != nil {
+		return nil, err
+	}
+
+	regs := contextDebugRegisters(&ctx)
+	hits := ws.Hit(regs.Dr6)
+
+	regs.Dr6 = 0
+	setContextDebugRegisters(&ctx, regs)
+
+	return hits, kernel32.SetThreadContext(thread, &ctx)
+}
@@ -0,0 +1,26 @@
+package debugger
+
+import "github.com/warrenulrich/win32-go/pkg/win32"
+
+func contextRegisters(c *win32.Context) Registers {
+	return Registers{PC: uint64(c.Eip), Flags: c.EFlags}
+}
+
+func setContextRegisters(c *win32.Context, regs Registers) {
+	c.Eip = uint32(regs.PC)
+	c.EFlags = regs.Flags
+}
+
+func contextDebugRegisters(c *win32.Context) DebugRegisters {
+	return DebugRegisters{
+		Dr:  [DebugRegisterSlots]uint64{uint64(c.Dr0), uint64(c.Dr1), uint64(c.Dr2), uint64(c.Dr3)},
+		Dr6: uint64(c.Dr6),
+		Dr7: uint64(c.Dr7),
+	}
+}
+
+func setContextDebugRegisters(c *win32.Context, regs DebugRegisters) {
+	c.Dr0, c.Dr1, c.Dr2, c.Dr3 = uint32(regs.Dr[0]), uint32(regs.Dr[1]), uint32(regs.Dr[2]), uint32(regs.Dr[3])
+	c.Dr6 = uint32(regs.Dr6)
+	c.Dr7 = uint32(regs.Dr7)
+}
@@ -0,0 +1,230 @@
+package debugger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	pollInterval is how long the loop blocks in WaitForDebugEvent before
+	checking whether it was asked to stop, in milliseconds.
+*/
+const pollInterval = 100
+
+var ErrSessionClosed = errors.New("debugger: session is closed")
+
+type continueRequest struct {
+	status kernel32.ContinueStatus
+	done   chan error
+}
+
+/*
+	Session is an attached debug session.
+
+	The debug API only accepts WaitForDebugEvent and ContinueDebugEvent calls
+	from the thread that attached, so all of them are made by a single
+	goroutine locked to its OS thread. Each event received from Events must be
+	answered with exactly one call to Continue before the next one is delivered.
+*/
+type Session struct {
+	PID    uint32
+	Events <-chan Event
+
+	cont     chan continueRequest
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	err      error
+}
+
+/*
+	Attach attaches to the process identified by pid and starts delivering its
+	debug events. The session detaches when ctx is done, when Detach is called,
+	or after the process exits, and Events is closed once it has.
+
+	The debuggee is not killed when the session ends.
+*/
+func Attach(ctx context.Context, pid uint32) (*Session, error) {
+	events := make(chan Event)
+	s := &Session{
+		PID:    pid,
+		Events: events,
+		cont:   make(chan continueRequest),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	attached := make(chan error, 1)
+	go s.loop(ctx, events, attached)
+
+	if err := <-attached; err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Session) loop(ctx context.Context, events chan<- Event, attached chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	defer close(s.done)
+	defer close(events)
+
+	if err := kernel32.DebugActiveProcess(s.PID); err != nil {
+		attached <- fmt.Errorf("debugger: attaching to %d: %w", s.PID, err)
+		return
+	}
+
+	if err := kernel32.DebugSetProcessKillOnExit(false); err != nil {
+		kernel32.DebugActiveProcessStop(s.PID)
+		attached <- err
+		return
+	}
+
+	attached <- nil
+
+	for {
+		var raw win32.DebugEvent
+		if err := kernel32.WaitForDebugEventEx(&raw, pollInterval); err != nil {
+			if errors.Is(err, kernel32.ERROR_SEM_TIMEOUT) {
+				select {
+				case <-ctx.Done():
+					s.err = s.detach()
+					return
+				case <-s.stop:
+					s.err = s.detach()
+					return
+				default:
+					continue
+				}
+			}
+
+			s.err = err
+			s.detach()
+			return
+		}
+
+		ev, err := DecodeEvent(raw.Bytes(), int(unsafe.Sizeof(uintptr(0))))
+		if err != nil {
+			// Never leave the debuggee suspended on an event we cannot decode.
+			kernel32.ContinueDebugEvent(raw.ProcessID, raw.ThreadID, kernel32.DBG_CONTINUE)
+			continue
+		}
+
+		req, ok := s.deliver(ctx, events, ev)
+		if !ok {
+			// Stopping: the event still has to be continued before detaching.
+			kernel32.ContinueDebugEvent(raw.ProcessID, raw.ThreadID, kernel32.DBG_CONTINUE)
+			closeEventHandles(ev)
+			s.err = s.detach()
+			return
+		}
+
+		err = kernel32.ContinueDebugEvent(raw.ProcessID, raw.ThreadID, req.status)
+		req.done <- err
+		closeEventHandles(ev)
+
+		if err != nil {
+			s.err = err
+			s.detach()
+			return
+		}
+
+		if _, ok := ev.(*ExitProcessEvent); ok && raw.ProcessID == s.PID {
+			s.detach()
+			return
+		}
+	}
+}
+
+/*
+	deliver sends ev on events and waits for the matching Continue call.
+	It reports false if the session was asked to stop in the meantime.
+*/
+func (s *Session) deliver(ctx context.Context, events chan<- Event, ev Event) (continueRequest, bool) {
+	select {
+	case events <- ev:
+	case <-ctx.Done():
+		return continueRequest{}, false
+	case <-s.stop:
+		return continueRequest{}, false
+	}
+
+	select {
+	case req := <-s.cont:
+		return req, true
+	case <-ctx.Done():
+		return continueRequest{}, false
+	case <-s.stop:
+		return continueRequest{}, false
+	}
+}
+
+func (s *Session) detach() error {
+	return kernel32.DebugActiveProcessStop(s.PID)
+}
+
+/*
+	closeEventHandles closes the file handles the debugger owns once an
+	event has been continued. Process and thread handles are owned by the system.
+*/
+func closeEventHandles(ev Event) {
+	switch ev := ev.(type) {
+	case *CreateProcessEvent:
+		if ev.File != 0 {
+			kernel32.CloseHandle(ev.File)
+		}
+	case *LoadDLLEvent:
+		if ev.File != 0 {
+			kernel32.CloseHandle(ev.File)
+		}
+	}
+}
+
+/*
+	Continue resumes the thread that reported the last event received from Events.
+	Use DBG_EXCEPTION_NOT_HANDLED to pass an exception on to the debuggee.
+*/
+func (s *Session) Continue(status kernel32.ContinueStatus) error {
+	req := continueRequest{status: status, done: make(chan error, 1)}
+
+	select {
+	case s.cont <- req:
+		return <-req.done
+	case <-s.done:
+		return ErrSessionClosed
+	}
+}
+
+/*
+	Detach stops the session and detaches from the process.
+	An event that was received but not yet continued is continued with DBG_CONTINUE.
+*/
+func (s *Session) Detach() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	<-s.done
+	return s.err
+}
+
+/*
+	Err returns the error that ended the session, if any, once Events is closed.
+*/
+func (s *Session) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,22 @@
+package debugger
+
+import (
+	"errors"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	ErrWow64Unsupported is returned for WOW64 exceptions in a 32-bit debugger.
+	Such exceptions only reach 64-bit debuggers of 32-bit processes; a 32-bit
+	debugger sees the native x86 exception codes instead.
+*/
+var ErrWow64Unsupported = errors.New("debugger: WOW64 thread contexts require a 64-bit debugger")
+
+func getWow64Registers(thread win32.Handle) (Registers, error) {
+	return Registers{}, ErrWow64Unsupported
+}
+
+func setWow64Registers(thread win32.Handle, regs Registers) error {
+	return ErrWow64Unsupported
+}
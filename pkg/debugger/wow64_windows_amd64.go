@@ -0,0 +1,26 @@
+package debugger
+
+import (
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+func getWow64Registers(thread win32.Handle) (Registers, error) {
+	ctx := win32.Wow64Context{ContextFlags: win32.WOW64_CONTEXT_CONTROL}
+	if err := kernel32.Wow64GetThreadContext(thread, &ctx); err != nil {
+		return Registers{}, err
+	}
+
+	return Registers{PC: uint64(ctx.Eip), Flags: ctx.EFlags}, nil
+}
+
+func setWow64Registers(thread win32.Handle, regs Registers) error {
+	ctx := win32.Wow64Context{ContextFlags: win32.WOW64_CONTEXT_CONTROL}
+	if err := kernel32.Wow64GetThreadContext(thread, &ctx); err != nil {
+		return err
+	}
+
+	ctx.Eip = uint32(regs.PC)
+	ctx.EFlags = regs.Flags
+	return kernel32.Wow64SetThreadContext(thread, &ctx)
+}
@@ -0,0 +1,134 @@
+package detour
+
+import (
+	"errors"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	allocationGranularity is the alignment of VirtualAllocEx reservations.
+*/
+const allocationGranularity = 0x10000
+
+/*
+	nearRange is how far from the requested address an allocation may be,
+	leaving room for the size of the allocation itself.
+*/
+const nearRange = 1<<31 - allocationGranularity
+
+var ErrNoNearMemory = errors.New("detour: no free memory within 2GB")
+
+/*
+	RemoteAllocator allocates trampolines in another process with VirtualAllocEx.
+	Handle must have been opened with PROCESS_VM_OPERATION and PROCESS_QUERY_INFORMATION.
+*/
+type RemoteAllocator struct {
+	Handle win32.Handle
+}
+
+/*
+	AllocNear searches the free regions closest to addr, alternating
+	between higher and lower addresses, until an allocation succeeds.
+*/
+func (a RemoteAllocator) AllocNear(addr uintptr, size int) (uintptr, error) {
+	base := addr &^ (allocationGranularity - 1)
+
+	lo, hi := uintptr(allocationGranularity), ^uintptr(0)
+	if base > nearRange+lo {
+		lo = base - nearRange
+	}
+	if base < hi-nearRange {
+		hi = base + nearRange
+	}
+
+	up, down := base+allocationGranularity, base
+	for up < hi || down > lo {
+		if up < hi {
+			p, next, ok := a.try(up, size)
+			if ok {
+				return p, nil
+			}
+			up = next
+		}
+
+		if down > lo {
+			down -= allocationGranularity
+			p, prev, ok := a.tryBelow(down, size)
+			if ok {
+				return p, nil
+			}
+			down = prev
+		}
+	}
+
+	return 0, ErrNoNearMemory
+}
+
+/*
+	try allocates at candidate if it is free, otherwise it returns the
+	next candidate after the region containing it.
+*/
+func (a RemoteAllocator) try(candidate uintptr, size int) (uintptr, uintptr, bool) {
+	mbi, err := kernel32.VirtualQueryEx(a.Handle, candidate)
+	if err != nil {
+		return 0, ^uintptr(0), false
+	}
+
+	if mbi.State == kernel32.MEM_FREE && mbi.BaseAddress+mbi.RegionSize-candidate >= uintptr(size) {
+		if p, err := a.alloc(candidate, size); err == nil {
+			return p, 0, true
+		}
+	}
+
+	next := (mbi.BaseAddress + mbi.RegionSize + allocationGranularity - 1) &^ (allocationGranularity - 1)
+	if next <= candidate {
+		next = candidate + allocationGranularity
+	}
+
+	return 0, next, false
+}
+
+/*
+	tryBelow allocates at candidate if it is free, otherwise it returns
+	the granularity boundary at the start of the allocation containing it,
+	from which the search continues downwards.
+*/
+func (a RemoteAllocator) tryBelow(candidate uintptr, size int) (uintptr, uintptr, bool) {
+	mbi, err := kernel32.VirtualQueryEx(a.Handle, candidate)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if mbi.State == kernel32.MEM_FREE && mbi.BaseAddress+mbi.RegionSize-candidate >= uintptr(size) {
+		if p, err := a.alloc(candidate, size); err == nil {
+			return p, 0, true
+		}
+	}
+
+	if mbi.State != kernel32.MEM_FREE && mbi.AllocationBase < candidate {
+		return 0, mbi.AllocationBase &^ (allocationGranularity - 1), false
+	}
+
+	return 0, candidate, false
+}
+
+func (a RemoteAllocator) alloc(addr uintptr, size int) (uintptr, error) {
+	return kernel32.VirtualAllocEx(a.Handle, addr, uintptr(size), kernel32.MEM_COMMIT|kernel32.MEM_RESERVE, kernel32.PAGE_EXECUTE_READWRITE)
+}
+
+func (a RemoteAllocator) Free(addr uintptr) error {
+	return kernel32.VirtualFreeEx(a.Handle, addr, 0, kernel32.MEM_RELEASE)
+}
+
+/*
+	Attach prepares a detour of target to hook in the process behind handle,
+	which needs PROCESS_VM_READ, PROCESS_VM_WRITE, PROCESS_VM_OPERATION and
+	PROCESS_QUERY_INFORMATION.
+*/
+func Attach(handle win32.Handle, mode x86.Mode, target, hook uintptr) (*Detour, error) {
+	return New(process.Remote{Handle: handle}, RemoteAllocator{Handle: handle}, mode, target, hook)
+}
@@ -0,0 +1,272 @@
+/*
+	Package detour installs inline hooks in the code of a process.
+
+	The first instructions of the target function are replaced by a jump to
+	the hook. They are relocated into a trampoline followed by a jump back to
+	the rest of the function, so the hook can call the original implementation
+	through the trampoline.
+
+	Memory access and allocation go through interfaces, so the whole engine
+	runs against a fake address space as well as against another process.
+*/
+package detour
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+var (
+	ErrInstalled    = errors.New("detour: already installed")
+	ErrNotInstalled = errors.New("detour: not installed")
+	ErrModified     = errors.New("detour: target was modified by someone else")
+)
+
+/*
+	Allocator allocates executable memory for trampolines.
+*/
+type Allocator interface {
+	/*
+		AllocNear allocates size bytes of readable, writable and executable
+		memory within ±2GB of addr, so that rel32 jumps between the two reach.
+	*/
+	AllocNear(addr uintptr, size int) (uintptr, error)
+	Free(addr uintptr) error
+}
+
+/*
+	Detour is an inline hook redirecting Target to Hook.
+*/
+type Detour struct {
+	Target uintptr
+	Hook   uintptr
+
+	/*
+		Trampoline is the address to call to run the original function
+		once the detour is installed.
+	*/
+	Trampoline uintptr
+
+	/*
+		Original is the code overwritten at Target.
+	*/
+	Original []byte
+
+	mem   process.Memory
+	alloc Allocator
+	mode  x86.Mode
+	patch []byte
+	block uintptr
+
+	installed bool
+}
+
+/*
+	New prepares a detour of target to hook: the instructions covering the
+	jump are relocated into a trampoline allocated near target. Nothing is
+	written at target until Install is called.
+
+	Instructions are decoded for mode, which must match the target process.
+	Code that jumps into the middle of the overwritten instructions from
+	elsewhere in the function cannot be detected.
+*/
+func New(mem process.Memory, alloc Allocator, mode x86.Mode, target, hook uintptr) (*Detour, error) {
+	// Enough for the longest instructions that can straddle the jump.
+	code := make([]byte, JmpRel32Size+x86.MaxInstLen)
+	read, err := readCode(mem, target, code)
+	if err != nil {
+		return nil, err
+	}
+
+	insts, err := x86.DecodeAll(code, mode, JmpRel32Size)
+	if err != nil {
+		return nil, fmt.Errorf("detour: decoding %#x: %w", target, err)
+	}
+
+	n := 0
+	for _, inst := range insts {
+		n += inst.Len
+	}
+
+	if n > read {
+		return nil, fmt.Errorf("detour: instructions at %#x run past readable memory", target)
+	}
+
+	// Worst case layout, the exact size is known after relocation.
+	size := 0
+	for i := range insts {
+		size += relocatedSize(&insts[i])
+	}
+	size += JmpRel32Size + JmpAbs64Size
+
+	block, err := alloc.AllocNear(target, size)
+	if err != nil {
+		return nil, fmt.Errorf("detour: allocating trampoline: %w", err)
+	}
+
+	d := &Detour{
+		Target:     target,
+		Hook:       hook,
+		Trampoline: block,
+		Original:   code[:n],
+		mem:        mem,
+		alloc:      alloc,
+		mode:       mode,
+		block:      block,
+	}
+
+	if err := d.build(size); err != nil {
+		alloc.Free(block)
+		return nil, err
+	}
+
+	return d, nil
+}
+
+/*
+	readCode reads up to len(buf) bytes at addr, shrinking the read when the
+	end of the buffer runs past the end of the mapped code.
+	It returns the number of bytes read.
+*/
+func readCode(mem process.Reader, addr uintptr, buf []byte) (int, error) {
+	var err error
+	for n := len(buf); n >= JmpRel32Size; n-- {
+		if err = mem.ReadMemory(addr, buf[:n]); err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("detour: reading %#x: %w", addr, err)
+}
+
+/*
+	build writes the trampoline and computes the patch for Target.
+
+	The trampoline block holds the relocated instructions, a jump back to
+	the first instruction that was not relocated and, when Hook cannot be
+	reached from Target with a rel32 jump, an absolute jump to Hook used as relay.
+*/
+func (d *Detour) build(size int) error {
+	target, block := uint64(d.Target), uint64(d.block)
+
+	r, err := Relocate(d.Original, target, block, JmpRel32Size, d.mode)
+	if err != nil {
+		return err
+	}
+
+	code := append([]byte(nil), r.Code...)
+
+	back, err := JmpRel32(block+uint64(len(code)), target+uint64(r.Len), d.mode)
+	if err != nil {
+		return err
+	}
+	code = append(code, back...)
+
+	dest := uint64(d.Hook)
+	if !InRel32(target, dest, d.mode) {
+		dest = block + uint64(len(code))
+		code = append(code, JmpAbs64(uint64(d.Hook))...)
+	}
+
+	if len(code) > size {
+		return fmt.Errorf("detour: trampoline is %d bytes, %d were allocated", len(code), size)
+	}
+
+	if d.patch, err = JmpRel32(target, dest, d.mode); err != nil {
+		return err
+	}
+
+	// Pad the overwritten instructions so no partial instruction remains.
+	for len(d.patch) < len(d.Original) {
+		d.patch = append(d.patch, 0xCC)
+	}
+
+	return process.WriteCode(d.mem, d.block, code)
+}
+
+/*
+	Installed reports whether the jump to Hook is written at Target.
+*/
+func (d *Detour) Installed() bool {
+	return d.installed
+}
+
+/*
+	Install writes the jump to Hook at Target. Threads of the target process
+	should be suspended outside of the overwritten instructions meanwhile.
+*/
+func (d *Detour) Install() error {
+	if d.installed {
+		return ErrInstalled
+	}
+
+	cur := make([]byte, len(d.Original))
+	if err := d.mem.ReadMemory(d.Target, cur); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(cur, d.Original) {
+		return ErrModified
+	}
+
+	if err := process.WriteCode(d.mem, d.Target, d.patch); err != nil {
+		return err
+	}
+
+	d.installed = true
+	return nil
+}
+
+/*
+	Uninstall restores the original code at Target. The trampoline is kept,
+	so the detour can be installed again.
+*/
+func (d *Detour) Uninstall() error {
+	if !d.installed {
+		return ErrNotInstalled
+	}
+
+	cur := make([]byte, len(d.patch))
+	if err := d.mem.ReadMemory(d.Target, cur); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(cur, d.patch) {
+		return ErrModified
+	}
+
+	if err := process.WriteCode(d.mem, d.Target, d.Original); err != nil {
+		return err
+	}
+
+	d.installed = false
+	return nil
+}
+
+/*
+	Close uninstalls the detour if needed and frees the trampoline.
+	The trampoline is kept if the original code could not be restored,
+	since the target still jumps into it.
+*/
+func (d *Detour) Close() error {
+	if d.installed {
+		if err := d.Uninstall(); err != nil {
+			return err
+		}
+	}
+
+	if d.block == 0 {
+		return nil
+	}
+
+	if err := d.alloc.Free(d.block); err != nil {
+		return err
+	}
+
+	d.block, d.Trampoline = 0, 0
+	return nil
+}
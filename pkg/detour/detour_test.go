@@ -0,0 +1,263 @@
+package detour
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/process/processtest"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	fakeAllocator hands out trampolines from consecutive 64KB blocks
+	starting at next, mapped in mem.
+*/
+type fakeAllocator struct {
+	mem   *processtest.Memory
+	next  uintptr
+	freed []uintptr
+}
+
+func (a *fakeAllocator) AllocNear(addr uintptr, size int) (uintptr, error) {
+	if a.next == 0 {
+		return 0, ErrOutOfRange
+	}
+
+	p := a.next
+	a.mem.Alloc(p, size)
+	a.next += 0x10000
+	return p, nil
+}
+
+func (a *fakeAllocator) Free(addr uintptr) error {
+	a.mem.Unmap(addr)
+	a.freed = append(a.freed, addr)
+	return nil
+}
+
+const (
+	target     = 0x40001000
+	trampoline = 0x40100000
+	nearHook   = 0x40050000
+	farHook    = 0xF2340000
+)
+
+func newFixture(t *testing.T, code string) (*processtest.Memory, *fakeAllocator, []byte) {
+	t.Helper()
+
+	mem := processtest.NewMemory()
+	text := mem.Alloc(target, 0x100)
+	copy(text, unhex(t, code))
+
+	return mem, &fakeAllocator{mem: mem, next: trampoline}, text
+}
+
+func mustSlice(t *testing.T, mem *processtest.Memory, addr uintptr, n int) []byte {
+	t.Helper()
+
+	b, err := mem.Slice(addr, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDetourNearHook(t *testing.T) {
+	// mov [rsp+8], rbx; push rdi; sub rsp, 0x20
+	mem, alloc, text := newFixture(t, "48 89 5c 24 08 57 48 83 ec 20")
+	orig := append([]byte(nil), text...)
+
+	d, err := New(mem, alloc, x86.Mode64, target, nearHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Trampoline != trampoline || !bytes.Equal(d.Original, unhex(t, "48 89 5c 24 08")) {
+		t.Fatalf("trampoline %#x, original % x", d.Trampoline, d.Original)
+	}
+
+	// The relocated instruction and a jump back to target+5.
+	want := unhex(t, "48 89 5c 24 08 e9 fb 0f f0 ff")
+	if got := mustSlice(t, mem, trampoline, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("trampoline: got % x, want % x", got, want)
+	}
+
+	if !bytes.Equal(text, orig) || d.Installed() {
+		t.Fatal("New wrote to the target")
+	}
+
+	if err := d.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	// jmp nearHook
+	if want := unhex(t, "e9 fb ef 04 00 57"); !bytes.Equal(text[:6], want) {
+		t.Errorf("patched target: got % x, want % x", text[:6], want)
+	}
+
+	if err := d.Install(); !errors.Is(err, ErrInstalled) {
+		t.Errorf("second Install: got %v, want ErrInstalled", err)
+	}
+
+	if err := d.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(text, orig) {
+		t.Errorf("Uninstall left % x", text[:10])
+	}
+
+	if err := d.Uninstall(); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("second Uninstall: got %v, want ErrNotInstalled", err)
+	}
+
+	// The trampoline survives Uninstall, so the detour can be installed again.
+	if err := d.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(text, orig) || len(alloc.freed) != 1 || alloc.freed[0] != trampoline || d.Trampoline != 0 {
+		t.Errorf("after Close: target % x, freed %#x, trampoline %#x", text[:10], alloc.freed, d.Trampoline)
+	}
+}
+
+func TestDetourFarHookRelay(t *testing.T) {
+	// sub rsp, 0x28; xor eax, eax
+	mem, alloc, text := newFixture(t, "48 83 ec 28 31 c0 c3")
+
+	d, err := New(mem, alloc, x86.Mode64, target, farHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both instructions are relocated, followed by the jump back to
+	// target+6 and an absolute jump to the hook used as relay.
+	want := unhex(t, "48 83 ec 28 31 c0 e9 fb 0f f0 ff ff 25 00 00 00 00 00 00 34 f2 00 00 00 00")
+	if got := mustSlice(t, mem, trampoline, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("trampoline: got % x, want % x", got, want)
+	}
+
+	if err := d.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	// jmp to the relay at trampoline+11, padded with int3 over the rest of xor eax, eax.
+	if want := unhex(t, "e9 06 f0 0f 00 cc c3"); !bytes.Equal(text[:7], want) {
+		t.Errorf("patched target: got % x, want % x", text[:7], want)
+	}
+}
+
+func TestDetourRelocatesBranches(t *testing.T) {
+	// test ecx, ecx; je +0x20; mov rax, [rip+0x100]
+	mem, alloc, _ := newFixture(t, "85 c9 74 20 48 8b 05 00 01 00 00")
+
+	d, err := New(mem, alloc, x86.Mode64, target, nearHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Original) != 11 {
+		t.Fatalf("relocated %d bytes, want 11", len(d.Original))
+	}
+
+	code := mustSlice(t, mem, trampoline, 2+6+7+5)
+	if !bytes.Equal(code[:2], unhex(t, "85 c9")) {
+		t.Errorf("test ecx, ecx: got % x", code[:2])
+	}
+
+	// je widened to rel32, still going to target+0x24.
+	je, _ := x86.Decode(code[2:], x86.Mode64)
+	if got, _ := je.Target(code[2:], trampoline+2); je.Len != 6 || got != target+0x24 {
+		t.Errorf("je: length %d, target %#x", je.Len, got)
+	}
+
+	// The RIP-relative load still reads target+11+0x100.
+	mov, _ := x86.Decode(code[8:], x86.Mode64)
+	if got, _ := mov.Target(code[8:], trampoline+8); got != target+11+0x100 {
+		t.Errorf("mov: target %#x", got)
+	}
+
+	jmp, _ := x86.Decode(code[15:], x86.Mode64)
+	if got, _ := jmp.Target(code[15:], trampoline+15); got != target+11 {
+		t.Errorf("jump back: target %#x, want %#x", got, target+11)
+	}
+}
+
+func TestDetourModified(t *testing.T) {
+	mem, alloc, text := newFixture(t, "48 83 ec 28 31 c0 c3")
+
+	d, err := New(mem, alloc, x86.Mode64, target, nearHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text[0] = 0xCC
+	if err := d.Install(); !errors.Is(err, ErrModified) {
+		t.Fatalf("Install over modified code: got %v, want ErrModified", err)
+	}
+	text[0] = 0x48
+
+	if err := d.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	text[1] = 0
+	if err := d.Uninstall(); !errors.Is(err, ErrModified) {
+		t.Errorf("Uninstall over modified patch: got %v, want ErrModified", err)
+	}
+
+	// The trampoline is kept while the target may still jump into it.
+	if err := d.Close(); !errors.Is(err, ErrModified) || len(alloc.freed) != 0 {
+		t.Errorf("Close: got %v, freed %#x", err, alloc.freed)
+	}
+}
+
+func TestDetourEndOfMapping(t *testing.T) {
+	// The function ends right at the end of the mapping, so the first
+	// read of 20 bytes fails and has to shrink.
+	mem := processtest.NewMemory()
+	mem.Map(target, unhex(t, "48 83 ec 28 31 c0 c3"))
+	alloc := &fakeAllocator{mem: mem, next: trampoline}
+
+	d, err := New(mem, alloc, x86.Mode64, target, nearHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d.Original, unhex(t, "48 83 ec 28 31 c0")) {
+		t.Errorf("original: got % x", d.Original)
+	}
+
+	// The instructions covering the jump run past the mapping.
+	mem.Map(target, unhex(t, "90 90 90 90 48 8b"))
+	if _, err := New(mem, alloc, x86.Mode64, target, nearHook); err == nil {
+		t.Error("no error for instructions running past the mapping")
+	}
+}
+
+func TestDetourErrors(t *testing.T) {
+	// A branch out of the relocated range cannot reach from a far trampoline.
+	mem, _, _ := newFixture(t, "74 20 90 90 90")
+	far := &fakeAllocator{mem: mem, next: 0xE0000000}
+
+	if _, err := New(mem, far, x86.Mode64, target, nearHook); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("far trampoline: got %v, want ErrOutOfRange", err)
+	}
+
+	if len(far.freed) != 1 || far.freed[0] != 0xE0000000 {
+		t.Errorf("trampoline not freed after a failed relocation: %#x", far.freed)
+	}
+
+	if _, err := New(mem, &fakeAllocator{mem: mem}, x86.Mode64, target, nearHook); err == nil {
+		t.Error("no error when allocation fails")
+	}
+
+	if _, err := New(mem, far, x86.Mode64, 0x1000, nearHook); err == nil {
+		t.Error("no error for unmapped target")
+	}
+}
@@ -0,0 +1,238 @@
+package detour
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	JmpRel32Size is the size of a near jump with a 32-bit displacement.
+*/
+const JmpRel32Size = 5
+
+/*
+	JmpAbs64Size is the size of an absolute x64 jump through a RIP-relative
+	pointer stored right after the instruction.
+*/
+const JmpAbs64Size = 14
+
+var ErrOutOfRange = errors.New("detour: displacement does not fit in 32 bits")
+
+/*
+	rel32 returns the displacement from the end of an instruction at from
+	with length n to to, for the given mode.
+*/
+func rel32(from uint64, n int, to uint64, mode x86.Mode) (int32, error) {
+	next := from + uint64(n)
+
+	if mode == x86.Mode32 {
+		return int32(uint32(to) - uint32(next)), nil
+	}
+
+	d := int64(to - next)
+	if d < -1<<31 || d > 1<<31-1 {
+		return 0, fmt.Errorf("%w: %#x to %#x", ErrOutOfRange, from, to)
+	}
+
+	return int32(d), nil
+}
+
+/*
+	InRel32 reports whether a rel32 jump at from can reach to.
+*/
+func InRel32(from, to uint64, mode x86.Mode) bool {
+	_, err := rel32(from, JmpRel32Size, to, mode)
+	return err == nil
+}
+
+/*
+	JmpRel32 encodes a near jump at from to to.
+*/
+func JmpRel32(from, to uint64, mode x86.Mode) ([]byte, error) {
+	d, err := rel32(from, JmpRel32Size, to, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte{0xE9, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(b[1:], uint32(d))
+	return b, nil
+}
+
+/*
+	JmpAbs64 encodes jmp qword ptr [rip+0] followed by the 64-bit address to.
+	It reaches any address but is only valid in 64-bit mode.
+*/
+func JmpAbs64(to uint64) []byte {
+	b := []byte{0xFF, 0x25, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(b[6:], to)
+	return b
+}
+
+/*
+	relocatedSize returns the size of inst once relocated. Short branches
+	are widened to rel32 forms, so the result only depends on the instruction.
+*/
+func relocatedSize(inst *x86.Inst) int {
+	switch inst.Branch {
+	case x86.BranchJmp, x86.BranchCall:
+		return JmpRel32Size
+	case x86.BranchJcc:
+		return 6
+	case x86.BranchLoop:
+		// loop +2; jmp short +5; jmp rel32 target
+		return inst.Len + 2 + JmpRel32Size
+	case x86.BranchXBegin:
+		return inst.ImmOffset + 4
+	}
+
+	return inst.Len
+}
+
+/*
+	Relocation is the result of moving instructions to a new address.
+*/
+type Relocation struct {
+	/*
+		Code is the relocated instructions.
+	*/
+	Code []byte
+
+	/*
+		Len is the number of source bytes that were relocated,
+		a whole number of instructions.
+	*/
+	Len int
+
+	/*
+		Offsets maps the offset of each source instruction to its offset in Code.
+	*/
+	Offsets map[int]int
+}
+
+/*
+	Relocate decodes whole instructions of code, located at from, until at least
+	min bytes are covered, and rewrites them to execute at to.
+
+	RIP-relative operands and relative branches leaving the relocated range are
+	adjusted to keep their absolute target. Branches into the relocated range are
+	redirected to the relocated copy. Short branches are widened to rel32.
+*/
+func Relocate(code []byte, from, to uint64, min int, mode x86.Mode) (*Relocation, error) {
+	insts, err := x86.DecodeAll(code, mode, min)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Relocation{Offsets: make(map[int]int)}
+
+	// The relocated size only depends on the instruction, so the layout
+	// is known before any target is computed.
+	src, dst := 0, 0
+	for i := range insts {
+		r.Offsets[src] = dst
+		src += insts[i].Len
+		dst += relocatedSize(&insts[i])
+	}
+	r.Len = src
+	r.Offsets[src] = dst
+
+	r.Code = make([]byte, 0, dst)
+
+	src = 0
+	for i := range insts {
+		inst := &insts[i]
+		raw := code[src : src+inst.Len]
+		pc := from + uint64(src)
+		npc := to + uint64(r.Offsets[src])
+
+		out, err := r.relocate(inst, raw, pc, npc, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("detour: relocating instruction at %#x: %w", pc, err)
+		}
+
+		r.Code = append(r.Code, out...)
+		src += inst.Len
+	}
+
+	return r, nil
+}
+
+/*
+	target returns where a branch of the relocated code should go: inside
+	the copy for targets within the relocated range, unchanged otherwise.
+*/
+func (r *Relocation) target(abs, from, to uint64) (uint64, error) {
+	if abs < from || abs >= from+uint64(r.Len) {
+		return abs, nil
+	}
+
+	off, ok := r.Offsets[int(abs-from)]
+	if !ok {
+		return 0, fmt.Errorf("detour: branch to %#x lands inside an instruction", abs)
+	}
+
+	return to + uint64(off), nil
+}
+
+func (r *Relocation) relocate(inst *x86.Inst, raw []byte, pc, npc, from, to uint64) ([]byte, error) {
+	mode := inst.Mode
+
+	if inst.RIPRelative {
+		abs, _ := inst.Target(raw, pc)
+
+		d, err := rel32(npc, inst.Len, abs, mode)
+		if err != nil {
+			return nil, err
+		}
+
+		out := append([]byte(nil), raw...)
+		binary.LittleEndian.PutUint32(out[inst.DispOffset:], uint32(d))
+		return out, nil
+	}
+
+	if inst.Branch == x86.BranchNone {
+		return append([]byte(nil), raw...), nil
+	}
+
+	abs, _ := inst.Target(raw, pc)
+	dest, err := r.target(abs, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	switch inst.Branch {
+	case x86.BranchJmp:
+		out = []byte{0xE9, 0, 0, 0, 0}
+	case x86.BranchCall:
+		out = []byte{0xE8, 0, 0, 0, 0}
+	case x86.BranchJcc:
+		cc := inst.Opcode & 0xF
+		out = []byte{0x0F, 0x80 | cc, 0, 0, 0, 0}
+	case x86.BranchXBegin:
+		if inst.ImmSize != 4 {
+			return nil, errors.New("detour: cannot relocate 16-bit xbegin")
+		}
+		out = append([]byte(nil), raw...)
+	case x86.BranchLoop:
+		// The condition is kept on the original 8-bit form, jumping over
+		// a short jump to the widened jump to the real target.
+		out = append([]byte(nil), raw...)
+		out[inst.ImmOffset] = 2
+		out = append(out, 0xEB, JmpRel32Size, 0xE9, 0, 0, 0, 0)
+	}
+
+	// In every form the displacement is the last field and is relative
+	// to the end of the emitted bytes.
+	d, err := rel32(npc, len(out), dest, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(out[len(out)-4:], uint32(d))
+	return out, nil
+}
@@ -0,0 +1,290 @@
+package detour
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestJmpRel32(t *testing.T) {
+	tests := []struct {
+		mode     x86.Mode
+		from, to uint64
+		want     string
+	}{
+		{x86.Mode64, 0x1000, 0x1005, "e9 00 00 00 00"},
+		{x86.Mode64, 0x1000, 0x1000, "e9 fb ff ff ff"},
+		{x86.Mode64, 0x140001000, 0x140001000 + 5 + 1<<31 - 1, "e9 ff ff ff 7f"},
+		{x86.Mode64, 0x140001000, 0x140001005 - 1<<31, "e9 00 00 00 80"},
+		{x86.Mode32, 0x1000, 0xFFFF0000, "e9 fb ef fe ff"},
+		{x86.Mode32, 0xFFFF0000, 0x1000, "e9 fb 0f 01 00"},
+	}
+
+	for _, tc := range tests {
+		got, err := JmpRel32(tc.from, tc.to, tc.mode)
+		if err != nil || !bytes.Equal(got, unhex(t, tc.want)) {
+			t.Errorf("%d-bit %#x to %#x: got % x, %v; want %s", tc.mode, tc.from, tc.to, got, err, tc.want)
+		}
+
+		if !InRel32(tc.from, tc.to, tc.mode) {
+			t.Errorf("%d-bit %#x to %#x: not in range", tc.mode, tc.from, tc.to)
+		}
+	}
+}
+
+func TestJmpRel32OutOfRange(t *testing.T) {
+	tests := []struct{ from, to uint64 }{
+		{0x140001000, 0x140001005 + 1<<31},
+		{0x140001000, 0x140001004 - 1<<31},
+		{0x140001000, 0x7FF600000000},
+		{0x7FF600000000, 0x140001000},
+	}
+
+	for _, tc := range tests {
+		if _, err := JmpRel32(tc.from, tc.to, x86.Mode64); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("%#x to %#x: got %v, want ErrOutOfRange", tc.from, tc.to, err)
+		}
+
+		if InRel32(tc.from, tc.to, x86.Mode64) {
+			t.Errorf("%#x to %#x: reported in range", tc.from, tc.to)
+		}
+	}
+}
+
+func TestJmpAbs64(t *testing.T) {
+	got := JmpAbs64(0x7FF612345678)
+	want := unhex(t, "ff 25 00 00 00 00 78 56 34 12 f6 7f 00 00")
+
+	if !bytes.Equal(got, want) || len(got) != JmpAbs64Size {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     x86.Mode
+		code     string
+		from, to uint64
+		min      int
+
+		want    string
+		len     int
+		offsets map[int]int
+	}{
+		{
+			name: "position independent prologue",
+			mode: x86.Mode64, code: "55 48 89 e5 48 83 ec 20 c3", from: 0x1000, to: 0x2000, min: 5,
+			want: "55 48 89 e5 48 83 ec 20", len: 8,
+			offsets: map[int]int{0: 0, 1: 1, 4: 4, 8: 8},
+		},
+		{
+			name: "jmp rel8 widened",
+			mode: x86.Mode64, code: "eb 10", from: 0x1000, to: 0x2000, min: 2,
+			want: "e9 0d f0 ff ff", len: 2,
+			offsets: map[int]int{0: 0, 2: 5},
+		},
+		{
+			name: "jcc rel8 widened",
+			mode: x86.Mode64, code: "74 10", from: 0x1000, to: 0x2000, min: 2,
+			want: "0f 84 0c f0 ff ff", len: 2,
+			offsets: map[int]int{0: 0, 2: 6},
+		},
+		{
+			name: "jcc rel32 keeps its condition",
+			mode: x86.Mode64, code: "0f 85 00 01 00 00", from: 0x1000, to: 0x2000, min: 5,
+			want: "0f 85 00 f1 ff ff", len: 6,
+			offsets: map[int]int{0: 0, 6: 6},
+		},
+		{
+			name: "loop through a widened jump",
+			mode: x86.Mode64, code: "e2 10", from: 0x1000, to: 0x2000, min: 2,
+			want: "e2 02 eb 05 e9 09 f0 ff ff", len: 2,
+			offsets: map[int]int{0: 0, 2: 9},
+		},
+		{
+			name: "jrcxz with an address size prefix",
+			mode: x86.Mode64, code: "67 e3 10", from: 0x1000, to: 0x2000, min: 3,
+			want: "67 e3 02 eb 05 e9 09 f0 ff ff", len: 3,
+			offsets: map[int]int{0: 0, 3: 10},
+		},
+		{
+			name: "call rel32",
+			mode: x86.Mode64, code: "e8 00 01 00 00", from: 0x1000, to: 0x2000, min: 5,
+			want: "e8 00 f1 ff ff", len: 5,
+			offsets: map[int]int{0: 0, 5: 5},
+		},
+		{
+			name: "xbegin",
+			mode: x86.Mode64, code: "c7 f8 10 00 00 00", from: 0x1000, to: 0x2000, min: 5,
+			want: "c7 f8 10 f0 ff ff", len: 6,
+			offsets: map[int]int{0: 0, 6: 6},
+		},
+		{
+			name: "RIP-relative load",
+			mode: x86.Mode64, code: "48 8b 05 10 00 00 00", from: 0x1000, to: 0x2000, min: 5,
+			want: "48 8b 05 10 f0 ff ff", len: 7,
+			offsets: map[int]int{0: 0, 7: 7},
+		},
+		{
+			name: "RIP-relative operand followed by an immediate",
+			mode: x86.Mode64, code: "80 3d 00 01 00 00 01", from: 0x1000, to: 0x2000, min: 5,
+			want: "80 3d 00 f1 ff ff 01", len: 7,
+			offsets: map[int]int{0: 0, 7: 7},
+		},
+		{
+			name: "RIP-relative load moved to a higher address",
+			mode: x86.Mode64, code: "48 8b 05 10 00 00 00", from: 0x140001000, to: 0x140101000, min: 5,
+			want: "48 8b 05 10 00 f0 ff", len: 7,
+			offsets: map[int]int{0: 0, 7: 7},
+		},
+		{
+			name: "branch into the relocated range",
+			mode: x86.Mode64, code: "74 02 90 90 c3", from: 0x1000, to: 0x2000, min: 5,
+			want: "0f 84 02 00 00 00 90 90 c3", len: 5,
+			offsets: map[int]int{0: 0, 2: 6, 3: 7, 4: 8, 5: 9},
+		},
+		{
+			name: "backward branch to the start of the range",
+			mode: x86.Mode64, code: "90 eb fd", from: 0x1000, to: 0x2000, min: 3,
+			want: "90 e9 fa ff ff ff", len: 3,
+			offsets: map[int]int{0: 0, 1: 1, 3: 6},
+		},
+		{
+			name: "branch to the end of the range",
+			mode: x86.Mode64, code: "74 03 48 89 e5", from: 0x1000, to: 0x2000, min: 5,
+			want: "0f 84 ff ef ff ff 48 89 e5", len: 5,
+			offsets: map[int]int{0: 0, 2: 6, 5: 9},
+		},
+		{
+			name: "32-bit call",
+			mode: x86.Mode32, code: "e8 00 01 00 00", from: 0x401000, to: 0x10000, min: 5,
+			want: "e8 00 11 3f 00", len: 5,
+			offsets: map[int]int{0: 0, 5: 5},
+		},
+		{
+			name: "32-bit jmp wraps around",
+			mode: x86.Mode32, code: "e9 00 00 00 00", from: 0x1000, to: 0xFFFF0000, min: 5,
+			want: "e9 00 10 01 00", len: 5,
+			offsets: map[int]int{0: 0, 5: 5},
+		},
+		{
+			name: "32-bit absolute operand is not relocated",
+			mode: x86.Mode32, code: "8b 05 00 10 40 00", from: 0x401000, to: 0x10000, min: 5,
+			want: "8b 05 00 10 40 00", len: 6,
+			offsets: map[int]int{0: 0, 6: 6},
+		},
+	}
+
+	for _, tc := range tests {
+		r, err := Relocate(unhex(t, tc.code), tc.from, tc.to, tc.min, tc.mode)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+
+		if want := unhex(t, tc.want); !bytes.Equal(r.Code, want) {
+			t.Errorf("%s: got % x, want % x", tc.name, r.Code, want)
+		}
+
+		if r.Len != tc.len {
+			t.Errorf("%s: relocated %d bytes, want %d", tc.name, r.Len, tc.len)
+		}
+
+		if !reflect.DeepEqual(r.Offsets, tc.offsets) {
+			t.Errorf("%s: got offsets %v, want %v", tc.name, r.Offsets, tc.offsets)
+		}
+	}
+}
+
+/*
+	TestRelocateTargets checks that every relocated branch and RIP-relative
+	operand still refers to the same absolute address.
+*/
+func TestRelocateTargets(t *testing.T) {
+	const from, to uint64 = 0x7FF612341000, 0x7FF682341000
+
+	// mov rax, [rip+0x100]; test rax, rax; je +0x40; jmp -0x80; loopne +0x20; call +0x1000
+	code := unhex(t, "48 8b 05 00 01 00 00 48 85 c0 74 40 eb 80 e0 20 e8 00 10 00 00")
+
+	r, err := Relocate(code, from, to, len(code), x86.Mode64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := x86.DecodeAll(code, x86.Mode64, len(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	off := 0
+	for _, s := range src {
+		want, _ := s.Target(code[off:], from+uint64(off))
+
+		dst, ok := r.Offsets[off]
+		if !ok {
+			t.Fatalf("no offset for source instruction at %d", off)
+		}
+
+		d, err := x86.Decode(r.Code[dst:], x86.Mode64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Loops jump to the widened jump after them.
+		if s.Branch == x86.BranchLoop {
+			dst += d.Len + 2
+			if d, err = x86.Decode(r.Code[dst:], x86.Mode64); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, _ := d.Target(r.Code[dst:], to+uint64(dst))
+		if got != want {
+			t.Errorf("instruction at %d: target %#x, want %#x", off, got, want)
+		}
+
+		off += s.Len
+	}
+}
+
+func TestRelocateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     x86.Mode
+		code     string
+		from, to uint64
+		min      int
+		want     error
+	}{
+		{"RIP-relative out of range", x86.Mode64, "48 8b 05 00 00 00 00", 0x140001000, 0x7FF600000000, 5, ErrOutOfRange},
+		{"jcc out of range", x86.Mode64, "74 10 90 90 90", 0x140001000, 0x7FF600000000, 5, ErrOutOfRange},
+		{"call out of range", x86.Mode64, "e8 00 00 00 00", 0x140001000, 0x7FF600000000, 5, ErrOutOfRange},
+		{"truncated", x86.Mode64, "48 8b 05 00", 0x1000, 0x2000, 4, x86.ErrTruncated},
+		{"branch inside an instruction", x86.Mode64, "eb 01 48 89 e5", 0x1000, 0x2000, 5, nil},
+		{"16-bit xbegin", x86.Mode32, "66 c7 f8 10 00 90", 0x1000, 0x2000, 5, nil},
+		{"16-bit xbegin in 64-bit mode", x86.Mode64, "66 c7 f8 10 00 90", 0x1000, 0x2000, 5, nil},
+	}
+
+	for _, tc := range tests {
+		_, err := Relocate(unhex(t, tc.code), tc.from, tc.to, tc.min, tc.mode)
+		if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
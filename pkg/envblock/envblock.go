@@ -0,0 +1,250 @@
+/*
+	Package envblock encodes and decodes Windows environment blocks.
+
+	An environment block is a sequence of NUL terminated UTF-16 "name=value"
+	strings ended by an additional NUL. CreateProcess expects the variables to
+	be sorted by name, case-insensitively and in Unicode order without regard
+	to the locale. Names starting with '=' hold hidden variables, such as the
+	current directory of each drive ("=C:=C:\Windows"). They are not sorted
+	apart: '=' is U+003D, so they come after names starting with characters
+	below it, such as digits, '!' or '$', and before names starting with letters or '_'.
+
+	Variables are handled as "name=value" strings, as returned by os.Environ.
+*/
+package envblock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+/*
+	Split splits a "name=value" entry. The name of a hidden variable
+	starts with '=', so the separator is searched from the second character.
+*/
+func Split(entry string) (name, value string, ok bool) {
+	if entry == "" {
+		return "", "", false
+	}
+
+	i := strings.IndexByte(entry[1:], '=')
+	if i < 0 {
+		return "", "", false
+	}
+
+	return entry[:i+1], entry[i+2:], true
+}
+
+/*
+	IsHidden reports whether name is the name of a hidden variable.
+*/
+func IsHidden(name string) bool {
+	return strings.HasPrefix(name, "=")
+}
+
+/*
+	upcase folds a UTF-16 code unit to upper case like RtlUpcaseUnicodeChar.
+	Surrogates are left alone.
+*/
+func upcase(c uint16) uint16 {
+	if utf16.IsSurrogate(rune(c)) {
+		return c
+	}
+
+	u := unicode.ToUpper(rune(c))
+	if u > 0xFFFF {
+		return c
+	}
+
+	return uint16(u)
+}
+
+/*
+	fold returns the UTF-16 code units of name folded to upper case.
+*/
+func fold(name string) []uint16 {
+	u := utf16.Encode([]rune(name))
+	for i, c := range u {
+		u[i] = upcase(c)
+	}
+
+	return u
+}
+
+/*
+	CompareNames compares variable names the way Windows orders them:
+	case-insensitively, by UTF-16 code unit.
+*/
+func CompareNames(a, b string) int {
+	fa, fb := fold(a), fold(b)
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		if fa[i] != fb[i] {
+			if fa[i] < fb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(fa) < len(fb):
+		return -1
+	case len(fa) > len(fb):
+		return 1
+	}
+
+	return 0
+}
+
+func nameOf(entry string) string {
+	name, _, ok := Split(entry)
+	if !ok {
+		return entry
+	}
+
+	return name
+}
+
+/*
+	Sort sorts env by variable name in Windows order.
+	The order of variables with equal names is kept.
+*/
+func Sort(env []string) {
+	sort.SliceStable(env, func(i, j int) bool {
+		return CompareNames(nameOf(env[i]), nameOf(env[j])) < 0
+	})
+}
+
+/*
+	Lookup returns the value of the variable called name, matched case-insensitively.
+	When the variable is set several times, the last value is returned.
+*/
+func Lookup(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		n, v, ok := Split(env[i])
+		if ok && CompareNames(n, name) == 0 {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+/*
+	Remove returns env without the variables called names, matched case-insensitively.
+*/
+func Remove(env []string, names ...string) []string {
+	out := make([]string, 0, len(env))
+
+next:
+	for _, entry := range env {
+		for _, name := range names {
+			if CompareNames(nameOf(entry), name) == 0 {
+				continue next
+			}
+		}
+		out = append(out, entry)
+	}
+
+	return out
+}
+
+/*
+	Merge returns base with the variables of overrides set. A variable of base
+	is replaced by the override with the same name, matched case-insensitively,
+	and keeps its position; the other overrides are appended in order.
+	When a name appears several times, the last value wins.
+*/
+func Merge(base []string, overrides ...string) []string {
+	out := make([]string, 0, len(base)+len(overrides))
+	index := make(map[string]int)
+
+	for _, entry := range append(append([]string(nil), base...), overrides...) {
+		key := string(utf16.Decode(fold(nameOf(entry))))
+		if i, ok := index[key]; ok {
+			out[i] = entry
+			continue
+		}
+
+		index[key] = len(out)
+		out = append(out, entry)
+	}
+
+	return out
+}
+
+/*
+	Encode builds a sorted environment block from env, ready to be passed to
+	CreateProcess with CREATE_UNICODE_ENVIRONMENT. Duplicate names are merged,
+	the last value wins. An empty env results in an empty block of two NULs.
+*/
+func Encode(env []string) ([]uint16, error) {
+	for _, entry := range env {
+		if _, _, ok := Split(entry); !ok {
+			return nil, fmt.Errorf("envblock: invalid variable %q", entry)
+		}
+
+		if strings.IndexByte(entry, 0) >= 0 {
+			return nil, fmt.Errorf("envblock: variable %q contains NUL", entry)
+		}
+	}
+
+	env = Merge(nil, env...)
+	Sort(env)
+
+	var block []uint16
+	for _, entry := range env {
+		block = append(block, utf16.Encode([]rune(entry))...)
+		block = append(block, 0)
+	}
+
+	if len(block) == 0 {
+		block = append(block, 0)
+	}
+
+	return append(block, 0), nil
+}
+
+/*
+	Decode parses an environment block into "name=value" strings.
+	It stops at the empty string ending the block, or at the end of block
+	if the terminator is missing, as in a block read from a truncated buffer.
+*/
+func Decode(block []uint16) []string {
+	var env []string
+	for len(block) > 0 {
+		end := 0
+		for end < len(block) && block[end] != 0 {
+			end++
+		}
+
+		if end == 0 {
+			break
+		}
+
+		env = append(env, string(utf16.Decode(block[:end])))
+		if end == len(block) {
+			break
+		}
+		block = block[end+1:]
+	}
+
+	return env
+}
+
+/*
+	DecodeBytes is Decode for a block in little-endian bytes,
+	such as one read from the memory of another process.
+*/
+func DecodeBytes(b []byte) []string {
+	block := make([]uint16, len(b)/2)
+	for i := range block {
+		block[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+
+	return Decode(block)
+}
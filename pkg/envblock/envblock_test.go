@@ -0,0 +1,200 @@
+package envblock
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		entry, name, value string
+		ok                 bool
+	}{
+		{"PATH=C:\\Windows", "PATH", "C:\\Windows", true},
+		{"A=", "A", "", true},
+		{"A=b=c", "A", "b=c", true},
+		{"=C:=C:\\Windows", "=C:", "C:\\Windows", true},
+		{"=ExitCode=00000000", "=ExitCode", "00000000", true},
+		{"==", "=", "", true},
+		{"A", "", "", false},
+		{"=", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tc := range tests {
+		name, value, ok := Split(tc.entry)
+		if name != tc.name || value != tc.value || ok != tc.ok {
+			t.Errorf("%q: got %q, %q, %v", tc.entry, name, value, ok)
+		}
+	}
+
+	if !IsHidden("=C:") || IsHidden("C:") || IsHidden("") {
+		t.Error("IsHidden")
+	}
+}
+
+func TestCompareNames(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"PATH", "Path", 0},
+		{"path", "PATH", 0},
+		{"é", "É", 0},
+		{"A", "B", -1},
+		{"b", "A", 1},
+		{"A", "AB", -1},
+		{"AB", "A", 1},
+		{"", "A", -1},
+		{"", "", 0},
+
+		// Ordered by code unit after folding: '_' (U+005F) is above the
+		// upper case letters and below the lower case ones.
+		{"_A", "a", 1},
+		{"_A", "Z", 1},
+		{"A_B", "AB", 1},
+
+		// Hidden names are ordered by '=' like any other character.
+		{"=C:", "A", -1},
+		{"=C:", "1", 1},
+		{"=C:", "!x", 1},
+		{"=C:", "=D:", -1},
+		{"=c:", "=C:", 0},
+
+		// A character outside the BMP compares by its surrogates, which are
+		// below U+E000 to U+FFFF.
+		{"\U0001D11E", "\uFF21", -1},
+		{"\U0001D11E", "Z", 1},
+	}
+
+	for _, tc := range tests {
+		if got := CompareNames(tc.a, tc.b); got != tc.want {
+			t.Errorf("%q, %q: got %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	env := []string{
+		"windir=C:\\Windows",
+		"_X=1",
+		"=C:=C:\\",
+		"Path=C:\\Windows",
+		"1=one",
+		"ALLUSERSPROFILE=C:\\ProgramData",
+		"PATH=second",
+		"=::=::\\",
+		"!bang=1",
+		"=ExitCode=00000001",
+	}
+
+	Sort(env)
+
+	want := []string{
+		"!bang=1",
+		"1=one",
+		"=::=::\\",
+		"=C:=C:\\",
+		"=ExitCode=00000001",
+		"ALLUSERSPROFILE=C:\\ProgramData",
+		"Path=C:\\Windows",
+		"PATH=second",
+		"windir=C:\\Windows",
+		"_X=1",
+	}
+
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("got %q\nwant %q", env, want)
+	}
+}
+
+func TestLookupRemoveMerge(t *testing.T) {
+	env := []string{"A=1", "Path=x", "=C:=C:\\", "path=y"}
+
+	if v, ok := Lookup(env, "PATH"); !ok || v != "y" {
+		t.Errorf("Lookup PATH: got %q, %v", v, ok)
+	}
+	if v, ok := Lookup(env, "=c:"); !ok || v != "C:\\" {
+		t.Errorf("Lookup =c:: got %q, %v", v, ok)
+	}
+	if _, ok := Lookup(env, "B"); ok {
+		t.Error("Lookup B: found")
+	}
+
+	if got, want := Remove(env, "PATH", "=C:"), []string{"A=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Remove: got %q, want %q", got, want)
+	}
+
+	got := Merge([]string{"A=1", "Path=x", "B=2"}, "PATH=y", "C=3", "a=4", "C=5")
+	want := []string{"a=4", "PATH=y", "B=2", "C=5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge: got %q, want %q", got, want)
+	}
+
+	if len(env) != 4 || env[1] != "Path=x" {
+		t.Errorf("env modified: %q", env)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	block, err := Encode([]string{"b=2", "=C:=C:\\", "A=1", "B=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := utf16.Encode([]rune("=C:=C:\\\x00A=1\x00B=3\x00\x00"))
+	if !reflect.DeepEqual(block, want) {
+		t.Errorf("got %q, want %q", string(utf16.Decode(block)), string(utf16.Decode(want)))
+	}
+
+	if block, err := Encode(nil); err != nil || !reflect.DeepEqual(block, []uint16{0, 0}) {
+		t.Errorf("empty: got %v, %v", block, err)
+	}
+
+	for _, env := range [][]string{{"A"}, {""}, {"="}, {"A=b\x00c"}} {
+		if _, err := Encode(env); err == nil {
+			t.Errorf("%q: no error", env)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	env := []string{"=C:=C:\\", "A=1", "Path=C:\\é\U0001D11E"}
+	block, err := Encode(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Decode(block); !reflect.DeepEqual(got, env) {
+		t.Errorf("got %q, want %q", got, env)
+	}
+
+	// Data after the terminating empty string is ignored.
+	if got := Decode(append(append([]uint16(nil), block...), 'X', '=', '1', 0)); !reflect.DeepEqual(got, env) {
+		t.Errorf("trailing data: got %q", got)
+	}
+
+	// A block cut anywhere keeps the complete variables, and the cut one.
+	truncated := block[:len(block)-5]
+	if got, want := Decode(truncated), []string{"=C:=C:\\", "A=1", "Path=C:\\"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("truncated: got %q, want %q", got, want)
+	}
+
+	if got := Decode(nil); got != nil {
+		t.Errorf("nil: got %q", got)
+	}
+	if got := Decode([]uint16{0, 0}); got != nil {
+		t.Errorf("empty block: got %q", got)
+	}
+
+	b := []byte{'A', 0, '=', 0, '1', 0, 0, 0, 0, 0}
+	if got, want := DecodeBytes(b), []string{"A=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBytes: got %q, want %q", got, want)
+	}
+
+	// An odd trailing byte is dropped.
+	if got, want := DecodeBytes(b[:5]), []string{"A="}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBytes odd length: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package inject
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+var ErrLoadFailed = errors.New("inject: LoadLibraryW failed in the target")
+
+/*
+	EncodePath encodes path as the NUL terminated UTF-16 string passed to LoadLibraryW.
+*/
+func EncodePath(path string) []byte {
+	u := utf16.Encode([]rune(path))
+	b := make([]byte, 2*len(u)+2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+
+	return b
+}
+
+/*
+	FindLoaded finds the module loaded from path among modules, given the exit
+	code of the thread that ran LoadLibraryW in a process of the given mode.
+
+	The exit code of a thread is 32 bits, so in a 64-bit process it only holds
+	the low half of the module handle. The module is then identified by the
+	low half of its base together with its path, or its file name when paths
+	are reported differently, such as with a short or redirected path.
+*/
+func FindLoaded(modules []process.Module, path string, exitCode uint32, mode x86.Mode) (process.Module, error) {
+	if exitCode == 0 {
+		return process.Module{}, ErrLoadFailed
+	}
+
+	if mode == x86.Mode32 {
+		for _, m := range modules {
+			if uint64(m.Base) == uint64(exitCode) {
+				return m, nil
+			}
+		}
+
+		return process.Module{}, fmt.Errorf("inject: no module loaded at %#x", exitCode)
+	}
+
+	var candidates []process.Module
+	for _, m := range modules {
+		if uint32(m.Base) == exitCode {
+			candidates = append(candidates, m)
+		}
+	}
+
+	for _, m := range candidates {
+		if strings.EqualFold(m.Path, path) {
+			return m, nil
+		}
+	}
+
+	var named []process.Module
+	for _, m := range candidates {
+		if strings.EqualFold(m.Name, baseName(path)) {
+			named = append(named, m)
+		}
+	}
+
+	switch len(named) {
+	case 0:
+		return process.Module{}, fmt.Errorf("inject: no module %s loaded at %#x", baseName(path), exitCode)
+	case 1:
+		return named[0], nil
+	}
+
+	return process.Module{}, fmt.Errorf("inject: %d modules %s match the truncated base %#x", len(named), baseName(path), exitCode)
+}
+
+/*
+	baseName returns the file name of a Windows path on any platform.
+*/
+func baseName(path string) string {
+	return path[strings.LastIndexAny(path, `\/`)+1:]
+}
@@ -0,0 +1,67 @@
+//go:build amd64 || arm64
+
+package inject
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	TestFindLoaded64 needs module bases above 4GB, which only fit in the
+	uintptr of a 64-bit host.
+*/
+func TestFindLoaded64(t *testing.T) {
+	const path = `C:\Tools\hook.dll`
+
+	modules := []process.Module{
+		{Name: "app.exe", Path: `C:\App\app.exe`, Base: 0x7FF612340000},
+		{Name: "other.dll", Path: `C:\App\other.dll`, Base: 0x7FFB12340000},
+		{Name: "hook.dll", Path: `C:\Tools\HOOK.DLL`, Base: 0x7FFC56780000},
+		{Name: "hook.dll", Path: `C:\Other\hook.dll`, Base: 0x7FFD56780000},
+		{Name: "hook32.dll", Path: `C:\Tools\hook32.dll`, Base: 0x56780000},
+		{Name: "short.dll", Path: `C:\PROGRA~1\short.dll`, Base: 0x7FFE9ABC0000},
+	}
+
+	tests := []struct {
+		path string
+		code uint32
+		want uintptr
+	}{
+		// The path tells apart modules sharing the low half of their base.
+		{path, 0x56780000, 0x7FFC56780000},
+
+		// The file name is used when the path is reported differently.
+		{`C:\Program Files\short.dll`, 0x9ABC0000, 0x7FFE9ABC0000},
+	}
+
+	for _, tc := range tests {
+		m, err := FindLoaded(modules, tc.path, tc.code, x86.Mode64)
+		if err != nil || m.Base != tc.want {
+			t.Errorf("%s at %#x: got %#x, %v; want %#x", tc.path, tc.code, m.Base, err, tc.want)
+		}
+	}
+
+	errs := []struct {
+		path string
+		code uint32
+	}{
+		// Two hook.dll share the truncated base and neither has this path.
+		{`C:\Elsewhere\hook.dll`, 0x56780000},
+		{`C:\Tools\missing.dll`, 0x56780000},
+		{path, 0x11110000},
+	}
+
+	for _, tc := range errs {
+		if m, err := FindLoaded(modules, tc.path, tc.code, x86.Mode64); err == nil {
+			t.Errorf("%s at %#x: got %+v, want an error", tc.path, tc.code, m)
+		}
+	}
+
+	if _, err := FindLoaded(modules, path, 0, x86.Mode64); !errors.Is(err, ErrLoadFailed) {
+		t.Errorf("exit code 0: got %v, want ErrLoadFailed", err)
+	}
+}
@@ -0,0 +1,44 @@
+package inject
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+func TestEncodePath(t *testing.T) {
+	got := EncodePath(`C:\é\𝄞.dll`)
+	want := []byte{
+		'C', 0, ':', 0, '\\', 0, 0xE9, 0, '\\', 0,
+		0x34, 0xD8, 0x1E, 0xDD, // U+1D11E as a surrogate pair
+		'.', 0, 'd', 0, 'l', 0, 'l', 0,
+		0, 0,
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestFindLoaded32(t *testing.T) {
+	modules := []process.Module{
+		{Name: "app.exe", Path: `C:\App\app.exe`, Base: 0x400000},
+		{Name: "hook.dll", Path: `C:\Tools\hook.dll`, Base: 0x56780000},
+	}
+
+	m, err := FindLoaded(modules, `C:\Whatever\hook.dll`, 0x56780000, x86.Mode32)
+	if err != nil || m.Base != 0x56780000 {
+		t.Errorf("got %#x, %v", m.Base, err)
+	}
+
+	if _, err := FindLoaded(modules, `C:\Tools\hook.dll`, 0x11110000, x86.Mode32); err == nil {
+		t.Error("no error for an exit code that is not a module base")
+	}
+
+	if _, err := FindLoaded(modules, `C:\Tools\hook.dll`, 0, x86.Mode32); !errors.Is(err, ErrLoadFailed) {
+		t.Errorf("exit code 0: got %v, want ErrLoadFailed", err)
+	}
+}
@@ -0,0 +1,141 @@
+package inject
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/remote"
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	NewProcessResolver creates a Resolver for the modules currently loaded in
+	the process behind handle, identified by pid. The handle must have the
+	PROCESS_VM_READ and PROCESS_QUERY_INFORMATION access rights.
+
+	A process created suspended has no modules besides its image and ntdll yet.
+*/
+func NewProcessResolver(handle win32.Handle, pid uint32) (*Resolver, error) {
+	mode, err := remote.Mode(handle)
+	if err != nil {
+		return nil, fmt.Errorf("inject: %w", err)
+	}
+
+	modules, err := process.Modules(pid)
+	if err != nil {
+		return nil, fmt.Errorf("inject: listing modules: %w", err)
+	}
+
+	return NewResolver(process.Remote{Handle: handle}, modules, mode), nil
+}
+
+/*
+	runThread runs start(parameter) on a new thread of the process and returns its exit code.
+*/
+func runThread(handle win32.Handle, start, parameter uintptr) (uint32, error) {
+	thread, _, err := kernel32.CreateRemoteThread(handle, 0, start, parameter, 0)
+	if err != nil {
+		return 0, fmt.Errorf("inject: creating thread: %w", err)
+	}
+	defer kernel32.CloseHandle(thread)
+
+	if _, err := kernel32.WaitForSingleObject(thread, kernel32.INFINITE); err != nil {
+		return 0, fmt.Errorf("inject: waiting for thread: %w", err)
+	}
+
+	code, err := kernel32.GetExitCodeThread(thread)
+	if err != nil {
+		return 0, fmt.Errorf("inject: %w", err)
+	}
+
+	return code, nil
+}
+
+/*
+	LoadLibrary loads the DLL at path into the process behind handle, identified by pid,
+	by running LoadLibraryW on a remote thread, and returns the loaded module.
+	Relative paths are made absolute in the calling process.
+
+	The handle must have the PROCESS_CREATE_THREAD, PROCESS_QUERY_INFORMATION,
+	PROCESS_VM_OPERATION, PROCESS_VM_WRITE and PROCESS_VM_READ access rights.
+*/
+func LoadLibrary(handle win32.Handle, pid uint32, path string) (mod process.Module, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return mod, err
+	}
+
+	r, err := NewProcessResolver(handle, pid)
+	if err != nil {
+		return mod, err
+	}
+
+	loadLibrary, err := r.Proc("kernel32.dll", "LoadLibraryW")
+	if err != nil {
+		return mod, err
+	}
+
+	arg := EncodePath(path)
+	addr, err := kernel32.VirtualAllocEx(handle, 0, uintptr(len(arg)), kernel32.MEM_COMMIT|kernel32.MEM_RESERVE, kernel32.PAGE_READWRITE)
+	if err != nil {
+		return mod, fmt.Errorf("inject: allocating path: %w", err)
+	}
+
+	defer func() {
+		if ferr := kernel32.VirtualFreeEx(handle, addr, 0, kernel32.MEM_RELEASE); err == nil && ferr != nil {
+			err = fmt.Errorf("inject: freeing path: %w", ferr)
+		}
+	}()
+
+	if err := (process.Remote{Handle: handle}).WriteMemory(addr, arg); err != nil {
+		return mod, fmt.Errorf("inject: writing path: %w", err)
+	}
+
+	code, err := runThread(handle, loadLibrary, addr)
+	if err != nil {
+		return mod, err
+	}
+
+	if code == 0 {
+		return mod, ErrLoadFailed
+	}
+
+	modules, err := process.Modules(pid)
+	if err != nil {
+		return mod, fmt.Errorf("inject: listing modules: %w", err)
+	}
+
+	return FindLoaded(modules, path, code, r.mode)
+}
+
+/*
+	FreeLibrary unloads mod from the process behind handle, identified by pid,
+	by running FreeLibrary on a remote thread. The module is only unmapped
+	once its reference count drops to zero.
+
+	The handle needs the same access rights as for LoadLibrary.
+*/
+func FreeLibrary(handle win32.Handle, pid uint32, mod process.Module) error {
+	r, err := NewProcessResolver(handle, pid)
+	if err != nil {
+		return err
+	}
+
+	freeLibrary, err := r.Proc("kernel32.dll", "FreeLibrary")
+	if err != nil {
+		return err
+	}
+
+	code, err := runThread(handle, freeLibrary, mod.Base)
+	if err != nil {
+		return err
+	}
+
+	if code == 0 {
+		return fmt.Errorf("inject: FreeLibrary failed for %s in the target", mod.Name)
+	}
+
+	return nil
+}
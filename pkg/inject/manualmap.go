@@ -0,0 +1,278 @@
+package inject
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	DLL_PROCESS_ATTACH is the reason passed to TLS callbacks and DllMain when a module is loaded.
+*/
+const DLL_PROCESS_ATTACH = 1
+
+var ErrRelocsStripped = errors.New("inject: image cannot be mapped away from its preferred base, its relocations were stripped")
+
+/*
+	ParseDLL parses the DLL file in data and checks that it can be mapped
+	into a process of the given mode.
+*/
+func ParseDLL(data []byte, mode x86.Mode) (*pe.Image, error) {
+	img, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("inject: %w", err)
+	}
+
+	machine := pe.IMAGE_FILE_MACHINE_I386
+	if mode == x86.Mode64 {
+		machine = pe.IMAGE_FILE_MACHINE_AMD64
+	}
+
+	if img.FileHeader.Machine != machine {
+		return nil, fmt.Errorf("inject: image machine %#x does not match the %d-bit target", img.FileHeader.Machine, mode)
+	}
+
+	if img.FileHeader.Characteristics&pe.IMAGE_FILE_DLL == 0 {
+		return nil, errors.New("inject: image is not a DLL")
+	}
+
+	return img, nil
+}
+
+/*
+	Layout copies the headers and sections of the file img into a buffer of
+	SizeOfImage bytes, at the RVAs the loader would map them at.
+*/
+func Layout(img *pe.Image) ([]byte, error) {
+	oh := &img.OptionalHeader
+	if oh.SizeOfHeaders > oh.SizeOfImage {
+		return nil, fmt.Errorf("inject: headers are larger than the image")
+	}
+
+	data := make([]byte, oh.SizeOfImage)
+	if _, err := img.Reader().ReadAt(data[:oh.SizeOfHeaders], 0); err != nil {
+		return nil, fmt.Errorf("inject: reading headers: %w", err)
+	}
+
+	for _, s := range img.Sections {
+		size := s.SizeOfRawData
+		if s.VirtualSize != 0 && s.VirtualSize < size {
+			size = s.VirtualSize
+		}
+
+		if size == 0 {
+			continue
+		}
+
+		if uint64(s.VirtualAddress)+uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("inject: section %s is outside of the image", s.Name)
+		}
+
+		if _, err := img.Reader().ReadAt(data[s.VirtualAddress:s.VirtualAddress+size], int64(s.PointerToRawData)); err != nil {
+			return nil, fmt.Errorf("inject: reading section %s: %w", s.Name, err)
+		}
+	}
+
+	return data, nil
+}
+
+/*
+	Buffer is an image laid out in local memory for mapping at Base.
+	It implements process.Memory over the addresses of the image, so the
+	mapping stages can run on it before it is copied into the target.
+*/
+type Buffer struct {
+	Base uintptr
+	Data []byte
+}
+
+func (b *Buffer) slice(addr uintptr, n int) ([]byte, error) {
+	if addr < b.Base || addr-b.Base > uintptr(len(b.Data)) || uintptr(len(b.Data))-(addr-b.Base) < uintptr(n) {
+		return nil, fmt.Errorf("inject: access of %d bytes at %#x is outside of the image", n, addr)
+	}
+
+	off := addr - b.Base
+	return b.Data[off : off+uintptr(n)], nil
+}
+
+func (b *Buffer) ReadMemory(addr uintptr, buf []byte) error {
+	s, err := b.slice(addr, len(buf))
+	if err != nil {
+		return err
+	}
+
+	copy(buf, s)
+	return nil
+}
+
+func (b *Buffer) WriteMemory(addr uintptr, buf []byte) error {
+	s, err := b.slice(addr, len(buf))
+	if err != nil {
+		return err
+	}
+
+	copy(s, buf)
+	return nil
+}
+
+/*
+	Relocate applies the base relocations of img to its image mapped at base in mem,
+	and updates the ImageBase field of the mapped headers.
+*/
+func Relocate(mem process.Memory, base uintptr, img *pe.Image) error {
+	delta := uint64(base) - img.OptionalHeader.ImageBase
+
+	// The mapped headers record the actual base, as the loader does.
+	field, size := img.OptionalHeaderOffset+28, 4
+	if img.Is64() {
+		field, size = img.OptionalHeaderOffset+24, 8
+	}
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(base))
+	if err := mem.WriteMemory(base+uintptr(field), b[:size]); err != nil {
+		return err
+	}
+
+	if delta == 0 {
+		return nil
+	}
+
+	relocs, err := img.BaseRelocations()
+	if errors.Is(err, pe.ErrNoDirectory) {
+		if img.FileHeader.Characteristics&pe.IMAGE_FILE_RELOCS_STRIPPED != 0 {
+			return ErrRelocsStripped
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inject: %w", err)
+	}
+
+	for _, r := range relocs {
+		if err := applyRelocation(mem, base, r, delta); err != nil {
+			return fmt.Errorf("inject: relocation at %#x: %w", r.RVA, err)
+		}
+	}
+
+	return nil
+}
+
+func applyRelocation(mem process.Memory, base uintptr, r pe.BaseRelocation, delta uint64) error {
+	addr := base + uintptr(r.RVA)
+
+	var b [8]byte
+	switch r.Type {
+	case pe.IMAGE_REL_BASED_DIR64:
+		if err := mem.ReadMemory(addr, b[:8]); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(b[:], binary.LittleEndian.Uint64(b[:])+delta)
+		return mem.WriteMemory(addr, b[:8])
+
+	case pe.IMAGE_REL_BASED_HIGHLOW:
+		if err := mem.ReadMemory(addr, b[:4]); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(b[:], binary.LittleEndian.Uint32(b[:])+uint32(delta))
+		return mem.WriteMemory(addr, b[:4])
+
+	case pe.IMAGE_REL_BASED_HIGH, pe.IMAGE_REL_BASED_LOW, pe.IMAGE_REL_BASED_HIGHADJ:
+		if err := mem.ReadMemory(addr, b[:2]); err != nil {
+			return err
+		}
+
+		v := binary.LittleEndian.Uint16(b[:])
+		switch r.Type {
+		case pe.IMAGE_REL_BASED_HIGH:
+			v += uint16(uint32(delta) >> 16)
+		case pe.IMAGE_REL_BASED_LOW:
+			v += uint16(delta)
+		default:
+			// The high half of the 32-bit value, rounded by its low half.
+			full := uint32(v)<<16 + uint32(int32(int16(r.Param)))
+			v = uint16((full + uint32(delta) + 0x8000) >> 16)
+		}
+
+		binary.LittleEndian.PutUint16(b[:], v)
+		return mem.WriteMemory(addr, b[:2])
+	}
+
+	return fmt.Errorf("unsupported relocation type %d", r.Type)
+}
+
+/*
+	ResolveImports writes the addresses of the functions imported by img,
+	resolved with r against the modules of the target, to the import address
+	table of its image mapped at base in mem.
+
+	Modules the image imports from must already be loaded in the target.
+*/
+func ResolveImports(mem process.Memory, base uintptr, img *pe.Image, r *Resolver) error {
+	imports, err := img.Imports()
+	if errors.Is(err, pe.ErrNoDirectory) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inject: %w", err)
+	}
+
+	size := 4
+	if img.Is64() {
+		size = 8
+	}
+
+	for _, imp := range imports {
+		for _, fn := range imp.Functions {
+			var addr uintptr
+			if fn.Name != "" {
+				addr, err = r.Proc(imp.Module, fn.Name)
+			} else {
+				addr, err = r.ProcOrdinal(imp.Module, fn.Ordinal)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], uint64(addr))
+			if err := mem.WriteMemory(base+uintptr(fn.IAT), b[:size]); err != nil {
+				return fmt.Errorf("inject: writing import %s: %w", imp.Module, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+	TLSCallbacks returns the addresses of the TLS callbacks of the image mapped
+	at base in r, once it has been relocated.
+*/
+func TLSCallbacks(r process.Reader, base uintptr, size uint32) ([]uintptr, error) {
+	img, err := process.OpenModule(r, process.Module{Base: base, Size: size})
+	if err != nil {
+		return nil, fmt.Errorf("inject: %w", err)
+	}
+
+	callbacks, err := img.TLSCallbacks()
+	if errors.Is(err, pe.ErrNoDirectory) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inject: %w", err)
+	}
+
+	addrs := make([]uintptr, len(callbacks))
+	for i, cb := range callbacks {
+		addrs[i] = uintptr(cb)
+	}
+
+	return addrs, nil
+}
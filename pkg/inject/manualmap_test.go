@@ -0,0 +1,331 @@
+package inject
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+const dataRVA = 0x1000
+
+/*
+	layoutDLL parses the image built by b and lays it out in a Buffer at base.
+*/
+func layoutDLL(t *testing.T, b *petest.Builder, base uintptr) (*pe.Image, *Buffer) {
+	t.Helper()
+
+	mode := x86.Mode32
+	if b.Is64() {
+		mode = x86.Mode64
+	}
+
+	img, err := ParseDLL(b.Bytes(), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Layout(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img, &Buffer{Base: base, Data: data}
+}
+
+/*
+	relocImage builds a DLL with data as its first section at dataRVA and a
+	base relocation directory holding relocs.
+*/
+func relocImage(is64 bool, data []byte, relocs []petest.Relocation) *petest.Builder {
+	b := petest.New(is64)
+	b.AddSection(".data", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+
+	if relocs != nil {
+		dir := petest.RelocationDirectory(relocs)
+		rva := b.AddSection(".reloc", dir, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+		b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_BASERELOC, rva, len(dir))
+	}
+
+	return b
+}
+
+func TestRelocateDIR64(t *testing.T) {
+	const base = 0x40000000
+
+	data := make([]byte, 0x20)
+	binary.LittleEndian.PutUint64(data[0x00:], 0x180001234)
+	binary.LittleEndian.PutUint64(data[0x08:], 0x180002000)
+	binary.LittleEndian.PutUint64(data[0x10:], 0x180001234) // not relocated
+
+	b := relocImage(true, data, []petest.Relocation{
+		{RVA: dataRVA + 0x00, Type: pe.IMAGE_REL_BASED_DIR64},
+		{RVA: dataRVA + 0x08, Type: pe.IMAGE_REL_BASED_DIR64},
+	})
+
+	img, buf := layoutDLL(t, b, base)
+	if err := Relocate(buf, base, img); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.Data[dataRVA:]
+	want := []uint64{base + 0x1234, base + 0x2000, 0x180001234}
+	for i, w := range want {
+		if v := binary.LittleEndian.Uint64(got[8*i:]); v != w {
+			t.Errorf("value %d: got %#x, want %#x", i, v, w)
+		}
+	}
+
+	if v := binary.LittleEndian.Uint64(buf.Data[img.OptionalHeaderOffset+24:]); v != base {
+		t.Errorf("ImageBase: got %#x, want %#x", v, base)
+	}
+}
+
+func TestRelocate32(t *testing.T) {
+	const base = 0x12340000
+
+	data := make([]byte, 0x20)
+	binary.LittleEndian.PutUint32(data[0x00:], 0x10001234)
+	binary.LittleEndian.PutUint16(data[0x04:], 0x1000)
+	binary.LittleEndian.PutUint16(data[0x06:], 0x5678)
+
+	// The high half of 0x10009000 as the loader sees it with HIGHADJ: the low
+	// half is negative as a 16-bit value, so the high half is rounded up.
+	binary.LittleEndian.PutUint16(data[0x08:], 0x1001)
+	binary.LittleEndian.PutUint16(data[0x0A:], 0x1000)
+
+	b := relocImage(false, data, []petest.Relocation{
+		{RVA: dataRVA + 0x00, Type: pe.IMAGE_REL_BASED_HIGHLOW},
+		{RVA: dataRVA + 0x04, Type: pe.IMAGE_REL_BASED_HIGH},
+		{RVA: dataRVA + 0x06, Type: pe.IMAGE_REL_BASED_LOW},
+		{RVA: dataRVA + 0x08, Type: pe.IMAGE_REL_BASED_HIGHADJ, Param: 0x9000},
+		{RVA: dataRVA + 0x0A, Type: pe.IMAGE_REL_BASED_HIGHADJ, Param: 0x1000},
+	})
+
+	img, buf := layoutDLL(t, b, base)
+	if err := Relocate(buf, base, img); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.Data[dataRVA:]
+	if v := binary.LittleEndian.Uint32(got[0x00:]); v != 0x12341234 {
+		t.Errorf("HIGHLOW: got %#x, want 0x12341234", v)
+	}
+
+	tests := []struct {
+		name string
+		off  int
+		want uint16
+	}{
+		{"HIGH", 0x04, 0x1234},
+		{"LOW", 0x06, 0x5678},
+		{"HIGHADJ with negative low half", 0x08, 0x1235}, // 0x12349000
+		{"HIGHADJ with positive low half", 0x0A, 0x1234}, // 0x12341000
+	}
+
+	for _, tc := range tests {
+		if v := binary.LittleEndian.Uint16(got[tc.off:]); v != tc.want {
+			t.Errorf("%s: got %#x, want %#x", tc.name, v, tc.want)
+		}
+	}
+
+	field := buf.Data[img.OptionalHeaderOffset+28:]
+	if v := binary.LittleEndian.Uint32(field); v != base {
+		t.Errorf("ImageBase: got %#x, want %#x", v, base)
+	}
+}
+
+func TestRelocatePreferredBase(t *testing.T) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data, 0x10001234)
+
+	b := relocImage(false, data, []petest.Relocation{{RVA: dataRVA, Type: pe.IMAGE_REL_BASED_HIGHLOW}})
+	b.Characteristics |= pe.IMAGE_FILE_RELOCS_STRIPPED
+
+	img, buf := layoutDLL(t, b, 0x10000000)
+	if err := Relocate(buf, 0x10000000, img); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := binary.LittleEndian.Uint32(buf.Data[dataRVA:]); v != 0x10001234 {
+		t.Errorf("value changed at the preferred base: %#x", v)
+	}
+}
+
+func TestRelocateWithoutDirectory(t *testing.T) {
+	const base = 0x12340000
+
+	b := relocImage(false, make([]byte, 8), nil)
+	img, buf := layoutDLL(t, b, base)
+	if err := Relocate(buf, base, img); err != nil {
+		t.Errorf("image without relocations to apply: %v", err)
+	}
+
+	b.Characteristics |= pe.IMAGE_FILE_RELOCS_STRIPPED
+	img, buf = layoutDLL(t, b, base)
+	if err := Relocate(buf, base, img); !errors.Is(err, ErrRelocsStripped) {
+		t.Errorf("stripped image: got %v, want ErrRelocsStripped", err)
+	}
+}
+
+func TestRelocateErrors(t *testing.T) {
+	const base = 0x12340000
+
+	tests := []struct {
+		name  string
+		reloc petest.Relocation
+	}{
+		{"outside of the image", petest.Relocation{RVA: 0x8000, Type: pe.IMAGE_REL_BASED_HIGHLOW}},
+		{"unsupported type", petest.Relocation{RVA: dataRVA, Type: 5}},
+	}
+
+	for _, tc := range tests {
+		b := relocImage(false, make([]byte, 8), []petest.Relocation{tc.reloc})
+		img, buf := layoutDLL(t, b, base)
+		if err := Relocate(buf, base, img); err == nil {
+			t.Errorf("%s: no error", tc.name)
+		}
+	}
+}
+
+/*
+	importImage builds a DLL importing imports and returns the Buffer it is
+	laid out in at base, along with the IAT slots of the functions.
+*/
+func importImage(t *testing.T, is64 bool, base uintptr, imports []petest.Import) (*pe.Image, *Buffer, [][]uint32) {
+	t.Helper()
+
+	b := petest.New(is64)
+	rva := b.NextRVA()
+	dir, iat := petest.ImportDirectory(rva, is64, imports)
+	b.AddSection(".idata", dir, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ|pe.IMAGE_SCN_MEM_WRITE)
+	b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_IMPORT, rva, len(dir))
+
+	img, buf := layoutDLL(t, b, base)
+	return img, buf, iat
+}
+
+func TestResolveImports64(t *testing.T) {
+	const base = 0x40000000
+
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode64)
+
+	img, buf, iat := importImage(t, true, base, []petest.Import{
+		{Module: "KERNEL32.dll", Functions: []petest.ImportedFunction{
+			{Name: "GetProcAddress", Hint: 7},
+			{Name: "HeapAlloc"},
+		}},
+		{Module: "ntdll.dll", Functions: []petest.ImportedFunction{
+			{Ordinal: 3},
+		}},
+	})
+
+	if err := ResolveImports(buf, base, img, r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]uint64{
+		{kernel32Base + 0x1000, ntdllBase + 0x1010},
+		{ntdllBase + 0x1030},
+	}
+
+	for i := range want {
+		for j, w := range want[i] {
+			if v := binary.LittleEndian.Uint64(buf.Data[iat[i][j]:]); v != w {
+				t.Errorf("import %d.%d at %#x: got %#x, want %#x", i, j, iat[i][j], v, w)
+			}
+		}
+	}
+}
+
+func TestResolveImports32(t *testing.T) {
+	const base = 0x12340000
+
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode32)
+
+	img, buf, iat := importImage(t, false, base, []petest.Import{
+		{Module: "kernel32.dll", Functions: []petest.ImportedFunction{
+			{Name: "GetProcAddress"},
+			{Ordinal: 1},
+		}},
+	})
+
+	if err := ResolveImports(buf, base, img, r); err != nil {
+		t.Fatal(err)
+	}
+
+	for j, slot := range iat[0] {
+		if v := binary.LittleEndian.Uint32(buf.Data[slot:]); v != wow64Base+0x1100 {
+			t.Errorf("import %d: got %#x, want %#x", j, v, wow64Base+0x1100)
+		}
+	}
+
+	// Slots are 4 bytes, so the null thunk terminating the table is left alone.
+	if v := binary.LittleEndian.Uint32(buf.Data[iat[0][1]+4:]); v != 0 {
+		t.Errorf("terminator overwritten with %#x", v)
+	}
+}
+
+func TestResolveImportsErrors(t *testing.T) {
+	const base = 0x40000000
+
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode64)
+
+	img, buf, _ := importImage(t, true, base, []petest.Import{
+		{Module: "ntdll.dll", Functions: []petest.ImportedFunction{{Name: "NtMissing"}}},
+	})
+	if err := ResolveImports(buf, base, img, r); !errors.Is(err, ErrProcNotFound) {
+		t.Errorf("missing function: got %v, want ErrProcNotFound", err)
+	}
+
+	img, buf, _ = importImage(t, true, base, []petest.Import{
+		{Module: "user32.dll", Functions: []petest.ImportedFunction{{Ordinal: 1}}},
+	})
+	if err := ResolveImports(buf, base, img, r); !errors.Is(err, ErrModuleNotFound) {
+		t.Errorf("missing module: got %v, want ErrModuleNotFound", err)
+	}
+
+	// An image without imports has nothing to resolve.
+	img, buf = layoutDLL(t, petest.New(true), base)
+	if err := ResolveImports(buf, base, img, r); err != nil {
+		t.Errorf("no imports: %v", err)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	buf := &Buffer{Base: 0x10000, Data: make([]byte, 0x10)}
+
+	if err := buf.WriteMemory(0x1000C, []byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]byte, 2)
+	if err := buf.ReadMemory(0x1000D, got); err != nil || got[0] != 2 || got[1] != 3 {
+		t.Errorf("got % x, %v", got, err)
+	}
+
+	tests := []struct {
+		name string
+		addr uintptr
+		n    int
+	}{
+		{"before the image", 0xFFFF, 2},
+		{"past the end", 0x1000F, 2},
+		{"after the end", 0x10011, 0},
+	}
+
+	for _, tc := range tests {
+		if err := buf.ReadMemory(tc.addr, make([]byte, tc.n)); err == nil {
+			t.Errorf("read %s: no error", tc.name)
+		}
+		if err := buf.WriteMemory(tc.addr, make([]byte, tc.n)); err == nil {
+			t.Errorf("write %s: no error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package inject
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/remote"
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	MappedModule is a DLL mapped into a process by MapLibrary.
+*/
+type MappedModule struct {
+	Base       uintptr
+	Size       uint32
+	EntryPoint uintptr
+}
+
+/*
+	sectionProtection returns the page protection for the characteristics of a section.
+*/
+func sectionProtection(characteristics uint32) kernel32.PageAccess {
+	exec := characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0
+	read := characteristics&pe.IMAGE_SCN_MEM_READ != 0
+	write := characteristics&pe.IMAGE_SCN_MEM_WRITE != 0
+
+	var protect kernel32.PageAccess
+	switch {
+	case exec && write:
+		protect = kernel32.PAGE_EXECUTE_READWRITE
+	case exec && read:
+		protect = kernel32.PAGE_EXECUTE_READ
+	case exec:
+		protect = kernel32.PAGE_EXECUTE
+	case write:
+		protect = kernel32.PAGE_READWRITE
+	case read:
+		protect = kernel32.PAGE_READONLY
+	default:
+		protect = kernel32.PAGE_NOACCESS
+	}
+
+	if characteristics&pe.IMAGE_SCN_MEM_NOT_CACHED != 0 {
+		protect |= kernel32.PAGE_NOCACHE
+	}
+
+	return protect
+}
+
+/*
+	MapLibrary maps the DLL file in data into the process behind handle,
+	identified by pid, without the OS loader: the image is laid out,
+	relocated and linked against the modules loaded in the target, then copied
+	into it. Sections get their own protections, the x64 exception table is
+	registered, and the TLS callbacks and the entry point are called with
+	DLL_PROCESS_ATTACH on remote threads.
+
+	The module is not registered with the loader: it is not listed among the
+	modules of the process, GetModuleHandle does not find it and its static TLS
+	data is not allocated. The modules it imports from must already be loaded.
+
+	The handle must have the PROCESS_CREATE_THREAD, PROCESS_QUERY_INFORMATION,
+	PROCESS_VM_OPERATION, PROCESS_VM_WRITE and PROCESS_VM_READ access rights.
+*/
+func MapLibrary(handle win32.Handle, pid uint32, data []byte) (*MappedModule, error) {
+	r, err := NewProcessResolver(handle, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := ParseDLL(data, r.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := Layout(img)
+	if err != nil {
+		return nil, err
+	}
+
+	size := uintptr(len(buf))
+	alloc := kernel32.MEM_COMMIT | kernel32.MEM_RESERVE
+
+	// The preferred base saves relocating, any address will do otherwise.
+	base, err := kernel32.VirtualAllocEx(handle, uintptr(img.OptionalHeader.ImageBase), size, alloc, kernel32.PAGE_READWRITE)
+	if err != nil {
+		if base, err = kernel32.VirtualAllocEx(handle, 0, size, alloc, kernel32.PAGE_READWRITE); err != nil {
+			return nil, fmt.Errorf("inject: allocating image: %w", err)
+		}
+	}
+
+	m := &MappedModule{
+		Base: base,
+		Size: uint32(size),
+	}
+	if img.OptionalHeader.AddressOfEntryPoint != 0 {
+		m.EntryPoint = base + uintptr(img.OptionalHeader.AddressOfEntryPoint)
+	}
+
+	if err := m.load(handle, img, &Buffer{Base: base, Data: buf}, r); err != nil {
+		kernel32.VirtualFreeEx(handle, base, 0, kernel32.MEM_RELEASE)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *MappedModule) load(handle win32.Handle, img *pe.Image, buf *Buffer, r *Resolver) error {
+	if err := Relocate(buf, m.Base, img); err != nil {
+		return err
+	}
+
+	if err := ResolveImports(buf, m.Base, img, r); err != nil {
+		return err
+	}
+
+	callbacks, err := TLSCallbacks(buf, m.Base, m.Size)
+	if err != nil {
+		return err
+	}
+
+	if err := (process.Remote{Handle: handle}).WriteMemory(m.Base, buf.Data); err != nil {
+		return fmt.Errorf("inject: writing image: %w", err)
+	}
+
+	if err := m.protect(handle, img); err != nil {
+		return err
+	}
+
+	if err := kernel32.FlushInstructionCache(handle, m.Base, uintptr(m.Size)); err != nil {
+		return fmt.Errorf("inject: %w", err)
+	}
+
+	if r.mode == x86.Mode64 {
+		if err := m.addFunctionTable(handle, img, r); err != nil {
+			return err
+		}
+	}
+
+	for _, cb := range callbacks {
+		if _, err := remote.CallRemoteMode(handle, r.mode, cb, uint64(m.Base), DLL_PROCESS_ATTACH, 0); err != nil {
+			return fmt.Errorf("inject: TLS callback %#x: %w", cb, err)
+		}
+	}
+
+	if m.EntryPoint == 0 {
+		return nil
+	}
+
+	ok, err := remote.CallRemoteMode(handle, r.mode, m.EntryPoint, uint64(m.Base), DLL_PROCESS_ATTACH, 0)
+	if err != nil {
+		return fmt.Errorf("inject: calling entry point: %w", err)
+	}
+
+	// DllMain returns a BOOL, only the low 32 bits are meaningful.
+	if uint32(ok) == 0 {
+		return errors.New("inject: DllMain failed to initialize the module")
+	}
+
+	return nil
+}
+
+/*
+	protect applies the protection of each section, the headers are read-only.
+*/
+func (m *MappedModule) protect(handle win32.Handle, img *pe.Image) error {
+	if _, err := kernel32.VirtualProtectEx(handle, m.Base, uintptr(img.OptionalHeader.SizeOfHeaders), kernel32.PAGE_READONLY); err != nil {
+		return fmt.Errorf("inject: protecting headers: %w", err)
+	}
+
+	align := img.OptionalHeader.SectionAlignment
+	for _, s := range img.Sections {
+		size := s.VirtualSize
+		if size == 0 {
+			size = s.SizeOfRawData
+		}
+
+		if align != 0 {
+			size = (size + align - 1) &^ (align - 1)
+		}
+
+		if size == 0 {
+			continue
+		}
+
+		if _, err := kernel32.VirtualProtectEx(handle, m.Base+uintptr(s.VirtualAddress), uintptr(size), sectionProtection(s.Characteristics)); err != nil {
+			return fmt.Errorf("inject: protecting section %s: %w", s.Name, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+	addFunctionTable registers the exception directory of an x64 image
+	with RtlAddFunctionTable, so exceptions can be unwound through its code.
+*/
+func (m *MappedModule) addFunctionTable(handle win32.Handle, img *pe.Image, r *Resolver) error {
+	dd, err := img.Directory(pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION)
+	if errors.Is(err, pe.ErrNoDirectory) {
+		return nil
+	}
+
+	fn, err := r.Proc("ntdll.dll", "RtlAddFunctionTable")
+	if err != nil {
+		return err
+	}
+
+	ok, err := remote.CallRemoteMode(handle, x86.Mode64, fn, uint64(m.Base)+uint64(dd.VirtualAddress), uint64(dd.Size/12), uint64(m.Base))
+	if err != nil {
+		return fmt.Errorf("inject: registering function table: %w", err)
+	}
+
+	if uint8(ok) == 0 {
+		return errors.New("inject: RtlAddFunctionTable failed")
+	}
+
+	return nil
+}
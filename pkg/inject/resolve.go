@@ -0,0 +1,211 @@
+/*
+	Package inject loads DLLs into other processes.
+
+	LoadLibrary injection runs LoadLibraryW on a remote thread, while manual
+	mapping copies and links the image itself, without the OS loader.
+	Addresses are resolved from the export tables of the modules loaded in
+	the target, read through process.Reader, so resolution works against a
+	fake address space as well as against another process.
+*/
+package inject
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	maxForwards bounds the chain of forwarded exports followed by the Resolver.
+*/
+const maxForwards = 16
+
+var (
+	ErrModuleNotFound = errors.New("inject: module not loaded")
+	ErrProcNotFound   = errors.New("inject: procedure not found")
+)
+
+/*
+	Resolver resolves exported functions of the modules loaded in a process.
+
+	Only modules matching the instruction set of the process are considered,
+	so the 32-bit kernel32 of a WOW64 process is found rather than the 64-bit
+	system modules that toolhelp also reports for it.
+*/
+type Resolver struct {
+	/*
+		APISet maps an API set name, such as "api-ms-win-core-synch-l1-2-0",
+		to the module implementing it. It is consulted for forwarders and
+		imports naming a module that is not loaded. If nil, DefaultAPISet is used.
+	*/
+	APISet func(name string) (string, bool)
+
+	mem     process.Reader
+	modules []process.Module
+	mode    x86.Mode
+	exports map[uintptr]*pe.ExportDirectory
+}
+
+/*
+	NewResolver creates a Resolver for a process of the given mode
+	whose memory is read through r and whose loaded modules are modules.
+*/
+func NewResolver(r process.Reader, modules []process.Module, mode x86.Mode) *Resolver {
+	return &Resolver{
+		mem:     r,
+		modules: modules,
+		mode:    mode,
+		exports: make(map[uintptr]*pe.ExportDirectory),
+	}
+}
+
+/*
+	DefaultAPISet approximates the API set schema of current Windows versions:
+	the CRT sets are implemented by ucrtbase.dll and the other core sets by kernelbase.dll.
+*/
+func DefaultAPISet(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(name, "api-ms-win-crt-"):
+		return "ucrtbase.dll", true
+	case strings.HasPrefix(name, "api-ms-win-core-"):
+		return "kernelbase.dll", true
+	}
+
+	return "", false
+}
+
+/*
+	Module returns the loaded module called name, matched case-insensitively
+	and with or without its extension. API set names are redirected to the
+	module implementing them.
+*/
+func (r *Resolver) Module(name string) (process.Module, error) {
+	if m, ok := r.find(name); ok {
+		return m, nil
+	}
+
+	apiSet := r.APISet
+	if apiSet == nil {
+		apiSet = DefaultAPISet
+	}
+
+	if host, ok := apiSet(strings.TrimSuffix(name, filepath.Ext(name))); ok {
+		if m, ok := r.find(host); ok {
+			return m, nil
+		}
+	}
+
+	return process.Module{}, fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+}
+
+func (r *Resolver) find(name string) (process.Module, bool) {
+	if filepath.Ext(name) == "" {
+		name += ".dll"
+	}
+
+	for _, m := range r.modules {
+		if !strings.EqualFold(m.Name, name) {
+			continue
+		}
+
+		img, err := process.OpenModule(r.mem, m)
+		if err == nil && img.Is64() == (r.mode == x86.Mode64) {
+			return m, true
+		}
+	}
+
+	return process.Module{}, false
+}
+
+func (r *Resolver) exportsOf(m process.Module) (*pe.ExportDirectory, error) {
+	if ed, ok := r.exports[m.Base]; ok {
+		return ed, nil
+	}
+
+	img, err := process.OpenModule(r.mem, m)
+	if err != nil {
+		return nil, fmt.Errorf("inject: %s: %w", m.Name, err)
+	}
+
+	ed, err := img.Exports()
+	if err != nil {
+		return nil, fmt.Errorf("inject: %s: %w", m.Name, err)
+	}
+
+	r.exports[m.Base] = ed
+	return ed, nil
+}
+
+/*
+	Proc returns the address of the function exported by module as name.
+	Forwarded exports are followed to the module implementing them.
+*/
+func (r *Resolver) Proc(module, name string) (uintptr, error) {
+	return r.resolve(module, name, 0, 0)
+}
+
+/*
+	ProcOrdinal returns the address of the function exported by module with the given biased ordinal.
+*/
+func (r *Resolver) ProcOrdinal(module string, ordinal uint16) (uintptr, error) {
+	return r.resolve(module, "", ordinal, 0)
+}
+
+func (r *Resolver) resolve(module, name string, ordinal uint16, depth int) (uintptr, error) {
+	if depth > maxForwards {
+		return 0, fmt.Errorf("inject: forwarder chain of %s!%s is too long", module, name)
+	}
+
+	m, err := r.Module(module)
+	if err != nil {
+		return 0, err
+	}
+
+	ed, err := r.exportsOf(m)
+	if err != nil {
+		return 0, err
+	}
+
+	var exp pe.Export
+	var ok bool
+	if name != "" {
+		exp, ok = ed.Lookup(name)
+	} else {
+		exp, ok = ed.LookupOrdinal(ordinal)
+	}
+
+	if !ok {
+		if name == "" {
+			name = "#" + strconv.Itoa(int(ordinal))
+		}
+		return 0, fmt.Errorf("%w: %s!%s", ErrProcNotFound, m.Name, name)
+	}
+
+	if exp.Forwarder == "" {
+		return m.Base + uintptr(exp.RVA), nil
+	}
+
+	// Forwarders name the module without extension: "NTDLL.RtlAllocateHeap".
+	i := strings.LastIndex(exp.Forwarder, ".")
+	if i <= 0 {
+		return 0, fmt.Errorf("inject: invalid forwarder %q in %s", exp.Forwarder, m.Name)
+	}
+
+	fwdModule, fwdName := exp.Forwarder[:i], exp.Forwarder[i+1:]
+	if strings.HasPrefix(fwdName, "#") {
+		n, err := strconv.ParseUint(fwdName[1:], 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("inject: invalid forwarder %q in %s", exp.Forwarder, m.Name)
+		}
+		return r.resolve(fwdModule, "", uint16(n), depth+1)
+	}
+
+	return r.resolve(fwdModule, fwdName, 0, depth+1)
+}
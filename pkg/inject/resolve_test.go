@@ -0,0 +1,226 @@
+package inject
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/process/processtest"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+const (
+	ntdllBase      = 0x7A000000
+	kernelbaseBase = 0x79000000
+	kernel32Base   = 0x78000000
+	wow64Base      = 0x76000000
+)
+
+/*
+	mapDLL builds a DLL exporting exports with ordinal base 1 and maps it at base.
+*/
+func mapDLL(mem *processtest.Memory, is64 bool, name string, base uintptr, exports []petest.Export) process.Module {
+	b := petest.New(is64)
+	b.AddSection(".text", make([]byte, 0x200), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+
+	rva := b.NextRVA()
+	dir := petest.ExportDirectory(rva, name, 1, exports)
+	b.AddSection(".edata", dir, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT, rva, len(dir))
+
+	img := mem.Map(base, b.Bytes())
+	return process.Module{Name: name, Path: `C:\Windows\System32\` + name, Base: base, Size: uint32(len(img))}
+}
+
+/*
+	newFixture loads ntdll, kernelbase and kernel32 in a 64-bit process along
+	with the 32-bit kernel32 that toolhelp also reports for WOW64 processes.
+*/
+func newFixture() (*processtest.Memory, []process.Module) {
+	mem := processtest.NewMemory()
+
+	modules := []process.Module{
+		mapDLL(mem, true, "ntdll.dll", ntdllBase, []petest.Export{
+			{Names: []string{"RtlAllocateHeap"}, RVA: 0x1010},
+			{Names: []string{"NtClose"}, RVA: 0x1020},
+			{RVA: 0x1030},
+		}),
+		mapDLL(mem, true, "KernelBase.dll", kernelbaseBase, []petest.Export{
+			{Names: []string{"Sleep"}, RVA: 0x1040},
+			{Names: []string{"Loop"}, Forwarder: "KERNEL32.Loop"},
+		}),
+		mapDLL(mem, true, "KERNEL32.DLL", kernel32Base, []petest.Export{
+			{Names: []string{"GetProcAddress"}, RVA: 0x1000},
+			{Names: []string{"HeapAlloc"}, Forwarder: "NTDLL.RtlAllocateHeap"},
+			{Names: []string{"Sleep"}, Forwarder: "api-ms-win-core-synch-l1-2-0.Sleep"},
+			{Names: []string{"ByOrdinal"}, Forwarder: "NTDLL.#3"},
+			{Names: []string{"Chain"}, Forwarder: "KERNEL32.HeapAlloc"},
+			{Names: []string{"Loop"}, Forwarder: "KERNELBASE.Loop"},
+			{Names: []string{"NoModule"}, Forwarder: "NOPE"},
+			{Names: []string{"BadOrdinal"}, Forwarder: "NTDLL.#x"},
+			{Names: []string{"Missing"}, Forwarder: "NTDLL.NtMissing"},
+			{Names: []string{"Unloaded"}, Forwarder: "USER32.MessageBoxW"},
+		}),
+		mapDLL(mem, false, "kernel32.dll", wow64Base, []petest.Export{
+			{Names: []string{"GetProcAddress"}, RVA: 0x1100},
+		}),
+	}
+
+	return mem, modules
+}
+
+func TestResolverProc(t *testing.T) {
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode64)
+
+	tests := []struct {
+		module, name string
+		want         uintptr
+	}{
+		{"kernel32.dll", "GetProcAddress", kernel32Base + 0x1000},
+		{"Kernel32", "GetProcAddress", kernel32Base + 0x1000},
+		{"ntdll", "NtClose", ntdllBase + 0x1020},
+		{"kernel32", "HeapAlloc", ntdllBase + 0x1010},
+		{"kernel32", "ByOrdinal", ntdllBase + 0x1030},
+		{"kernel32", "Chain", ntdllBase + 0x1010},
+		{"kernel32", "Sleep", kernelbaseBase + 0x1040},
+		{"api-ms-win-core-synch-l1-2-0.dll", "Sleep", kernelbaseBase + 0x1040},
+		{"API-MS-WIN-CORE-SYNCH-L1-2-0", "Sleep", kernelbaseBase + 0x1040},
+	}
+
+	for _, tc := range tests {
+		got, err := r.Proc(tc.module, tc.name)
+		if err != nil || got != tc.want {
+			t.Errorf("%s!%s: got %#x, %v; want %#x", tc.module, tc.name, got, err, tc.want)
+		}
+	}
+
+	got, err := r.ProcOrdinal("ntdll", 3)
+	if err != nil || got != ntdllBase+0x1030 {
+		t.Errorf("ntdll!#3: got %#x, %v", got, err)
+	}
+
+	got, err = r.ProcOrdinal("ntdll", 1)
+	if err != nil || got != ntdllBase+0x1010 {
+		t.Errorf("ntdll!#1: got %#x, %v", got, err)
+	}
+}
+
+func TestResolverWOW64(t *testing.T) {
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode32)
+
+	got, err := r.Proc("kernel32", "GetProcAddress")
+	if err != nil || got != wow64Base+0x1100 {
+		t.Errorf("kernel32!GetProcAddress: got %#x, %v; want the 32-bit module", got, err)
+	}
+
+	if _, err := r.Module("ntdll"); !errors.Is(err, ErrModuleNotFound) {
+		t.Errorf("64-bit ntdll in a 32-bit resolver: got %v, want ErrModuleNotFound", err)
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode64)
+
+	tests := []struct {
+		module, name string
+		want         error
+		text         string
+	}{
+		{"user32", "MessageBoxW", ErrModuleNotFound, "user32"},
+		{"api-ms-win-core-missing-l1-1-0", "F", ErrProcNotFound, "KernelBase.dll!F"},
+		{"ntdll", "NtMissing", ErrProcNotFound, "ntdll.dll!NtMissing"},
+		{"kernel32", "Missing", ErrProcNotFound, "ntdll.dll!NtMissing"},
+		{"kernel32", "Unloaded", ErrModuleNotFound, "USER32"},
+		{"kernel32", "NoModule", nil, `invalid forwarder "NOPE"`},
+		{"kernel32", "BadOrdinal", nil, `invalid forwarder "NTDLL.#x"`},
+		{"kernel32", "Loop", nil, "too long"},
+	}
+
+	for _, tc := range tests {
+		_, err := r.Proc(tc.module, tc.name)
+		if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) || !strings.Contains(err.Error(), tc.text) {
+			t.Errorf("%s!%s: got %v, want %v mentioning %q", tc.module, tc.name, err, tc.want, tc.text)
+		}
+	}
+
+	if _, err := r.ProcOrdinal("ntdll", 9); !errors.Is(err, ErrProcNotFound) || !strings.Contains(err.Error(), "#9") {
+		t.Errorf("ntdll!#9: got %v", err)
+	}
+}
+
+func TestResolverAPISet(t *testing.T) {
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode64)
+
+	var queried []string
+	r.APISet = func(name string) (string, bool) {
+		queried = append(queried, name)
+		if name == "ext-ms-win-test-l1-1-0" {
+			return "ntdll.dll", true
+		}
+		return "", false
+	}
+
+	got, err := r.Proc("ext-ms-win-test-l1-1-0.dll", "NtClose")
+	if err != nil || got != ntdllBase+0x1020 {
+		t.Errorf("got %#x, %v", got, err)
+	}
+
+	// The custom schema replaces the default one.
+	if _, err := r.Proc("kernel32", "Sleep"); !errors.Is(err, ErrModuleNotFound) {
+		t.Errorf("kernel32!Sleep: got %v, want ErrModuleNotFound", err)
+	}
+
+	want := []string{"ext-ms-win-test-l1-1-0", "api-ms-win-core-synch-l1-2-0"}
+	if len(queried) != len(want) || queried[0] != want[0] || queried[1] != want[1] {
+		t.Errorf("queried %q, want %q", queried, want)
+	}
+}
+
+func TestDefaultAPISet(t *testing.T) {
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"api-ms-win-core-synch-l1-2-0", "kernelbase.dll", true},
+		{"API-MS-WIN-CORE-PROCESSTHREADS-L1-1-3", "kernelbase.dll", true},
+		{"api-ms-win-crt-runtime-l1-1-0", "ucrtbase.dll", true},
+		{"ext-ms-win-ntuser-window-l1-1-0", "", false},
+		{"kernel32", "", false},
+	}
+
+	for _, tc := range tests {
+		got, ok := DefaultAPISet(tc.name)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %q, %v; want %q, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestResolverCachesExports(t *testing.T) {
+	mem, modules := newFixture()
+	r := NewResolver(mem, modules, x86.Mode64)
+
+	if _, err := r.Proc("ntdll", "NtClose"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The export directory in the second section is not read again once parsed.
+	edata, err := mem.Slice(ntdllBase+0x2000, 0x1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range edata {
+		edata[i] = 0
+	}
+
+	if got, err := r.Proc("ntdll", "NtClose"); err != nil || got != ntdllBase+0x1020 {
+		t.Errorf("got %#x, %v", got, err)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+	Package job contains processes in Windows job objects: it limits their memory,
+	process count, CPU rate and user interface access, kills them together,
+	accounts for their resource usage and reports job notifications on a channel.
+*/
+package job
+
+import "strconv"
+
+/*
+	Message is a job notification message posted to the completion port of a job.
+*/
+type Message uint32
+
+const (
+	JOB_OBJECT_MSG_END_OF_JOB_TIME       Message = 1
+	JOB_OBJECT_MSG_END_OF_PROCESS_TIME   Message = 2
+	JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT  Message = 3
+	JOB_OBJECT_MSG_ACTIVE_PROCESS_ZERO   Message = 4
+	JOB_OBJECT_MSG_NEW_PROCESS           Message = 6
+	JOB_OBJECT_MSG_EXIT_PROCESS          Message = 7
+	JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS Message = 8
+	JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT  Message = 9
+	JOB_OBJECT_MSG_JOB_MEMORY_LIMIT      Message = 10
+	JOB_OBJECT_MSG_NOTIFICATION_LIMIT    Message = 11
+	JOB_OBJECT_MSG_JOB_CYCLE_TIME_LIMIT  Message = 12
+	JOB_OBJECT_MSG_SILO_TERMINATED       Message = 13
+)
+
+func (m Message) String() string {
+	switch m {
+	case JOB_OBJECT_MSG_END_OF_JOB_TIME:
+		return "JOB_OBJECT_MSG_END_OF_JOB_TIME"
+	case JOB_OBJECT_MSG_END_OF_PROCESS_TIME:
+		return "JOB_OBJECT_MSG_END_OF_PROCESS_TIME"
+	case JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT:
+		return "JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT"
+	case JOB_OBJECT_MSG_ACTIVE_PROCESS_ZERO:
+		return "JOB_OBJECT_MSG_ACTIVE_PROCESS_ZERO"
+	case JOB_OBJECT_MSG_NEW_PROCESS:
+		return "JOB_OBJECT_MSG_NEW_PROCESS"
+	case JOB_OBJECT_MSG_EXIT_PROCESS:
+		return "JOB_OBJECT_MSG_EXIT_PROCESS"
+	case JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS:
+		return "JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS"
+	case JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT:
+		return "JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT"
+	case JOB_OBJECT_MSG_JOB_MEMORY_LIMIT:
+		return "JOB_OBJECT_MSG_JOB_MEMORY_LIMIT"
+	case JOB_OBJECT_MSG_NOTIFICATION_LIMIT:
+		return "JOB_OBJECT_MSG_NOTIFICATION_LIMIT"
+	case JOB_OBJECT_MSG_JOB_CYCLE_TIME_LIMIT:
+		return "JOB_OBJECT_MSG_JOB_CYCLE_TIME_LIMIT"
+	case JOB_OBJECT_MSG_SILO_TERMINATED:
+		return "JOB_OBJECT_MSG_SILO_TERMINATED"
+	}
+
+	return "Message(" + strconv.Itoa(int(m)) + ")"
+}
+
+/*
+	HasProcess reports whether notifications with the message are about a single process,
+	whose identifier is then in the ProcessId of the Notification.
+*/
+func (m Message) HasProcess() bool {
+	switch m {
+	case JOB_OBJECT_MSG_END_OF_PROCESS_TIME, JOB_OBJECT_MSG_NEW_PROCESS, JOB_OBJECT_MSG_EXIT_PROCESS,
+		JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS, JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT, JOB_OBJECT_MSG_JOB_MEMORY_LIMIT,
+		JOB_OBJECT_MSG_NOTIFICATION_LIMIT:
+		return true
+	}
+
+	return false
+}
+
+/*
+	Notification is a notification about a job or one of its processes.
+*/
+type Notification struct {
+	Message Message
+
+	/*
+		ProcessId is the process the notification is about, if Message.HasProcess,
+		and 0 otherwise.
+	*/
+	ProcessId uint32
+}
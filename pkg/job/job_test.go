@@ -0,0 +1,59 @@
+package job
+
+import "testing"
+
+func TestMessageString(t *testing.T) {
+	tests := []struct {
+		m    Message
+		want string
+	}{
+		{JOB_OBJECT_MSG_END_OF_JOB_TIME, "JOB_OBJECT_MSG_END_OF_JOB_TIME"},
+		{JOB_OBJECT_MSG_END_OF_PROCESS_TIME, "JOB_OBJECT_MSG_END_OF_PROCESS_TIME"},
+		{JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT, "JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT"},
+		{JOB_OBJECT_MSG_ACTIVE_PROCESS_ZERO, "JOB_OBJECT_MSG_ACTIVE_PROCESS_ZERO"},
+		{JOB_OBJECT_MSG_NEW_PROCESS, "JOB_OBJECT_MSG_NEW_PROCESS"},
+		{JOB_OBJECT_MSG_EXIT_PROCESS, "JOB_OBJECT_MSG_EXIT_PROCESS"},
+		{JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS, "JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS"},
+		{JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT, "JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT"},
+		{JOB_OBJECT_MSG_JOB_MEMORY_LIMIT, "JOB_OBJECT_MSG_JOB_MEMORY_LIMIT"},
+		{JOB_OBJECT_MSG_NOTIFICATION_LIMIT, "JOB_OBJECT_MSG_NOTIFICATION_LIMIT"},
+		{JOB_OBJECT_MSG_JOB_CYCLE_TIME_LIMIT, "JOB_OBJECT_MSG_JOB_CYCLE_TIME_LIMIT"},
+		{JOB_OBJECT_MSG_SILO_TERMINATED, "JOB_OBJECT_MSG_SILO_TERMINATED"},
+		{0, "Message(0)"},
+		{5, "Message(5)"},
+		{14, "Message(14)"},
+	}
+
+	for _, tc := range tests {
+		if got := tc.m.String(); got != tc.want {
+			t.Errorf("Message(%d).String() = %q, want %q", uint32(tc.m), got, tc.want)
+		}
+	}
+}
+
+func TestMessageHasProcess(t *testing.T) {
+	tests := []struct {
+		m    Message
+		want bool
+	}{
+		{JOB_OBJECT_MSG_END_OF_JOB_TIME, false},
+		{JOB_OBJECT_MSG_END_OF_PROCESS_TIME, true},
+		{JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT, false},
+		{JOB_OBJECT_MSG_ACTIVE_PROCESS_ZERO, false},
+		{JOB_OBJECT_MSG_NEW_PROCESS, true},
+		{JOB_OBJECT_MSG_EXIT_PROCESS, true},
+		{JOB_OBJECT_MSG_ABNORMAL_EXIT_PROCESS, true},
+		{JOB_OBJECT_MSG_PROCESS_MEMORY_LIMIT, true},
+		{JOB_OBJECT_MSG_JOB_MEMORY_LIMIT, true},
+		{JOB_OBJECT_MSG_NOTIFICATION_LIMIT, true},
+		{JOB_OBJECT_MSG_JOB_CYCLE_TIME_LIMIT, false},
+		{JOB_OBJECT_MSG_SILO_TERMINATED, false},
+		{0, false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.m.HasProcess(); got != tc.want {
+			t.Errorf("%v.HasProcess() = %v, want %v", tc.m, got, tc.want)
+		}
+	}
+}
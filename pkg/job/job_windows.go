@@ -0,0 +1,257 @@
+package job
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	stopKey is the completion key of the packet that wakes Notify up when its context is done.
+	Job notifications are posted with the job handle as their key, which is never stopKey.
+*/
+const stopKey = ^uintptr(0)
+
+/*
+	Job is a job object with a completion port for its notifications.
+*/
+type Job struct {
+	Handle win32.Handle
+	port   win32.Handle
+
+	/*
+		pending is a notification read from the port that Notify could not send
+		before its context was done.
+	*/
+	pending *Notification
+}
+
+/*
+	New creates a job object, named name unless it is empty, and associates it with
+	a new completion port so that its notifications can be received with Notify.
+*/
+func New(name string) (*Job, error) {
+	handle, err := kernel32.CreateJobObject(name)
+	if err != nil {
+		return nil, fmt.Errorf("job: creating job object: %w", err)
+	}
+
+	port, err := kernel32.CreateIoCompletionPort(kernel32.INVALID_HANDLE_VALUE, 0, 0, 1)
+	if err != nil {
+		kernel32.CloseHandle(handle)
+		return nil, fmt.Errorf("job: creating completion port: %w", err)
+	}
+
+	if err := kernel32.SetJobObjectAssociateCompletionPort(handle, port, uintptr(handle)); err != nil {
+		kernel32.CloseHandle(port)
+		kernel32.CloseHandle(handle)
+		return nil, fmt.Errorf("job: associating completion port: %w", err)
+	}
+
+	return &Job{Handle: handle, port: port}, nil
+}
+
+/*
+	Assign adds the process behind handle to the job. Processes it creates afterwards
+	are added to the job as well.
+
+	The handle must have the PROCESS_SET_QUOTA and PROCESS_TERMINATE access rights.
+*/
+func (j *Job) Assign(process win32.Handle) error {
+	if err := kernel32.AssignProcessToJobObject(j.Handle, process); err != nil {
+		return fmt.Errorf("job: %w", err)
+	}
+
+	return nil
+}
+
+/*
+	Limits are the restrictions applied to the processes of a job.
+	Zero values leave the corresponding limit unset.
+*/
+type Limits struct {
+	/*
+		KillOnClose terminates the processes of the job when the job is closed,
+		including when the process owning the Job exits.
+	*/
+	KillOnClose bool
+
+	/*
+		ProcessMemory limits the memory each process can commit, in bytes.
+	*/
+	ProcessMemory uintptr
+
+	/*
+		JobMemory limits the memory all processes of the job can commit together, in bytes.
+	*/
+	JobMemory uintptr
+
+	/*
+		ActiveProcesses limits the number of processes in the job. Creating
+		more fails and posts JOB_OBJECT_MSG_ACTIVE_PROCESS_LIMIT.
+	*/
+	ActiveProcesses uint32
+
+	/*
+		CPURate caps the processor time the job can use in hundredths of a percent
+		of the processors of the system, from 1 to 10000.
+	*/
+	CPURate uint32
+
+	UIRestrictions kernel32.JobObjectUILimit
+}
+
+/*
+	SetLimits replaces the memory, process count and kill on close limits of the job with
+	those of l, and sets its CPU rate and user interface restrictions when they are not zero.
+*/
+func (j *Job) SetLimits(l Limits) error {
+	var info kernel32.JobExtendedLimitInformation
+
+	basic := &info.BasicLimitInformation
+	if l.KillOnClose {
+		basic.LimitFlags |= kernel32.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	}
+
+	if l.ProcessMemory != 0 {
+		basic.LimitFlags |= kernel32.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = l.ProcessMemory
+	}
+
+	if l.JobMemory != 0 {
+		basic.LimitFlags |= kernel32.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = l.JobMemory
+	}
+
+	if l.ActiveProcesses != 0 {
+		basic.LimitFlags |= kernel32.JOB_OBJECT_LIMIT_ACTIVE_PROCESS
+		basic.ActiveProcessLimit = l.ActiveProcesses
+	}
+
+	if err := kernel32.SetJobObjectExtendedLimitInformation(j.Handle, &info); err != nil {
+		return fmt.Errorf("job: setting limits: %w", err)
+	}
+
+	if l.CPURate != 0 {
+		rate := kernel32.JobCpuRateControlInformation{
+			ControlFlags: kernel32.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE | kernel32.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP,
+			Rate:         l.CPURate,
+		}
+
+		if err := kernel32.SetJobObjectCpuRateControlInformation(j.Handle, &rate); err != nil {
+			return fmt.Errorf("job: setting CPU rate: %w", err)
+		}
+	}
+
+	if l.UIRestrictions != 0 {
+		if err := kernel32.SetJobObjectBasicUIRestrictions(j.Handle, l.UIRestrictions); err != nil {
+			return fmt.Errorf("job: setting UI restrictions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Accounting returns the CPU time and I/O used by all processes that ran in the job.
+*/
+func (j *Job) Accounting() (*kernel32.JobBasicAndIoAccountingInformation, error) {
+	info, err := kernel32.QueryJobObjectAccountingInformation(j.Handle)
+	if err != nil {
+		return nil, fmt.Errorf("job: %w", err)
+	}
+
+	return info, nil
+}
+
+/*
+	ProcessIds returns the identifiers of the active processes of the job.
+*/
+func (j *Job) ProcessIds() ([]uint32, error) {
+	ids, err := kernel32.QueryJobObjectProcessIds(j.Handle)
+	if err != nil {
+		return nil, fmt.Errorf("job: %w", err)
+	}
+
+	return ids, nil
+}
+
+/*
+	Terminate terminates all processes of the job with the given exit code.
+*/
+func (j *Job) Terminate(exitCode uint32) error {
+	if err := kernel32.TerminateJobObject(j.Handle, exitCode); err != nil {
+		return fmt.Errorf("job: %w", err)
+	}
+
+	return nil
+}
+
+/*
+	Notify sends the notifications of the job to notifications until ctx is done,
+	and then returns ctx.Err(). Notifications posted while Notify is not running
+	are queued and delivered by the next call, as is a notification that was
+	already read from the port when ctx was done. Only one Notify may run at a time.
+*/
+func (j *Job) Notify(ctx context.Context, notifications chan<- Notification) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			kernel32.PostQueuedCompletionStatus(j.port, 0, stopKey, 0)
+		case <-stop:
+		}
+	}()
+
+	for {
+		if j.pending == nil {
+			packet, err := kernel32.GetQueuedCompletionStatus(j.port, kernel32.INFINITE)
+			if err != nil {
+				return fmt.Errorf("job: reading notifications: %w", err)
+			}
+
+			if packet.CompletionKey == stopKey {
+				// A stop packet can be left over from an earlier call that returned for
+				// another reason just as its context was done.
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			n := Notification{Message: Message(packet.BytesTransferred)}
+			if n.Message.HasProcess() {
+				n.ProcessId = uint32(packet.Overlapped)
+			}
+			j.pending = &n
+		}
+
+		select {
+		case notifications <- *j.pending:
+			j.pending = nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+/*
+	Close closes the job and its completion port. With KillOnClose set and no
+	other handles to the job open, this terminates its processes.
+*/
+func (j *Job) Close() error {
+	err := kernel32.CloseHandle(j.Handle)
+	if perr := kernel32.CloseHandle(j.port); err == nil {
+		err = perr
+	}
+
+	if err != nil {
+		return fmt.Errorf("job: %w", err)
+	}
+
+	return nil
+}
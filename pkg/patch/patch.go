@@ -0,0 +1,235 @@
+/*
+	Package patch applies verified, reversible byte patches to the memory of a process.
+
+	Every patch carries the bytes it expects to replace, so patching a build
+	other than the one a patch was written for fails instead of corrupting it.
+	Applied patches are recorded in an undo log and reverted in reverse order.
+*/
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+)
+
+var (
+	ErrOriginalMismatch = errors.New("patch: original bytes do not match")
+	ErrLengthMismatch   = errors.New("patch: original and replacement lengths differ")
+	ErrOverlap          = errors.New("patch: overlaps an applied patch")
+	ErrModified         = errors.New("patch: patched bytes were modified")
+)
+
+/*
+	Patch replaces Original with Replacement at Address.
+*/
+type Patch struct {
+	/*
+		Name identifies the patch in errors, it is optional.
+	*/
+	Name        string
+	Address     uintptr
+	Original    []byte
+	Replacement []byte
+}
+
+func (p Patch) String() string {
+	if p.Name != "" {
+		return fmt.Sprintf("%s at %#x", p.Name, p.Address)
+	}
+	return fmt.Sprintf("patch at %#x", p.Address)
+}
+
+func (p Patch) overlaps(q Patch) bool {
+	return p.Address < q.Address+uintptr(len(q.Original)) && q.Address < p.Address+uintptr(len(p.Original))
+}
+
+/*
+	MismatchError reports the bytes found where a patch expected its original bytes.
+*/
+type MismatchError struct {
+	Patch Patch
+	Found []byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("patch: %v: expected % X, found % X", e.Patch, e.Patch.Original, e.Found)
+}
+
+func (e *MismatchError) Unwrap() error {
+	return ErrOriginalMismatch
+}
+
+/*
+	Protector makes memory temporarily writable.
+*/
+type Protector interface {
+	/*
+		Unprotect makes size bytes at addr writable and returns a function
+		that restores the previous protection.
+	*/
+	Unprotect(addr uintptr, size int) (restore func() error, err error)
+}
+
+/*
+	Patcher applies patches and keeps an undo log of the applied ones.
+	It is not safe for concurrent use.
+*/
+type Patcher struct {
+	mem     process.Memory
+	protect Protector
+	applied []Patch
+}
+
+/*
+	New creates a Patcher writing through mem. If protect is nil, memory is
+	written without changing its protection. Code is written with
+	process.WriteCode, so the instruction cache is flushed when mem supports it.
+*/
+func New(mem process.Memory, protect Protector) *Patcher {
+	return &Patcher{mem: mem, protect: protect}
+}
+
+/*
+	Applied returns the undo log, in the order the patches were applied.
+*/
+func (p *Patcher) Applied() []Patch {
+	return append([]Patch(nil), p.applied...)
+}
+
+/*
+	Verify checks that the original bytes of patch are present.
+*/
+func (p *Patcher) Verify(patch Patch) error {
+	if len(patch.Original) != len(patch.Replacement) {
+		return fmt.Errorf("%w: %v", ErrLengthMismatch, patch)
+	}
+
+	cur := make([]byte, len(patch.Original))
+	if err := p.mem.ReadMemory(patch.Address, cur); err != nil {
+		return fmt.Errorf("patch: %v: %w", patch, err)
+	}
+
+	if !bytes.Equal(cur, patch.Original) {
+		return &MismatchError{Patch: patch, Found: cur}
+	}
+
+	return nil
+}
+
+/*
+	Apply verifies and writes patch, then records it in the undo log.
+*/
+func (p *Patcher) Apply(patch Patch) error {
+	for _, q := range p.applied {
+		if patch.overlaps(q) {
+			return fmt.Errorf("%w: %v and %v", ErrOverlap, patch, q)
+		}
+	}
+
+	if err := p.Verify(patch); err != nil {
+		return err
+	}
+
+	if err := p.write(patch.Address, patch.Replacement); err != nil {
+		return fmt.Errorf("patch: %v: %w", patch, err)
+	}
+
+	p.applied = append(p.applied, patch)
+	return nil
+}
+
+/*
+	ApplyAll verifies all patches before writing any of them, then applies them
+	in order. If a write fails, the patches applied by this call are reverted.
+*/
+func (p *Patcher) ApplyAll(patches []Patch) error {
+	for i, patch := range patches {
+		if err := p.Verify(patch); err != nil {
+			return err
+		}
+
+		for _, q := range patches[:i] {
+			if patch.overlaps(q) {
+				return fmt.Errorf("%w: %v and %v", ErrOverlap, patch, q)
+			}
+		}
+	}
+
+	mark := len(p.applied)
+	for _, patch := range patches {
+		if err := p.Apply(patch); err != nil {
+			p.revertTo(mark)
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Revert restores the original bytes of all applied patches, most recent first.
+	A patch that cannot be reverted, such as one whose bytes were modified
+	since it was applied, is left alone in the undo log and reported;
+	reverting continues with the others.
+*/
+func (p *Patcher) Revert() error {
+	return p.revertTo(0)
+}
+
+func (p *Patcher) revertTo(mark int) error {
+	var errs []error
+	for i := len(p.applied) - 1; i >= mark; i-- {
+		if err := p.revert(p.applied[i]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		p.applied = append(p.applied[:i], p.applied[i+1:]...)
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	return fmt.Errorf("%w (and %d more errors)", errs[0], len(errs)-1)
+}
+
+func (p *Patcher) revert(patch Patch) error {
+	cur := make([]byte, len(patch.Replacement))
+	if err := p.mem.ReadMemory(patch.Address, cur); err != nil {
+		return fmt.Errorf("patch: reverting %v: %w", patch, err)
+	}
+
+	if !bytes.Equal(cur, patch.Replacement) {
+		return fmt.Errorf("%w: %v", ErrModified, patch)
+	}
+
+	if err := p.write(patch.Address, patch.Original); err != nil {
+		return fmt.Errorf("patch: reverting %v: %w", patch, err)
+	}
+
+	return nil
+}
+
+func (p *Patcher) write(addr uintptr, b []byte) (err error) {
+	if p.protect != nil {
+		restore, perr := p.protect.Unprotect(addr, len(b))
+		if perr != nil {
+			return perr
+		}
+
+		defer func() {
+			if rerr := restore(); err == nil {
+				err = rerr
+			}
+		}()
+	}
+
+	return process.WriteCode(p.mem, addr, b)
+}
@@ -0,0 +1,196 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/process/processtest"
+)
+
+const patchBase = 0x400000
+
+var errProtect = errors.New("protect failed")
+
+/*
+	fakeProtector records the addresses it unprotects and fails for fail.
+*/
+type fakeProtector struct {
+	calls []uintptr
+	fail  uintptr
+}
+
+func (f *fakeProtector) Unprotect(addr uintptr, size int) (func() error, error) {
+	f.calls = append(f.calls, addr)
+	if addr == f.fail {
+		return nil, errProtect
+	}
+	return func() error { return nil }, nil
+}
+
+func newPatcher() (*Patcher, *fakeProtector, []byte) {
+	mem := processtest.NewMemory()
+	code := mem.Map(patchBase, []byte{0x74, 0x05, 0x90, 0x90, 0xC3, 0xCC, 0xCC, 0xCC})
+	f := &fakeProtector{}
+	return New(mem, f), f, code
+}
+
+var (
+	jeToJmp   = Patch{Name: "je", Address: patchBase, Original: []byte{0x74, 0x05}, Replacement: []byte{0xEB, 0x05}}
+	nopToInt3 = Patch{Name: "nop", Address: patchBase + 2, Original: []byte{0x90}, Replacement: []byte{0xCC}}
+	retToNop  = Patch{Name: "ret", Address: patchBase + 4, Original: []byte{0xC3}, Replacement: []byte{0x90}}
+)
+
+func TestApply(t *testing.T) {
+	p, _, code := newPatcher()
+
+	if err := p.Apply(jeToJmp); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []byte{0xEB, 0x05, 0x90}; !bytes.Equal(code[:3], want) {
+		t.Errorf("got % X, want % X", code[:3], want)
+	}
+
+	if got := p.Applied(); !reflect.DeepEqual(got, []Patch{jeToJmp}) {
+		t.Errorf("got undo log %v, want [%v]", got, jeToJmp)
+	}
+}
+
+func TestApplyMismatch(t *testing.T) {
+	p, _, code := newPatcher()
+
+	patch := Patch{Address: patchBase, Original: []byte{0x75, 0x05}, Replacement: []byte{0xEB, 0x05}}
+	err := p.Apply(patch)
+	if !errors.Is(err, ErrOriginalMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrOriginalMismatch)
+	}
+
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) || !bytes.Equal(mismatch.Found, []byte{0x74, 0x05}) {
+		t.Errorf("got %#v, want the bytes found in memory", err)
+	}
+
+	if code[0] != 0x74 || len(p.Applied()) != 0 {
+		t.Errorf("mismatched patch was written")
+	}
+
+	short := Patch{Address: patchBase, Original: []byte{0x74, 0x05}, Replacement: []byte{0xEB}}
+	if err := p.Verify(short); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("got %v, want %v", err, ErrLengthMismatch)
+	}
+}
+
+func TestApplyOverlap(t *testing.T) {
+	p, _, code := newPatcher()
+
+	if err := p.Apply(jeToJmp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The original bytes match, but the patch covers the one applied above.
+	over := Patch{Address: patchBase + 1, Original: []byte{0x05}, Replacement: []byte{0x00}}
+	if err := p.Apply(over); !errors.Is(err, ErrOverlap) {
+		t.Errorf("got %v, want %v", err, ErrOverlap)
+	}
+
+	p, _, code = newPatcher()
+	inner := Patch{Address: patchBase + 1, Original: []byte{0x05, 0x90}, Replacement: []byte{0x00, 0xCC}}
+	if err := p.ApplyAll([]Patch{jeToJmp, inner}); !errors.Is(err, ErrOverlap) {
+		t.Errorf("ApplyAll: got %v, want %v", err, ErrOverlap)
+	}
+
+	if code[0] != 0x74 || len(p.Applied()) != 0 {
+		t.Errorf("ApplyAll wrote patches before rejecting an overlap")
+	}
+}
+
+func TestApplyAllRollback(t *testing.T) {
+	p, f, code := newPatcher()
+	orig := append([]byte(nil), code...)
+
+	if err := p.Apply(retToNop); err != nil {
+		t.Fatal(err)
+	}
+
+	f.fail = nopToInt3.Address
+	if err := p.ApplyAll([]Patch{jeToJmp, nopToInt3}); !errors.Is(err, errProtect) {
+		t.Fatalf("got %v, want %v", err, errProtect)
+	}
+
+	// Only the patches applied by ApplyAll are rolled back.
+	want := append([]byte(nil), orig...)
+	want[4] = 0x90
+	if !bytes.Equal(code, want) {
+		t.Errorf("got % X, want % X", code, want)
+	}
+
+	if got := p.Applied(); !reflect.DeepEqual(got, []Patch{retToNop}) {
+		t.Errorf("got undo log %v, want [%v]", got, retToNop)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	p, f, code := newPatcher()
+	orig := append([]byte(nil), code...)
+
+	if err := p.ApplyAll([]Patch{jeToJmp, nopToInt3, retToNop}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.calls = nil
+	if err := p.Revert(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(code, orig) {
+		t.Errorf("got % X, want % X", code, orig)
+	}
+
+	want := []uintptr{retToNop.Address, nopToInt3.Address, jeToJmp.Address}
+	if !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("reverted %#x, want %#x", f.calls, want)
+	}
+
+	if len(p.Applied()) != 0 {
+		t.Errorf("got undo log %v, want it empty", p.Applied())
+	}
+}
+
+func TestRevertFailure(t *testing.T) {
+	p, f, code := newPatcher()
+
+	if err := p.ApplyAll([]Patch{jeToJmp, nopToInt3, retToNop}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first patch was overwritten and the write of the second one fails;
+	// both stay in the undo log, and the third is still reverted.
+	code[0] = 0x90
+	f.fail = nopToInt3.Address
+
+	err := p.Revert()
+	if !errors.Is(err, errProtect) || !strings.Contains(err.Error(), "1 more") {
+		t.Fatalf("got %v, want %v and one more error", err, errProtect)
+	}
+
+	if code[4] != 0xC3 {
+		t.Errorf("third patch was not reverted")
+	}
+
+	if got, want := p.Applied(), []Patch{jeToJmp, nopToInt3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got undo log %v, want %v", got, want)
+	}
+
+	f.fail = 0
+	code[0] = 0xEB
+	if err := p.Revert(); err != nil {
+		t.Fatal(err)
+	}
+
+	if code[0] != 0x74 || code[2] != 0x90 || len(p.Applied()) != 0 {
+		t.Errorf("got % X after retrying, want the original bytes", code)
+	}
+}
@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	RemoteProtector changes page protections in another process with VirtualProtectEx.
+	Handle must have been opened with PROCESS_VM_OPERATION and PROCESS_QUERY_INFORMATION.
+*/
+type RemoteProtector struct {
+	Handle win32.Handle
+}
+
+/*
+	Unprotect makes the pages writable, keeping them executable if they were.
+	The protection of the first page decides for the whole range, which is
+	restored to that protection afterwards.
+*/
+func (rp RemoteProtector) Unprotect(addr uintptr, size int) (func() error, error) {
+	mbi, err := kernel32.VirtualQueryEx(rp.Handle, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	const executable = kernel32.PAGE_EXECUTE | kernel32.PAGE_EXECUTE_READ | kernel32.PAGE_EXECUTE_READWRITE | kernel32.PAGE_EXECUTE_WRITECOPY
+
+	prot := kernel32.PAGE_READWRITE
+	if mbi.Protect&executable != 0 {
+		prot = kernel32.PAGE_EXECUTE_READWRITE
+	}
+
+	old, err := kernel32.VirtualProtectEx(rp.Handle, addr, uintptr(size), prot)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		_, err := kernel32.VirtualProtectEx(rp.Handle, addr, uintptr(size), old)
+		return err
+	}, nil
+}
+
+/*
+	NewRemote creates a Patcher for the process behind handle, which needs
+	PROCESS_VM_READ, PROCESS_VM_WRITE, PROCESS_VM_OPERATION and PROCESS_QUERY_INFORMATION.
+*/
+func NewRemote(handle win32.Handle) *Patcher {
+	return New(process.Remote{Handle: handle}, RemoteProtector{Handle: handle})
+}
+
+/*
+	ResolveForProcess resolves set against the modules loaded in the process
+	identified by pid, reading its memory through handle.
+*/
+func (set *Set) ResolveForProcess(handle win32.Handle, pid uint32) ([]Patch, error) {
+	modules, err := process.Modules(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Resolve(process.Remote{Handle: handle}, modules)
+}
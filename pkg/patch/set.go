@@ -0,0 +1,433 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+)
+
+/*
+	Spec describes a patch relative to a module.
+
+	The patch address is the module base plus Offset, or, when Signature is set,
+	the address of the unique match of the signature in the sections of the
+	module plus Offset.
+	Offset accepts decimal or 0x prefixed hex and may be negative.
+	Byte strings are hex, optionally separated by spaces.
+*/
+type Spec struct {
+	Name        string `json:"name,omitempty"`
+	Module      string `json:"module"`
+	Offset      string `json:"offset,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+}
+
+/*
+	Set is a named list of patches, as stored in a patch file.
+*/
+type Set struct {
+	Name    string `json:"name,omitempty"`
+	Patches []Spec `json:"patches"`
+}
+
+/*
+	Load reads a patch set from a .json, .yaml or .yml file.
+*/
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+
+	return nil, fmt.Errorf("patch: unknown patch file type %q", filepath.Ext(path))
+}
+
+/*
+	ParseJSON parses a patch set from JSON. Unknown fields are rejected,
+	so that a misspelled field does not silently change the meaning of a patch.
+*/
+func ParseJSON(data []byte) (*Set, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var set Set
+	if err := dec.Decode(&set); err != nil {
+		return nil, fmt.Errorf("patch: %w", err)
+	}
+
+	return &set, nil
+}
+
+/*
+	ParseYAML parses a patch set from YAML.
+
+	Patch files use a small subset of YAML and ParseYAML only accepts that
+	subset, rejecting anything else instead of guessing at its meaning:
+
+		file    = [ "---" ] { top }
+		top     = key ":" [ value ]                at column 0
+		patches = "patches:" [ "[]" ] { item }     items indented alike, or at column 0
+		item    = "-" [ field ] { field }           fields aligned under the first one
+		field   = key ":" [ value ]
+		value   = plain | "double quoted" | 'single quoted'
+
+	The top level keys are name and patches, the fields are those of Spec, and
+	each key appears at most once in its mapping. Indentation is made of spaces.
+	A # starts a comment at the beginning of a line or after a space, outside
+	of quotes. Double quoted values are unescaped with strconv.Unquote, which
+	covers the escapes patch files need, and single quoted values escape ' as ”.
+	Plain values are taken verbatim and may not start with a YAML indicator,
+	so flow collections, anchors, aliases, tags and block scalars are errors,
+	as are multiple documents.
+
+		name: example
+		patches:
+		  - name: skip license check
+		    module: app.exe
+		    signature: "74 ?? 48 8B 05"
+		    original: "74 05"
+		    replacement: "EB 05"
+*/
+func ParseYAML(data []byte) (*Set, error) {
+	var set Set
+	var cur *Spec
+	inPatches, started := false, false
+	itemIndent, fieldIndent := -1, -1
+	seenTop := make(map[string]bool)
+	var seenField map[string]bool
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line == "---" {
+			if started {
+				return nil, fmt.Errorf("patch: yaml line %d: multiple documents are not supported", n+1)
+			}
+			started = true
+			continue
+		}
+		started = true
+
+		if strings.Contains(line, "\t") {
+			return nil, fmt.Errorf("patch: yaml line %d: tabs are not allowed for indentation", n+1)
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		text := line[indent:]
+		isItem := text == "-" || strings.HasPrefix(text, "- ")
+
+		if indent == 0 && !(isItem && inPatches) {
+			key, raw, err := splitKey(text)
+			if err != nil {
+				return nil, fmt.Errorf("patch: yaml line %d: %w", n+1, err)
+			}
+
+			if seenTop[key] {
+				return nil, fmt.Errorf("patch: yaml line %d: duplicate key %q", n+1, key)
+			}
+			seenTop[key] = true
+
+			inPatches, cur = false, nil
+			switch key {
+			case "name":
+				if set.Name, err = unquote(raw); err != nil {
+					return nil, fmt.Errorf("patch: yaml line %d: %w", n+1, err)
+				}
+			case "patches":
+				if raw != "" && raw != "[]" {
+					return nil, fmt.Errorf("patch: yaml line %d: patches must be a block sequence", n+1)
+				}
+				inPatches = raw == ""
+			default:
+				return nil, fmt.Errorf("patch: yaml line %d: unknown key %q", n+1, key)
+			}
+			continue
+		}
+
+		if !inPatches {
+			return nil, fmt.Errorf("patch: yaml line %d: unexpected indentation", n+1)
+		}
+
+		if isItem {
+			if itemIndent < 0 {
+				itemIndent = indent
+			} else if indent != itemIndent {
+				return nil, fmt.Errorf("patch: yaml line %d: unexpected indentation", n+1)
+			}
+
+			set.Patches = append(set.Patches, Spec{})
+			cur = &set.Patches[len(set.Patches)-1]
+			seenField = make(map[string]bool)
+
+			rest := strings.TrimLeft(text[1:], " ")
+			if rest == "" {
+				// The fields start on the next line.
+				fieldIndent = -1
+				continue
+			}
+
+			fieldIndent = indent + len(text) - len(rest)
+			text, indent = rest, fieldIndent
+		} else if cur != nil && fieldIndent < 0 && indent > itemIndent {
+			fieldIndent = indent
+		}
+
+		if cur == nil || indent != fieldIndent {
+			return nil, fmt.Errorf("patch: yaml line %d: unexpected indentation", n+1)
+		}
+
+		key, raw, err := splitKey(text)
+		if err == nil && seenField[key] {
+			err = fmt.Errorf("duplicate field %q", key)
+		}
+		if err == nil {
+			seenField[key] = true
+
+			var val string
+			if val, err = unquote(raw); err == nil {
+				err = cur.set(key, val)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("patch: yaml line %d: %w", n+1, err)
+		}
+	}
+
+	return &set, nil
+}
+
+/*
+	stripComment removes a # comment that is not inside a quoted scalar.
+	Quotes only start a scalar after a space, so an apostrophe inside a
+	plain value does not hide a comment.
+*/
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			escaped := quote == '"' && c == '\\' ||
+				quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\''
+			if escaped {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || line[i-1] == ' '):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+/*
+	splitKey splits "key: value" into the key and the raw, still quoted, value.
+*/
+func splitKey(text string) (string, string, error) {
+	i := strings.Index(text, ":")
+	if i <= 0 || (i+1 < len(text) && text[i+1] != ' ') {
+		return "", "", fmt.Errorf("expected key: value, got %q", text)
+	}
+
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), nil
+}
+
+func unquote(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s != "" && strings.ContainsRune("[]{}&*!|>%@`,", rune(s[0])):
+		return "", fmt.Errorf("unsupported YAML value %s", s)
+	}
+
+	return s, nil
+}
+
+func (s *Spec) set(key, val string) error {
+	switch key {
+	case "name":
+		s.Name = val
+	case "module":
+		s.Module = val
+	case "offset":
+		s.Offset = val
+	case "signature":
+		s.Signature = val
+	case "original":
+		s.Original = val
+	case "replacement":
+		s.Replacement = val
+	default:
+		return fmt.Errorf("unknown patch field %q", key)
+	}
+
+	return nil
+}
+
+/*
+	Resolve computes the patches of the set for a process whose memory is
+	read through r and whose loaded modules are modules. Module names are
+	matched case-insensitively, with or without their extension.
+*/
+func (set *Set) Resolve(r process.Reader, modules []process.Module) ([]Patch, error) {
+	patches := make([]Patch, 0, len(set.Patches))
+
+	for i, spec := range set.Patches {
+		p, err := spec.resolve(r, modules)
+		if err != nil {
+			name := spec.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i)
+			}
+			return nil, fmt.Errorf("patch: %s: %w", name, err)
+		}
+
+		patches = append(patches, p)
+	}
+
+	return patches, nil
+}
+
+func (spec Spec) resolve(r process.Reader, modules []process.Module) (Patch, error) {
+	p := Patch{Name: spec.Name}
+
+	var err error
+	if p.Original, err = parseHex(spec.Original); err != nil {
+		return p, err
+	}
+
+	if p.Replacement, err = parseHex(spec.Replacement); err != nil {
+		return p, err
+	}
+
+	if len(p.Original) == 0 || len(p.Original) != len(p.Replacement) {
+		return p, ErrLengthMismatch
+	}
+
+	mod, ok := findModule(modules, spec.Module)
+	if !ok {
+		return p, fmt.Errorf("module %q is not loaded", spec.Module)
+	}
+
+	var offset int64
+	if spec.Offset != "" {
+		if offset, err = strconv.ParseInt(spec.Offset, 0, 64); err != nil {
+			return p, fmt.Errorf("invalid offset %q", spec.Offset)
+		}
+	}
+
+	var start int64
+	if spec.Signature != "" {
+		sig, err := ParseSignature(spec.Signature)
+		if err != nil {
+			return p, err
+		}
+
+		matches, err := findInModule(r, mod, sig)
+		if err != nil {
+			return p, err
+		}
+
+		switch len(matches) {
+		case 0:
+			return p, fmt.Errorf("signature not found in %s", mod.Name)
+		case 1:
+			start = matches[0]
+		default:
+			return p, fmt.Errorf("signature matches %d times in %s", len(matches), mod.Name)
+		}
+	}
+
+	rva := start + offset
+	if rva < 0 || rva+int64(len(p.Original)) > int64(mod.Size) {
+		return p, fmt.Errorf("offset %#x is outside of %s", rva, mod.Name)
+	}
+
+	p.Address = mod.Base + uintptr(rva)
+	return p, nil
+}
+
+/*
+	findInModule returns the RVAs of the matches of sig in the sections of mod.
+
+	Sections are read one at a time, so the gaps the loader leaves between
+	them are never read and at most one section is held in memory.
+	A match cannot span two sections.
+*/
+func findInModule(r process.Reader, mod process.Module, sig Signature) ([]int64, error) {
+	img, err := process.OpenModule(r, mod)
+	if err != nil {
+		return nil, fmt.Errorf("reading the headers of %s: %w", mod.Name, err)
+	}
+
+	var matches []int64
+	var buf []byte
+	for _, s := range img.Sections {
+		size := s.VirtualSize
+		if size == 0 {
+			size = s.SizeOfRawData
+		}
+
+		if uint64(s.VirtualAddress)+uint64(size) > uint64(mod.Size) {
+			return nil, fmt.Errorf("section %s is outside of %s", s.Name, mod.Name)
+		}
+
+		if cap(buf) < int(size) {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+
+		if err := r.ReadMemory(mod.Base+uintptr(s.VirtualAddress), buf); err != nil {
+			return nil, fmt.Errorf("reading %s section %s: %w", mod.Name, s.Name, err)
+		}
+
+		for _, m := range sig.Find(buf) {
+			matches = append(matches, int64(s.VirtualAddress)+int64(m))
+		}
+	}
+
+	return matches, nil
+}
+
+func findModule(modules []process.Module, name string) (process.Module, bool) {
+	for _, m := range modules {
+		if strings.EqualFold(m.Name, name) {
+			return m, true
+		}
+	}
+
+	for _, m := range modules {
+		if strings.EqualFold(strings.TrimSuffix(m.Name, filepath.Ext(m.Name)), name) {
+			return m, true
+		}
+	}
+
+	return process.Module{}, false
+}
@@ -0,0 +1,356 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/process/processtest"
+)
+
+var exampleSet = &Set{
+	Name: "example",
+	Patches: []Spec{
+		{
+			Name:        "skip license check",
+			Module:      "app.exe",
+			Signature:   "74 ?? 48 8B 05",
+			Original:    "74 05",
+			Replacement: "EB 05",
+		},
+		{
+			Module:      "core",
+			Offset:      "0x1234",
+			Original:    "90",
+			Replacement: "CC",
+		},
+	},
+}
+
+const exampleYAML = `# Patches for app 1.2
+name: example
+patches:
+  - name: skip license check
+    module: app.exe
+    signature: "74 ?? 48 8B 05"
+    original: "74 05"   # je
+    replacement: 'EB 05' # jmp
+  - module: core
+    offset: 0x1234
+    original: 90
+    replacement: CC
+`
+
+const exampleJSON = `{
+	"name": "example",
+	"patches": [
+		{
+			"name": "skip license check",
+			"module": "app.exe",
+			"signature": "74 ?? 48 8B 05",
+			"original": "74 05",
+			"replacement": "EB 05"
+		},
+		{"module": "core", "offset": "0x1234", "original": "90", "replacement": "CC"}
+	]
+}`
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want *Set
+	}{
+		{"example", exampleYAML, exampleSet},
+		{"document marker", "---\nname: x\n", &Set{Name: "x"}},
+		{"empty", "", &Set{}},
+		{"no patches", "name: x\npatches: []\n", &Set{Name: "x"}},
+		{"patches without items", "patches:\n", &Set{}},
+		{
+			name: "items at column 0",
+			yaml: "patches:\n- module: a\n  original: 90\n- module: b\n",
+			want: &Set{Patches: []Spec{{Module: "a", Original: "90"}, {Module: "b"}}},
+		},
+		{
+			name: "fields after a bare dash",
+			yaml: "patches:\n  -\n     module: a\n     offset: -16\n  - module: b\n",
+			want: &Set{Patches: []Spec{{Module: "a", Offset: "-16"}, {Module: "b"}}},
+		},
+		{
+			name: "wide dash",
+			yaml: "patches:\n  -   module: a\n      original: 90\n",
+			want: &Set{Patches: []Spec{{Module: "a", Original: "90"}}},
+		},
+		{
+			name: "quoting",
+			yaml: "name: \"a # b\\tc\"\npatches:\n  - name: 'it''s # here'\n    module: it's # a comment\n",
+			want: &Set{Name: "a # b\tc", Patches: []Spec{{Name: "it's # here", Module: "it's"}}},
+		},
+		{
+			name: "empty values",
+			yaml: "name:\npatches:\n  - name: \"\"\n    module:\n",
+			want: &Set{Patches: []Spec{{}}},
+		},
+		{
+			name: "name after patches",
+			yaml: "patches:\n  - name: a\n    module: m\nname: x\n",
+			want: &Set{Name: "x", Patches: []Spec{{Name: "a", Module: "m"}}},
+		},
+		{"CRLF line endings", "name: x\r\npatches:\r\n  - module: a\r\n", &Set{Name: "x", Patches: []Spec{{Module: "a"}}}},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseYAML([]byte(tc.yaml))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		line int
+	}{
+		{"unknown top level key", "name: x\nversion: 2\n", 2},
+		{"unknown field", "patches:\n  - module: a\n    address: 0x1000\n", 3},
+		{"duplicate top level key", "name: x\nname: y\n", 2},
+		{"duplicate field", "patches:\n  - module: a\n    module: b\n", 3},
+		{"duplicate patches after an item", "patches:\n  - module: a\npatches:\n  - module: b\n", 3},
+		{"duplicate name after an item", "name: x\npatches:\n  - module: a\nname: y\n", 4},
+		{"tab indentation", "patches:\n\t- module: a\n", 2},
+		{"indented top level", " name: x\n", 1},
+		{"field outside of an item", "patches:\n  module: a\n", 2},
+		{"misaligned field", "patches:\n  - module: a\n     original: 90\n", 3},
+		{"misaligned item", "patches:\n  - module: a\n    - module: b\n", 3},
+		{"nested mapping", "patches:\n  - module:\n      name: a\n", 3},
+		{"missing colon", "name x\n", 1},
+		{"no space after colon", "name:x\n", 1},
+		{"flow sequence", "patches: [{module: a}]\n", 1},
+		{"flow mapping value", "patches:\n  - module: {a: b}\n", 2},
+		{"anchor", "name: &a x\n", 1},
+		{"alias", "name: *a\n", 1},
+		{"tag", "name: !!str x\n", 1},
+		{"block scalar", "name: |\n", 1},
+		{"unterminated double quote", "name: \"x\n", 1},
+		{"unterminated single quote", "name: 'x\n", 1},
+		{"bad escape", "name: \"\\q\"\n", 1},
+		{"items after empty patches", "patches: []\n  - module: a\n", 2},
+		{"multiple documents", "name: a\n---\nname: b\n", 2},
+	}
+
+	for _, tc := range tests {
+		_, err := ParseYAML([]byte(tc.yaml))
+		if err == nil {
+			t.Errorf("%s: no error", tc.name)
+			continue
+		}
+
+		if want := "line " + strconv.Itoa(tc.line) + ":"; !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %q, want an error on line %d", tc.name, err, tc.line)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	got, err := ParseJSON([]byte(exampleJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, exampleSet) {
+		t.Errorf("got %+v, want %+v", got, exampleSet)
+	}
+
+	for _, bad := range []string{
+		`{"patches": [{"module": "a", "adress": "0x10"}]}`,
+		`{"patches": {}}`,
+		`{"name": "x"`,
+	} {
+		if _, err := ParseJSON([]byte(bad)); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"set.json": exampleJSON,
+		"set.yaml": exampleYAML,
+		"set.YML":  exampleYAML,
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		set, err := Load(path)
+		if err != nil || !reflect.DeepEqual(set, exampleSet) {
+			t.Errorf("%s: got %+v, %v", name, set, err)
+		}
+	}
+
+	txt := filepath.Join(dir, "set.txt")
+	if err := os.WriteFile(txt, []byte(exampleJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(txt); err == nil {
+		t.Error("set.txt: no error for an unknown extension")
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	sig, err := ParseSignature("48 8b ?? ? C3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Signature{Bytes: []byte{0x48, 0x8B, 0, 0, 0xC3}, Mask: []bool{true, true, false, false, true}}
+	if !reflect.DeepEqual(sig, want) {
+		t.Errorf("got %+v, want %+v", sig, want)
+	}
+
+	data := []byte{0x48, 0x8B, 1, 2, 0xC3, 0x48, 0x8B, 0x48, 0x8B, 3, 4, 0xC3, 0x48, 0x8B, 5}
+	if got := sig.Find(data); !reflect.DeepEqual(got, []int{0, 7}) {
+		t.Errorf("matches: got %v, want [0 7]", got)
+	}
+
+	for _, bad := range []string{"", "   ", "48 8", "48 8B0", "48 zz", "???"} {
+		if _, err := ParseSignature(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+const moduleBase = 0x12340000
+
+/*
+	mapModule maps the headers and each section of a built image in separate
+	regions, leaving the rest of each section's pages unmapped the way
+	Resolve may find them in a real process.
+*/
+func mapModule(mem *processtest.Memory, b *petest.Builder) process.Module {
+	img := b.Bytes()
+	mem.Map(moduleBase, img[:0x400])
+
+	parsed, err := pe.NewImage(bytes.NewReader(img))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, s := range parsed.Sections {
+		mem.Map(moduleBase+uintptr(s.VirtualAddress), img[s.VirtualAddress:s.VirtualAddress+s.VirtualSize])
+	}
+
+	return process.Module{Name: "App.exe", Base: moduleBase, Size: uint32(len(img))}
+}
+
+func TestResolve(t *testing.T) {
+	text := make([]byte, 0x200)
+	copy(text[0x40:], []byte{0x85, 0xC0, 0x74, 0x05, 0x48, 0x8B, 0x05})
+	copy(text[0x80:], []byte{0x74, 0x10, 0x48, 0x8B, 0x0D})
+
+	data := make([]byte, 0x80)
+	copy(data[0x10:], []byte{0xDE, 0xAD, 0xBE, 0xEF})
+
+	b := petest.New(true)
+	textRVA := b.AddSection(".text", text, pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+	dataRVA := b.AddSection(".data", data, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+
+	mem := processtest.NewMemory()
+	modules := []process.Module{
+		{Name: "ntdll.dll", Base: 0x7A000000, Size: 0x1000},
+		mapModule(mem, b),
+	}
+
+	set := &Set{Patches: []Spec{
+		{Name: "by signature", Module: "app.exe", Signature: "74 ?? 48 8B 05", Original: "74 05", Replacement: "EB 05"},
+		{Name: "signature and offset", Module: "APP", Signature: "85 C0 74", Offset: "2", Original: "7405", Replacement: "9090"},
+		{Name: "negative offset", Module: "app", Signature: "48 8B 0D", Offset: "-2", Original: "74 10", Replacement: "EB 10"},
+		{Name: "data section", Module: "app.exe", Signature: "DE AD BE EF", Original: "DE AD", Replacement: "00 00"},
+		{Name: "by offset", Module: "app.exe", Offset: "0x1000", Original: "00", Replacement: "CC"},
+	}}
+
+	got, err := set.Resolve(mem, modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := uintptr(moduleBase)
+	want := []Patch{
+		{Name: "by signature", Address: base + uintptr(textRVA) + 0x42, Original: []byte{0x74, 0x05}, Replacement: []byte{0xEB, 0x05}},
+		{Name: "signature and offset", Address: base + uintptr(textRVA) + 0x42, Original: []byte{0x74, 0x05}, Replacement: []byte{0x90, 0x90}},
+		{Name: "negative offset", Address: base + uintptr(textRVA) + 0x80, Original: []byte{0x74, 0x10}, Replacement: []byte{0xEB, 0x10}},
+		{Name: "data section", Address: base + uintptr(dataRVA) + 0x10, Original: []byte{0xDE, 0xAD}, Replacement: []byte{0, 0}},
+		{Name: "by offset", Address: base + 0x1000, Original: []byte{0}, Replacement: []byte{0xCC}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	text := make([]byte, 0x100)
+	copy(text[0x10:], []byte{0x74, 0x05, 0xCC})
+	copy(text[0x20:], []byte{0x74, 0x05, 0xCC})
+
+	b := petest.New(false)
+	b.AddSection(".text", text, pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_READ)
+
+	mem := processtest.NewMemory()
+	modules := []process.Module{mapModule(mem, b)}
+
+	tests := []struct {
+		spec Spec
+		want string
+	}{
+		{Spec{Module: "app.exe", Signature: "74 05 CC", Original: "74", Replacement: "EB"}, "matches 2 times"},
+		{Spec{Module: "app.exe", Signature: "74 06", Original: "74", Replacement: "EB"}, "not found"},
+		{Spec{Module: "app.exe", Signature: "74 0", Original: "74", Replacement: "EB"}, "invalid signature"},
+		{Spec{Module: "other.dll", Offset: "0", Original: "74", Replacement: "EB"}, "not loaded"},
+		{Spec{Module: "app.exe", Offset: "0x2000", Original: "74", Replacement: "EB"}, "outside of"},
+		{Spec{Module: "app.exe", Offset: "-1", Original: "74", Replacement: "EB"}, "outside of"},
+		{Spec{Module: "app.exe", Offset: "ten", Original: "74", Replacement: "EB"}, "invalid offset"},
+		{Spec{Module: "app.exe", Offset: "0", Original: "74 05", Replacement: "EB"}, ErrLengthMismatch.Error()},
+		{Spec{Module: "app.exe", Offset: "0", Original: "", Replacement: ""}, ErrLengthMismatch.Error()},
+		{Spec{Module: "app.exe", Offset: "0", Original: "7", Replacement: "E"}, "invalid hex"},
+	}
+
+	for i, tc := range tests {
+		_, err := (&Set{Patches: []Spec{tc.spec}}).Resolve(mem, modules)
+		if err == nil || !strings.Contains(err.Error(), tc.want) || !strings.Contains(err.Error(), "#0") {
+			t.Errorf("%d: got %v, want an error about %q", i, err, tc.want)
+		}
+	}
+
+	// Without mapped headers the sections cannot be found.
+	mem.Unmap(moduleBase)
+	spec := Spec{Name: "no headers", Module: "app.exe", Signature: "74 05", Original: "74", Replacement: "EB"}
+	if _, err := (&Set{Patches: []Spec{spec}}).Resolve(mem, modules); err == nil || !strings.Contains(err.Error(), "no headers") {
+		t.Errorf("unmapped headers: got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package patch
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+/*
+	Signature is a byte pattern where some bytes may match any value.
+*/
+type Signature struct {
+	Bytes []byte
+
+	/*
+		Mask is true for the bytes that must match.
+	*/
+	Mask []bool
+}
+
+/*
+	ParseSignature parses a pattern of hex bytes separated by spaces,
+	where "?" or "??" matches any byte, such as "48 8B 05 ?? ?? ?? ?? C3".
+*/
+func ParseSignature(s string) (Signature, error) {
+	var sig Signature
+	for _, tok := range strings.Fields(s) {
+		if tok == "?" || tok == "??" {
+			sig.Bytes = append(sig.Bytes, 0)
+			sig.Mask = append(sig.Mask, false)
+			continue
+		}
+
+		b, err := hex.DecodeString(tok)
+		if err != nil || len(b) != 1 {
+			return Signature{}, fmt.Errorf("patch: invalid signature byte %q", tok)
+		}
+
+		sig.Bytes = append(sig.Bytes, b[0])
+		sig.Mask = append(sig.Mask, true)
+	}
+
+	if len(sig.Bytes) == 0 {
+		return Signature{}, fmt.Errorf("patch: empty signature")
+	}
+
+	return sig, nil
+}
+
+func (s Signature) matchAt(data []byte, i int) bool {
+	for j, b := range s.Bytes {
+		if s.Mask[j] && data[i+j] != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Find returns the offsets of all matches of s in data.
+*/
+func (s Signature) Find(data []byte) []int {
+	var matches []int
+	for i := 0; i+len(s.Bytes) <= len(data); i++ {
+		if s.matchAt(data, i) {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches
+}
+
+/*
+	parseHex parses bytes written as hex, optionally separated by spaces.
+*/
+func parseHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("patch: invalid hex bytes %q", s)
+	}
+
+	return b, nil
+}
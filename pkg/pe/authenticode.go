@@ -0,0 +1,569 @@
+package pe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"sort"
+
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	WIN_CERT_REVISION_1_0 uint16 = 0x0100
+	WIN_CERT_REVISION_2_0 uint16 = 0x0200
+
+	WIN_CERT_TYPE_X509             uint16 = 0x0001
+	WIN_CERT_TYPE_PKCS_SIGNED_DATA uint16 = 0x0002
+	WIN_CERT_TYPE_RESERVED_1       uint16 = 0x0003
+	WIN_CERT_TYPE_TS_STACK_SIGNED  uint16 = 0x0004
+)
+
+var (
+	ErrNotSigned      = errors.New("pe: image is not signed")
+	ErrDigestMismatch = errors.New("pe: image hash does not match the Authenticode digest")
+)
+
+var (
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidMessageDigest   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidMD5    = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+func hashFromOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidMD5):
+		return crypto.MD5, nil
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+
+	return 0, fmt.Errorf("pe: unsupported digest algorithm %v", oid)
+}
+
+/*
+	WinCertificate is an entry of the attribute certificate table (WIN_CERTIFICATE).
+*/
+type WinCertificate struct {
+	Revision        uint16
+	CertificateType uint16
+	Certificate     []byte
+}
+
+/*
+	securityDirectory returns the certificate table. Unlike every other data
+	directory its VirtualAddress is a file offset, and the table is not
+	mapped into memory by the loader.
+*/
+func (img *Image) securityDirectory() (DataDirectory, error) {
+	if img.mapped {
+		return DataDirectory{}, errors.New("pe: the certificate table is only available in the file layout")
+	}
+
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_SECURITY)
+	if errors.Is(err, ErrNoDirectory) {
+		return dd, ErrNotSigned
+	}
+
+	return dd, err
+}
+
+/*
+	Certificates returns the entries of the attribute certificate table.
+	The image must have been opened with NewFile.
+*/
+func (img *Image) Certificates() ([]WinCertificate, error) {
+	dd, err := img.securityDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	table := make([]byte, dd.Size)
+	if _, err := img.r.ReadAt(table, int64(dd.VirtualAddress)); err != nil {
+		return nil, fmt.Errorf("pe: reading certificate table: %w", err)
+	}
+
+	var certs []WinCertificate
+	for off := 0; off+8 <= len(table); {
+		length := int(binary.LittleEndian.Uint32(table[off:]))
+		if length < 8 || off+length > len(table) {
+			return nil, fmt.Errorf("pe: invalid WIN_CERTIFICATE length %d", length)
+		}
+
+		certs = append(certs, WinCertificate{
+			Revision:        binary.LittleEndian.Uint16(table[off+4:]),
+			CertificateType: binary.LittleEndian.Uint16(table[off+6:]),
+			Certificate:     table[off+8 : off+length],
+		})
+
+		off += (length + 7) &^ 7
+	}
+
+	return certs, nil
+}
+
+/*
+	contentInfo is a PKCS#7 ContentInfo. Because Content is an explicitly tagged
+	RawValue, its Bytes hold the complete DER encoding of the inner content.
+*/
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+/*
+	Signature is an Authenticode signature: a PKCS#7 SignedData whose content
+	is the digest of the image.
+*/
+type Signature struct {
+	/*
+		DigestAlgorithm is the hash used for the image digest.
+	*/
+	DigestAlgorithm crypto.Hash
+
+	/*
+		Digest is the Authenticode hash of the image recorded by the signer.
+	*/
+	Digest []byte
+
+	/*
+		Certificates are all certificates embedded in the SignedData.
+	*/
+	Certificates []*x509.Certificate
+
+	/*
+		Signer is the certificate matching the SignerInfo, or nil if it is not embedded.
+	*/
+	Signer *x509.Certificate
+
+	/*
+		Chain is Signer followed by its issuers as far as they are embedded in the signature.
+		It is built from the embedded certificates only; no trust store is consulted.
+	*/
+	Chain []*x509.Certificate
+
+	signer  signerInfo
+	content []byte
+}
+
+/*
+	ParseSignature parses a DER encoded PKCS#7 SignedData containing an Authenticode digest,
+	as found in a WIN_CERT_TYPE_PKCS_SIGNED_DATA certificate.
+*/
+func ParseSignature(der []byte) (*Signature, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("pe: parsing PKCS#7 content info: %w", err)
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("pe: unexpected PKCS#7 content type %v", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("pe: parsing SignedData: %w", err)
+	}
+
+	if !sd.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		return nil, fmt.Errorf("pe: unexpected SignedData content type %v", sd.ContentInfo.ContentType)
+	}
+
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("pe: parsing SpcIndirectDataContent: %w", err)
+	}
+
+	var spc spcIndirectDataContent
+	if _, err := asn1.Unmarshal(content.FullBytes, &spc); err != nil {
+		return nil, fmt.Errorf("pe: parsing SpcIndirectDataContent: %w", err)
+	}
+
+	hash, err := hashFromOID(spc.MessageDigest.Algorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("pe: expected one SignerInfo, found %d", len(sd.SignerInfos))
+	}
+
+	sig := &Signature{
+		DigestAlgorithm: hash,
+		Digest:          spc.MessageDigest.Digest,
+		signer:          sd.SignerInfos[0],
+		content:         content.Bytes,
+	}
+
+	if len(sd.Certificates.Bytes) > 0 {
+		if sig.Certificates, err = x509.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, fmt.Errorf("pe: parsing embedded certificates: %w", err)
+		}
+	}
+
+	ias := sig.signer.IssuerAndSerialNumber
+	for _, cert := range sig.Certificates {
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			sig.Signer = cert
+			break
+		}
+	}
+
+	if sig.Signer != nil {
+		sig.Chain = buildChain(sig.Signer, sig.Certificates)
+	}
+
+	return sig, nil
+}
+
+func buildChain(leaf *x509.Certificate, pool []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{leaf}
+
+	for cur := leaf; !bytes.Equal(cur.RawIssuer, cur.RawSubject); {
+		var next *x509.Certificate
+		for _, c := range pool {
+			if containsCert(chain, c) || !bytes.Equal(c.RawSubject, cur.RawIssuer) {
+				continue
+			}
+
+			if cur.CheckSignatureFrom(c) == nil {
+				next = c
+				break
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		chain = append(chain, next)
+		cur = next
+	}
+
+	return chain
+}
+
+func containsCert(certs []*x509.Certificate, c *x509.Certificate) bool {
+	for _, x := range certs {
+		if x.Equal(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Verify checks that the SignerInfo signature was produced by Signer over the
+	Authenticode digest, and that every link of Chain is signed by the next one.
+	It does not check that the chain ends in a trusted root, nor certificate validity periods.
+*/
+func (s *Signature) Verify() error {
+	if s.Signer == nil {
+		return errors.New("pe: signer certificate is not embedded in the signature")
+	}
+
+	hash, err := hashFromOID(s.signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	signed := s.content
+	if attrs := s.signer.AuthenticatedAttributes; len(attrs.FullBytes) > 0 {
+		digest, err := messageDigestAttribute(attrs.Bytes)
+		if err != nil {
+			return err
+		}
+
+		h := hash.New()
+		h.Write(s.content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return errors.New("pe: messageDigest attribute does not match the signed content")
+		}
+
+		// The signature covers the attributes encoded as a SET OF,
+		// not with the IMPLICIT [0] tag they are stored with.
+		signed = append([]byte{0x31}, attrs.FullBytes[1:]...)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	sum := h.Sum(nil)
+
+	switch pub := s.Signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, hash, sum, s.signer.EncryptedDigest)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, sum, s.signer.EncryptedDigest) {
+			err = errors.New("ecdsa verification failure")
+		}
+	default:
+		err = fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	if err != nil {
+		return fmt.Errorf("pe: verifying signer signature: %w", err)
+	}
+
+	for i := 0; i+1 < len(s.Chain); i++ {
+		if err := s.Chain[i].CheckSignatureFrom(s.Chain[i+1]); err != nil {
+			return fmt.Errorf("pe: verifying certificate chain: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func messageDigestAttribute(attrs []byte) ([]byte, error) {
+	for rest := attrs; len(rest) > 0; {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return nil, fmt.Errorf("pe: parsing authenticated attributes: %w", err)
+		}
+
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, fmt.Errorf("pe: parsing messageDigest attribute: %w", err)
+		}
+
+		return digest, nil
+	}
+
+	return nil, errors.New("pe: authenticated attributes have no messageDigest")
+}
+
+/*
+	Signatures parses every PKCS#7 signature in the certificate table.
+*/
+func (img *Image) Signatures() ([]*Signature, error) {
+	certs, err := img.Certificates()
+	if err != nil {
+		return nil, err
+	}
+
+	var sigs []*Signature
+	for _, c := range certs {
+		if c.CertificateType != WIN_CERT_TYPE_PKCS_SIGNED_DATA {
+			continue
+		}
+
+		sig, err := ParseSignature(c.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+
+	if len(sigs) == 0 {
+		return nil, ErrNotSigned
+	}
+
+	return sigs, nil
+}
+
+func readerSize(r io.ReaderAt) (int64, error) {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size(), nil
+	case interface{ Stat() (fs.FileInfo, error) }:
+		fi, err := r.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+
+	return 0, errors.New("pe: cannot determine the size of the image reader")
+}
+
+/*
+	AuthenticodeHash computes the Authenticode digest of the image with hash.
+	The checksum, the certificate table directory entry and the certificate table
+	itself are excluded from the digest, as described in the Authenticode specification.
+
+	The image must have been opened with NewFile on a reader whose size can be
+	determined, such as an *os.File, *bytes.Reader or *io.SectionReader.
+*/
+func (img *Image) AuthenticodeHash(hash crypto.Hash) ([]byte, error) {
+	if img.mapped {
+		return nil, errors.New("pe: the Authenticode hash is only defined for the file layout")
+	}
+
+	if !hash.Available() {
+		return nil, fmt.Errorf("pe: hash %v is not available", hash)
+	}
+
+	size, err := readerSize(img.r)
+	if err != nil {
+		return nil, err
+	}
+
+	checksumOffset := img.OptionalHeaderOffset + 64
+	securityOffset := img.OptionalHeaderOffset + 128
+	if img.Is64() {
+		securityOffset = img.OptionalHeaderOffset + 144
+	}
+
+	headersEnd := int64(img.OptionalHeader.SizeOfHeaders)
+	if headersEnd < securityOffset+8 || headersEnd > size {
+		return nil, errors.New("pe: invalid SizeOfHeaders")
+	}
+
+	h := hash.New()
+	copyRange := func(start, end int64) error {
+		if start >= end {
+			return nil
+		}
+
+		if _, err := io.Copy(h, io.NewSectionReader(img.r, start, end-start)); err != nil {
+			return fmt.Errorf("pe: hashing image: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := copyRange(0, checksumOffset); err != nil {
+		return nil, err
+	}
+
+	if err := copyRange(checksumOffset+4, securityOffset); err != nil {
+		return nil, err
+	}
+
+	if err := copyRange(securityOffset+8, headersEnd); err != nil {
+		return nil, err
+	}
+
+	sections := make([]Section, 0, len(img.Sections))
+	for _, s := range img.Sections {
+		if s.SizeOfRawData > 0 {
+			sections = append(sections, s)
+		}
+	}
+
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].PointerToRawData < sections[j].PointerToRawData
+	})
+
+	hashed := headersEnd
+	for _, s := range sections {
+		start := int64(s.PointerToRawData)
+		end := start + int64(s.SizeOfRawData)
+		if end > size {
+			return nil, fmt.Errorf("pe: section %s extends past the end of the file", s.Name)
+		}
+
+		if err := copyRange(start, end); err != nil {
+			return nil, err
+		}
+		hashed += int64(s.SizeOfRawData)
+	}
+
+	end := size
+	if dd := img.OptionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_SECURITY]; dd.Size != 0 {
+		if int64(dd.VirtualAddress)+int64(dd.Size) > size {
+			return nil, errors.New("pe: certificate table extends past the end of the file")
+		}
+		end -= int64(dd.Size)
+	}
+
+	if err := copyRange(hashed, end); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+/*
+	VerifySignature parses the first Authenticode signature of the image, recomputes the
+	image hash and compares it with the signed digest, then verifies the signature itself.
+	It returns the signature along with ErrDigestMismatch if the image was modified after signing.
+*/
+func (img *Image) VerifySignature() (*Signature, error) {
+	sigs, err := img.Signatures()
+	if err != nil {
+		return nil, err
+	}
+
+	sig := sigs[0]
+
+	sum, err := img.AuthenticodeHash(sig.DigestAlgorithm)
+	if err != nil {
+		return sig, err
+	}
+
+	if !bytes.Equal(sum, sig.Digest) {
+		return sig, ErrDigestMismatch
+	}
+
+	return sig, sig.Verify()
+}
@@ -0,0 +1,147 @@
+package pe_test
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+)
+
+/*
+	testdata/signed.dll is the German NuGet.Localization.resources.dll 5.11.1.5 shipped
+	with the .NET 5 SDK, signed by Microsoft with SHA-256. testdata/signed-modified.dll
+	is a copy with one byte of its .text section flipped.
+*/
+func openSigned(t *testing.T, name string) (*pe.Image, []byte) {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img, data
+}
+
+func TestVerifySignature(t *testing.T) {
+	img, _ := openSigned(t, "signed.dll")
+
+	certs, err := img.Certificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 1 || certs[0].Revision != pe.WIN_CERT_REVISION_2_0 || certs[0].CertificateType != pe.WIN_CERT_TYPE_PKCS_SIGNED_DATA {
+		t.Fatalf("got certificates %+v", certs)
+	}
+
+	sig, err := img.VerifySignature()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sig.DigestAlgorithm != crypto.SHA256 {
+		t.Errorf("got digest algorithm %v", sig.DigestAlgorithm)
+	}
+
+	sum, err := img.AuthenticodeHash(crypto.SHA256)
+	if err != nil || !bytes.Equal(sum, sig.Digest) {
+		t.Errorf("AuthenticodeHash = %x, %v; want %x", sum, err, sig.Digest)
+	}
+
+	if sig.Signer == nil || sig.Signer.Subject.CommonName != "Microsoft Corporation" {
+		t.Fatalf("got signer %v", sig.Signer)
+	}
+
+	if len(sig.Chain) != 2 || sig.Chain[1].Subject.CommonName != "Microsoft Code Signing PCA 2011" {
+		t.Errorf("got a chain of %d certificates", len(sig.Chain))
+	}
+}
+
+func TestVerifySignatureModified(t *testing.T) {
+	img, _ := openSigned(t, "signed-modified.dll")
+
+	sig, err := img.VerifySignature()
+	if !errors.Is(err, pe.ErrDigestMismatch) {
+		t.Fatalf("got %v, want ErrDigestMismatch", err)
+	}
+
+	// The signature itself is intact, only the image no longer matches it.
+	if sig == nil || sig.Verify() != nil {
+		t.Errorf("signature of the modified image does not verify")
+	}
+}
+
+func TestVerifySignatureChecksum(t *testing.T) {
+	img, data := openSigned(t, "signed.dll")
+
+	// The checksum is excluded from the Authenticode hash.
+	data[img.OptionalHeaderOffset+64] ^= 0xFF
+
+	img, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := img.VerifySignature(); err != nil {
+		t.Errorf("changing the checksum broke the signature: %v", err)
+	}
+}
+
+func TestVerifySignatureTampered(t *testing.T) {
+	img, _ := openSigned(t, "signed.dll")
+
+	sigs, err := img.Signatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Forge a signature over a different digest with the same signer.
+	sig := *sigs[0]
+	sig.Digest = append([]byte(nil), sig.Digest...)
+	sig.Digest[0] ^= 0xFF
+
+	certs, _ := img.Certificates()
+	der := bytes.Replace(certs[0].Certificate, sigs[0].Digest, sig.Digest, 1)
+
+	forged, err := pe.ParseSignature(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := forged.Verify(); err == nil {
+		t.Error("signature over a modified digest verified")
+	}
+}
+
+func TestNotSigned(t *testing.T) {
+	b := petest.New(true)
+	b.AddSection(".text", make([]byte, 0x10), pe.IMAGE_SCN_CNT_CODE)
+
+	img, err := pe.NewFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := img.VerifySignature(); !errors.Is(err, pe.ErrNotSigned) {
+		t.Errorf("got %v, want ErrNotSigned", err)
+	}
+
+	img, err = pe.NewImage(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := img.AuthenticodeHash(crypto.SHA256); err == nil {
+		t.Error("AuthenticodeHash succeeded on a mapped image")
+	}
+}
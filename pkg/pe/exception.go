@@ -0,0 +1,312 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/*
+	UnwindOp is the operation of an x64 unwind code.
+*/
+type UnwindOp uint8
+
+const (
+	UWOP_PUSH_NONVOL     UnwindOp = 0
+	UWOP_ALLOC_LARGE     UnwindOp = 1
+	UWOP_ALLOC_SMALL     UnwindOp = 2
+	UWOP_SET_FPREG       UnwindOp = 3
+	UWOP_SAVE_NONVOL     UnwindOp = 4
+	UWOP_SAVE_NONVOL_FAR UnwindOp = 5
+	UWOP_EPILOG          UnwindOp = 6
+	UWOP_SPARE_CODE      UnwindOp = 7
+	UWOP_SAVE_XMM128     UnwindOp = 8
+	UWOP_SAVE_XMM128_FAR UnwindOp = 9
+	UWOP_PUSH_MACHFRAME  UnwindOp = 10
+)
+
+func (op UnwindOp) String() string {
+	switch op {
+	case UWOP_PUSH_NONVOL:
+		return "UWOP_PUSH_NONVOL"
+	case UWOP_ALLOC_LARGE:
+		return "UWOP_ALLOC_LARGE"
+	case UWOP_ALLOC_SMALL:
+		return "UWOP_ALLOC_SMALL"
+	case UWOP_SET_FPREG:
+		return "UWOP_SET_FPREG"
+	case UWOP_SAVE_NONVOL:
+		return "UWOP_SAVE_NONVOL"
+	case UWOP_SAVE_NONVOL_FAR:
+		return "UWOP_SAVE_NONVOL_FAR"
+	case UWOP_EPILOG:
+		return "UWOP_EPILOG"
+	case UWOP_SPARE_CODE:
+		return "UWOP_SPARE_CODE"
+	case UWOP_SAVE_XMM128:
+		return "UWOP_SAVE_XMM128"
+	case UWOP_SAVE_XMM128_FAR:
+		return "UWOP_SAVE_XMM128_FAR"
+	case UWOP_PUSH_MACHFRAME:
+		return "UWOP_PUSH_MACHFRAME"
+	}
+
+	return fmt.Sprintf("UnwindOp(%d)", uint8(op))
+}
+
+const (
+	UNW_FLAG_NHANDLER  uint8 = 0x0
+	UNW_FLAG_EHANDLER  uint8 = 0x1
+	UNW_FLAG_UHANDLER  uint8 = 0x2
+	UNW_FLAG_CHAININFO uint8 = 0x4
+)
+
+/*
+	RuntimeFunction is an entry of the x64 exception directory (RUNTIME_FUNCTION).
+*/
+type RuntimeFunction struct {
+	BeginAddress      uint32
+	EndAddress        uint32
+	UnwindInfoAddress uint32
+}
+
+/*
+	Contains reports whether rva lies inside the function.
+*/
+func (rf RuntimeFunction) Contains(rva uint32) bool {
+	return rva >= rf.BeginAddress && rva < rf.EndAddress
+}
+
+/*
+	UnwindCode is a decoded unwind code. Codes that span several slots
+	of the UNWIND_INFO array are decoded into a single UnwindCode.
+*/
+type UnwindCode struct {
+	/*
+		CodeOffset is the offset from the start of the prolog of the
+		end of the instruction that performs this operation.
+	*/
+	CodeOffset uint8
+	Op         UnwindOp
+	OpInfo     uint8
+
+	/*
+		Value holds the decoded operand of the code: the allocation size for
+		UWOP_ALLOC_*, and the stack offset in bytes for UWOP_SAVE_*.
+	*/
+	Value uint32
+
+	/*
+		Slots is the number of UNWIND_CODE slots the code occupies.
+	*/
+	Slots int
+}
+
+/*
+	UnwindInfo is a parsed UNWIND_INFO structure.
+*/
+type UnwindInfo struct {
+	Version       uint8
+	Flags         uint8
+	SizeOfProlog  uint8
+	FrameRegister uint8
+
+	/*
+		FrameOffset is the scaled offset applied to the frame register, in bytes.
+	*/
+	FrameOffset uint32
+	Codes       []UnwindCode
+
+	/*
+		HandlerAddress is the RVA of the language specific handler when
+		Flags contains UNW_FLAG_EHANDLER or UNW_FLAG_UHANDLER.
+	*/
+	HandlerAddress uint32
+
+	/*
+		Chained is the function whose unwind information continues this one
+		when Flags contains UNW_FLAG_CHAININFO.
+	*/
+	Chained *RuntimeFunction
+}
+
+/*
+	RuntimeFunctions returns the entries of the exception directory of an x64 image,
+	sorted by BeginAddress.
+*/
+func (img *Image) RuntimeFunctions() ([]RuntimeFunction, error) {
+	if img.FileHeader.Machine != IMAGE_FILE_MACHINE_AMD64 {
+		return nil, fmt.Errorf("pe: exception directory parsing is not supported for machine %#x", img.FileHeader.Machine)
+	}
+
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_EXCEPTION)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, dd.Size-dd.Size%12)
+	if err := img.ReadRVA(dd.VirtualAddress, raw); err != nil {
+		return nil, err
+	}
+
+	funcs := make([]RuntimeFunction, len(raw)/12)
+	for i := range funcs {
+		funcs[i] = RuntimeFunction{
+			BeginAddress:      binary.LittleEndian.Uint32(raw[12*i:]),
+			EndAddress:        binary.LittleEndian.Uint32(raw[12*i+4:]),
+			UnwindInfoAddress: binary.LittleEndian.Uint32(raw[12*i+8:]),
+		}
+	}
+
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].BeginAddress < funcs[j].BeginAddress
+	})
+
+	return funcs, nil
+}
+
+/*
+	LookupRuntimeFunction finds the function containing rva in funcs,
+	which must be sorted by BeginAddress.
+*/
+func LookupRuntimeFunction(funcs []RuntimeFunction, rva uint32) (RuntimeFunction, bool) {
+	i := sort.Search(len(funcs), func(i int) bool {
+		return funcs[i].BeginAddress > rva
+	})
+
+	if i == 0 || !funcs[i-1].Contains(rva) {
+		return RuntimeFunction{}, false
+	}
+
+	return funcs[i-1], true
+}
+
+/*
+	UnwindInfo reads and parses the UNWIND_INFO at rva.
+*/
+func (img *Image) UnwindInfo(rva uint32) (*UnwindInfo, error) {
+	var hdr [4]byte
+	if err := img.ReadRVA(rva, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	count := int(hdr[2])
+	size := 4 + 2*((count+1)&^1) + 12
+
+	buf := make([]byte, size)
+	if err := img.ReadRVA(rva, buf); err != nil {
+		// The trailer is optional, retry without it in case the
+		// structure is at the very end of the image.
+		buf = buf[:size-12]
+		if err := img.ReadRVA(rva, buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return ParseUnwindInfo(buf)
+}
+
+/*
+	ParseUnwindInfo parses an UNWIND_INFO structure and its trailing
+	handler address or chained RUNTIME_FUNCTION.
+*/
+func ParseUnwindInfo(b []byte) (*UnwindInfo, error) {
+	if len(b) < 4 {
+		return nil, errors.New("pe: truncated UNWIND_INFO")
+	}
+
+	info := &UnwindInfo{
+		Version:       b[0] & 0x7,
+		Flags:         b[0] >> 3,
+		SizeOfProlog:  b[1],
+		FrameRegister: b[3] & 0xF,
+		FrameOffset:   uint32(b[3]>>4) * 16,
+	}
+
+	if info.Version != 1 && info.Version != 2 {
+		return nil, fmt.Errorf("pe: unsupported UNWIND_INFO version %d", info.Version)
+	}
+
+	count := int(b[2])
+	codesEnd := 4 + 2*count
+	if len(b) < codesEnd {
+		return nil, errors.New("pe: truncated unwind codes")
+	}
+
+	slot := func(i int) uint16 {
+		return binary.LittleEndian.Uint16(b[4+2*i:])
+	}
+
+	for i := 0; i < count; {
+		code := UnwindCode{
+			CodeOffset: b[4+2*i],
+			Op:         UnwindOp(b[5+2*i] & 0xF),
+			OpInfo:     b[5+2*i] >> 4,
+			Slots:      1,
+		}
+
+		switch code.Op {
+		case UWOP_PUSH_NONVOL, UWOP_SET_FPREG, UWOP_PUSH_MACHFRAME:
+		case UWOP_ALLOC_SMALL:
+			code.Value = uint32(code.OpInfo)*8 + 8
+		case UWOP_ALLOC_LARGE:
+			if code.OpInfo == 0 {
+				code.Slots = 2
+			} else {
+				code.Slots = 3
+			}
+		case UWOP_SAVE_NONVOL, UWOP_SAVE_XMM128, UWOP_EPILOG:
+			code.Slots = 2
+		case UWOP_SAVE_NONVOL_FAR, UWOP_SAVE_XMM128_FAR, UWOP_SPARE_CODE:
+			code.Slots = 3
+		default:
+			return nil, fmt.Errorf("pe: invalid unwind code %d", code.Op)
+		}
+
+		if i+code.Slots > count {
+			return nil, fmt.Errorf("pe: unwind code %v is truncated", code.Op)
+		}
+
+		switch code.Op {
+		case UWOP_ALLOC_LARGE:
+			if code.OpInfo == 0 {
+				code.Value = uint32(slot(i+1)) * 8
+			} else {
+				code.Value = uint32(slot(i+1)) | uint32(slot(i+2))<<16
+			}
+		case UWOP_SAVE_NONVOL:
+			code.Value = uint32(slot(i+1)) * 8
+		case UWOP_SAVE_XMM128:
+			code.Value = uint32(slot(i+1)) * 16
+		case UWOP_SAVE_NONVOL_FAR, UWOP_SAVE_XMM128_FAR:
+			code.Value = uint32(slot(i+1)) | uint32(slot(i+2))<<16
+		case UWOP_EPILOG:
+			code.Value = uint32(slot(i + 1))
+		}
+
+		info.Codes = append(info.Codes, code)
+		i += code.Slots
+	}
+
+	trailer := 4 + 2*((count+1)&^1)
+	switch {
+	case info.Flags&UNW_FLAG_CHAININFO != 0:
+		if len(b) < trailer+12 {
+			return nil, errors.New("pe: truncated chained unwind info")
+		}
+		info.Chained = &RuntimeFunction{
+			BeginAddress:      binary.LittleEndian.Uint32(b[trailer:]),
+			EndAddress:        binary.LittleEndian.Uint32(b[trailer+4:]),
+			UnwindInfoAddress: binary.LittleEndian.Uint32(b[trailer+8:]),
+		}
+
+	case info.Flags&(UNW_FLAG_EHANDLER|UNW_FLAG_UHANDLER) != 0:
+		if len(b) < trailer+4 {
+			return nil, errors.New("pe: truncated exception handler address")
+		}
+		info.HandlerAddress = binary.LittleEndian.Uint32(b[trailer:])
+	}
+
+	return info, nil
+}
@@ -0,0 +1,175 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+	Export is a single entry of an image's export table.
+	A function exported under several names has one Export for each name.
+*/
+type Export struct {
+	/*
+		Name is the exported name, or empty if the function is exported by ordinal only.
+	*/
+	Name string
+
+	/*
+		Ordinal is the biased ordinal of the export.
+	*/
+	Ordinal uint16
+
+	/*
+		RVA is the address of the export relative to the image base.
+		For forwarded exports RVA points at the forwarder string.
+	*/
+	RVA uint32
+
+	/*
+		Forwarder is set to "dll.Function" or "dll.#ordinal" when the export
+		is forwarded to another module.
+	*/
+	Forwarder string
+}
+
+/*
+	ExportDirectory is the parsed IMAGE_EXPORT_DIRECTORY of an image.
+*/
+type ExportDirectory struct {
+	/*
+		Name is the name the image was linked as, such as "KERNEL32.dll".
+	*/
+	Name string
+
+	/*
+		Base is the ordinal base of the export address table.
+	*/
+	Base uint32
+
+	Exports []Export
+}
+
+/*
+	Exports parses the export directory of the image.
+	Entries of the export address table that are zero are skipped.
+*/
+func (img *Image) Exports() (*ExportDirectory, error) {
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_EXPORT)
+	if err != nil {
+		return nil, err
+	}
+
+	var hdr [40]byte
+	if err := img.ReadRVA(dd.VirtualAddress, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	nameRVA := binary.LittleEndian.Uint32(hdr[12:])
+	base := binary.LittleEndian.Uint32(hdr[16:])
+	numFuncs := binary.LittleEndian.Uint32(hdr[20:])
+	numNames := binary.LittleEndian.Uint32(hdr[24:])
+	funcsRVA := binary.LittleEndian.Uint32(hdr[28:])
+	namesRVA := binary.LittleEndian.Uint32(hdr[32:])
+	ordsRVA := binary.LittleEndian.Uint32(hdr[36:])
+
+	// Aliases can make the names outnumber the functions; both counts are
+	// bounded so a corrupt header cannot force a huge allocation.
+	if numFuncs > 0x10000 || numNames > 0x10000 {
+		return nil, fmt.Errorf("pe: export directory is corrupt (%d functions, %d names)", numFuncs, numNames)
+	}
+
+	ed := &ExportDirectory{Base: base}
+	if nameRVA != 0 {
+		if ed.Name, err = img.ReadString(nameRVA); err != nil {
+			return nil, err
+		}
+	}
+
+	funcs := make([]byte, 4*numFuncs)
+	if err := img.ReadRVA(funcsRVA, funcs); err != nil {
+		return nil, err
+	}
+
+	names := make([][]string, numFuncs)
+	if numNames > 0 {
+		nameRVAs := make([]byte, 4*numNames)
+		if err := img.ReadRVA(namesRVA, nameRVAs); err != nil {
+			return nil, err
+		}
+
+		ords := make([]byte, 2*numNames)
+		if err := img.ReadRVA(ordsRVA, ords); err != nil {
+			return nil, err
+		}
+
+		for i := uint32(0); i < numNames; i++ {
+			idx := binary.LittleEndian.Uint16(ords[2*i:])
+			if uint32(idx) >= numFuncs {
+				continue
+			}
+
+			name, err := img.ReadString(binary.LittleEndian.Uint32(nameRVAs[4*i:]))
+			if err != nil {
+				return nil, err
+			}
+			names[idx] = append(names[idx], name)
+		}
+	}
+
+	for i := uint32(0); i < numFuncs; i++ {
+		rva := binary.LittleEndian.Uint32(funcs[4*i:])
+		if rva == 0 {
+			continue
+		}
+
+		exp := Export{
+			Ordinal: uint16(base + i),
+			RVA:     rva,
+		}
+
+		if rva >= dd.VirtualAddress && rva-dd.VirtualAddress < dd.Size {
+			if exp.Forwarder, err = img.ReadString(rva); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(names[i]) == 0 {
+			ed.Exports = append(ed.Exports, exp)
+			continue
+		}
+
+		for _, name := range names[i] {
+			exp.Name = name
+			ed.Exports = append(ed.Exports, exp)
+		}
+	}
+
+	return ed, nil
+}
+
+/*
+	Lookup returns the export with the given name.
+*/
+func (ed *ExportDirectory) Lookup(name string) (Export, bool) {
+	for _, exp := range ed.Exports {
+		if exp.Name == name {
+			return exp, true
+		}
+	}
+
+	return Export{}, false
+}
+
+/*
+	LookupOrdinal returns the export with the given biased ordinal.
+*/
+func (ed *ExportDirectory) LookupOrdinal(ordinal uint16) (Export, bool) {
+	for _, exp := range ed.Exports {
+		if exp.Ordinal == ordinal {
+			return exp, true
+		}
+	}
+
+	return Export{}, false
+}
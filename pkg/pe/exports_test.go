@@ -0,0 +1,116 @@
+package pe_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+)
+
+func exportImage(t *testing.T, is64 bool) []byte {
+	t.Helper()
+
+	b := petest.New(is64)
+	b.AddSection(".text", make([]byte, 0x100), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+
+	rva := b.NextRVA()
+	dir := petest.ExportDirectory(rva, "test.dll", 5, []petest.Export{
+		{Names: []string{"Alpha"}, RVA: 0x1000},
+		{Names: []string{"Beta", "BetaAlias"}, RVA: 0x1010},
+		{RVA: 0x1020},
+		{},
+		{Names: []string{"Forwarded"}, Forwarder: "NTDLL.RtlAllocateHeap"},
+	})
+	b.AddSection(".edata", dir, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT, rva, len(dir))
+
+	return b.Bytes()
+}
+
+func TestExports(t *testing.T) {
+	for _, is64 := range []bool{false, true} {
+		data := exportImage(t, is64)
+
+		for _, open := range []func([]byte) (*pe.Image, error){
+			func(b []byte) (*pe.Image, error) { return pe.NewFile(bytes.NewReader(b)) },
+			func(b []byte) (*pe.Image, error) { return pe.NewImage(bytes.NewReader(b)) },
+		} {
+			img, err := open(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ed, err := img.Exports()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ed.Name != "test.dll" || ed.Base != 5 {
+				t.Errorf("got name %q base %d", ed.Name, ed.Base)
+			}
+
+			rva := ed.Exports[len(ed.Exports)-1].RVA
+			want := []pe.Export{
+				{Name: "Alpha", Ordinal: 5, RVA: 0x1000},
+				{Name: "Beta", Ordinal: 6, RVA: 0x1010},
+				{Name: "BetaAlias", Ordinal: 6, RVA: 0x1010},
+				{Ordinal: 7, RVA: 0x1020},
+				{Name: "Forwarded", Ordinal: 9, RVA: rva, Forwarder: "NTDLL.RtlAllocateHeap"},
+			}
+
+			if !reflect.DeepEqual(ed.Exports, want) {
+				t.Errorf("64-bit %v: got exports\n%+v\nwant\n%+v", is64, ed.Exports, want)
+			}
+
+			if exp, ok := ed.Lookup("BetaAlias"); !ok || exp.RVA != 0x1010 {
+				t.Errorf("Lookup(BetaAlias) = %+v, %v", exp, ok)
+			}
+
+			if exp, ok := ed.LookupOrdinal(7); !ok || exp.RVA != 0x1020 || exp.Name != "" {
+				t.Errorf("LookupOrdinal(7) = %+v, %v", exp, ok)
+			}
+
+			if _, ok := ed.LookupOrdinal(8); ok {
+				t.Error("LookupOrdinal(8) found the gap in the export address table")
+			}
+
+			if _, ok := ed.Lookup("Gamma"); ok {
+				t.Error("Lookup(Gamma) found a missing export")
+			}
+		}
+	}
+}
+
+func TestExportsMissingDirectory(t *testing.T) {
+	b := petest.New(true)
+	b.AddSection(".text", make([]byte, 0x10), pe.IMAGE_SCN_CNT_CODE)
+
+	img, err := pe.NewFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := img.Exports(); !errors.Is(err, pe.ErrNoDirectory) {
+		t.Errorf("got %v, want ErrNoDirectory", err)
+	}
+}
+
+func TestExportsCorrupt(t *testing.T) {
+	data := exportImage(t, true)
+
+	img, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dd, _ := img.Directory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT)
+
+	// More functions than 16-bit ordinals can address.
+	data[dd.VirtualAddress+22] = 0xFF
+	if _, err := img.Exports(); err == nil {
+		t.Error("corrupt export directory parsed without error")
+	}
+}
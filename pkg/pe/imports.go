@@ -0,0 +1,110 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+	ImportedFunction is a function imported by name or by ordinal.
+*/
+type ImportedFunction struct {
+	/*
+		Name is empty when the function is imported by ordinal.
+	*/
+	Name    string
+	Hint    uint16
+	Ordinal uint16
+
+	/*
+		IAT is the RVA of the import address table slot the loader writes
+		the address of the function to.
+	*/
+	IAT uint32
+}
+
+/*
+	Import lists the functions an image imports from one module.
+*/
+type Import struct {
+	Module    string
+	Functions []ImportedFunction
+}
+
+/*
+	Imports parses the import directory of the image.
+
+	Functions are read from the import lookup table, or from the import
+	address table when the lookup table is missing, as with some old linkers.
+	Bound imports are ignored, the names are always returned.
+*/
+func (img *Image) Imports() ([]Import, error) {
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_IMPORT)
+	if err != nil {
+		return nil, err
+	}
+
+	thunkSize := uint32(4)
+	ordinalFlag := uint64(1) << 31
+	if img.Is64() {
+		thunkSize, ordinalFlag = 8, 1<<63
+	}
+
+	var imports []Import
+	for rva := dd.VirtualAddress; ; rva += 20 {
+		var desc [20]byte
+		if err := img.ReadRVA(rva, desc[:]); err != nil {
+			return nil, err
+		}
+
+		lookup := binary.LittleEndian.Uint32(desc[0:])
+		nameRVA := binary.LittleEndian.Uint32(desc[12:])
+		iat := binary.LittleEndian.Uint32(desc[16:])
+		if nameRVA == 0 && iat == 0 {
+			break
+		}
+
+		imp := Import{}
+		if imp.Module, err = img.ReadString(nameRVA); err != nil {
+			return nil, err
+		}
+
+		if lookup == 0 {
+			lookup = iat
+		}
+
+		for i := uint32(0); ; i++ {
+			var b [8]byte
+			if err := img.ReadRVA(lookup+i*thunkSize, b[:thunkSize]); err != nil {
+				return nil, err
+			}
+
+			thunk := binary.LittleEndian.Uint64(b[:])
+			if thunk == 0 {
+				break
+			}
+
+			fn := ImportedFunction{IAT: iat + i*thunkSize}
+			if thunk&ordinalFlag != 0 {
+				fn.Ordinal = uint16(thunk)
+			} else {
+				hintRVA := uint32(thunk) & 0x7FFFFFFF
+				if fn.Hint, err = img.readUint16(hintRVA); err != nil {
+					return nil, err
+				}
+				if fn.Name, err = img.ReadString(hintRVA + 2); err != nil {
+					return nil, err
+				}
+			}
+
+			imp.Functions = append(imp.Functions, fn)
+			if len(imp.Functions) > 0x10000 {
+				return nil, fmt.Errorf("pe: import lookup table of %s is not terminated", imp.Module)
+			}
+		}
+
+		imports = append(imports, imp)
+	}
+
+	return imports, nil
+}
@@ -0,0 +1,433 @@
+/*
+	Package pe parses Portable Executable images, either as files on disk
+	or as images that have been mapped into the address space of a process.
+
+	The package is written in pure Go so that it can be used to inspect
+	images read out of a remote process as well as images on any platform.
+*/
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	IMAGE_DOS_SIGNATURE uint16 = 0x5A4D
+	IMAGE_NT_SIGNATURE  uint32 = 0x00004550
+
+	IMAGE_NT_OPTIONAL_HDR32_MAGIC uint16 = 0x10B
+	IMAGE_NT_OPTIONAL_HDR64_MAGIC uint16 = 0x20B
+
+	IMAGE_FILE_MACHINE_I386  uint16 = 0x014C
+	IMAGE_FILE_MACHINE_AMD64 uint16 = 0x8664
+
+	IMAGE_FILE_DLL uint16 = 0x2000
+)
+
+const (
+	IMAGE_DIRECTORY_ENTRY_EXPORT         = 0
+	IMAGE_DIRECTORY_ENTRY_IMPORT         = 1
+	IMAGE_DIRECTORY_ENTRY_RESOURCE       = 2
+	IMAGE_DIRECTORY_ENTRY_EXCEPTION      = 3
+	IMAGE_DIRECTORY_ENTRY_SECURITY       = 4
+	IMAGE_DIRECTORY_ENTRY_BASERELOC      = 5
+	IMAGE_DIRECTORY_ENTRY_DEBUG          = 6
+	IMAGE_DIRECTORY_ENTRY_ARCHITECTURE   = 7
+	IMAGE_DIRECTORY_ENTRY_GLOBALPTR      = 8
+	IMAGE_DIRECTORY_ENTRY_TLS            = 9
+	IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG    = 10
+	IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT   = 11
+	IMAGE_DIRECTORY_ENTRY_IAT            = 12
+	IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT   = 13
+	IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR = 14
+
+	IMAGE_NUMBEROF_DIRECTORY_ENTRIES = 16
+)
+
+const (
+	IMAGE_SCN_CNT_CODE               uint32 = 0x00000020
+	IMAGE_SCN_CNT_INITIALIZED_DATA   uint32 = 0x00000040
+	IMAGE_SCN_CNT_UNINITIALIZED_DATA uint32 = 0x00000080
+	IMAGE_SCN_MEM_DISCARDABLE        uint32 = 0x02000000
+	IMAGE_SCN_MEM_NOT_CACHED         uint32 = 0x04000000
+	IMAGE_SCN_MEM_SHARED             uint32 = 0x10000000
+	IMAGE_SCN_MEM_EXECUTE            uint32 = 0x20000000
+	IMAGE_SCN_MEM_READ               uint32 = 0x40000000
+	IMAGE_SCN_MEM_WRITE              uint32 = 0x80000000
+)
+
+var (
+	ErrNotPE       = errors.New("pe: not a PE image")
+	ErrBadRVA      = errors.New("pe: RVA is outside of the image")
+	ErrNoDirectory = errors.New("pe: data directory is not present")
+)
+
+/*
+	FileHeader is the COFF file header (IMAGE_FILE_HEADER).
+*/
+type FileHeader struct {
+	Machine              uint16
+	NumberOfSections     uint16
+	TimeDateStamp        uint32
+	PointerToSymbolTable uint32
+	NumberOfSymbols      uint32
+	SizeOfOptionalHeader uint16
+	Characteristics      uint16
+}
+
+/*
+	DataDirectory describes the location and size of a table in the image.
+*/
+type DataDirectory struct {
+	VirtualAddress uint32
+	Size           uint32
+}
+
+/*
+	OptionalHeader holds the fields of IMAGE_OPTIONAL_HEADER32 and
+	IMAGE_OPTIONAL_HEADER64 that are common to both formats.
+	Fields that differ in width are widened to 64 bits.
+*/
+type OptionalHeader struct {
+	Magic               uint16
+	AddressOfEntryPoint uint32
+	BaseOfCode          uint32
+	ImageBase           uint64
+	SectionAlignment    uint32
+	FileAlignment       uint32
+	SizeOfImage         uint32
+	SizeOfHeaders       uint32
+	CheckSum            uint32
+	Subsystem           uint16
+	DllCharacteristics  uint16
+	SizeOfStackReserve  uint64
+	SizeOfStackCommit   uint64
+	NumberOfRvaAndSizes uint32
+	DataDirectory       [IMAGE_NUMBEROF_DIRECTORY_ENTRIES]DataDirectory
+}
+
+/*
+	Section describes a section header (IMAGE_SECTION_HEADER).
+*/
+type Section struct {
+	Name             string
+	VirtualSize      uint32
+	VirtualAddress   uint32
+	SizeOfRawData    uint32
+	PointerToRawData uint32
+	Characteristics  uint32
+}
+
+/*
+	Contains reports whether rva lies inside the section once it is mapped.
+*/
+func (s *Section) Contains(rva uint32) bool {
+	size := s.VirtualSize
+	if size == 0 {
+		size = s.SizeOfRawData
+	}
+
+	return rva >= s.VirtualAddress && rva-s.VirtualAddress < size
+}
+
+/*
+	Image is a parsed PE image.
+
+	Depending on how it was opened, the underlying reader is either
+	addressed by file offset (NewFile) or by RVA (NewImage).
+*/
+type Image struct {
+	FileHeader     FileHeader
+	OptionalHeader OptionalHeader
+	Sections       []Section
+
+	/*
+		OptionalHeaderOffset is the offset of the optional header from the start of the image.
+	*/
+	OptionalHeaderOffset int64
+
+	r      io.ReaderAt
+	mapped bool
+}
+
+/*
+	NewFile parses a PE image laid out as a file on disk.
+*/
+func NewFile(r io.ReaderAt) (*Image, error) {
+	return parse(r, false)
+}
+
+/*
+	NewImage parses a PE image laid out as it is mapped in memory by the loader,
+	for example a module read out of a process with ReadProcessMemory.
+	Offsets into r are treated as RVAs.
+*/
+func NewImage(r io.ReaderAt) (*Image, error) {
+	return parse(r, true)
+}
+
+func parse(r io.ReaderAt, mapped bool) (*Image, error) {
+	var dos [64]byte
+	if _, err := r.ReadAt(dos[:], 0); err != nil {
+		return nil, fmt.Errorf("pe: reading DOS header: %w", err)
+	}
+
+	if binary.LittleEndian.Uint16(dos[0:]) != IMAGE_DOS_SIGNATURE {
+		return nil, ErrNotPE
+	}
+
+	ntOffset := int64(binary.LittleEndian.Uint32(dos[0x3C:]))
+
+	var nt [24]byte
+	if _, err := r.ReadAt(nt[:], ntOffset); err != nil {
+		return nil, fmt.Errorf("pe: reading NT headers: %w", err)
+	}
+
+	if binary.LittleEndian.Uint32(nt[0:]) != IMAGE_NT_SIGNATURE {
+		return nil, ErrNotPE
+	}
+
+	img := &Image{
+		r:                    r,
+		mapped:               mapped,
+		OptionalHeaderOffset: ntOffset + 24,
+	}
+
+	fh := &img.FileHeader
+	fh.Machine = binary.LittleEndian.Uint16(nt[4:])
+	fh.NumberOfSections = binary.LittleEndian.Uint16(nt[6:])
+	fh.TimeDateStamp = binary.LittleEndian.Uint32(nt[8:])
+	fh.PointerToSymbolTable = binary.LittleEndian.Uint32(nt[12:])
+	fh.NumberOfSymbols = binary.LittleEndian.Uint32(nt[16:])
+	fh.SizeOfOptionalHeader = binary.LittleEndian.Uint16(nt[20:])
+	fh.Characteristics = binary.LittleEndian.Uint16(nt[22:])
+
+	opt := make([]byte, fh.SizeOfOptionalHeader)
+	if _, err := r.ReadAt(opt, img.OptionalHeaderOffset); err != nil {
+		return nil, fmt.Errorf("pe: reading optional header: %w", err)
+	}
+
+	if err := img.parseOptionalHeader(opt); err != nil {
+		return nil, err
+	}
+
+	sections := make([]byte, 40*int(fh.NumberOfSections))
+	if _, err := r.ReadAt(sections, img.OptionalHeaderOffset+int64(fh.SizeOfOptionalHeader)); err != nil {
+		return nil, fmt.Errorf("pe: reading section headers: %w", err)
+	}
+
+	img.Sections = make([]Section, fh.NumberOfSections)
+	for i := range img.Sections {
+		b := sections[i*40:]
+		img.Sections[i] = Section{
+			Name:             strings.TrimRight(string(b[:8]), "\x00"),
+			VirtualSize:      binary.LittleEndian.Uint32(b[8:]),
+			VirtualAddress:   binary.LittleEndian.Uint32(b[12:]),
+			SizeOfRawData:    binary.LittleEndian.Uint32(b[16:]),
+			PointerToRawData: binary.LittleEndian.Uint32(b[20:]),
+			Characteristics:  binary.LittleEndian.Uint32(b[36:]),
+		}
+	}
+
+	return img, nil
+}
+
+func (img *Image) parseOptionalHeader(b []byte) error {
+	if len(b) < 2 {
+		return ErrNotPE
+	}
+
+	oh := &img.OptionalHeader
+	oh.Magic = binary.LittleEndian.Uint16(b[0:])
+
+	var dirOffset int
+	switch oh.Magic {
+	case IMAGE_NT_OPTIONAL_HDR32_MAGIC:
+		if len(b) < 96 {
+			return ErrNotPE
+		}
+		oh.ImageBase = uint64(binary.LittleEndian.Uint32(b[28:]))
+		oh.SizeOfStackReserve = uint64(binary.LittleEndian.Uint32(b[72:]))
+		oh.SizeOfStackCommit = uint64(binary.LittleEndian.Uint32(b[76:]))
+		oh.NumberOfRvaAndSizes = binary.LittleEndian.Uint32(b[92:])
+		dirOffset = 96
+
+	case IMAGE_NT_OPTIONAL_HDR64_MAGIC:
+		if len(b) < 112 {
+			return ErrNotPE
+		}
+		oh.ImageBase = binary.LittleEndian.Uint64(b[24:])
+		oh.SizeOfStackReserve = binary.LittleEndian.Uint64(b[72:])
+		oh.SizeOfStackCommit = binary.LittleEndian.Uint64(b[80:])
+		oh.NumberOfRvaAndSizes = binary.LittleEndian.Uint32(b[108:])
+		dirOffset = 112
+
+	default:
+		return fmt.Errorf("pe: unknown optional header magic %#x", oh.Magic)
+	}
+
+	oh.AddressOfEntryPoint = binary.LittleEndian.Uint32(b[16:])
+	oh.BaseOfCode = binary.LittleEndian.Uint32(b[20:])
+	oh.SectionAlignment = binary.LittleEndian.Uint32(b[32:])
+	oh.FileAlignment = binary.LittleEndian.Uint32(b[36:])
+	oh.SizeOfImage = binary.LittleEndian.Uint32(b[56:])
+	oh.SizeOfHeaders = binary.LittleEndian.Uint32(b[60:])
+	oh.CheckSum = binary.LittleEndian.Uint32(b[64:])
+	oh.Subsystem = binary.LittleEndian.Uint16(b[68:])
+	oh.DllCharacteristics = binary.LittleEndian.Uint16(b[70:])
+
+	for i := 0; i < IMAGE_NUMBEROF_DIRECTORY_ENTRIES && uint32(i) < oh.NumberOfRvaAndSizes; i++ {
+		off := dirOffset + i*8
+		if off+8 > len(b) {
+			break
+		}
+		oh.DataDirectory[i] = DataDirectory{
+			VirtualAddress: binary.LittleEndian.Uint32(b[off:]),
+			Size:           binary.LittleEndian.Uint32(b[off+4:]),
+		}
+	}
+
+	return nil
+}
+
+/*
+	Is64 reports whether the image is a PE32+ (64-bit) image.
+*/
+func (img *Image) Is64() bool {
+	return img.OptionalHeader.Magic == IMAGE_NT_OPTIONAL_HDR64_MAGIC
+}
+
+/*
+	Mapped reports whether the image was opened with its in-memory layout.
+*/
+func (img *Image) Mapped() bool {
+	return img.mapped
+}
+
+/*
+	Reader returns the reader the image was parsed from.
+*/
+func (img *Image) Reader() io.ReaderAt {
+	return img.r
+}
+
+/*
+	Directory returns the data directory entry at index, or ErrNoDirectory
+	if the image does not contain that directory.
+*/
+func (img *Image) Directory(index int) (DataDirectory, error) {
+	if index < 0 || index >= IMAGE_NUMBEROF_DIRECTORY_ENTRIES {
+		return DataDirectory{}, ErrNoDirectory
+	}
+
+	dd := img.OptionalHeader.DataDirectory[index]
+	if dd.VirtualAddress == 0 || dd.Size == 0 {
+		return dd, ErrNoDirectory
+	}
+
+	return dd, nil
+}
+
+/*
+	SectionByRVA returns the section containing rva, or nil if the RVA
+	does not fall inside any section.
+*/
+func (img *Image) SectionByRVA(rva uint32) *Section {
+	for i := range img.Sections {
+		if img.Sections[i].Contains(rva) {
+			return &img.Sections[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+	Offset translates rva into an offset into the underlying reader.
+*/
+func (img *Image) Offset(rva uint32) (int64, error) {
+	if img.mapped {
+		return int64(rva), nil
+	}
+
+	if rva < img.OptionalHeader.SizeOfHeaders {
+		return int64(rva), nil
+	}
+
+	s := img.SectionByRVA(rva)
+	if s == nil || rva-s.VirtualAddress >= s.SizeOfRawData {
+		return 0, ErrBadRVA
+	}
+
+	return int64(s.PointerToRawData) + int64(rva-s.VirtualAddress), nil
+}
+
+/*
+	ReadRVA reads len(buf) bytes starting at rva.
+*/
+func (img *Image) ReadRVA(rva uint32, buf []byte) error {
+	off, err := img.Offset(rva)
+	if err != nil {
+		return err
+	}
+
+	if _, err := img.r.ReadAt(buf, off); err != nil {
+		return fmt.Errorf("pe: reading RVA %#x: %w", rva, err)
+	}
+
+	return nil
+}
+
+/*
+	ReadString reads a NUL terminated ASCII string starting at rva.
+*/
+func (img *Image) ReadString(rva uint32) (string, error) {
+	var sb strings.Builder
+	var chunk [64]byte
+
+	for sb.Len() < 4096 {
+		off, err := img.Offset(rva)
+		if err != nil {
+			return "", err
+		}
+
+		n, err := img.r.ReadAt(chunk[:], off)
+		for i := 0; i < n; i++ {
+			if chunk[i] == 0 {
+				sb.Write(chunk[:i])
+				return sb.String(), nil
+			}
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("pe: reading string at RVA %#x: %w", rva, err)
+		}
+
+		sb.Write(chunk[:n])
+		rva += uint32(n)
+	}
+
+	return "", fmt.Errorf("pe: string at RVA %#x is not terminated", rva)
+}
+
+func (img *Image) readUint16(rva uint32) (uint16, error) {
+	var b [2]byte
+	if err := img.ReadRVA(rva, b[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint16(b[:]), nil
+}
+
+func (img *Image) readUint32(rva uint32) (uint32, error) {
+	var b [4]byte
+	if err := img.ReadRVA(rva, b[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
@@ -0,0 +1,96 @@
+package petest
+
+import "encoding/binary"
+
+/*
+	ImportedFunction is a function of an import directory built by ImportDirectory.
+*/
+type ImportedFunction struct {
+	/*
+		Name imports the function by name. If it is empty, the function is imported by Ordinal.
+	*/
+	Name    string
+	Hint    uint16
+	Ordinal uint16
+}
+
+/*
+	Import lists the functions imported from one module.
+*/
+type Import struct {
+	Module    string
+	Functions []ImportedFunction
+}
+
+/*
+	ImportDirectory builds an import directory to be placed at rva. The
+	descriptors are followed by the lookup and address tables of each module,
+	then by the names, so the whole result can be used as the data directory entry.
+
+	iat holds the RVA of the import address table slot of each function,
+	indexed like imports.
+*/
+func ImportDirectory(rva uint32, is64 bool, imports []Import) (dir []byte, iat [][]uint32) {
+	thunkSize := uint32(4)
+	ordinalFlag := uint64(1) << 31
+	if is64 {
+		thunkSize, ordinalFlag = 8, 1<<63
+	}
+
+	// The descriptors are terminated by a zeroed one.
+	tables := uint32(20 * (len(imports) + 1))
+
+	size := tables
+	for _, imp := range imports {
+		size += 2 * thunkSize * uint32(len(imp.Functions)+1)
+	}
+
+	b := make([]byte, size)
+	put := func(s string) uint32 {
+		off := uint32(len(b))
+		b = append(b, s...)
+		b = append(b, 0)
+		return rva + off
+	}
+
+	putThunk := func(off uint32, v uint64) {
+		if is64 {
+			binary.LittleEndian.PutUint64(b[off:], v)
+		} else {
+			binary.LittleEndian.PutUint32(b[off:], uint32(v))
+		}
+	}
+
+	next := tables
+	iat = make([][]uint32, len(imports))
+	for i, imp := range imports {
+		n := thunkSize * uint32(len(imp.Functions)+1)
+		lookup, addrs := next, next+n
+		next += 2 * n
+
+		name := put(imp.Module)
+		binary.LittleEndian.PutUint32(b[20*i:], rva+lookup)
+		binary.LittleEndian.PutUint32(b[20*i+12:], name)
+		binary.LittleEndian.PutUint32(b[20*i+16:], rva+addrs)
+
+		for j, fn := range imp.Functions {
+			thunk := ordinalFlag | uint64(fn.Ordinal)
+			if fn.Name != "" {
+				thunk = uint64(rva + uint32(len(b)))
+				b = append(b, byte(fn.Hint), byte(fn.Hint>>8))
+				put(fn.Name)
+				if len(b)%2 != 0 {
+					b = append(b, 0)
+				}
+			}
+
+			// The address table holds the same thunks until the loader binds it.
+			off := thunkSize * uint32(j)
+			putThunk(lookup+off, thunk)
+			putThunk(addrs+off, thunk)
+			iat[i] = append(iat[i], rva+addrs+off)
+		}
+	}
+
+	return b, iat
+}
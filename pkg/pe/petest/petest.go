@@ -0,0 +1,268 @@
+/*
+	Package petest builds small PE images for tests.
+
+	Sections are aligned to the same value in the file and in memory, so the
+	bytes returned by Builder.Bytes can be parsed both as a file and as an
+	image mapped by the loader.
+*/
+package petest
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+/*
+	Alignment is the section and file alignment of built images.
+	The headers occupy the first Alignment bytes.
+*/
+const Alignment = 0x1000
+
+const (
+	machineI386  = 0x014C
+	machineAMD64 = 0x8664
+
+	ntHeadersOffset = 0x80
+)
+
+type section struct {
+	name            string
+	rva             uint32
+	data            []byte
+	characteristics uint32
+}
+
+/*
+	Builder lays out a PE image from sections and data directories.
+*/
+type Builder struct {
+	Machine            uint16
+	Characteristics    uint16
+	ImageBase          uint64
+	EntryPoint         uint32
+	Subsystem          uint16
+	DllCharacteristics uint16
+
+	sections    []section
+	directories [16][2]uint32
+}
+
+/*
+	New returns a Builder for a 64-bit or 32-bit DLL.
+*/
+func New(is64 bool) *Builder {
+	b := &Builder{
+		Machine:         machineI386,
+		Characteristics: 0x2102, // IMAGE_FILE_DLL | IMAGE_FILE_32BIT_MACHINE | IMAGE_FILE_EXECUTABLE_IMAGE
+		ImageBase:       0x10000000,
+		Subsystem:       2,
+	}
+
+	if is64 {
+		b.Machine = machineAMD64
+		b.Characteristics = 0x2022 // IMAGE_FILE_DLL | IMAGE_FILE_LARGE_ADDRESS_AWARE | IMAGE_FILE_EXECUTABLE_IMAGE
+		b.ImageBase = 0x180000000
+	}
+
+	return b
+}
+
+/*
+	Is64 reports whether the Builder builds a PE32+ image.
+*/
+func (b *Builder) Is64() bool {
+	return b.Machine == machineAMD64
+}
+
+func align(n uint32) uint32 {
+	return (n + Alignment - 1) &^ (Alignment - 1)
+}
+
+/*
+	NextRVA returns the RVA the next added section will be placed at,
+	so that its contents can refer to their own addresses.
+*/
+func (b *Builder) NextRVA() uint32 {
+	if len(b.sections) == 0 {
+		return Alignment
+	}
+
+	last := b.sections[len(b.sections)-1]
+	size := uint32(len(last.data))
+	if size == 0 {
+		size = 1
+	}
+
+	return last.rva + align(size)
+}
+
+/*
+	AddSection appends a section and returns its RVA.
+*/
+func (b *Builder) AddSection(name string, data []byte, characteristics uint32) uint32 {
+	rva := b.NextRVA()
+	b.sections = append(b.sections, section{name: name, rva: rva, data: data, characteristics: characteristics})
+	return rva
+}
+
+/*
+	SetDirectory sets the data directory entry at index.
+*/
+func (b *Builder) SetDirectory(index int, rva uint32, size int) {
+	b.directories[index] = [2]uint32{rva, uint32(size)}
+}
+
+/*
+	Bytes lays out the image.
+*/
+func (b *Builder) Bytes() []byte {
+	size := b.NextRVA()
+	img := make([]byte, size)
+
+	binary.LittleEndian.PutUint16(img, 0x5A4D)
+	binary.LittleEndian.PutUint32(img[0x3C:], ntHeadersOffset)
+	binary.LittleEndian.PutUint32(img[ntHeadersOffset:], 0x4550)
+
+	optSize, dirOffset := 224, 96
+	if b.Is64() {
+		optSize, dirOffset = 240, 112
+	}
+
+	fh := img[ntHeadersOffset+4:]
+	binary.LittleEndian.PutUint16(fh[0:], b.Machine)
+	binary.LittleEndian.PutUint16(fh[2:], uint16(len(b.sections)))
+	binary.LittleEndian.PutUint16(fh[16:], uint16(optSize))
+	binary.LittleEndian.PutUint16(fh[18:], b.Characteristics)
+
+	opt := img[ntHeadersOffset+24:]
+	if b.Is64() {
+		binary.LittleEndian.PutUint16(opt[0:], 0x20B)
+		binary.LittleEndian.PutUint64(opt[24:], b.ImageBase)
+		binary.LittleEndian.PutUint64(opt[72:], 0x100000)
+		binary.LittleEndian.PutUint64(opt[80:], 0x1000)
+		binary.LittleEndian.PutUint64(opt[88:], 0x100000)
+		binary.LittleEndian.PutUint64(opt[96:], 0x1000)
+		binary.LittleEndian.PutUint32(opt[108:], 16)
+	} else {
+		binary.LittleEndian.PutUint16(opt[0:], 0x10B)
+		binary.LittleEndian.PutUint32(opt[28:], uint32(b.ImageBase))
+		binary.LittleEndian.PutUint32(opt[72:], 0x100000)
+		binary.LittleEndian.PutUint32(opt[76:], 0x1000)
+		binary.LittleEndian.PutUint32(opt[80:], 0x100000)
+		binary.LittleEndian.PutUint32(opt[84:], 0x1000)
+		binary.LittleEndian.PutUint32(opt[92:], 16)
+	}
+
+	binary.LittleEndian.PutUint32(opt[16:], b.EntryPoint)
+	binary.LittleEndian.PutUint32(opt[32:], Alignment)
+	binary.LittleEndian.PutUint32(opt[36:], Alignment)
+	binary.LittleEndian.PutUint16(opt[40:], 6)
+	binary.LittleEndian.PutUint16(opt[48:], 6)
+	binary.LittleEndian.PutUint32(opt[56:], size)
+	binary.LittleEndian.PutUint32(opt[60:], Alignment)
+	binary.LittleEndian.PutUint16(opt[68:], b.Subsystem)
+	binary.LittleEndian.PutUint16(opt[70:], b.DllCharacteristics)
+
+	for i, d := range b.directories {
+		binary.LittleEndian.PutUint32(opt[dirOffset+8*i:], d[0])
+		binary.LittleEndian.PutUint32(opt[dirOffset+8*i+4:], d[1])
+	}
+
+	headers := opt[optSize:]
+	for i, s := range b.sections {
+		h := headers[40*i:]
+		copy(h[:8], s.name)
+		binary.LittleEndian.PutUint32(h[8:], uint32(len(s.data)))
+		binary.LittleEndian.PutUint32(h[12:], s.rva)
+		binary.LittleEndian.PutUint32(h[16:], align(uint32(len(s.data))))
+		binary.LittleEndian.PutUint32(h[20:], s.rva)
+		binary.LittleEndian.PutUint32(h[36:], s.characteristics)
+
+		copy(img[s.rva:], s.data)
+	}
+
+	return img
+}
+
+/*
+	Export is a function of an export directory built by ExportDirectory.
+*/
+type Export struct {
+	/*
+		Names are the names the function is exported under. A function
+		without names is exported by ordinal only.
+	*/
+	Names []string
+
+	/*
+		RVA is the address of the function. Zero leaves a gap in the export address table.
+	*/
+	RVA uint32
+
+	/*
+		Forwarder, if set, forwards the export to another module, such as "NTDLL.RtlAllocateHeap".
+	*/
+	Forwarder string
+}
+
+/*
+	ExportDirectory builds an export directory to be placed at rva. The function at
+	index i of exports gets the ordinal base+i. The directory is followed by its tables
+	and strings, so the whole result is the size of the data directory entry.
+*/
+func ExportDirectory(rva uint32, dllName string, base uint32, exports []Export) []byte {
+	type nameEntry struct {
+		name  string
+		index int
+	}
+
+	var names []nameEntry
+	for i, e := range exports {
+		for _, n := range e.Names {
+			names = append(names, nameEntry{n, i})
+		}
+	}
+
+	// The loader binary searches the name table, so it is sorted.
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].name < names[j].name
+	})
+
+	funcs := 40
+	nameTable := funcs + 4*len(exports)
+	ordTable := nameTable + 4*len(names)
+	strs := ordTable + 2*len(names)
+
+	b := make([]byte, strs)
+	put := func(s string) uint32 {
+		off := uint32(len(b))
+		b = append(b, s...)
+		b = append(b, 0)
+		return rva + off
+	}
+
+	name := put(dllName)
+	binary.LittleEndian.PutUint32(b[12:], name)
+	binary.LittleEndian.PutUint32(b[16:], base)
+	binary.LittleEndian.PutUint32(b[20:], uint32(len(exports)))
+	binary.LittleEndian.PutUint32(b[24:], uint32(len(names)))
+	binary.LittleEndian.PutUint32(b[28:], rva+uint32(funcs))
+	binary.LittleEndian.PutUint32(b[32:], rva+uint32(nameTable))
+	binary.LittleEndian.PutUint32(b[36:], rva+uint32(ordTable))
+
+	for i, e := range exports {
+		addr := e.RVA
+		if e.Forwarder != "" {
+			addr = put(e.Forwarder)
+		}
+		binary.LittleEndian.PutUint32(b[funcs+4*i:], addr)
+	}
+
+	for i, n := range names {
+		name := put(n.name)
+		binary.LittleEndian.PutUint32(b[nameTable+4*i:], name)
+		binary.LittleEndian.PutUint16(b[ordTable+2*i:], uint16(n.index))
+	}
+
+	return b
+}
@@ -0,0 +1,61 @@
+package petest
+
+import "encoding/binary"
+
+/*
+	Relocation is a fixup of a base relocation directory built by RelocationDirectory.
+*/
+type Relocation struct {
+	RVA  uint32
+	Type uint8
+
+	/*
+		Param is written in the entry following an IMAGE_REL_BASED_HIGHADJ relocation.
+	*/
+	Param uint16
+}
+
+const relBasedHighAdj = 4
+
+/*
+	RelocationDirectory builds a base relocation directory. A block is started
+	whenever the page of a relocation differs from the previous one, and blocks
+	are padded to 4 bytes with IMAGE_REL_BASED_ABSOLUTE entries as linkers do.
+*/
+func RelocationDirectory(relocs []Relocation) []byte {
+	var b []byte
+	block := -1
+	var page uint32
+
+	end := func() {
+		if block < 0 {
+			return
+		}
+		if (len(b)-block)%4 != 0 {
+			b = append(b, 0, 0)
+		}
+		binary.LittleEndian.PutUint32(b[block+4:], uint32(len(b)-block))
+	}
+
+	for _, r := range relocs {
+		if block < 0 || r.RVA&^0xFFF != page {
+			end()
+			page = r.RVA &^ 0xFFF
+			block = len(b)
+			b = append(b, make([]byte, 8)...)
+			binary.LittleEndian.PutUint32(b[block:], page)
+		}
+
+		var e [4]byte
+		binary.LittleEndian.PutUint16(e[0:], uint16(r.Type)<<12|uint16(r.RVA&0xFFF))
+		binary.LittleEndian.PutUint16(e[2:], r.Param)
+		if r.Type == relBasedHighAdj {
+			b = append(b, e[:4]...)
+		} else {
+			b = append(b, e[:2]...)
+		}
+	}
+
+	end()
+	return b
+}
@@ -0,0 +1,157 @@
+package petest
+
+import (
+	"encoding/binary"
+	"sort"
+	"unicode/utf16"
+)
+
+/*
+	Resource is a leaf of a resource directory built by ResourceDirectory.
+*/
+type Resource struct {
+	Type uint16
+
+	/*
+		Name names the resource by a string. If it is empty, the resource is named by ID.
+	*/
+	Name     string
+	ID       uint16
+	Language uint16
+	Data     []byte
+}
+
+type resourceNode struct {
+	name     string
+	id       uint16
+	children []*resourceNode
+	leaf     *Resource
+	offset   uint32
+}
+
+func (n *resourceNode) child(name string, id uint16) *resourceNode {
+	for _, c := range n.children {
+		if c.name == name && c.id == id {
+			return c
+		}
+	}
+
+	c := &resourceNode{name: name, id: id}
+	n.children = append(n.children, c)
+	return c
+}
+
+/*
+	sortEntries orders the entries of every directory as the resource compiler does:
+	named entries first, sorted by name, followed by the ID entries in ascending order.
+*/
+func (n *resourceNode) sortEntries() {
+	sort.Slice(n.children, func(i, j int) bool {
+		a, b := n.children[i], n.children[j]
+		if (a.name != "") != (b.name != "") {
+			return a.name != ""
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.id < b.id
+	})
+
+	for _, c := range n.children {
+		c.sortEntries()
+	}
+}
+
+/*
+	ResourceDirectory builds a resource directory to be placed at rva, followed by the
+	data entries, the name strings and the resource data.
+*/
+func ResourceDirectory(rva uint32, resources []Resource) []byte {
+	root := &resourceNode{}
+	for i := range resources {
+		r := &resources[i]
+		root.child("", r.Type).child(r.Name, r.ID).child("", r.Language).leaf = r
+	}
+	root.sortEntries()
+
+	// Lay out the directories breadth first, then the leaves, strings and data.
+	var dirs, leaves []*resourceNode
+	size := uint32(0)
+	for queue := []*resourceNode{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		if n.leaf != nil {
+			leaves = append(leaves, n)
+			continue
+		}
+
+		n.offset = size
+		size += 16 + 8*uint32(len(n.children))
+		dirs = append(dirs, n)
+		queue = append(queue, n.children...)
+	}
+
+	for _, n := range leaves {
+		n.offset = size
+		size += 16
+	}
+
+	b := make([]byte, size)
+	names := make(map[string]uint32)
+	for _, n := range dirs {
+		for _, c := range n.children {
+			if c.name == "" {
+				continue
+			}
+			if _, ok := names[c.name]; ok {
+				continue
+			}
+
+			s := utf16.Encode([]rune(c.name))
+			names[c.name] = uint32(len(b))
+			b = append(b, byte(len(s)), byte(len(s)>>8))
+			for _, u := range s {
+				b = append(b, byte(u), byte(u>>8))
+			}
+		}
+	}
+
+	for _, n := range leaves {
+		for len(b)%8 != 0 {
+			b = append(b, 0)
+		}
+
+		binary.LittleEndian.PutUint32(b[n.offset:], rva+uint32(len(b)))
+		binary.LittleEndian.PutUint32(b[n.offset+4:], uint32(len(n.leaf.Data)))
+		b = append(b, n.leaf.Data...)
+	}
+
+	for _, n := range dirs {
+		named := 0
+		for _, c := range n.children {
+			if c.name != "" {
+				named++
+			}
+		}
+
+		binary.LittleEndian.PutUint16(b[n.offset+12:], uint16(named))
+		binary.LittleEndian.PutUint16(b[n.offset+14:], uint16(len(n.children)-named))
+
+		for i, c := range n.children {
+			e := b[n.offset+16+8*uint32(i):]
+
+			id := uint32(c.id)
+			if c.name != "" {
+				id = 0x80000000 | names[c.name]
+			}
+			binary.LittleEndian.PutUint32(e, id)
+
+			offset := c.offset
+			if c.leaf == nil {
+				offset |= 0x80000000
+			}
+			binary.LittleEndian.PutUint32(e[4:], offset)
+		}
+	}
+
+	return b
+}
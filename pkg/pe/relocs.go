@@ -0,0 +1,82 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	IMAGE_REL_BASED_ABSOLUTE uint8 = 0
+	IMAGE_REL_BASED_HIGH     uint8 = 1
+	IMAGE_REL_BASED_LOW      uint8 = 2
+	IMAGE_REL_BASED_HIGHLOW  uint8 = 3
+	IMAGE_REL_BASED_HIGHADJ  uint8 = 4
+	IMAGE_REL_BASED_DIR64    uint8 = 10
+)
+
+const IMAGE_FILE_RELOCS_STRIPPED uint16 = 0x0001
+
+/*
+	BaseRelocation is a single fixup of the base relocation directory.
+*/
+type BaseRelocation struct {
+	/*
+		RVA is the address of the value to fix up.
+	*/
+	RVA  uint32
+	Type uint8
+
+	/*
+		Param is the low 16 bits of the adjusted value for IMAGE_REL_BASED_HIGHADJ,
+		which are stored in the entry following the relocation.
+	*/
+	Param uint16
+}
+
+/*
+	BaseRelocations returns the fixups of the base relocation directory in
+	the order they appear. IMAGE_REL_BASED_ABSOLUTE padding entries are skipped.
+*/
+func (img *Image) BaseRelocations() ([]BaseRelocation, error) {
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_BASERELOC)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, dd.Size)
+	if err := img.ReadRVA(dd.VirtualAddress, raw); err != nil {
+		return nil, err
+	}
+
+	var relocs []BaseRelocation
+	for len(raw) >= 8 {
+		page := binary.LittleEndian.Uint32(raw[0:])
+		size := binary.LittleEndian.Uint32(raw[4:])
+		if size < 8 || size > uint32(len(raw)) {
+			return nil, fmt.Errorf("pe: base relocation block at page %#x is corrupt", page)
+		}
+
+		entries := raw[8:size]
+		for i := 0; i+2 <= len(entries); i += 2 {
+			e := binary.LittleEndian.Uint16(entries[i:])
+			r := BaseRelocation{RVA: page + uint32(e&0xFFF), Type: uint8(e >> 12)}
+
+			switch r.Type {
+			case IMAGE_REL_BASED_ABSOLUTE:
+				continue
+			case IMAGE_REL_BASED_HIGHADJ:
+				i += 2
+				if i+2 > len(entries) {
+					return nil, fmt.Errorf("pe: truncated HIGHADJ relocation at %#x", r.RVA)
+				}
+				r.Param = binary.LittleEndian.Uint16(entries[i:])
+			}
+
+			relocs = append(relocs, r)
+		}
+
+		raw = raw[size:]
+	}
+
+	return relocs, nil
+}
@@ -0,0 +1,247 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+/*
+	Predefined resource types.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/menurc/resource-types
+*/
+const (
+	RT_CURSOR       uint16 = 1
+	RT_BITMAP       uint16 = 2
+	RT_ICON         uint16 = 3
+	RT_MENU         uint16 = 4
+	RT_DIALOG       uint16 = 5
+	RT_STRING       uint16 = 6
+	RT_FONTDIR      uint16 = 7
+	RT_FONT         uint16 = 8
+	RT_ACCELERATOR  uint16 = 9
+	RT_RCDATA       uint16 = 10
+	RT_MESSAGETABLE uint16 = 11
+	RT_GROUP_CURSOR uint16 = 12
+	RT_GROUP_ICON   uint16 = 14
+	RT_VERSION      uint16 = 16
+	RT_MANIFEST     uint16 = 24
+)
+
+/*
+	ResourceID identifies a resource type or name, either by a
+	string when Name is not empty or by an integer ID.
+*/
+type ResourceID struct {
+	Name string
+	ID   uint16
+}
+
+func (id ResourceID) String() string {
+	if id.Name != "" {
+		return id.Name
+	}
+
+	return fmt.Sprintf("#%d", id.ID)
+}
+
+/*
+	Resource is a leaf of the resource tree.
+*/
+type Resource struct {
+	Type     ResourceID
+	Name     ResourceID
+	Language uint16
+
+	/*
+		RVA and Size locate the resource data inside the image.
+	*/
+	RVA      uint32
+	Size     uint32
+	CodePage uint32
+}
+
+const maxResourceEntries = 1 << 16
+
+type resourceWalker struct {
+	img   *Image
+	root  uint32
+	size  uint32
+	count int
+}
+
+/*
+	Resources walks the resource directory and returns every resource in the image.
+*/
+func (img *Image) Resources() ([]Resource, error) {
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_RESOURCE)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &resourceWalker{img: img, root: dd.VirtualAddress, size: dd.Size}
+
+	var resources []Resource
+	types, err := w.entries(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range types {
+		if !t.dir {
+			continue
+		}
+
+		names, err := w.entries(t.offset)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range names {
+			if !n.dir {
+				continue
+			}
+
+			langs, err := w.entries(n.offset)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, l := range langs {
+				if l.dir {
+					continue
+				}
+
+				var data [16]byte
+				if err := w.read(l.offset, data[:]); err != nil {
+					return nil, err
+				}
+
+				resources = append(resources, Resource{
+					Type:     t.id,
+					Name:     n.id,
+					Language: l.id.ID,
+					RVA:      binary.LittleEndian.Uint32(data[0:]),
+					Size:     binary.LittleEndian.Uint32(data[4:]),
+					CodePage: binary.LittleEndian.Uint32(data[8:]),
+				})
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+/*
+	ResourcesByType returns the resources of the given predefined type.
+*/
+func (img *Image) ResourcesByType(typ uint16) ([]Resource, error) {
+	all, err := img.Resources()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []Resource
+	for _, r := range all {
+		if r.Type.Name == "" && r.Type.ID == typ {
+			resources = append(resources, r)
+		}
+	}
+
+	return resources, nil
+}
+
+/*
+	ReadResource returns the data of r.
+*/
+func (img *Image) ReadResource(r Resource) ([]byte, error) {
+	if r.Size > img.OptionalHeader.SizeOfImage && img.OptionalHeader.SizeOfImage != 0 {
+		return nil, fmt.Errorf("pe: resource size %d exceeds image size", r.Size)
+	}
+
+	data := make([]byte, r.Size)
+	if err := img.ReadRVA(r.RVA, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+type resourceEntry struct {
+	id     ResourceID
+	dir    bool
+	offset uint32
+}
+
+func (w *resourceWalker) read(offset uint32, buf []byte) error {
+	if uint64(offset)+uint64(len(buf)) > uint64(w.size) {
+		return fmt.Errorf("pe: resource entry at %#x is outside of the resource directory", offset)
+	}
+
+	return w.img.ReadRVA(w.root+offset, buf)
+}
+
+func (w *resourceWalker) entries(offset uint32) ([]resourceEntry, error) {
+	var hdr [16]byte
+	if err := w.read(offset, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	n := int(binary.LittleEndian.Uint16(hdr[12:])) + int(binary.LittleEndian.Uint16(hdr[14:]))
+	w.count += n
+	if w.count > maxResourceEntries {
+		return nil, fmt.Errorf("pe: too many resource entries")
+	}
+
+	raw := make([]byte, 8*n)
+	if err := w.read(offset+16, raw); err != nil {
+		return nil, err
+	}
+
+	entries := make([]resourceEntry, n)
+	for i := range entries {
+		name := binary.LittleEndian.Uint32(raw[8*i:])
+		data := binary.LittleEndian.Uint32(raw[8*i+4:])
+
+		e := &entries[i]
+		e.dir = data&0x80000000 != 0
+		e.offset = data &^ 0x80000000
+
+		if name&0x80000000 == 0 {
+			e.id.ID = uint16(name)
+			continue
+		}
+
+		s, err := w.string(name &^ 0x80000000)
+		if err != nil {
+			return nil, err
+		}
+		e.id.Name = s
+	}
+
+	return entries, nil
+}
+
+func (w *resourceWalker) string(offset uint32) (string, error) {
+	var length [2]byte
+	if err := w.read(offset, length[:]); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 2*int(binary.LittleEndian.Uint16(length[:])))
+	if err := w.read(offset+2, buf); err != nil {
+		return "", err
+	}
+
+	return decodeUTF16(buf), nil
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+
+	return string(utf16.Decode(u))
+}
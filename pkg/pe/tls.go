@@ -0,0 +1,103 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+	TLSDirectory is the TLS directory of an image (IMAGE_TLS_DIRECTORY).
+	Addresses are virtual addresses, which the loader relocates with the image.
+*/
+type TLSDirectory struct {
+	StartAddressOfRawData uint64
+	EndAddressOfRawData   uint64
+	AddressOfIndex        uint64
+	AddressOfCallBacks    uint64
+	SizeOfZeroFill        uint32
+	Characteristics       uint32
+}
+
+/*
+	TLS parses the TLS directory of the image.
+*/
+func (img *Image) TLS() (*TLSDirectory, error) {
+	dd, err := img.Directory(IMAGE_DIRECTORY_ENTRY_TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	if !img.Is64() {
+		var b [24]byte
+		if err := img.ReadRVA(dd.VirtualAddress, b[:]); err != nil {
+			return nil, err
+		}
+
+		return &TLSDirectory{
+			StartAddressOfRawData: uint64(binary.LittleEndian.Uint32(b[0:])),
+			EndAddressOfRawData:   uint64(binary.LittleEndian.Uint32(b[4:])),
+			AddressOfIndex:        uint64(binary.LittleEndian.Uint32(b[8:])),
+			AddressOfCallBacks:    uint64(binary.LittleEndian.Uint32(b[12:])),
+			SizeOfZeroFill:        binary.LittleEndian.Uint32(b[16:]),
+			Characteristics:       binary.LittleEndian.Uint32(b[20:]),
+		}, nil
+	}
+
+	var b [40]byte
+	if err := img.ReadRVA(dd.VirtualAddress, b[:]); err != nil {
+		return nil, err
+	}
+
+	return &TLSDirectory{
+		StartAddressOfRawData: binary.LittleEndian.Uint64(b[0:]),
+		EndAddressOfRawData:   binary.LittleEndian.Uint64(b[8:]),
+		AddressOfIndex:        binary.LittleEndian.Uint64(b[16:]),
+		AddressOfCallBacks:    binary.LittleEndian.Uint64(b[24:]),
+		SizeOfZeroFill:        binary.LittleEndian.Uint32(b[32:]),
+		Characteristics:       binary.LittleEndian.Uint32(b[36:]),
+	}, nil
+}
+
+/*
+	TLSCallbacks returns the virtual addresses of the TLS callbacks of the image,
+	as stored in the image: for a mapped image that was relocated they are
+	the addresses of the callbacks in memory.
+*/
+func (img *Image) TLSCallbacks() ([]uint64, error) {
+	tls, err := img.TLS()
+	if err != nil {
+		return nil, err
+	}
+
+	if tls.AddressOfCallBacks == 0 {
+		return nil, nil
+	}
+
+	if tls.AddressOfCallBacks < img.OptionalHeader.ImageBase {
+		return nil, fmt.Errorf("pe: TLS callbacks at %#x are outside of the image", tls.AddressOfCallBacks)
+	}
+
+	rva := uint32(tls.AddressOfCallBacks - img.OptionalHeader.ImageBase)
+	size := uint32(4)
+	if img.Is64() {
+		size = 8
+	}
+
+	var callbacks []uint64
+	for i := uint32(0); ; i++ {
+		var b [8]byte
+		if err := img.ReadRVA(rva+i*size, b[:size]); err != nil {
+			return nil, err
+		}
+
+		cb := binary.LittleEndian.Uint64(b[:])
+		if cb == 0 {
+			return callbacks, nil
+		}
+
+		callbacks = append(callbacks, cb)
+		if len(callbacks) > 0x1000 {
+			return nil, fmt.Errorf("pe: TLS callback array is not terminated")
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const vsFixedFileInfoSignature = 0xFEEF04BD
+
+var ErrNoVersionInfo = errors.New("pe: image has no version information")
+
+/*
+	Version is a four part version number such as 10.0.19041.1.
+*/
+type Version [4]uint16
+
+func versionFrom(ms, ls uint32) Version {
+	return Version{uint16(ms >> 16), uint16(ms), uint16(ls >> 16), uint16(ls)}
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v[0], v[1], v[2], v[3])
+}
+
+/*
+	FixedFileInfo is the language independent part of the version
+	information (VS_FIXEDFILEINFO).
+*/
+type FixedFileInfo struct {
+	StrucVersion   uint32
+	FileVersion    Version
+	ProductVersion Version
+	FileFlagsMask  uint32
+	FileFlags      uint32
+	FileOS         uint32
+	FileType       uint32
+	FileSubtype    uint32
+	FileDate       uint64
+}
+
+/*
+	Translation is a language and code page pair listed in VarFileInfo.
+*/
+type Translation struct {
+	Language uint16
+	CodePage uint16
+}
+
+/*
+	StringTable holds the strings of a StringFileInfo block for one language and code page.
+*/
+type StringTable struct {
+	Language uint16
+	CodePage uint16
+	Strings  map[string]string
+}
+
+/*
+	VersionInfo is a parsed VS_VERSIONINFO resource.
+*/
+type VersionInfo struct {
+	/*
+		Fixed is nil if the resource does not contain a VS_FIXEDFILEINFO.
+	*/
+	Fixed        *FixedFileInfo
+	StringTables []StringTable
+	Translations []Translation
+}
+
+/*
+	Lookup returns the value of a StringFileInfo key such as "CompanyName" or "ProductVersion".
+	Tables are searched in the order listed by VarFileInfo, then in file order.
+*/
+func (vi *VersionInfo) Lookup(key string) (string, bool) {
+	for _, t := range vi.Translations {
+		for _, st := range vi.StringTables {
+			if st.Language == t.Language && st.CodePage == t.CodePage {
+				if v, ok := st.Strings[key]; ok {
+					return v, true
+				}
+			}
+		}
+	}
+
+	for _, st := range vi.StringTables {
+		if v, ok := st.Strings[key]; ok {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+/*
+	VersionInfo finds and parses the first RT_VERSION resource of the image.
+*/
+func (img *Image) VersionInfo() (*VersionInfo, error) {
+	resources, err := img.ResourcesByType(RT_VERSION)
+	if errors.Is(err, ErrNoDirectory) || (err == nil && len(resources) == 0) {
+		return nil, ErrNoVersionInfo
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := img.ReadResource(resources[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVersionInfo(data)
+}
+
+type versionNode struct {
+	key      string
+	text     bool
+	value    []byte
+	children []versionNode
+}
+
+const maxVersionDepth = 8
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+/*
+	parseVersionNode parses the block starting at off and returns it
+	along with the offset of the first byte after it.
+*/
+func parseVersionNode(data []byte, off int, depth int) (versionNode, int, error) {
+	var node versionNode
+
+	if depth > maxVersionDepth {
+		return node, 0, errors.New("pe: version information is nested too deeply")
+	}
+
+	if off+6 > len(data) {
+		return node, 0, errors.New("pe: truncated version information block")
+	}
+
+	length := int(binary.LittleEndian.Uint16(data[off:]))
+	valueLength := int(binary.LittleEndian.Uint16(data[off+2:]))
+	node.text = binary.LittleEndian.Uint16(data[off+4:]) == 1
+
+	if length < 6 {
+		return node, 0, fmt.Errorf("pe: invalid version information block length %d", length)
+	}
+
+	end := off + length
+	if end > len(data) {
+		end = len(data)
+	}
+
+	p := off + 6
+	keyStart := p
+	for p+2 <= end && binary.LittleEndian.Uint16(data[p:]) != 0 {
+		p += 2
+	}
+	node.key = decodeUTF16(data[keyStart:p])
+	p = align4(p + 2)
+
+	if node.text {
+		valueLength *= 2
+	}
+
+	if p < end {
+		vend := p + valueLength
+		if vend > end {
+			vend = end
+		}
+		node.value = data[p:vend]
+		p = align4(vend)
+	}
+
+	for p+6 <= end {
+		child, next, err := parseVersionNode(data[:end], p, depth+1)
+		if err != nil {
+			return node, 0, err
+		}
+
+		node.children = append(node.children, child)
+		p = align4(next)
+	}
+
+	return node, end, nil
+}
+
+func (n *versionNode) textValue() string {
+	v := n.value
+	for i := 0; i+1 < len(v); i += 2 {
+		if v[i] == 0 && v[i+1] == 0 {
+			v = v[:i]
+			break
+		}
+	}
+
+	return decodeUTF16(v)
+}
+
+/*
+	ParseVersionInfo parses the raw data of an RT_VERSION resource.
+*/
+func ParseVersionInfo(data []byte) (*VersionInfo, error) {
+	root, _, err := parseVersionNode(data, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("pe: unexpected version information key %q", root.key)
+	}
+
+	vi := &VersionInfo{}
+
+	if v := root.value; len(v) >= 52 && binary.LittleEndian.Uint32(v) == vsFixedFileInfoSignature {
+		vi.Fixed = &FixedFileInfo{
+			StrucVersion:   binary.LittleEndian.Uint32(v[4:]),
+			FileVersion:    versionFrom(binary.LittleEndian.Uint32(v[8:]), binary.LittleEndian.Uint32(v[12:])),
+			ProductVersion: versionFrom(binary.LittleEndian.Uint32(v[16:]), binary.LittleEndian.Uint32(v[20:])),
+			FileFlagsMask:  binary.LittleEndian.Uint32(v[24:]),
+			FileFlags:      binary.LittleEndian.Uint32(v[28:]),
+			FileOS:         binary.LittleEndian.Uint32(v[32:]),
+			FileType:       binary.LittleEndian.Uint32(v[36:]),
+			FileSubtype:    binary.LittleEndian.Uint32(v[40:]),
+			FileDate:       uint64(binary.LittleEndian.Uint32(v[44:]))<<32 | uint64(binary.LittleEndian.Uint32(v[48:])),
+		}
+	}
+
+	for _, child := range root.children {
+		switch child.key {
+		case "StringFileInfo":
+			for _, table := range child.children {
+				st := StringTable{Strings: make(map[string]string, len(table.children))}
+				if id, err := strconv.ParseUint(table.key, 16, 32); err == nil {
+					st.Language = uint16(id >> 16)
+					st.CodePage = uint16(id)
+				}
+
+				for _, s := range table.children {
+					st.Strings[s.key] = s.textValue()
+				}
+
+				vi.StringTables = append(vi.StringTables, st)
+			}
+
+		case "VarFileInfo":
+			for _, v := range child.children {
+				if v.key != "Translation" {
+					continue
+				}
+
+				for i := 0; i+4 <= len(v.value); i += 4 {
+					vi.Translations = append(vi.Translations, Translation{
+						Language: binary.LittleEndian.Uint16(v.value[i:]),
+						CodePage: binary.LittleEndian.Uint16(v.value[i+2:]),
+					})
+				}
+			}
+		}
+	}
+
+	return vi, nil
+}
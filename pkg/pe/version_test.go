@@ -0,0 +1,262 @@
+package pe_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+)
+
+/*
+	testdata/versioninfo.bin is the RT_VERSION resource of the German
+	NuGet.Localization.resources.dll 5.11.1.5 shipped with the .NET 5 SDK.
+*/
+func readVersionInfo(t testing.TB) []byte {
+	data, err := os.ReadFile("testdata/versioninfo.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestParseVersionInfo(t *testing.T) {
+	vi, err := pe.ParseVersionInfo(readVersionInfo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixed := &pe.FixedFileInfo{
+		StrucVersion:   0x10000,
+		FileVersion:    pe.Version{5, 11, 1, 5},
+		ProductVersion: pe.Version{0, 0, 0, 0},
+		FileFlagsMask:  0x17,
+		FileOS:         4,
+		FileType:       2,
+	}
+	if !reflect.DeepEqual(vi.Fixed, fixed) {
+		t.Errorf("got fixed file info %+v, want %+v", vi.Fixed, fixed)
+	}
+
+	if want := []pe.Translation{{Language: 0x407, CodePage: 1200}}; !reflect.DeepEqual(vi.Translations, want) {
+		t.Errorf("got translations %+v, want %+v", vi.Translations, want)
+	}
+
+	if len(vi.StringTables) != 1 || vi.StringTables[0].Language != 0x407 || vi.StringTables[0].CodePage != 1200 {
+		t.Fatalf("got string tables %+v", vi.StringTables)
+	}
+
+	for key, want := range map[string]string{
+		"CompanyName":      "Microsoft Corporation",
+		"FileDescription":  "NuGet.Localization",
+		"FileVersion":      "5.11.1.5",
+		"InternalName":     "NuGet.Localization.resources.dll",
+		"LegalCopyright":   "© Microsoft Corporation. Alle Rechte vorbehalten.",
+		"OriginalFilename": "NuGet.Localization.resources.dll",
+		"ProductName":      "NuGet",
+		"Comments":         "NuGet-Lokalisierungspaket für .NET-CLI.",
+	} {
+		if got, ok := vi.Lookup(key); !ok || got != want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+
+	if got, _ := vi.Lookup("ProductVersion"); got != "5.11.1-rc.5+83d5f160f4bfb11ec7fd471886fb70cda2112df3.83d5f160f4bfb11ec7fd471886fb70cda2112df3" {
+		t.Errorf("got ProductVersion %q", got)
+	}
+
+	if _, ok := vi.Lookup("PrivateBuild"); ok {
+		t.Error("Lookup(PrivateBuild) found a missing key")
+	}
+}
+
+func TestParseVersionInfoErrors(t *testing.T) {
+	data := readVersionInfo(t)
+
+	wrongKey := append([]byte(nil), data...)
+	wrongKey[6] = 'W'
+
+	shortBlock := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(shortBlock, 4)
+
+	tests := map[string][]byte{
+		"empty":       nil,
+		"truncated":   data[:4],
+		"wrong key":   wrongKey,
+		"short block": shortBlock,
+		"too deep":    nestedVersionBlocks(16),
+	}
+
+	for name, data := range tests {
+		if _, err := pe.ParseVersionInfo(data); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+/*
+	nestedVersionBlocks builds a VS_VERSION_INFO block with depth levels of children.
+*/
+func nestedVersionBlocks(depth int) []byte {
+	var b []byte
+	for i := 0; i < depth; i++ {
+		key := "x"
+		if i == depth-1 {
+			key = "VS_VERSION_INFO"
+		}
+
+		node := make([]byte, 6)
+		for _, r := range key {
+			node = append(node, byte(r), 0)
+		}
+		node = append(node, 0, 0)
+		for len(node)%4 != 0 {
+			node = append(node, 0)
+		}
+
+		node = append(node, b...)
+		binary.LittleEndian.PutUint16(node, uint16(len(node)))
+		b = node
+	}
+
+	return b
+}
+
+func resourceImage(t testing.TB, resources []petest.Resource) []byte {
+	t.Helper()
+
+	b := petest.New(false)
+	b.AddSection(".text", make([]byte, 0x10), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE)
+
+	rva := b.NextRVA()
+	dir := petest.ResourceDirectory(rva, resources)
+	b.AddSection(".rsrc", dir, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_RESOURCE, rva, len(dir))
+
+	return b.Bytes()
+}
+
+func TestResources(t *testing.T) {
+	vi := readVersionInfo(t)
+	data := resourceImage(t, []petest.Resource{
+		{Type: pe.RT_VERSION, ID: 1, Language: 0x407, Data: vi},
+		{Type: pe.RT_MANIFEST, ID: 2, Language: 0x409, Data: []byte("<assembly/>")},
+		{Type: pe.RT_RCDATA, Name: "CONFIG", Language: 0, Data: []byte("neutral")},
+		{Type: pe.RT_RCDATA, Name: "CONFIG", Language: 0x409, Data: []byte("english")},
+	})
+
+	img, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := img.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type leaf struct {
+		Type, Name string
+		Language   uint16
+		Data       string
+	}
+
+	var got []leaf
+	for _, r := range all {
+		d, err := img.ReadResource(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, leaf{r.Type.String(), r.Name.String(), r.Language, string(d)})
+	}
+
+	want := []leaf{
+		{"#10", "CONFIG", 0, "neutral"},
+		{"#10", "CONFIG", 0x409, "english"},
+		{"#16", "#1", 0x407, string(vi)},
+		{"#24", "#2", 0x409, "<assembly/>"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	rcdata, err := img.ResourcesByType(pe.RT_RCDATA)
+	if err != nil || len(rcdata) != 2 {
+		t.Errorf("ResourcesByType(RT_RCDATA) = %+v, %v", rcdata, err)
+	}
+
+	info, err := img.VersionInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := info.Lookup("FileVersion"); got != "5.11.1.5" {
+		t.Errorf("got FileVersion %q", got)
+	}
+}
+
+func TestNoVersionInfo(t *testing.T) {
+	manifestOnly := resourceImage(t, []petest.Resource{{Type: pe.RT_MANIFEST, ID: 1, Data: []byte("<assembly/>")}})
+
+	b := petest.New(true)
+	b.AddSection(".text", make([]byte, 0x10), pe.IMAGE_SCN_CNT_CODE)
+
+	for _, data := range [][]byte{manifestOnly, b.Bytes()} {
+		img, err := pe.NewFile(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := img.VersionInfo(); !errors.Is(err, pe.ErrNoVersionInfo) {
+			t.Errorf("got %v, want ErrNoVersionInfo", err)
+		}
+	}
+}
+
+func FuzzParseVersionInfo(f *testing.F) {
+	f.Add(readVersionInfo(f))
+	f.Add(nestedVersionBlocks(4))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		vi, err := pe.ParseVersionInfo(data)
+		if err != nil {
+			return
+		}
+
+		for _, st := range vi.StringTables {
+			for key := range st.Strings {
+				vi.Lookup(key)
+			}
+		}
+	})
+}
+
+func FuzzResources(f *testing.F) {
+	f.Add(resourceImage(f, []petest.Resource{
+		{Type: pe.RT_VERSION, ID: 1, Language: 0x407, Data: readVersionInfo(f)},
+		{Type: pe.RT_RCDATA, Name: "CONFIG", Language: 0x409, Data: []byte("english")},
+	}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := pe.NewFile(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		resources, err := img.Resources()
+		if err != nil {
+			return
+		}
+
+		for _, r := range resources {
+			img.ReadResource(r)
+		}
+
+		img.VersionInfo()
+	})
+}
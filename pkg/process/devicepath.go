@@ -0,0 +1,120 @@
+package process
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownDevice = errors.New("process: path is not on a known device")
+
+/*
+	DeviceMap translates NT device paths, such as "\Device\HarddiskVolume3\Windows",
+	into DOS paths, such as "C:\Windows".
+*/
+type DeviceMap struct {
+	devices []driveDevice
+}
+
+type driveDevice struct {
+	drive  string
+	device string
+}
+
+/*
+	NewDeviceMap creates a DeviceMap from the device each drive is mapped to,
+	as returned by QueryDosDevice, such as {"C:": `\Device\HarddiskVolume3`}.
+
+	Drives mapped to another DOS path with SUBST, whose target starts with `\??\`,
+	are ignored so that paths are translated to the drive of the underlying volume.
+	When several drives map to the same device, the first in drive order is used.
+*/
+func NewDeviceMap(drives map[string]string) *DeviceMap {
+	m := &DeviceMap{}
+	for drive, device := range drives {
+		if strings.HasPrefix(device, `\??\`) || device == "" {
+			continue
+		}
+
+		m.devices = append(m.devices, driveDevice{
+			drive:  strings.ToUpper(strings.TrimRight(drive, `\`)),
+			device: strings.TrimRight(device, `\`),
+		})
+	}
+
+	sort.Slice(m.devices, func(i, j int) bool {
+		return m.devices[i].drive < m.devices[j].drive
+	})
+
+	return m
+}
+
+/*
+	hasPathPrefix reports whether path is prefix or starts with prefix followed by a separator,
+	comparing case-insensitively.
+*/
+func hasPathPrefix(path, prefix string) bool {
+	if len(path) < len(prefix) || !strings.EqualFold(path[:len(prefix)], prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || path[len(prefix)] == '\\'
+}
+
+/*
+	uncDevices are the redirector devices that network paths are reported on.
+*/
+var uncDevices = []string{`\Device\Mup`, `\Device\LanmanRedirector`}
+
+/*
+	ToDOSPath translates an NT path into a DOS path.
+
+	Paths on a mapped drive device are translated to that drive, paths on the network
+	redirectors to UNC paths, and paths in the `\??\` or `\\?\` namespaces are returned
+	without their prefix. Other paths result in ErrUnknownDevice.
+*/
+func (m *DeviceMap) ToDOSPath(path string) (string, error) {
+	for _, prefix := range []string{`\??\UNC\`, `\\?\UNC\`} {
+		if len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+			return `\\` + path[len(prefix):], nil
+		}
+	}
+
+	for _, prefix := range []string{`\??\`, `\\?\`} {
+		if strings.HasPrefix(path, prefix) {
+			return path[len(prefix):], nil
+		}
+	}
+
+	for _, d := range m.devices {
+		if hasPathPrefix(path, d.device) {
+			rest := path[len(d.device):]
+			if rest == "" {
+				rest = `\`
+			}
+			return d.drive + rest, nil
+		}
+	}
+
+	for _, device := range uncDevices {
+		if !hasPathPrefix(path, device) || len(path) == len(device) {
+			continue
+		}
+
+		rest := path[len(device)+1:]
+
+		// Mapped network drives add components such as ";Z:0000000000012345",
+		// preceded on Mup by the redirector, as in ";LanmanRedirector".
+		for strings.HasPrefix(rest, ";") {
+			i := strings.IndexByte(rest, '\\')
+			if i < 0 {
+				return "", ErrUnknownDevice
+			}
+			rest = rest[i+1:]
+		}
+
+		return `\\` + rest, nil
+	}
+
+	return "", ErrUnknownDevice
+}
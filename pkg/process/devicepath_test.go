@@ -0,0 +1,103 @@
+package process
+
+import (
+	"errors"
+	"testing"
+)
+
+/*
+	devices is a device table as QueryDosDevice reports it on a machine with
+	two volumes, a drive mapped twice, a SUBST drive and a mapped network drive.
+*/
+var devices = map[string]string{
+	"C:": `\Device\HarddiskVolume3`,
+	"d:": `\Device\HarddiskVolume10\`,
+	"F:": `\Device\HarddiskVolume3`,
+	"S:": `\??\C:\Source`,
+	"Z:": `\Device\LanmanRedirector\;Z:0000000000012345\server\share`,
+	"X:": ``,
+}
+
+func TestToDOSPath(t *testing.T) {
+	m := NewDeviceMap(devices)
+
+	tests := []struct {
+		path, want string
+	}{
+		{`\Device\HarddiskVolume3\Windows\System32\ntdll.dll`, `C:\Windows\System32\ntdll.dll`},
+		{`\device\harddiskvolume3\Windows`, `C:\Windows`},
+		{`\Device\HarddiskVolume3`, `C:\`},
+		{`\Device\HarddiskVolume3\`, `C:\`},
+
+		// The trailing separator of the device is ignored and the drive is upper cased.
+		{`\Device\HarddiskVolume10\Games\a.exe`, `D:\Games\a.exe`},
+
+		// Paths under SUBST drives are reported on the underlying volume.
+		{`\Device\HarddiskVolume3\Source\main.go`, `C:\Source\main.go`},
+
+		{`\Device\Mup\server\share\a.exe`, `\\server\share\a.exe`},
+		{`\Device\LanmanRedirector\server\share\a.exe`, `\\server\share\a.exe`},
+		{`\Device\LanmanRedirector\;Y:0000000000012345\server\other\a.exe`, `\\server\other\a.exe`},
+		{`\Device\Mup\;LanmanRedirector\;Y:0000000000012345\server\other\a.exe`, `\\server\other\a.exe`},
+
+		// The mapped network drive itself.
+		{`\Device\LanmanRedirector\;Z:0000000000012345\server\share\a.exe`, `Z:\a.exe`},
+
+		{`\??\C:\Windows\notepad.exe`, `C:\Windows\notepad.exe`},
+		{`\\?\C:\Windows\notepad.exe`, `C:\Windows\notepad.exe`},
+		{`\??\UNC\server\share\a.exe`, `\\server\share\a.exe`},
+		{`\\?\unc\server\share\a.exe`, `\\server\share\a.exe`},
+	}
+
+	for _, tc := range tests {
+		got, err := m.ToDOSPath(tc.path)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v; want %q", tc.path, got, err, tc.want)
+		}
+	}
+}
+
+func TestToDOSPathUnknown(t *testing.T) {
+	m := NewDeviceMap(devices)
+
+	paths := []string{
+		``,
+		`C:\Windows`,
+		`\Device\HarddiskVolume1\Windows`,
+		`\Device\HarddiskVolume30\Windows`,
+		`\Device\HarddiskVolume3x\Windows`,
+		`\Device\Mup`,
+		`\Device\LanmanRedirector\;Y:0000000000012345`,
+		`\Device\Mup\;LanmanRedirector`,
+		`\Device\CdRom0\setup.exe`,
+	}
+
+	for _, path := range paths {
+		if got, err := m.ToDOSPath(path); !errors.Is(err, ErrUnknownDevice) {
+			t.Errorf("%s: got %q, %v; want ErrUnknownDevice", path, got, err)
+		}
+	}
+
+	if _, err := NewDeviceMap(nil).ToDOSPath(`\Device\HarddiskVolume3\Windows`); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("empty map: got %v", err)
+	}
+}
+
+/*
+	TestNewDeviceMapOrder checks that the drive chosen for a device shared by
+	several drives does not depend on the iteration order of the map.
+*/
+func TestNewDeviceMapOrder(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		m := NewDeviceMap(map[string]string{
+			"G:": `\Device\HarddiskVolume5`,
+			"E:": `\Device\HarddiskVolume5`,
+			"H:": `\Device\HarddiskVolume5`,
+			"F:": `\Device\HarddiskVolume5`,
+		})
+
+		if got, err := m.ToDOSPath(`\Device\HarddiskVolume5\a`); err != nil || got != `E:\a` {
+			t.Fatalf("got %q, %v; want E:\\a", got, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	QueryDeviceMap builds a DeviceMap from the current mapping of every logical drive.
+	Drives whose mapping cannot be queried are skipped.
+*/
+func QueryDeviceMap() (*DeviceMap, error) {
+	roots, err := kernel32.GetLogicalDriveStrings()
+	if err != nil {
+		return nil, err
+	}
+
+	drives := make(map[string]string)
+	for _, root := range roots {
+		drive := strings.TrimRight(root, `\`)
+
+		targets, err := kernel32.QueryDosDevice(drive)
+		if err != nil || len(targets) == 0 {
+			continue
+		}
+
+		drives[drive] = targets[0]
+	}
+
+	return NewDeviceMap(drives), nil
+}
+
+/*
+	ImagePath returns the Win32 path of the executable image of the process behind handle.
+	When the image is not on a drive, as for a process started from a volume without
+	a drive letter, the native path is translated with the device map instead, and
+	returned unchanged if that fails too.
+
+	The handle must have the PROCESS_QUERY_LIMITED_INFORMATION access right.
+*/
+func ImagePath(handle win32.Handle) (string, error) {
+	path, err := kernel32.QueryFullProcessImageName(handle, kernel32.PROCESS_NAME_WIN32)
+	if err == nil {
+		return path, nil
+	}
+
+	native, nerr := kernel32.QueryFullProcessImageName(handle, kernel32.PROCESS_NAME_NATIVE)
+	if nerr != nil {
+		return "", fmt.Errorf("process: %w", err)
+	}
+
+	m, merr := QueryDeviceMap()
+	if merr != nil {
+		return native, nil
+	}
+
+	if dos, err := m.ToDOSPath(native); err == nil {
+		return dos, nil
+	}
+
+	return native, nil
+}
@@ -0,0 +1,94 @@
+package process
+
+import (
+	"errors"
+	"io"
+)
+
+/*
+	Reader reads the address space of a process.
+	ReadMemory must either fill buf completely or return an error.
+*/
+type Reader interface {
+	ReadMemory(addr uintptr, buf []byte) error
+}
+
+/*
+	Writer writes the address space of a process.
+	WriteMemory must either write buf completely or return an error.
+*/
+type Writer interface {
+	WriteMemory(addr uintptr, buf []byte) error
+}
+
+/*
+	Memory reads and writes the address space of a process.
+*/
+type Memory interface {
+	Reader
+	Writer
+}
+
+/*
+	InstructionCacheFlusher is implemented by memories whose instruction cache
+	must be flushed after code has been modified.
+*/
+type InstructionCacheFlusher interface {
+	FlushInstructionCache(addr uintptr, size int) error
+}
+
+/*
+	WriteCode writes buf to addr and flushes the instruction cache of w
+	if it implements InstructionCacheFlusher.
+*/
+func WriteCode(w Writer, addr uintptr, buf []byte) error {
+	if err := w.WriteMemory(addr, buf); err != nil {
+		return err
+	}
+
+	if f, ok := w.(InstructionCacheFlusher); ok {
+		return f.FlushInstructionCache(addr, len(buf))
+	}
+
+	return nil
+}
+
+type readerAt struct {
+	r    Reader
+	base uintptr
+	size int64
+}
+
+/*
+	NewReaderAt returns an io.ReaderAt that reads size bytes of r starting at base.
+	Offsets passed to ReadAt are relative to base, which makes the result
+	suitable for parsing a mapped module with pe.NewImage.
+*/
+func NewReaderAt(r Reader, base uintptr, size int64) io.ReaderAt {
+	return &readerAt{r: r, base: base, size: size}
+}
+
+func (ra *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("process: negative offset")
+	}
+
+	if off >= ra.size {
+		return 0, io.EOF
+	}
+
+	n := len(p)
+	if rem := ra.size - off; int64(n) > rem {
+		n = int(rem)
+	}
+
+	if err := ra.r.ReadMemory(ra.base+uintptr(off), p[:n]); err != nil {
+		return 0, err
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	Remote implements Memory on top of ReadProcessMemory and WriteProcessMemory.
+	Handle must have been opened with PROCESS_VM_READ, and additionally
+	PROCESS_VM_WRITE and PROCESS_VM_OPERATION for writes.
+*/
+type Remote struct {
+	Handle win32.Handle
+}
+
+func (r Remote) ReadMemory(addr uintptr, buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+
+	n, err := kernel32.ReadProcessMemory(r.Handle, addr, &buf[0], uintptr(len(buf)))
+	if err != nil {
+		return err
+	}
+
+	if int(n) != len(buf) {
+		return fmt.Errorf("process: short read at %#x: %d of %d bytes", addr, n, len(buf))
+	}
+
+	return nil
+}
+
+func (r Remote) WriteMemory(addr uintptr, buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+
+	n, err := kernel32.WriteProcessMemory(r.Handle, addr, &buf[0], uintptr(len(buf)))
+	if err != nil {
+		return err
+	}
+
+	if int(n) != len(buf) {
+		return fmt.Errorf("process: short write at %#x: %d of %d bytes", addr, n, len(buf))
+	}
+
+	return nil
+}
+
+/*
+	FlushInstructionCache flushes the instruction cache for size bytes at addr.
+*/
+func (r Remote) FlushInstructionCache(addr uintptr, size int) error {
+	return kernel32.FlushInstructionCache(r.Handle, addr, uintptr(size))
+}
@@ -0,0 +1,37 @@
+package process
+
+import (
+	"github.com/warrenulrich/win32-go/pkg/pe"
+)
+
+/*
+	Module describes a module mapped into the address space of a process.
+*/
+type Module struct {
+	Name string
+	Path string
+	Base uintptr
+	Size uint32
+}
+
+/*
+	End returns the first address past the end of the module.
+*/
+func (m Module) End() uintptr {
+	return m.Base + uintptr(m.Size)
+}
+
+/*
+	Contains reports whether addr lies inside the module.
+*/
+func (m Module) Contains(addr uintptr) bool {
+	return addr >= m.Base && addr-m.Base < uintptr(m.Size)
+}
+
+/*
+	OpenModule parses the PE image of m as it is mapped in the process read by r.
+	The returned image reads lazily through r, so r must remain usable while the image is in use.
+*/
+func OpenModule(r Reader, m Module) (*pe.Image, error) {
+	return pe.NewImage(NewReaderAt(r, m.Base, int64(m.Size)))
+}
@@ -0,0 +1,53 @@
+package process
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	maxSnapshotRetries bounds the retries of a module snapshot failing with ERROR_BAD_LENGTH,
+	which happens while the target is loading or unloading modules.
+*/
+const maxSnapshotRetries = 100
+
+/*
+	Modules returns the modules currently loaded in the process identified by pid,
+	including the 32-bit modules of WOW64 processes.
+*/
+func Modules(pid uint32) ([]Module, error) {
+	snapshot, err := kernel32.CreateToolhelp32Snapshot(kernel32.TH32CS_SNAPMODULE|kernel32.TH32CS_SNAPMODULE32, pid)
+	for i := 0; i < maxSnapshotRetries && errors.Is(err, kernel32.ERROR_BAD_LENGTH); i++ {
+		snapshot, err = kernel32.CreateToolhelp32Snapshot(kernel32.TH32CS_SNAPMODULE|kernel32.TH32CS_SNAPMODULE32, pid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer kernel32.CloseHandle(snapshot)
+
+	var modules []Module
+
+	me := kernel32.ModuleEntry32{}
+	me.Size = uint32(unsafe.Sizeof(me))
+
+	err = kernel32.Module32First(snapshot, &me)
+	for err == nil {
+		modules = append(modules, Module{
+			Name: me.ModuleNameString(),
+			Path: me.ModulePathString(),
+			Base: me.BaseAddress,
+			Size: me.BaseSize,
+		})
+
+		err = kernel32.Module32Next(snapshot, &me)
+	}
+
+	if !errors.Is(err, kernel32.ERROR_NO_MORE_FILES) {
+		return nil, err
+	}
+
+	return modules, nil
+}
@@ -0,0 +1,8 @@
+/*
+	Package process provides higher level helpers for working with other
+	processes on top of the raw kernel32 bindings.
+
+	The platform independent parts (module lists, memory readers) are kept
+	separate from the Windows implementation so they can be used on any platform.
+*/
+package process
@@ -0,0 +1,98 @@
+/*
+	Package processtest provides an in-memory address space for testing code
+	that reads and writes the memory of another process.
+*/
+package processtest
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	Memory is a sparse address space made of mapped regions.
+	Accesses that are not entirely inside one region fail.
+*/
+type Memory struct {
+	regions map[uintptr][]byte
+}
+
+/*
+	NewMemory returns an empty address space.
+*/
+func NewMemory() *Memory {
+	return &Memory{regions: make(map[uintptr][]byte)}
+}
+
+/*
+	Map maps data at addr and returns it. The region shares data, so writes
+	through the Memory are visible in data and the other way round.
+*/
+func (m *Memory) Map(addr uintptr, data []byte) []byte {
+	m.regions[addr] = data
+	return data
+}
+
+/*
+	Alloc maps size zeroed bytes at addr and returns them.
+*/
+func (m *Memory) Alloc(addr uintptr, size int) []byte {
+	return m.Map(addr, make([]byte, size))
+}
+
+/*
+	Unmap removes the region mapped at addr.
+*/
+func (m *Memory) Unmap(addr uintptr) {
+	delete(m.regions, addr)
+}
+
+/*
+	Regions returns the base addresses of the mapped regions in ascending order.
+*/
+func (m *Memory) Regions() []uintptr {
+	bases := make([]uintptr, 0, len(m.regions))
+	for base := range m.regions {
+		bases = append(bases, base)
+	}
+
+	sort.Slice(bases, func(i, j int) bool {
+		return bases[i] < bases[j]
+	})
+
+	return bases
+}
+
+/*
+	Slice returns the n bytes at addr, or an error if they are not mapped.
+*/
+func (m *Memory) Slice(addr uintptr, n int) ([]byte, error) {
+	for base, b := range m.regions {
+		if addr >= base && addr-base <= uintptr(len(b)) && uintptr(n) <= uintptr(len(b))-(addr-base) {
+			off := addr - base
+			return b[off : off+uintptr(n)], nil
+		}
+	}
+
+	return nil, fmt.Errorf("processtest: access violation at %#x (%d bytes)", addr, n)
+}
+
+func (m *Memory) ReadMemory(addr uintptr, buf []byte) error {
+	b, err := m.Slice(addr, len(buf))
+	if err != nil {
+		return err
+	}
+
+	copy(buf, b)
+	return nil
+}
+
+func (m *Memory) WriteMemory(addr uintptr, buf []byte) error {
+	b, err := m.Slice(addr, len(buf))
+	if err != nil {
+		return err
+	}
+
+	copy(b, buf)
+	return nil
+}
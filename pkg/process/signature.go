@@ -0,0 +1,27 @@
+package process
+
+import (
+	"os"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+)
+
+/*
+	VerifyModuleSignature opens the file backing m and verifies its embedded
+	Authenticode signature. The signature is returned along with the error when
+	it could be parsed, so callers can still report the signer of a tampered module.
+*/
+func VerifyModuleSignature(m Module) (*pe.Signature, error) {
+	f, err := os.Open(m.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := pe.NewFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return img.VerifySignature()
+}
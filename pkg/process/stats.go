@@ -0,0 +1,240 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Stats is a snapshot of the resource usage of a process.
+	Memory sizes are in bytes.
+*/
+type Stats struct {
+	PID uint32
+
+	StartTime time.Time
+
+	/*
+		ExitTime is zero while the process is running.
+	*/
+	ExitTime time.Time
+
+	KernelTime time.Duration
+	UserTime   time.Duration
+
+	PageFaults     uint64
+	WorkingSet     uint64
+	PeakWorkingSet uint64
+
+	/*
+		PrivateBytes is the private memory committed by the process.
+		On Linux it is the resident anonymous memory plus the swapped out memory.
+	*/
+	PrivateBytes uint64
+
+	/*
+		PagefileUsage is the commit charge of the process on Windows,
+		and its swapped out memory on Linux.
+	*/
+	PagefileUsage     uint64
+	PeakPagefileUsage uint64
+
+	/*
+		I/O counters include all I/O performed by the process, not only disk I/O.
+		Other operations are not reported on Linux.
+	*/
+	ReadOperations  uint64
+	WriteOperations uint64
+	OtherOperations uint64
+	ReadBytes       uint64
+	WriteBytes      uint64
+	OtherBytes      uint64
+
+	/*
+		Handles is the number of open handles, or of open file descriptors on Linux.
+	*/
+	Handles uint32
+}
+
+/*
+	CPUTime returns the total processor time used by the process.
+*/
+func (s *Stats) CPUTime() time.Duration {
+	return s.KernelTime + s.UserTime
+}
+
+/*
+	filetimeEpoch is the number of 100-nanosecond intervals between
+	January 1, 1601 and January 1, 1970.
+*/
+const filetimeEpoch = 116444736000000000
+
+/*
+	FiletimeToTime converts a FILETIME value, in 100-nanosecond intervals
+	since January 1, 1601 (UTC), to a time.Time. Zero converts to the zero time.
+*/
+func FiletimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+
+	t := int64(ft) - filetimeEpoch
+	return time.Unix(t/1e7, (t%1e7)*100)
+}
+
+/*
+	userHZ is the unit of the clock tick counts of /proc, which the kernel
+	reports in USER_HZ regardless of its internal timer frequency.
+*/
+const userHZ = 100
+
+func ticks(n uint64) time.Duration {
+	return time.Duration(n) * (time.Second / userHZ)
+}
+
+/*
+	procStat holds the fields of /proc/<pid>/stat used by Stats.
+*/
+type procStat struct {
+	minflt, majflt uint64
+	utime, stime   uint64
+	starttime      uint64
+}
+
+/*
+	parseProcStat parses the contents of /proc/<pid>/stat. The command name
+	is in parentheses and may contain spaces and parentheses itself,
+	so the fields are counted from the last closing parenthesis.
+*/
+func parseProcStat(data []byte) (procStat, error) {
+	var st procStat
+
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return st, fmt.Errorf("process: malformed stat")
+	}
+
+	// fields[0] is field 3, the state.
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return st, fmt.Errorf("process: malformed stat, %d fields", len(fields)+2)
+	}
+
+	targets := []struct {
+		field int
+		dst   *uint64
+	}{
+		{10, &st.minflt},
+		{12, &st.majflt},
+		{14, &st.utime},
+		{15, &st.stime},
+		{22, &st.starttime},
+	}
+
+	for _, t := range targets {
+		v, err := strconv.ParseUint(fields[t.field-3], 10, 64)
+		if err != nil {
+			return st, fmt.Errorf("process: malformed stat field %d: %w", t.field, err)
+		}
+		*t.dst = v
+	}
+
+	return st, nil
+}
+
+/*
+	parseKeyValues parses "key: value" lines, such as those of /proc/<pid>/status and io.
+	Values in kB are converted to bytes.
+*/
+func parseKeyValues(data []byte) map[string]uint64 {
+	values := make(map[string]uint64)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		key, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+
+		values[key] = v
+	}
+
+	return values
+}
+
+/*
+	bootTime parses the boot time from the contents of /proc/stat.
+*/
+func bootTime(data []byte) (time.Time, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if line := sc.Text(); strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[len("btime "):]), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("process: malformed btime: %w", err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("process: btime not found")
+}
+
+/*
+	linuxStats builds Stats from the contents of /proc/stat and of the
+	stat, status and io files of a process. io may be nil, since reading
+	it requires the same privileges as tracing the process.
+*/
+func linuxStats(pid uint32, procStatFile, stat, status, io []byte) (*Stats, error) {
+	boot, err := bootTime(procStatFile)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := parseProcStat(stat)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stats{
+		PID:        pid,
+		StartTime:  boot.Add(ticks(st.starttime)),
+		KernelTime: ticks(st.stime),
+		UserTime:   ticks(st.utime),
+		PageFaults: st.minflt + st.majflt,
+	}
+
+	mem := parseKeyValues(status)
+	s.WorkingSet = mem["VmRSS"]
+	s.PeakWorkingSet = mem["VmHWM"]
+	s.PrivateBytes = mem["RssAnon"] + mem["VmSwap"]
+	s.PagefileUsage = mem["VmSwap"]
+
+	if io != nil {
+		counters := parseKeyValues(io)
+		s.ReadOperations = counters["syscr"]
+		s.WriteOperations = counters["syscw"]
+		s.ReadBytes = counters["rchar"]
+		s.WriteBytes = counters["wchar"]
+	}
+
+	return s, nil
+}
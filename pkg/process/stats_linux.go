@@ -0,0 +1,49 @@
+package process
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+/*
+	ReadStats returns a snapshot of the resource usage of the process identified by pid,
+	read from /proc. I/O counters are left zero when /proc/<pid>/io cannot be read,
+	which requires the privileges to trace the process.
+*/
+func ReadStats(pid uint32) (*Stats, error) {
+	dir := "/proc/" + strconv.FormatUint(uint64(pid), 10)
+
+	procStatFile, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.ReadFile(dir + "/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := os.ReadFile(dir + "/status")
+	if err != nil {
+		return nil, err
+	}
+
+	io, err := os.ReadFile(dir + "/io")
+	if err != nil && !errors.Is(err, fs.ErrPermission) {
+		return nil, err
+	}
+
+	s, err := linuxStats(pid, procStatFile, stat, status, io)
+	if err != nil {
+		return nil, err
+	}
+
+	fds, err := os.ReadDir(dir + "/fd")
+	if err == nil {
+		s.Handles = uint32(len(fds))
+	}
+
+	return s, nil
+}
@@ -0,0 +1,44 @@
+package process
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadStats(t *testing.T) {
+	pid := uint32(os.Getpid())
+
+	f, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s, err := ReadStats(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.PID != pid {
+		t.Errorf("PID: got %d, want %d", s.PID, pid)
+	}
+
+	// The start time is in whole ticks since boot, so allow for its rounding.
+	if now := time.Now(); s.StartTime.After(now) || s.StartTime.Before(now.Add(-time.Hour)) {
+		t.Errorf("start time %v is not within the last hour", s.StartTime)
+	}
+
+	if s.WorkingSet == 0 || s.PeakWorkingSet < s.WorkingSet {
+		t.Errorf("working set %d, peak %d", s.WorkingSet, s.PeakWorkingSet)
+	}
+
+	// At least the standard streams and the file opened above.
+	if s.Handles < 4 {
+		t.Errorf("%d handles", s.Handles)
+	}
+
+	if _, err := ReadStats(0); err == nil {
+		t.Error("no error for PID 0")
+	}
+}
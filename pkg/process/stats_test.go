@@ -0,0 +1,195 @@
+package process
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFiletimeToTime(t *testing.T) {
+	tests := []struct {
+		ft   uint64
+		want time.Time
+	}{
+		{0, time.Time{}},
+		{116444736000000000, time.Unix(0, 0)},
+		{116444736000000001, time.Unix(0, 100)},
+		{132223104000000000, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{1, time.Date(1601, 1, 1, 0, 0, 0, 100, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		if got := FiletimeToTime(tc.ft); !got.Equal(tc.want) || got.IsZero() != tc.want.IsZero() {
+			t.Errorf("%d: got %v, want %v", tc.ft, got, tc.want)
+		}
+	}
+}
+
+/*
+	statData is /proc/<pid>/stat of a process whose name contains spaces and
+	parentheses, with minflt 1500, majflt 3, utime 250, stime 75 and starttime 123456.
+*/
+const statData = "4242 (my (odd) proc) S 1 4242 4242 0 -1 4194560 1500 20 3 1 250 75 5 6 20 0 4 0 123456 10485760 512 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0\n"
+
+const statusData = `Name:	my (odd) proc
+Umask:	0022
+State:	S (sleeping)
+Pid:	4242
+VmPeak:	   10240 kB
+VmSize:	   10240 kB
+VmHWM:	    2400 kB
+VmRSS:	    2048 kB
+RssAnon:	     512 kB
+RssFile:	    1536 kB
+VmSwap:	      64 kB
+Threads:	4
+SigQ:	0/31146
+Cpus_allowed_list:	0-3
+`
+
+const ioData = `rchar: 4096
+wchar: 1024
+syscr: 12
+syscw: 5
+read_bytes: 0
+write_bytes: 0
+cancelled_write_bytes: 0
+`
+
+const procStatData = `cpu  2255 34 2290 22625563 6290 127 456 0 0 0
+cpu0 1132 34 1441 11311718 3675 127 438 0 0 0
+intr 114930548 113199788 3 0 5 263 0 4 [... 500 values ...]
+ctxt 1990473
+btime 1062191376
+processes 2915
+procs_running 1
+`
+
+func TestParseProcStat(t *testing.T) {
+	st, err := parseProcStat([]byte(statData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := procStat{minflt: 1500, majflt: 3, utime: 250, stime: 75, starttime: 123456}
+	if st != want {
+		t.Errorf("got %+v, want %+v", st, want)
+	}
+
+	// A name ending with ") " does not shift the fields.
+	st, err = parseProcStat([]byte(strings.Replace(statData, "(my (odd) proc)", "(a) S 9 9 9)", 1)))
+	if err != nil || st != want {
+		t.Errorf("name with a closing parenthesis: got %+v, %v", st, err)
+	}
+
+	errs := []string{
+		"",
+		"4242 my proc S 1",
+		"4242 (proc) S 1 4242 4242 0 -1 4194560 1500 20 3 1 250 75 5 6 20 0 4 0",
+		strings.Replace(statData, " 250 ", " -250 ", 1),
+		strings.Replace(statData, " 123456 ", " 12x ", 1),
+	}
+
+	for _, data := range errs {
+		if _, err := parseProcStat([]byte(data)); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	got := parseKeyValues([]byte(statusData))
+
+	want := map[string]uint64{
+		"Umask":   22,
+		"Pid":     4242,
+		"VmPeak":  10240 * 1024,
+		"VmSize":  10240 * 1024,
+		"VmHWM":   2400 * 1024,
+		"VmRSS":   2048 * 1024,
+		"RssAnon": 512 * 1024,
+		"RssFile": 1536 * 1024,
+		"VmSwap":  64 * 1024,
+		"Threads": 4,
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("got %d values, want %d: %v", len(got), len(want), got)
+	}
+
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %d, want %d", k, got[k], v)
+		}
+	}
+
+	// Lines without a number or a colon are skipped.
+	for _, k := range []string{"Name", "State", "SigQ", "Cpus_allowed_list"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("%s parsed as %d", k, got[k])
+		}
+	}
+}
+
+func TestBootTime(t *testing.T) {
+	got, err := bootTime([]byte(procStatData))
+	if err != nil || !got.Equal(time.Unix(1062191376, 0)) {
+		t.Errorf("got %v, %v", got, err)
+	}
+
+	for _, data := range []string{"cpu 1 2 3\n", "btime x\n", "xbtime 1\n"} {
+		if _, err := bootTime([]byte(data)); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
+
+func TestLinuxStats(t *testing.T) {
+	s, err := linuxStats(4242, []byte(procStatData), []byte(statData), []byte(statusData), []byte(ioData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Stats{
+		PID:             4242,
+		StartTime:       time.Unix(1062191376+1234, 560*int64(time.Millisecond)),
+		KernelTime:      750 * time.Millisecond,
+		UserTime:        2500 * time.Millisecond,
+		PageFaults:      1503,
+		WorkingSet:      2048 * 1024,
+		PeakWorkingSet:  2400 * 1024,
+		PrivateBytes:    (512 + 64) * 1024,
+		PagefileUsage:   64 * 1024,
+		ReadOperations:  12,
+		WriteOperations: 5,
+		ReadBytes:       4096,
+		WriteBytes:      1024,
+	}
+
+	if !s.StartTime.Equal(want.StartTime) {
+		t.Errorf("start time: got %v, want %v", s.StartTime, want.StartTime)
+	}
+
+	s.StartTime, want.StartTime = time.Time{}, time.Time{}
+	if *s != want {
+		t.Errorf("got %+v\nwant %+v", *s, want)
+	}
+
+	if s.CPUTime() != 3250*time.Millisecond {
+		t.Errorf("CPU time: got %v", s.CPUTime())
+	}
+
+	// Without the io file, which needs the privileges to trace the process.
+	s, err = linuxStats(4242, []byte(procStatData), []byte(statData), []byte(statusData), nil)
+	if err != nil || s.ReadOperations != 0 || s.ReadBytes != 0 || s.WorkingSet != 2048*1024 {
+		t.Errorf("without io: got %+v, %v", s, err)
+	}
+
+	if _, err := linuxStats(1, []byte("cpu 0\n"), []byte(statData), []byte(statusData), nil); err == nil {
+		t.Error("no error without btime")
+	}
+
+	if _, err := linuxStats(1, []byte(procStatData), []byte("1 (x) S"), []byte(statusData), nil); err == nil {
+		t.Error("no error for a malformed stat")
+	}
+}
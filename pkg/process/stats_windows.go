@@ -0,0 +1,115 @@
+package process
+
+import (
+	"time"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	ReadStats returns a snapshot of the resource usage of the process identified by pid.
+	The process is opened with PROCESS_QUERY_LIMITED_INFORMATION, which protected
+	processes grant too. Before Windows 8.1 the memory counters also need
+	PROCESS_VM_READ; they are left zero if no such handle can be opened.
+*/
+func ReadStats(pid uint32) (*Stats, error) {
+	handle, err := kernel32.OpenProcess(kernel32.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer kernel32.CloseHandle(handle)
+
+	s, err := readStats(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := readMemoryStats(s, handle); err != nil {
+		if vm, err := kernel32.OpenProcess(kernel32.PROCESS_QUERY_LIMITED_INFORMATION|kernel32.PROCESS_VM_READ, false, pid); err == nil {
+			readMemoryStats(s, vm)
+			kernel32.CloseHandle(vm)
+		}
+	}
+
+	s.PID = pid
+	return s, nil
+}
+
+/*
+	ReadStatsHandle returns a snapshot of the resource usage of the process behind handle,
+	which must have the PROCESS_QUERY_LIMITED_INFORMATION access right, and PROCESS_VM_READ
+	before Windows 8.1. The PID of the returned Stats is left zero.
+*/
+func ReadStatsHandle(handle win32.Handle) (*Stats, error) {
+	s, err := readStats(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := readMemoryStats(s, handle); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+/*
+	readStats reads the statistics that only need PROCESS_QUERY_LIMITED_INFORMATION.
+*/
+func readStats(handle win32.Handle) (*Stats, error) {
+	times, err := kernel32.GetProcessTimes(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	io, err := kernel32.GetProcessIoCounters(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	handles, err := kernel32.GetProcessHandleCount(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stats{
+		StartTime:  FiletimeToTime(times.CreationTime),
+		KernelTime: time.Duration(times.KernelTime) * 100,
+		UserTime:   time.Duration(times.UserTime) * 100,
+
+		ReadOperations:  io.ReadOperationCount,
+		WriteOperations: io.WriteOperationCount,
+		OtherOperations: io.OtherOperationCount,
+		ReadBytes:       io.ReadTransferCount,
+		WriteBytes:      io.WriteTransferCount,
+		OtherBytes:      io.OtherTransferCount,
+
+		Handles: handles,
+	}
+
+	if _, exited, err := ExitCode(handle); err == nil && exited {
+		s.ExitTime = FiletimeToTime(times.ExitTime)
+	}
+
+	return s, nil
+}
+
+/*
+	readMemoryStats fills in the memory counters of s, which need PROCESS_VM_READ
+	before Windows 8.1.
+*/
+func readMemoryStats(s *Stats, handle win32.Handle) error {
+	mem, err := kernel32.GetProcessMemoryInfo(handle)
+	if err != nil {
+		return err
+	}
+
+	s.PageFaults = uint64(mem.PageFaultCount)
+	s.WorkingSet = uint64(mem.WorkingSetSize)
+	s.PeakWorkingSet = uint64(mem.PeakWorkingSetSize)
+	s.PrivateBytes = uint64(mem.PrivateUsage)
+	s.PagefileUsage = uint64(mem.PagefileUsage)
+	s.PeakPagefileUsage = uint64(mem.PeakPagefileUsage)
+	return nil
+}
@@ -0,0 +1,39 @@
+package process
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	Threads returns the identifiers of the threads of the process identified by pid.
+*/
+func Threads(pid uint32) ([]uint32, error) {
+	snapshot, err := kernel32.CreateToolhelp32Snapshot(kernel32.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer kernel32.CloseHandle(snapshot)
+
+	var threads []uint32
+
+	te := kernel32.ThreadEntry32{}
+	te.Size = uint32(unsafe.Sizeof(te))
+
+	err = kernel32.Thread32First(snapshot, &te)
+	for err == nil {
+		if te.OwnerProcessID == pid {
+			threads = append(threads, te.ThreadID)
+		}
+
+		err = kernel32.Thread32Next(snapshot, &te)
+	}
+
+	if !errors.Is(err, kernel32.ERROR_NO_MORE_FILES) {
+		return nil, err
+	}
+
+	return threads, nil
+}
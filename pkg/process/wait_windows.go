@@ -0,0 +1,96 @@
+package process
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	ExitCode returns the exit code of the process behind handle and whether it
+	has exited. A process that exited with STILL_ACTIVE (259) as its code is
+	told apart from a running one by checking whether the handle is signaled.
+
+	The handle must have the PROCESS_QUERY_LIMITED_INFORMATION and SYNCHRONIZE access rights.
+*/
+func ExitCode(handle win32.Handle) (uint32, bool, error) {
+	code, err := kernel32.GetExitCodeProcess(handle)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if code != kernel32.STILL_ACTIVE {
+		return code, true, nil
+	}
+
+	result, err := kernel32.WaitForSingleObject(handle, 0)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return code, result == kernel32.WAIT_OBJECT_0, nil
+}
+
+/*
+	Wait waits for the process behind handle to exit and returns its exit code,
+	or returns the error of ctx if it is done first.
+
+	The wait blocks a thread on both the process and an event that is signaled
+	when ctx is done, so no goroutine is left blocked after Wait returns.
+	The goroutine owns the event and closes it once its wait is over, so if
+	signaling the event fails, it still finishes when the process exits.
+
+	The handle must have the PROCESS_QUERY_LIMITED_INFORMATION and SYNCHRONIZE access rights.
+*/
+func Wait(ctx context.Context, handle win32.Handle) (uint32, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	cancel, err := kernel32.CreateEvent(true, false)
+	if err != nil {
+		return 0, fmt.Errorf("process: %w", err)
+	}
+
+	type waitResult struct {
+		result kernel32.WaitResult
+		err    error
+	}
+
+	done := make(chan waitResult, 1)
+	go func() {
+		result, err := kernel32.WaitForMultipleObjects([]win32.Handle{handle, cancel}, false, kernel32.INFINITE)
+		kernel32.CloseHandle(cancel)
+		done <- waitResult{result, err}
+	}()
+
+	var r waitResult
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		// Wake the waiting goroutine up. If that fails, it is left waiting on
+		// the process, and done is buffered so it can still finish and close
+		// the event.
+		if err := kernel32.SetEvent(cancel); err != nil {
+			return 0, fmt.Errorf("process: %w", err)
+		}
+		r = <-done
+	}
+
+	if r.err != nil {
+		return 0, fmt.Errorf("process: waiting: %w", r.err)
+	}
+
+	if r.result != kernel32.WAIT_OBJECT_0 {
+		return 0, ctx.Err()
+	}
+
+	code, err := kernel32.GetExitCodeProcess(handle)
+	if err != nil {
+		return 0, fmt.Errorf("process: %w", err)
+	}
+
+	return code, nil
+}
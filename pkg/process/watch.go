@@ -0,0 +1,176 @@
+package process
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ModuleEventKind int
+
+const (
+	ModuleLoaded ModuleEventKind = iota
+	ModuleUnloaded
+)
+
+func (k ModuleEventKind) String() string {
+	switch k {
+	case ModuleLoaded:
+		return "Loaded"
+	case ModuleUnloaded:
+		return "Unloaded"
+	}
+
+	return "ModuleEventKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+/*
+	ModuleEvent reports that a module was mapped into or unmapped from a process.
+*/
+type ModuleEvent struct {
+	Kind   ModuleEventKind
+	Module Module
+
+	/*
+		Initial is set on the Loaded events generated from the first snapshot,
+		for modules that were already loaded when watching started.
+	*/
+	Initial bool
+}
+
+type moduleKey struct {
+	base uintptr
+	size uint32
+	path string
+}
+
+func keyOf(m Module) moduleKey {
+	return moduleKey{base: m.Base, size: m.Size, path: strings.ToLower(m.Path)}
+}
+
+/*
+	DiffModules compares two snapshots of a module list and returns the modules
+	that were unloaded followed by the modules that were loaded, each ordered by base address.
+	A module that is replaced by another at the same base is reported as an unload and a load.
+*/
+func DiffModules(prev, next []Module) []ModuleEvent {
+	before := make(map[moduleKey]Module, len(prev))
+	for _, m := range prev {
+		before[keyOf(m)] = m
+	}
+
+	after := make(map[moduleKey]Module, len(next))
+	for _, m := range next {
+		after[keyOf(m)] = m
+	}
+
+	var unloaded, loaded []Module
+	for k, m := range before {
+		if _, ok := after[k]; !ok {
+			unloaded = append(unloaded, m)
+		}
+	}
+
+	for k, m := range after {
+		if _, ok := before[k]; !ok {
+			loaded = append(loaded, m)
+		}
+	}
+
+	sortModules(unloaded)
+	sortModules(loaded)
+
+	events := make([]ModuleEvent, 0, len(unloaded)+len(loaded))
+	for _, m := range unloaded {
+		events = append(events, ModuleEvent{Kind: ModuleUnloaded, Module: m})
+	}
+
+	for _, m := range loaded {
+		events = append(events, ModuleEvent{Kind: ModuleLoaded, Module: m})
+	}
+
+	return events
+}
+
+func sortModules(modules []Module) {
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Base < modules[j].Base
+	})
+}
+
+/*
+	ModuleWatcher polls a module source and reports changes between snapshots.
+*/
+type ModuleWatcher struct {
+	/*
+		Source returns the current module list of the watched process.
+	*/
+	Source func() ([]Module, error)
+
+	/*
+		Interval is the time between snapshots. It defaults to one second.
+	*/
+	Interval time.Duration
+}
+
+/*
+	Run takes snapshots until ctx is done or Source fails, sending an event
+	for every module loaded or unloaded between two snapshots.
+	Modules present in the first snapshot are sent as Loaded events with Initial set.
+
+	Run returns ctx.Err() once the context is done, or the error returned by Source.
+*/
+func (w *ModuleWatcher) Run(ctx context.Context, events chan<- ModuleEvent) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	prev, err := w.Source()
+	if err != nil {
+		return err
+	}
+
+	initial := append([]Module(nil), prev...)
+	sortModules(initial)
+	for _, m := range initial {
+		if err := send(ctx, events, ModuleEvent{Kind: ModuleLoaded, Module: m, Initial: true}); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		next, err := w.Source()
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range DiffModules(prev, next) {
+			if err := send(ctx, events, ev); err != nil {
+				return err
+			}
+		}
+
+		prev = next
+	}
+}
+
+func send(ctx context.Context, events chan<- ModuleEvent, ev ModuleEvent) error {
+	select {
+	case events <- ev:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
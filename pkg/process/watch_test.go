@@ -0,0 +1,164 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	ntdllModule    = Module{Name: "ntdll.dll", Path: `C:\Windows\System32\ntdll.dll`, Base: 0x77900000, Size: 0x1f8000}
+	kernel32Module = Module{Name: "KERNEL32.DLL", Path: `C:\Windows\System32\KERNEL32.DLL`, Base: 0x77800000, Size: 0xc2000}
+	appModule      = Module{Name: "app.exe", Path: `C:\app\app.exe`, Base: 0x400000, Size: 0x20000}
+	pluginModule   = Module{Name: "plugin.dll", Path: `C:\app\plugin.dll`, Base: 0x10000000, Size: 0x10000}
+	otherModule    = Module{Name: "other.dll", Path: `C:\app\other.dll`, Base: 0x10000000, Size: 0x10000}
+)
+
+func loaded(m Module) ModuleEvent {
+	return ModuleEvent{Kind: ModuleLoaded, Module: m}
+}
+
+func unloaded(m Module) ModuleEvent {
+	return ModuleEvent{Kind: ModuleUnloaded, Module: m}
+}
+
+func TestDiffModules(t *testing.T) {
+	renamed := kernel32Module
+	renamed.Path = `c:\windows\system32\kernel32.dll`
+
+	resized := pluginModule
+	resized.Size = 0x20000
+
+	tests := []struct {
+		name       string
+		prev, next []Module
+		want       []ModuleEvent
+	}{
+		{"empty", nil, nil, []ModuleEvent{}},
+		{"unchanged", []Module{appModule, ntdllModule}, []Module{ntdllModule, appModule}, []ModuleEvent{}},
+		{"initial", nil, []Module{ntdllModule, appModule, kernel32Module}, []ModuleEvent{loaded(appModule), loaded(kernel32Module), loaded(ntdllModule)}},
+		{"load", []Module{appModule, ntdllModule}, []Module{appModule, pluginModule, ntdllModule}, []ModuleEvent{loaded(pluginModule)}},
+		{"unload", []Module{appModule, pluginModule, ntdllModule}, []Module{appModule, ntdllModule}, []ModuleEvent{unloaded(pluginModule)}},
+		{"replaced at same base", []Module{appModule, pluginModule}, []Module{appModule, otherModule}, []ModuleEvent{unloaded(pluginModule), loaded(otherModule)}},
+		{"resized at same base", []Module{pluginModule}, []Module{resized}, []ModuleEvent{unloaded(pluginModule), loaded(resized)}},
+		{"case-only path change", []Module{appModule, kernel32Module}, []Module{appModule, renamed}, []ModuleEvent{}},
+		{"exit", []Module{ntdllModule, appModule, pluginModule}, nil, []ModuleEvent{unloaded(appModule), unloaded(pluginModule), unloaded(ntdllModule)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffModules(tt.prev, tt.next); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+/*
+	fakeSource returns each of its snapshots in turn and then err.
+*/
+type fakeSource struct {
+	snapshots [][]Module
+	err       error
+}
+
+func (s *fakeSource) next() ([]Module, error) {
+	if len(s.snapshots) == 0 {
+		return nil, s.err
+	}
+
+	m := s.snapshots[0]
+	s.snapshots = s.snapshots[1:]
+	return m, nil
+}
+
+func TestModuleWatcherRun(t *testing.T) {
+	errExited := errors.New("process exited")
+	src := &fakeSource{
+		snapshots: [][]Module{
+			{ntdllModule, appModule},
+			{ntdllModule, appModule},
+			{ntdllModule, appModule, pluginModule},
+			{ntdllModule, appModule, otherModule},
+			{ntdllModule, appModule},
+		},
+		err: errExited,
+	}
+
+	w := &ModuleWatcher{Source: src.next, Interval: time.Millisecond}
+	events := make(chan ModuleEvent)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(context.Background(), events)
+		close(events)
+	}()
+
+	var got []ModuleEvent
+	for ev := range events {
+		got = append(got, ev)
+	}
+
+	want := []ModuleEvent{
+		{Kind: ModuleLoaded, Module: appModule, Initial: true},
+		{Kind: ModuleLoaded, Module: ntdllModule, Initial: true},
+		loaded(pluginModule),
+		unloaded(pluginModule),
+		loaded(otherModule),
+		unloaded(otherModule),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	if err := <-done; !errors.Is(err, errExited) {
+		t.Errorf("Run returned %v, want %v", err, errExited)
+	}
+}
+
+func TestModuleWatcherCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	w := &ModuleWatcher{
+		Source: func() ([]Module, error) {
+			calls++
+			if calls == 3 {
+				cancel()
+			}
+			return []Module{appModule}, nil
+		},
+		Interval: time.Millisecond,
+	}
+
+	// Source cancels ctx on its third call, after which Run must stop polling.
+	events := make(chan ModuleEvent)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- w.Run(ctx, events)
+	}()
+
+	if ev := <-events; !ev.Initial {
+		t.Errorf("got %+v, want an initial event", ev)
+	}
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func TestModuleWatcherSourceError(t *testing.T) {
+	errDenied := errors.New("access denied")
+	w := &ModuleWatcher{Source: (&fakeSource{err: errDenied}).next}
+
+	if err := w.Run(context.Background(), make(chan ModuleEvent)); !errors.Is(err, errDenied) {
+		t.Errorf("Run returned %v, want %v", err, errDenied)
+	}
+}
@@ -0,0 +1,22 @@
+package process
+
+import (
+	"context"
+	"time"
+)
+
+/*
+	WatchModules reports modules loaded into and unloaded from the process identified by pid,
+	taking a toolhelp snapshot every interval. It runs until ctx is done or the
+	process can no longer be snapshotted, for example because it exited.
+*/
+func WatchModules(ctx context.Context, pid uint32, interval time.Duration, events chan<- ModuleEvent) error {
+	w := &ModuleWatcher{
+		Source: func() ([]Module, error) {
+			return Modules(pid)
+		},
+		Interval: interval,
+	}
+
+	return w.Run(ctx, events)
+}
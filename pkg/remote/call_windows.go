@@ -0,0 +1,116 @@
+package remote
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/win32"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	Mode returns the instruction set of the process behind handle:
+	32-bit for WOW64 processes and on 32-bit Windows, 64-bit otherwise.
+*/
+func Mode(handle win32.Handle) (x86.Mode, error) {
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		return x86.Mode32, nil
+	}
+
+	wow64, err := kernel32.IsWow64Process(handle)
+	if err != nil {
+		return 0, err
+	}
+
+	if wow64 {
+		return x86.Mode32, nil
+	}
+
+	return x86.Mode64, nil
+}
+
+/*
+	CallRemote calls fn in the process behind handle with args and returns its result,
+	see BuildStub for the calling conventions. It blocks until the call returns.
+	If waiting for the remote thread fails, the stub is left allocated in the
+	process since the thread may still be running it.
+
+	The handle must have the PROCESS_CREATE_THREAD, PROCESS_QUERY_INFORMATION,
+	PROCESS_VM_OPERATION, PROCESS_VM_WRITE and PROCESS_VM_READ access rights.
+*/
+func CallRemote(handle win32.Handle, fn uintptr, args ...uint64) (uint64, error) {
+	mode, err := Mode(handle)
+	if err != nil {
+		return 0, fmt.Errorf("remote: %w", err)
+	}
+
+	return CallRemoteMode(handle, mode, fn, args...)
+}
+
+/*
+	CallRemoteMode is CallRemote for a process whose mode is already known.
+*/
+func CallRemoteMode(handle win32.Handle, mode x86.Mode, fn uintptr, args ...uint64) (result uint64, err error) {
+	// The stub size does not depend on its address, build it once to size the block.
+	// The block is writable as well as executable since the stub stores its result there.
+	stub, err := BuildStub(mode, 0, uint64(fn), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	base, err := kernel32.VirtualAllocEx(handle, 0, uintptr(stub.Size()), kernel32.MEM_COMMIT|kernel32.MEM_RESERVE, kernel32.PAGE_EXECUTE_READWRITE)
+	if err != nil {
+		return 0, fmt.Errorf("remote: allocating stub: %w", err)
+	}
+
+	// Once the thread is created, the stub is only freed after the thread is
+	// known to have finished; otherwise it is leaked rather than freed under
+	// a thread that may still be executing it.
+	running := false
+	defer func() {
+		if running {
+			return
+		}
+		if ferr := kernel32.VirtualFreeEx(handle, base, 0, kernel32.MEM_RELEASE); err == nil && ferr != nil {
+			err = fmt.Errorf("remote: freeing stub: %w", ferr)
+		}
+	}()
+
+	if stub, err = BuildStub(mode, uint64(base), uint64(fn), args...); err != nil {
+		return 0, err
+	}
+
+	mem := process.Remote{Handle: handle}
+	if err := mem.WriteMemory(base, stub.Block()); err != nil {
+		return 0, fmt.Errorf("remote: writing stub: %w", err)
+	}
+
+	if err := kernel32.FlushInstructionCache(handle, base, uintptr(len(stub.Code))); err != nil {
+		return 0, fmt.Errorf("remote: %w", err)
+	}
+
+	thread, _, err := kernel32.CreateRemoteThread(handle, 0, base, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("remote: creating thread: %w", err)
+	}
+	defer kernel32.CloseHandle(thread)
+	running = true
+
+	wait, err := kernel32.WaitForSingleObject(thread, kernel32.INFINITE)
+	if err != nil {
+		return 0, fmt.Errorf("remote: waiting for thread: %w", err)
+	}
+	if wait != kernel32.WAIT_OBJECT_0 {
+		return 0, fmt.Errorf("remote: waiting for thread: unexpected result %#x", uint32(wait))
+	}
+	running = false
+
+	block := make([]byte, stub.Size())
+	if err := mem.ReadMemory(base, block); err != nil {
+		return 0, fmt.Errorf("remote: reading result: %w", err)
+	}
+
+	return stub.Result(block), nil
+}
@@ -0,0 +1,213 @@
+/*
+	Package remote calls functions in another process.
+
+	A call is made by writing a small stub into the target that loads the
+	arguments following the calling convention of the target, calls the
+	function and stores its return value, then running the stub on a new
+	remote thread. Stub generation is pure, so the machine code can be
+	checked byte for byte on any platform.
+*/
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	MaxArgs is the maximum number of arguments a stub passes to the called function.
+*/
+const MaxArgs = 16
+
+/*
+	ResultSize is the size of the result slot written by a stub.
+*/
+const ResultSize = 8
+
+var ErrTooManyArgs = fmt.Errorf("remote: more than %d arguments", MaxArgs)
+
+var ErrArgRange = errors.New("remote: argument does not fit in 32 bits")
+
+/*
+	Stub is the code of a remote call and the offset of its result slot.
+
+	The code is position dependent: it is built for a block at a known
+	address, holding the code followed by the 8 byte result slot.
+*/
+type Stub struct {
+	Code []byte
+
+	/*
+		ResultOffset is the offset of the result slot from the start of the block.
+	*/
+	ResultOffset int
+}
+
+/*
+	Size returns the size of the block holding the stub and its result slot.
+*/
+func (s *Stub) Size() int {
+	return s.ResultOffset + ResultSize
+}
+
+/*
+	Block returns the contents of the block, with a zeroed result slot.
+*/
+func (s *Stub) Block() []byte {
+	block := make([]byte, s.Size())
+	copy(block, s.Code)
+	return block
+}
+
+/*
+	Result decodes the result slot from the contents of the block after the call.
+*/
+func (s *Stub) Result(block []byte) uint64 {
+	return binary.LittleEndian.Uint64(block[s.ResultOffset:])
+}
+
+/*
+	BuildStub builds a stub placed at base that calls fn with args.
+
+	The stub is a thread start routine: it takes one pointer sized parameter,
+	which it ignores, and returns the low 32 bits of the result as exit code.
+
+	In 64-bit mode fn is called with the Microsoft x64 convention, the first
+	four arguments in RCX, RDX, R8 and R9 and the others on the stack above
+	the 32 byte shadow space, and RAX is stored as the result.
+
+	In 32-bit mode the arguments are pushed on the stack from right to left
+	and EDX:EAX is stored as the result. The stack pointer is restored after
+	the call, so fn may follow either the stdcall or the cdecl convention.
+	Arguments and fn must fit in 32 bits.
+*/
+func BuildStub(mode x86.Mode, base, fn uint64, args ...uint64) (*Stub, error) {
+	if len(args) > MaxArgs {
+		return nil, ErrTooManyArgs
+	}
+
+	switch mode {
+	case x86.Mode64:
+		return build64(base, fn, args), nil
+	case x86.Mode32:
+		return build32(base, fn, args)
+	}
+
+	return nil, fmt.Errorf("remote: unsupported mode %v", mode)
+}
+
+/*
+	resultOffset places the result slot after the code, aligned to 8 bytes.
+*/
+func resultOffset(codeLen int) int {
+	return (codeLen + ResultSize - 1) &^ (ResultSize - 1)
+}
+
+func build64(base, fn uint64, args []uint64) *Stub {
+	var code []byte
+
+	stackArgs := 0
+	if len(args) > 4 {
+		stackArgs = len(args) - 4
+	}
+
+	// The thread starts with RSP 8 bytes below a 16 byte boundary, after
+	// the return address was pushed. The frame keeps the call aligned.
+	frame := (0x20+8*stackArgs+15)&^15 + 8
+
+	// sub rsp, frame
+	code = append(code, 0x48, 0x81, 0xEC)
+	code = appendUint32(code, uint32(frame))
+
+	for i := 4; i < len(args); i++ {
+		// mov rax, imm64
+		code = append(code, 0x48, 0xB8)
+		code = appendUint64(code, args[i])
+
+		// mov [rsp+disp32], rax
+		code = append(code, 0x48, 0x89, 0x84, 0x24)
+		code = appendUint32(code, uint32(0x20+8*(i-4)))
+	}
+
+	// mov rcx, imm64; mov rdx, imm64; mov r8, imm64; mov r9, imm64
+	regs := [4][2]byte{{0x48, 0xB9}, {0x48, 0xBA}, {0x49, 0xB8}, {0x49, 0xB9}}
+	for i := 0; i < len(args) && i < 4; i++ {
+		code = append(code, regs[i][:]...)
+		code = appendUint64(code, args[i])
+	}
+
+	// mov rax, fn; call rax
+	code = append(code, 0x48, 0xB8)
+	code = appendUint64(code, fn)
+	code = append(code, 0xFF, 0xD0)
+
+	// Both immediates are patched below, once the code length is known.
+	// mov rcx, result; mov [rcx], rax
+	code = append(code, 0x48, 0xB9)
+	resultImm := len(code)
+	code = append(code, make([]byte, 8)...)
+	code = append(code, 0x48, 0x89, 0x01)
+
+	// add rsp, frame; ret
+	code = append(code, 0x48, 0x81, 0xC4)
+	code = appendUint32(code, uint32(frame))
+	code = append(code, 0xC3)
+
+	off := resultOffset(len(code))
+	binary.LittleEndian.PutUint64(code[resultImm:], base+uint64(off))
+
+	return &Stub{Code: code, ResultOffset: off}
+}
+
+func build32(base, fn uint64, args []uint64) (*Stub, error) {
+	if fn > 0xFFFFFFFF || base > 0xFFFFFFFF {
+		return nil, ErrArgRange
+	}
+
+	for _, arg := range args {
+		if arg > 0xFFFFFFFF {
+			return nil, ErrArgRange
+		}
+	}
+
+	// push ebp; mov ebp, esp
+	code := []byte{0x55, 0x89, 0xE5}
+
+	for i := len(args) - 1; i >= 0; i-- {
+		// push imm32
+		code = append(code, 0x68)
+		code = appendUint32(code, uint32(args[i]))
+	}
+
+	// mov eax, fn; call eax
+	code = append(code, 0xB8)
+	code = appendUint32(code, uint32(fn))
+	code = append(code, 0xFF, 0xD0)
+
+	// mov [result], eax; mov [result+4], edx
+	code = append(code, 0xA3)
+	resultImm := len(code)
+	code = append(code, make([]byte, 4)...)
+	code = append(code, 0x89, 0x15)
+	code = append(code, make([]byte, 4)...)
+
+	// mov esp, ebp; pop ebp; ret 4
+	code = append(code, 0x89, 0xEC, 0x5D, 0xC2, 0x04, 0x00)
+
+	off := resultOffset(len(code))
+	binary.LittleEndian.PutUint32(code[resultImm:], uint32(base)+uint32(off))
+	binary.LittleEndian.PutUint32(code[resultImm+6:], uint32(base)+uint32(off)+4)
+
+	return &Stub{Code: code, ResultOffset: off}, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
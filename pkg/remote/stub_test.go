@@ -0,0 +1,273 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	asm joins instructions written as hex into machine code.
+*/
+func asm(t *testing.T, insts ...string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(strings.Join(insts, ""), " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBuildStubGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   x86.Mode
+		base   uint64
+		fn     uint64
+		args   []uint64
+		code   []byte
+		result int
+	}{
+		{
+			name: "x64 without arguments",
+			mode: x86.Mode64, base: 0x10000, fn: 0x7FFA12345678,
+			code: asm(t,
+				"48 81 ec 28 00 00 00",          // sub rsp, 0x28
+				"48 b8 78 56 34 12 fa 7f 00 00", // mov rax, fn
+				"ff d0",                         // call rax
+				"48 b9 28 00 01 00 00 00 00 00", // mov rcx, result
+				"48 89 01",                      // mov [rcx], rax
+				"48 81 c4 28 00 00 00",          // add rsp, 0x28
+				"c3",                            // ret
+			),
+			result: 0x28,
+		},
+		{
+			name: "x64 register arguments",
+			mode: x86.Mode64, base: 0x20000000000, fn: 0x7FFA12345678,
+			args: []uint64{1, 0xFFFFFFFFFFFFFFFF, 0x123456789, 4},
+			code: asm(t,
+				"48 81 ec 28 00 00 00",          // sub rsp, 0x28
+				"48 b9 01 00 00 00 00 00 00 00", // mov rcx, 1
+				"48 ba ff ff ff ff ff ff ff ff", // mov rdx, -1
+				"49 b8 89 67 45 23 01 00 00 00", // mov r8, 0x123456789
+				"49 b9 04 00 00 00 00 00 00 00", // mov r9, 4
+				"48 b8 78 56 34 12 fa 7f 00 00", // mov rax, fn
+				"ff d0",                         // call rax
+				"48 b9 50 00 00 00 00 02 00 00", // mov rcx, result
+				"48 89 01",                      // mov [rcx], rax
+				"48 81 c4 28 00 00 00",          // add rsp, 0x28
+				"c3",                            // ret
+			),
+			result: 0x50,
+		},
+		{
+			name: "x64 stack arguments",
+			mode: x86.Mode64, base: 0x10000, fn: 0x7FFA12345678,
+			args: []uint64{1, 2, 3, 4, 5, 6, 7},
+			code: asm(t,
+				"48 81 ec 48 00 00 00",          // sub rsp, 0x48
+				"48 b8 05 00 00 00 00 00 00 00", // mov rax, 5
+				"48 89 84 24 20 00 00 00",       // mov [rsp+0x20], rax
+				"48 b8 06 00 00 00 00 00 00 00", // mov rax, 6
+				"48 89 84 24 28 00 00 00",       // mov [rsp+0x28], rax
+				"48 b8 07 00 00 00 00 00 00 00", // mov rax, 7
+				"48 89 84 24 30 00 00 00",       // mov [rsp+0x30], rax
+				"48 b9 01 00 00 00 00 00 00 00", // mov rcx, 1
+				"48 ba 02 00 00 00 00 00 00 00", // mov rdx, 2
+				"49 b8 03 00 00 00 00 00 00 00", // mov r8, 3
+				"49 b9 04 00 00 00 00 00 00 00", // mov r9, 4
+				"48 b8 78 56 34 12 fa 7f 00 00", // mov rax, fn
+				"ff d0",                         // call rax
+				"48 b9 88 00 01 00 00 00 00 00", // mov rcx, result
+				"48 89 01",                      // mov [rcx], rax
+				"48 81 c4 48 00 00 00",          // add rsp, 0x48
+				"c3",                            // ret
+			),
+			result: 0x88,
+		},
+		{
+			name: "x86 without arguments",
+			mode: x86.Mode32, base: 0x400000, fn: 0x77001234,
+			code: asm(t,
+				"55",                // push ebp
+				"89 e5",             // mov ebp, esp
+				"b8 34 12 00 77",    // mov eax, fn
+				"ff d0",             // call eax
+				"a3 20 00 40 00",    // mov [result], eax
+				"89 15 24 00 40 00", // mov [result+4], edx
+				"89 ec",             // mov esp, ebp
+				"5d",                // pop ebp
+				"c2 04 00",          // ret 4
+			),
+			result: 0x20,
+		},
+		{
+			name: "x86 arguments pushed right to left",
+			mode: x86.Mode32, base: 0x400000, fn: 0x77001234,
+			args: []uint64{0x11, 0xFFFFFFFF},
+			code: asm(t,
+				"55",                // push ebp
+				"89 e5",             // mov ebp, esp
+				"68 ff ff ff ff",    // push 0xFFFFFFFF
+				"68 11 00 00 00",    // push 0x11
+				"b8 34 12 00 77",    // mov eax, fn
+				"ff d0",             // call eax
+				"a3 28 00 40 00",    // mov [result], eax
+				"89 15 2c 00 40 00", // mov [result+4], edx
+				"89 ec",             // mov esp, ebp
+				"5d",                // pop ebp
+				"c2 04 00",          // ret 4
+			),
+			result: 0x28,
+		},
+	}
+
+	for _, tc := range tests {
+		stub, err := BuildStub(tc.mode, tc.base, tc.fn, tc.args...)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+
+		if !bytes.Equal(stub.Code, tc.code) {
+			t.Errorf("%s: got\n% x\nwant\n% x", tc.name, stub.Code, tc.code)
+		}
+
+		if stub.ResultOffset != tc.result || stub.Size() != tc.result+ResultSize {
+			t.Errorf("%s: result at %#x, size %#x; want result at %#x", tc.name, stub.ResultOffset, stub.Size(), tc.result)
+		}
+	}
+}
+
+/*
+	TestBuildStubStackAlignment checks that RSP is 16 byte aligned at the call
+	for every argument count, and that the stack arguments sit above the
+	shadow space inside the frame.
+*/
+func TestBuildStubStackAlignment(t *testing.T) {
+	for n := 0; n <= MaxArgs; n++ {
+		args := make([]uint64, n)
+		for i := range args {
+			args[i] = uint64(i + 1)
+		}
+
+		stub, err := BuildStub(x86.Mode64, 0x10000, 0x7FFA12345678, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code := stub.Code
+		if !bytes.Equal(code[:3], []byte{0x48, 0x81, 0xEC}) {
+			t.Fatalf("%d args: stub does not start with sub rsp: % x", n, code[:3])
+		}
+
+		frame := binary.LittleEndian.Uint32(code[3:])
+
+		// The thread starts with RSP at 8 modulo 16.
+		if frame%16 != 8 {
+			t.Errorf("%d args: frame %#x misaligns the call", n, frame)
+		}
+
+		stackArgs := 0
+		if n > 4 {
+			stackArgs = n - 4
+		}
+		if frame < uint32(0x20+8*stackArgs) {
+			t.Errorf("%d args: frame %#x is too small for %d stack arguments", n, frame, stackArgs)
+		}
+
+		// The frame is released with the same size.
+		epilogue := code[len(code)-8:]
+		if !bytes.Equal(epilogue[:3], []byte{0x48, 0x81, 0xC4}) || binary.LittleEndian.Uint32(epilogue[3:]) != frame || epilogue[7] != 0xC3 {
+			t.Errorf("%d args: epilogue % x does not release %#x bytes", n, epilogue, frame)
+		}
+	}
+}
+
+/*
+	TestBuildStubDecodes checks that the stubs are made of whole instructions
+	with nothing left after the ret.
+*/
+func TestBuildStubDecodes(t *testing.T) {
+	for _, mode := range []x86.Mode{x86.Mode32, x86.Mode64} {
+		for n := 0; n <= MaxArgs; n++ {
+			stub, err := BuildStub(mode, 0x10000, 0x12345678, make([]uint64, n)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			insts, err := x86.DecodeAll(stub.Code, mode, len(stub.Code))
+			if err != nil {
+				t.Fatalf("%d-bit, %d args: %v", mode, n, err)
+			}
+
+			end := 0
+			for _, inst := range insts {
+				end += inst.Len
+			}
+
+			last := insts[len(insts)-1]
+			if end != len(stub.Code) || (last.Opcode != 0xC3 && last.Opcode != 0xC2) {
+				t.Errorf("%d-bit, %d args: decoded %d of %d bytes, last opcode %#x", mode, n, end, len(stub.Code), last.Opcode)
+			}
+
+			if stub.ResultOffset < len(stub.Code) || stub.ResultOffset%8 != 0 {
+				t.Errorf("%d-bit, %d args: result slot at %#x overlaps the code or is misaligned", mode, n, stub.ResultOffset)
+			}
+		}
+	}
+}
+
+func TestBuildStubErrors(t *testing.T) {
+	if _, err := BuildStub(x86.Mode64, 0x10000, 0x1000, make([]uint64, MaxArgs+1)...); !errors.Is(err, ErrTooManyArgs) {
+		t.Errorf("too many arguments: got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		base, fn uint64
+		args     []uint64
+	}{
+		{"function", 0x10000, 0x100000000, nil},
+		{"base", 0x100000000, 0x1000, nil},
+		{"argument", 0x10000, 0x1000, []uint64{1, 0x100000000}},
+	}
+
+	for _, tc := range tests {
+		if _, err := BuildStub(x86.Mode32, tc.base, tc.fn, tc.args...); !errors.Is(err, ErrArgRange) {
+			t.Errorf("32-bit %s out of range: got %v", tc.name, err)
+		}
+	}
+
+	if _, err := BuildStub(16, 0x10000, 0x1000); err == nil {
+		t.Error("16-bit mode: no error")
+	}
+}
+
+func TestStubBlock(t *testing.T) {
+	stub, err := BuildStub(x86.Mode32, 0x400000, 0x77001234)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := stub.Block()
+	if len(block) != stub.Size() || !bytes.Equal(block[:len(stub.Code)], stub.Code) {
+		t.Fatalf("block % x does not start with the code", block)
+	}
+
+	if stub.Result(block) != 0 {
+		t.Errorf("result slot is not zeroed: %#x", stub.Result(block))
+	}
+
+	binary.LittleEndian.PutUint64(block[stub.ResultOffset:], 0x1122334455667788)
+	if got := stub.Result(block); got != 0x1122334455667788 {
+		t.Errorf("got result %#x", got)
+	}
+}
@@ -0,0 +1,351 @@
+/*
+	Package symbolize maps addresses in a process to stable, human readable
+	locations of the form "module!export+0xoff" and back.
+
+	Absolute addresses change between runs because of ASLR, while module
+	relative locations do not. A Symbolizer is built from a list of modules,
+	their export tables and their section tables and performs all lookups
+	in memory, so it can be constructed from fixtures as well as from a live process.
+*/
+package symbolize
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+)
+
+var ErrUnknownModule = errors.New("symbolize: unknown module")
+
+/*
+	Module describes a loaded module along with the symbols used to describe addresses inside it.
+*/
+type Module struct {
+	Name     string
+	Base     uintptr
+	Size     uint32
+	Exports  []pe.Export
+	Sections []pe.Section
+}
+
+/*
+	Symbol is the symbolic description of an address.
+*/
+type Symbol struct {
+	Address uintptr
+
+	/*
+		Module is the name of the module containing Address.
+	*/
+	Module string
+
+	/*
+		Offset is the distance of Address from the base of Module.
+	*/
+	Offset uintptr
+
+	/*
+		Export is the nearest export at or before Address in the same section,
+		or empty if there is none.
+	*/
+	Export string
+
+	/*
+		ExportOffset is the distance of Address from Export.
+	*/
+	ExportOffset uintptr
+
+	/*
+		Section is the name of the section containing Address, or empty if
+		Address falls inside the image headers.
+	*/
+	Section string
+}
+
+/*
+	String formats the symbol as "module!export+0xoff" when an export is known
+	and as "module+0xoff" otherwise.
+*/
+func (s Symbol) String() string {
+	if s.Export != "" {
+		if s.ExportOffset == 0 {
+			return s.Module + "!" + s.Export
+		}
+		return fmt.Sprintf("%s!%s+%#x", s.Module, s.Export, s.ExportOffset)
+	}
+
+	return fmt.Sprintf("%s+%#x", s.Module, s.Offset)
+}
+
+type export struct {
+	name string
+	rva  uint32
+}
+
+type module struct {
+	Module
+	exports []export
+}
+
+/*
+	Symbolizer resolves addresses against a fixed set of modules.
+	It is safe for concurrent use once constructed.
+*/
+type Symbolizer struct {
+	modules []module
+}
+
+/*
+	New builds a Symbolizer from modules. Forwarded exports are ignored
+	since they do not point into the module's code.
+*/
+func New(modules []Module) *Symbolizer {
+	s := &Symbolizer{modules: make([]module, 0, len(modules))}
+
+	for _, m := range modules {
+		idx := module{Module: m}
+		idx.Sections = append([]pe.Section(nil), m.Sections...)
+		sort.Slice(idx.Sections, func(i, j int) bool {
+			return idx.Sections[i].VirtualAddress < idx.Sections[j].VirtualAddress
+		})
+
+		for _, exp := range m.Exports {
+			if exp.Forwarder != "" {
+				continue
+			}
+
+			name := exp.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(int(exp.Ordinal))
+			}
+			idx.exports = append(idx.exports, export{name: name, rva: exp.RVA})
+		}
+
+		sort.SliceStable(idx.exports, func(i, j int) bool {
+			return idx.exports[i].rva < idx.exports[j].rva
+		})
+
+		s.modules = append(s.modules, idx)
+	}
+
+	sort.Slice(s.modules, func(i, j int) bool {
+		return s.modules[i].Base < s.modules[j].Base
+	})
+
+	return s
+}
+
+/*
+	Modules returns the modules known to the symbolizer, ordered by base address.
+*/
+func (s *Symbolizer) Modules() []Module {
+	modules := make([]Module, len(s.modules))
+	for i := range s.modules {
+		modules[i] = s.modules[i].Module
+	}
+
+	return modules
+}
+
+func (s *Symbolizer) moduleAt(addr uintptr) *module {
+	i := sort.Search(len(s.modules), func(i int) bool {
+		return s.modules[i].Base > addr
+	})
+
+	if i == 0 {
+		return nil
+	}
+
+	m := &s.modules[i-1]
+	if addr-m.Base >= uintptr(m.Size) {
+		return nil
+	}
+
+	return m
+}
+
+func (m *module) sectionAt(rva uint32) *pe.Section {
+	i := sort.Search(len(m.Sections), func(i int) bool {
+		return m.Sections[i].VirtualAddress > rva
+	})
+
+	if i == 0 || !m.Sections[i-1].Contains(rva) {
+		return nil
+	}
+
+	return &m.Sections[i-1]
+}
+
+/*
+	Symbolize describes addr. It returns false if addr is not inside any known module.
+*/
+func (s *Symbolizer) Symbolize(addr uintptr) (Symbol, bool) {
+	m := s.moduleAt(addr)
+	if m == nil {
+		return Symbol{Address: addr}, false
+	}
+
+	rva := uint32(addr - m.Base)
+	sym := Symbol{
+		Address: addr,
+		Module:  m.Name,
+		Offset:  addr - m.Base,
+	}
+
+	sec := m.sectionAt(rva)
+	if sec != nil {
+		sym.Section = sec.Name
+	}
+
+	i := sort.Search(len(m.exports), func(i int) bool {
+		return m.exports[i].rva > rva
+	})
+
+	// Of several names for the same address, the first is used.
+	for i > 1 && m.exports[i-2].rva == m.exports[i-1].rva {
+		i--
+	}
+
+	if i > 0 {
+		exp := m.exports[i-1]
+		if sec == nil || sec.Contains(exp.rva) {
+			sym.Export = exp.name
+			sym.ExportOffset = uintptr(rva - exp.rva)
+		}
+	}
+
+	return sym, true
+}
+
+/*
+	Format returns the symbolic form of addr, or the address in hex if
+	it is not inside any known module.
+*/
+func (s *Symbolizer) Format(addr uintptr) string {
+	sym, ok := s.Symbolize(addr)
+	if !ok {
+		return fmt.Sprintf("%#x", addr)
+	}
+
+	return sym.String()
+}
+
+func (s *Symbolizer) lookupModule(name string) *module {
+	for i := range s.modules {
+		m := &s.modules[i]
+		if strings.EqualFold(m.Name, name) || strings.EqualFold(trimExt(m.Name), name) {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func trimExt(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		return name[:i]
+	}
+
+	return name
+}
+
+/*
+	Resolve parses a location produced by Symbol.String back into an address.
+	Accepted forms are:
+
+		module
+		module+0x1234
+		module!Export
+		module!Export+0x10
+		0x7ff612340000
+
+	Module names are matched case insensitively, with or without their extension.
+	Offsets are always interpreted as hexadecimal; the 0x prefix is optional.
+*/
+func (s *Symbolizer) Resolve(location string) (uintptr, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return 0, errors.New("symbolize: empty location")
+	}
+
+	base, offset := location, ""
+	if i := strings.LastIndexByte(location, '+'); i >= 0 {
+		base, offset = location[:i], location[i+1:]
+	}
+
+	var off uintptr
+	if offset != "" {
+		v, err := parseHex(offset)
+		if err != nil {
+			return 0, fmt.Errorf("symbolize: invalid offset in %q: %w", location, err)
+		}
+		off = v
+	}
+
+	modName, expName := base, ""
+	if i := strings.IndexByte(base, '!'); i >= 0 {
+		modName, expName = base[:i], base[i+1:]
+	}
+
+	m := s.lookupModule(modName)
+	if m == nil {
+		if expName == "" && offset == "" {
+			if addr, err := parseHex(location); err == nil {
+				return addr, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: %q", ErrUnknownModule, modName)
+	}
+
+	if expName == "" {
+		return m.Base + off, nil
+	}
+
+	for _, exp := range m.exports {
+		if exp.name == expName {
+			return m.Base + uintptr(exp.rva) + off, nil
+		}
+	}
+
+	return 0, fmt.Errorf("symbolize: %s has no export %q", m.Name, expName)
+}
+
+func parseHex(s string) (uintptr, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return uintptr(v), nil
+}
+
+/*
+	ModuleFromImage builds a Module from a parsed image mapped at base.
+	A missing export directory is not an error; the module is then
+	described by sections and offsets only.
+*/
+func ModuleFromImage(name string, base uintptr, img *pe.Image) (Module, error) {
+	m := Module{
+		Name:     name,
+		Base:     base,
+		Size:     img.OptionalHeader.SizeOfImage,
+		Sections: img.Sections,
+	}
+
+	ed, err := img.Exports()
+	if err != nil && !errors.Is(err, pe.ErrNoDirectory) {
+		return m, err
+	}
+
+	if ed != nil {
+		m.Exports = ed.Exports
+	}
+
+	return m, nil
+}
@@ -0,0 +1,160 @@
+package symbolize
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+)
+
+func fixture() *Symbolizer {
+	return New([]Module{
+		{
+			Name: "kernel32.dll",
+			Base: 0x78000000,
+			Size: 0x4000,
+			Sections: []pe.Section{
+				{Name: ".data", VirtualAddress: 0x3000, VirtualSize: 0x1000},
+				{Name: ".text", VirtualAddress: 0x1000, VirtualSize: 0x2000},
+			},
+			Exports: []pe.Export{
+				{Name: "Sleep", Ordinal: 2, RVA: 0x1800},
+				{Name: "CreateFileW", Ordinal: 1, RVA: 0x1100},
+				{Name: "CreateFile", Ordinal: 1, RVA: 0x1100},
+				{Ordinal: 3, RVA: 0x2000},
+				{Name: "HeapAlloc", Ordinal: 4, RVA: 0x4000, Forwarder: "NTDLL.RtlAllocateHeap"},
+			},
+		},
+		{
+			Name: "app.exe",
+			Base: 0x400000,
+			Size: 0x2000,
+			Sections: []pe.Section{
+				{Name: ".text", VirtualAddress: 0x1000, VirtualSize: 0x1000},
+			},
+		},
+	})
+}
+
+func TestSymbolize(t *testing.T) {
+	s := fixture()
+
+	tests := []struct {
+		addr uintptr
+		want string
+		ok   bool
+	}{
+		{0x78001100, "kernel32.dll!CreateFileW", true},
+		{0x78001104, "kernel32.dll!CreateFileW+0x4", true},
+		{0x78001900, "kernel32.dll!Sleep+0x100", true},
+		{0x78002010, "kernel32.dll!#3+0x10", true},
+		// Exports are not used across section boundaries.
+		{0x78003010, "kernel32.dll+0x3010", true},
+		// Headers and code before the first export.
+		{0x78000010, "kernel32.dll+0x10", true},
+		{0x78001000, "kernel32.dll+0x1000", true},
+		{0x401234, "app.exe+0x1234", true},
+		{0x402000, "0x402000", false},
+		{0x1000, "0x1000", false},
+	}
+
+	for _, tt := range tests {
+		_, ok := s.Symbolize(tt.addr)
+		if got := s.Format(tt.addr); got != tt.want || ok != tt.ok {
+			t.Errorf("Format(%#x) = %q, %v; want %q, %v", tt.addr, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	sym, _ := s.Symbolize(0x78001104)
+	want := Symbol{
+		Address:      0x78001104,
+		Module:       "kernel32.dll",
+		Offset:       0x1104,
+		Export:       "CreateFileW",
+		ExportOffset: 4,
+		Section:      ".text",
+	}
+	if sym != want {
+		t.Errorf("got %+v, want %+v", sym, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := fixture()
+
+	tests := []struct {
+		location string
+		want     uintptr
+	}{
+		{"kernel32.dll", 0x78000000},
+		{"KERNEL32", 0x78000000},
+		{"kernel32+1234", 0x78001234},
+		{"kernel32.dll+0x1234", 0x78001234},
+		{"kernel32.dll!Sleep", 0x78001800},
+		{"kernel32.dll!CreateFile+0x10", 0x78001110},
+		{"kernel32.dll!#3", 0x78002000},
+		{" app.exe+0x10 ", 0x400010},
+		{"0x12340000", 0x12340000},
+	}
+
+	for _, tt := range tests {
+		got, err := s.Resolve(tt.location)
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %#x, %v; want %#x", tt.location, got, err, tt.want)
+		}
+	}
+
+	for _, location := range []string{"", "ntdll.dll+0x10", "kernel32.dll!HeapAlloc", "kernel32.dll+0xzz"} {
+		if _, err := s.Resolve(location); err == nil {
+			t.Errorf("Resolve(%q) succeeded", location)
+		}
+	}
+
+	if _, err := s.Resolve("ntdll.dll!RtlAllocateHeap"); !errors.Is(err, ErrUnknownModule) {
+		t.Errorf("got %v, want ErrUnknownModule", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := fixture()
+
+	for addr := uintptr(0x78000000); addr < 0x78004000; addr += 0x7b {
+		got, err := s.Resolve(s.Format(addr))
+		if err != nil || got != addr {
+			t.Fatalf("Resolve(Format(%#x)) = %#x, %v", addr, got, err)
+		}
+	}
+}
+
+func TestModuleFromImage(t *testing.T) {
+	b := petest.New(true)
+	text := b.AddSection(".text", make([]byte, 0x200), pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE)
+
+	rva := b.NextRVA()
+	dir := petest.ExportDirectory(rva, "lib.dll", 1, []petest.Export{
+		{Names: []string{"Run", "Start"}, RVA: text + 0x40},
+	})
+	b.AddSection(".edata", dir, pe.IMAGE_SCN_MEM_READ)
+	b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT, rva, len(dir))
+
+	img, err := pe.NewImage(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ModuleFromImage("lib.dll", 0x10000000, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New([]Module{m})
+	if got := s.Format(0x10000000 + uintptr(text) + 0x48); got != "lib.dll!Run+0x8" {
+		t.Errorf("got %q", got)
+	}
+
+	if got, err := s.Resolve("lib!Start"); err != nil || got != 0x10000000+uintptr(text)+0x40 {
+		t.Errorf("Resolve(lib!Start) = %#x, %v", got, err)
+	}
+}
@@ -0,0 +1,42 @@
+package symbolize
+
+import (
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+/*
+	ForProcess builds a Symbolizer for the modules currently loaded in the process identified by pid.
+	Modules whose headers cannot be read are still included, described by offset only.
+*/
+func ForProcess(pid uint32) (*Symbolizer, error) {
+	ph, err := kernel32.OpenProcess(kernel32.PROCESS_QUERY_INFORMATION|kernel32.PROCESS_VM_READ, false, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer kernel32.CloseHandle(ph)
+
+	loaded, err := process.Modules(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	mem := process.Remote{Handle: ph}
+	modules := make([]Module, 0, len(loaded))
+
+	for _, lm := range loaded {
+		m := Module{Name: lm.Name, Base: lm.Base, Size: lm.Size}
+
+		img, err := process.OpenModule(mem, lm)
+		if err == nil {
+			if full, err := ModuleFromImage(lm.Name, lm.Base, img); err == nil {
+				m = full
+				m.Size = lm.Size
+			}
+		}
+
+		modules = append(modules, m)
+	}
+
+	return New(modules), nil
+}
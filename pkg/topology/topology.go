@@ -0,0 +1,437 @@
+/*
+	Package topology decodes the processor topology reported by
+	GetLogicalProcessorInformationEx: processor groups, packages, cores,
+	caches and NUMA nodes, and the logical processors that belong to each.
+
+	Decoding works on the raw buffer, so captured buffers can be decoded
+	on any platform.
+*/
+package topology
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	Relationship values of SYSTEM_LOGICAL_PROCESSOR_INFORMATION_EX.
+*/
+const (
+	relationProcessorCore    = 0
+	relationNumaNode         = 1
+	relationCache            = 2
+	relationProcessorPackage = 3
+	relationGroup            = 4
+	relationProcessorDie     = 5
+	relationNumaNodeEx       = 6
+	relationProcessorModule  = 7
+)
+
+/*
+	LTP_PC_SMT is set in the Flags of a core with more than one logical processor.
+*/
+const LTP_PC_SMT uint8 = 0x1
+
+/*
+	CacheType is the kind of a processor cache (PROCESSOR_CACHE_TYPE).
+*/
+type CacheType uint32
+
+const (
+	CacheUnified     CacheType = 0
+	CacheInstruction CacheType = 1
+	CacheData        CacheType = 2
+	CacheTrace       CacheType = 3
+)
+
+func (t CacheType) String() string {
+	switch t {
+	case CacheUnified:
+		return "Unified"
+	case CacheInstruction:
+		return "Instruction"
+	case CacheData:
+		return "Data"
+	case CacheTrace:
+		return "Trace"
+	}
+
+	return fmt.Sprintf("CacheType(%d)", uint32(t))
+}
+
+/*
+	GroupAffinity is a set of logical processors of a single processor group.
+*/
+type GroupAffinity struct {
+	Group uint16
+	Mask  uint64
+}
+
+/*
+	Count returns the number of logical processors in the set.
+*/
+func (a GroupAffinity) Count() int {
+	return bits.OnesCount64(a.Mask)
+}
+
+/*
+	Contains reports whether the logical processor number of group is in the set.
+*/
+func (a GroupAffinity) Contains(group uint16, number int) bool {
+	return a.Group == group && number >= 0 && number < 64 && a.Mask&(1<<uint(number)) != 0
+}
+
+/*
+	Processors is the set of logical processors an entity, such as a core or a cache, spans.
+	It has one GroupAffinity for each processor group the entity has processors in.
+*/
+type Processors []GroupAffinity
+
+/*
+	Count returns the number of logical processors in the set.
+*/
+func (p Processors) Count() int {
+	n := 0
+	for _, a := range p {
+		n += a.Count()
+	}
+
+	return n
+}
+
+/*
+	Contains reports whether the logical processor number of group is in the set.
+*/
+func (p Processors) Contains(group uint16, number int) bool {
+	for _, a := range p {
+		if a.Contains(group, number) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	ProcessorSet is a core, package, die or module (PROCESSOR_RELATIONSHIP).
+*/
+type ProcessorSet struct {
+	/*
+		Flags is LTP_PC_SMT for a core with simultaneous multithreading, and zero otherwise.
+	*/
+	Flags uint8
+
+	/*
+		EfficiencyClass ranks cores by performance, higher classes being faster but less
+		power efficient. It is zero on systems with a single class of cores.
+	*/
+	EfficiencyClass uint8
+
+	Processors Processors
+}
+
+/*
+	Cache describes a processor cache (CACHE_RELATIONSHIP).
+*/
+type Cache struct {
+	Level uint8
+
+	/*
+		Associativity is the number of ways of the cache, or 0xFF for a fully associative cache.
+	*/
+	Associativity uint8
+	LineSize      uint16
+	Size          uint32
+	Type          CacheType
+	Processors    Processors
+}
+
+/*
+	NumaNode is a NUMA node and the logical processors attached to it (NUMA_NODE_RELATIONSHIP).
+*/
+type NumaNode struct {
+	Number     uint32
+	Processors Processors
+}
+
+/*
+	Group is a processor group (PROCESSOR_GROUP_INFO).
+*/
+type Group struct {
+	MaximumProcessors uint8
+	ActiveProcessors  uint8
+	ActiveMask        uint64
+}
+
+/*
+	Topology is the decoded processor topology of a system.
+	Entities are listed in the order the system reported them.
+*/
+type Topology struct {
+	/*
+		MaximumGroups is the number of processor groups the system supports,
+		which can be more than len(Groups) on systems that support hot adding processors.
+	*/
+	MaximumGroups uint16
+	Groups        []Group
+
+	Packages  []ProcessorSet
+	Dies      []ProcessorSet
+	Modules   []ProcessorSet
+	Cores     []ProcessorSet
+	Caches    []Cache
+	NumaNodes []NumaNode
+}
+
+/*
+	LogicalProcessors returns the number of active logical processors in all groups.
+*/
+func (t *Topology) LogicalProcessors() int {
+	n := 0
+	for _, g := range t.Groups {
+		n += bits.OnesCount64(g.ActiveMask)
+	}
+
+	return n
+}
+
+/*
+	PackageOf returns the index in Packages of the package containing the logical processor,
+	or -1 if none does.
+*/
+func (t *Topology) PackageOf(group uint16, number int) int {
+	for i, p := range t.Packages {
+		if p.Processors.Contains(group, number) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+	CoreOf returns the index in Cores of the core containing the logical processor,
+	or -1 if none does.
+*/
+func (t *Topology) CoreOf(group uint16, number int) int {
+	for i, c := range t.Cores {
+		if c.Processors.Contains(group, number) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+	Decode decodes a buffer of SYSTEM_LOGICAL_PROCESSOR_INFORMATION_EX entries
+	as returned by GetLogicalProcessorInformationEx. mode selects the size of the
+	affinity masks, which are pointer sized. Entries of unknown relationships are skipped.
+*/
+func Decode(b []byte, mode x86.Mode) (*Topology, error) {
+	if mode != x86.Mode32 && mode != x86.Mode64 {
+		return nil, fmt.Errorf("topology: invalid mode %d", mode)
+	}
+
+	d := decoder{mode: mode}
+	t := &Topology{}
+
+	for offset := 0; offset < len(b); {
+		if len(b)-offset < 8 {
+			return nil, fmt.Errorf("topology: truncated entry at offset %d", offset)
+		}
+
+		relationship := binary.LittleEndian.Uint32(b[offset:])
+		size := int(binary.LittleEndian.Uint32(b[offset+4:]))
+		if size < 8 || size > len(b)-offset {
+			return nil, fmt.Errorf("topology: invalid entry size %d at offset %d", size, offset)
+		}
+
+		entry := b[offset : offset+size]
+
+		var err error
+		switch relationship {
+		case relationProcessorCore, relationProcessorPackage, relationProcessorDie, relationProcessorModule:
+			var set ProcessorSet
+			if set, err = d.processorSet(entry); err != nil {
+				break
+			}
+
+			switch relationship {
+			case relationProcessorCore:
+				t.Cores = append(t.Cores, set)
+			case relationProcessorPackage:
+				t.Packages = append(t.Packages, set)
+			case relationProcessorDie:
+				t.Dies = append(t.Dies, set)
+			case relationProcessorModule:
+				t.Modules = append(t.Modules, set)
+			}
+
+		case relationNumaNode, relationNumaNodeEx:
+			var node NumaNode
+			if node, err = d.numaNode(entry); err == nil {
+				t.NumaNodes = append(t.NumaNodes, node)
+			}
+
+		case relationCache:
+			var cache Cache
+			if cache, err = d.cache(entry); err == nil {
+				t.Caches = append(t.Caches, cache)
+			}
+
+		case relationGroup:
+			err = d.groups(entry, t)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("topology: entry at offset %d: %w", offset, err)
+		}
+
+		offset += size
+	}
+
+	return t, nil
+}
+
+type decoder struct {
+	mode x86.Mode
+}
+
+func (d decoder) pointerSize() int {
+	return int(d.mode) / 8
+}
+
+func (d decoder) pointer(b []byte) uint64 {
+	if d.mode == x86.Mode64 {
+		return binary.LittleEndian.Uint64(b)
+	}
+
+	return uint64(binary.LittleEndian.Uint32(b))
+}
+
+/*
+	groupAffinitySize returns the size of GROUP_AFFINITY: the mask, the group and three reserved words,
+	padded to the alignment of the mask.
+*/
+func (d decoder) groupAffinitySize() int {
+	return d.pointerSize() + 8
+}
+
+/*
+	affinities decodes count GROUP_AFFINITY structures starting at offset.
+*/
+func (d decoder) affinities(entry []byte, offset int, count int) (Processors, error) {
+	size := d.groupAffinitySize()
+	if offset+count*size > len(entry) {
+		return nil, fmt.Errorf("%d group affinities do not fit in %d bytes", count, len(entry))
+	}
+
+	p := make(Processors, count)
+	for i := range p {
+		a := entry[offset+i*size:]
+		p[i] = GroupAffinity{
+			Mask:  d.pointer(a),
+			Group: binary.LittleEndian.Uint16(a[d.pointerSize():]),
+		}
+	}
+
+	return p, nil
+}
+
+func (d decoder) processorSet(entry []byte) (ProcessorSet, error) {
+	if len(entry) < 32 {
+		return ProcessorSet{}, errors.New("processor relationship is truncated")
+	}
+
+	processors, err := d.affinities(entry, 32, int(binary.LittleEndian.Uint16(entry[30:])))
+	if err != nil {
+		return ProcessorSet{}, err
+	}
+
+	return ProcessorSet{
+		Flags:           entry[8],
+		EfficiencyClass: entry[9],
+		Processors:      processors,
+	}, nil
+}
+
+func (d decoder) numaNode(entry []byte) (NumaNode, error) {
+	if len(entry) < 32 {
+		return NumaNode{}, errors.New("NUMA node relationship is truncated")
+	}
+
+	// Before Windows Server 2022 the group count was reserved and a single group affinity followed.
+	count := int(binary.LittleEndian.Uint16(entry[30:]))
+	if count == 0 {
+		count = 1
+	}
+
+	processors, err := d.affinities(entry, 32, count)
+	if err != nil {
+		return NumaNode{}, err
+	}
+
+	return NumaNode{
+		Number:     binary.LittleEndian.Uint32(entry[8:]),
+		Processors: processors,
+	}, nil
+}
+
+func (d decoder) cache(entry []byte) (Cache, error) {
+	if len(entry) < 40 {
+		return Cache{}, errors.New("cache relationship is truncated")
+	}
+
+	// Before Windows Server 2022 the group count was reserved and a single group affinity followed.
+	count := int(binary.LittleEndian.Uint16(entry[38:]))
+	if count == 0 {
+		count = 1
+	}
+
+	processors, err := d.affinities(entry, 40, count)
+	if err != nil {
+		return Cache{}, err
+	}
+
+	return Cache{
+		Level:         entry[8],
+		Associativity: entry[9],
+		LineSize:      binary.LittleEndian.Uint16(entry[10:]),
+		Size:          binary.LittleEndian.Uint32(entry[12:]),
+		Type:          CacheType(binary.LittleEndian.Uint32(entry[16:])),
+		Processors:    processors,
+	}, nil
+}
+
+/*
+	groups decodes a GROUP_RELATIONSHIP. Each PROCESSOR_GROUP_INFO holds two counts and
+	38 reserved bytes followed by the pointer sized active processor mask.
+*/
+func (d decoder) groups(entry []byte, t *Topology) error {
+	if len(entry) < 32 {
+		return errors.New("group relationship is truncated")
+	}
+
+	active := int(binary.LittleEndian.Uint16(entry[10:]))
+	size := 40 + d.pointerSize()
+	if 32+active*size > len(entry) {
+		return fmt.Errorf("%d processor groups do not fit in %d bytes", active, len(entry))
+	}
+
+	t.MaximumGroups = binary.LittleEndian.Uint16(entry[8:])
+	for i := 0; i < active; i++ {
+		info := entry[32+i*size:]
+		t.Groups = append(t.Groups, Group{
+			MaximumProcessors: info[0],
+			ActiveProcessors:  info[1],
+			ActiveMask:        d.pointer(info[40:]),
+		})
+	}
+
+	return nil
+}
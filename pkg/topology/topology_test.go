@@ -0,0 +1,236 @@
+package topology
+
+import (
+	"encoding/binary"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	The buffers in testdata are synthesized, not captured on real machines, so
+	they only check the decoder against the layouts below, read from winnt.h.
+	TestQuery in topology_windows_test.go decodes the buffer of the machine it
+	runs on, and its -slpi.dump flag writes that buffer out for use here.
+
+	Offsets are from the start of SYSTEM_LOGICAL_PROCESSOR_INFORMATION_EX, with
+	Relationship at 0 and Size at 4; P is the pointer size of KAFFINITY.
+
+		PROCESSOR_RELATIONSHIP  Flags 8, EfficiencyClass 9, GroupCount 30, GroupMask 32
+		NUMA_NODE_RELATIONSHIP  NodeNumber 8, GroupCount 30, GroupMask 32
+		CACHE_RELATIONSHIP      Level 8, Associativity 9, LineSize 10, CacheSize 12,
+		                        Type 16, GroupCount 38, GroupMask 40
+		GROUP_RELATIONSHIP      MaximumGroupCount 8, ActiveGroupCount 10, GroupInfo 32
+		PROCESSOR_GROUP_INFO    MaximumProcessorCount 0, ActiveProcessorCount 1,
+		                        ActiveProcessorMask 40, size 40+P
+		GROUP_AFFINITY          Mask 0, Group P, size P+8
+
+	testdata/slpi-x64.bin describes a 64-bit system supporting four processor
+	groups, with two active groups of four logical processors. Each group is a
+	package and a die of two SMT cores sharing an L3 cache, and the cores of
+	group 0 have their own L1 data and L2 caches. The L3 cache of group 0 and
+	NUMA node 0 leave the group count zero as before Windows Server 2022, and
+	node 1 is reported as RelationNumaNodeEx.
+
+	testdata/slpi-x86.bin describes a 32-bit hybrid system with one group of four
+	logical processors: an SMT performance core with efficiency class 1 and its L2,
+	two efficiency cores in a module sharing an L2, a fully associative L3, and an
+	entry of an unknown relationship.
+*/
+func readBuffer(t *testing.T, name string) []byte {
+	t.Helper()
+
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func one(group uint16, mask uint64) Processors {
+	return Processors{{Group: group, Mask: mask}}
+}
+
+func TestDecode64(t *testing.T) {
+	topo, err := Decode(readBuffer(t, "slpi-x64.bin"), x86.Mode64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Topology{
+		MaximumGroups: 4,
+		Groups: []Group{
+			{MaximumProcessors: 4, ActiveProcessors: 4, ActiveMask: 0xF},
+			{MaximumProcessors: 4, ActiveProcessors: 4, ActiveMask: 0xF},
+		},
+		Packages: []ProcessorSet{
+			{Processors: one(0, 0xF)},
+			{Processors: one(1, 0xF)},
+		},
+		Dies: []ProcessorSet{
+			{Processors: one(0, 0xF)},
+			{Processors: one(1, 0xF)},
+		},
+		Cores: []ProcessorSet{
+			{Flags: LTP_PC_SMT, Processors: one(0, 0x3)},
+			{Flags: LTP_PC_SMT, Processors: one(0, 0xC)},
+			{Flags: LTP_PC_SMT, Processors: one(1, 0x3)},
+			{Flags: LTP_PC_SMT, Processors: one(1, 0xC)},
+		},
+		Caches: []Cache{
+			{Level: 1, Associativity: 12, LineSize: 64, Size: 48 << 10, Type: CacheData, Processors: one(0, 0x3)},
+			{Level: 2, Associativity: 10, LineSize: 64, Size: 1280 << 10, Type: CacheUnified, Processors: one(0, 0x3)},
+			{Level: 1, Associativity: 12, LineSize: 64, Size: 48 << 10, Type: CacheData, Processors: one(0, 0xC)},
+			{Level: 2, Associativity: 10, LineSize: 64, Size: 1280 << 10, Type: CacheUnified, Processors: one(0, 0xC)},
+			{Level: 3, Associativity: 12, LineSize: 64, Size: 16 << 20, Type: CacheUnified, Processors: one(0, 0xF)},
+			{Level: 3, Associativity: 12, LineSize: 64, Size: 16 << 20, Type: CacheUnified, Processors: one(1, 0xF)},
+		},
+		NumaNodes: []NumaNode{
+			{Number: 0, Processors: one(0, 0xF)},
+			{Number: 1, Processors: one(1, 0xF)},
+		},
+	}
+
+	if !reflect.DeepEqual(topo, want) {
+		t.Errorf("got %+v\nwant %+v", topo, want)
+	}
+
+	if n := topo.LogicalProcessors(); n != 8 {
+		t.Errorf("%d logical processors, want 8", n)
+	}
+
+	tests := []struct {
+		group     uint16
+		number    int
+		pkg, core int
+	}{
+		{0, 0, 0, 0},
+		{0, 3, 0, 1},
+		{1, 1, 1, 2},
+		{1, 2, 1, 3},
+		{0, 4, -1, -1},
+		{2, 0, -1, -1},
+		{0, -1, -1, -1},
+		{0, 64, -1, -1},
+	}
+
+	for _, tc := range tests {
+		if got := topo.PackageOf(tc.group, tc.number); got != tc.pkg {
+			t.Errorf("PackageOf(%d, %d): got %d, want %d", tc.group, tc.number, got, tc.pkg)
+		}
+		if got := topo.CoreOf(tc.group, tc.number); got != tc.core {
+			t.Errorf("CoreOf(%d, %d): got %d, want %d", tc.group, tc.number, got, tc.core)
+		}
+	}
+}
+
+func TestDecode32(t *testing.T) {
+	topo, err := Decode(readBuffer(t, "slpi-x86.bin"), x86.Mode32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Topology{
+		MaximumGroups: 1,
+		Groups: []Group{
+			{MaximumProcessors: 4, ActiveProcessors: 4, ActiveMask: 0xF},
+		},
+		Packages: []ProcessorSet{
+			{Processors: one(0, 0xF)},
+		},
+		Modules: []ProcessorSet{
+			{Processors: one(0, 0xC)},
+		},
+		Cores: []ProcessorSet{
+			{Flags: LTP_PC_SMT, EfficiencyClass: 1, Processors: one(0, 0x3)},
+			{Processors: one(0, 0x4)},
+			{Processors: one(0, 0x8)},
+		},
+		Caches: []Cache{
+			{Level: 2, Associativity: 10, LineSize: 64, Size: 2 << 20, Type: CacheUnified, Processors: one(0, 0x3)},
+			{Level: 2, Associativity: 16, LineSize: 64, Size: 4 << 20, Type: CacheUnified, Processors: one(0, 0xC)},
+			{Level: 3, Associativity: 0xFF, LineSize: 64, Size: 12 << 20, Type: CacheUnified, Processors: one(0, 0xF)},
+		},
+		NumaNodes: []NumaNode{
+			{Number: 0, Processors: one(0, 0xF)},
+		},
+	}
+
+	if !reflect.DeepEqual(topo, want) {
+		t.Errorf("got %+v\nwant %+v", topo, want)
+	}
+
+	if n := topo.LogicalProcessors(); n != 4 {
+		t.Errorf("%d logical processors, want 4", n)
+	}
+}
+
+/*
+	TestDecodeWrongMode checks that a buffer decoded with the wrong pointer size
+	fails or at least does not decode into the same topology.
+*/
+func TestDecodeWrongMode(t *testing.T) {
+	b := readBuffer(t, "slpi-x64.bin")
+	right, err := Decode(b, x86.Mode64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wrong, err := Decode(b, x86.Mode32); err == nil && reflect.DeepEqual(wrong, right) {
+		t.Error("64-bit buffer decodes the same in 32-bit mode")
+	}
+
+	if _, err := Decode(b, 16); err == nil {
+		t.Error("no error for 16-bit mode")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	b := readBuffer(t, "slpi-x64.bin")
+
+	// The first entry is a 48 byte core.
+	setSize := func(size uint32) []byte {
+		c := append([]byte(nil), b...)
+		binary.LittleEndian.PutUint32(c[4:], size)
+		return c
+	}
+
+	setGroupCount := func(count uint16) []byte {
+		c := append([]byte(nil), b...)
+		binary.LittleEndian.PutUint16(c[30:], count)
+		return c
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		text string
+	}{
+		{"truncated header", b[:4], "truncated entry"},
+		{"truncated buffer", b[:47], "invalid entry size"},
+		{"size below the header", setSize(4), "invalid entry size"},
+		{"size past the buffer", setSize(uint32(len(b) + 1)), "invalid entry size"},
+		{"truncated core", setSize(24), "processor relationship is truncated"},
+		{"group count past the entry", setGroupCount(2), "2 group affinities do not fit"},
+	}
+
+	for _, tc := range tests {
+		_, err := Decode(tc.b, x86.Mode64)
+		if err == nil || !strings.Contains(err.Error(), tc.text) {
+			t.Errorf("%s: got %v, want an error mentioning %q", tc.name, err, tc.text)
+		}
+	}
+
+	if topo, err := Decode(nil, x86.Mode64); err != nil || topo.LogicalProcessors() != 0 {
+		t.Errorf("empty buffer: got %+v, %v", topo, err)
+	}
+}
+
+func TestCacheTypeString(t *testing.T) {
+	if CacheData.String() != "Data" || CacheType(7).String() != "CacheType(7)" {
+		t.Errorf("got %s, %s", CacheData, CacheType(7))
+	}
+}
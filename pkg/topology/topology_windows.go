@@ -0,0 +1,30 @@
+package topology
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+	"github.com/warrenulrich/win32-go/pkg/x86"
+)
+
+/*
+	Query retrieves and decodes the processor topology of the system.
+*/
+func Query() (*Topology, error) {
+	b, err := kernel32.GetLogicalProcessorInformationEx(kernel32.RelationAll)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(b, nativeMode())
+}
+
+/*
+	nativeMode returns the mode whose pointer size matches the running process.
+*/
+func nativeMode() x86.Mode {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		return x86.Mode64
+	}
+	return x86.Mode32
+}
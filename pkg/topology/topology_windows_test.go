@@ -0,0 +1,64 @@
+package topology
+
+import (
+	"flag"
+	"math/bits"
+	"os"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/win32/kernel32"
+)
+
+var dump = flag.String("slpi.dump", "", "write the raw GetLogicalProcessorInformationEx buffer to this file")
+
+/*
+	TestQuery decodes the topology of the running machine and checks that it
+	is consistent, which a misread layout would hardly be: every active logical
+	processor belongs to exactly one core and one package.
+*/
+func TestQuery(t *testing.T) {
+	b, err := kernel32.GetLogicalProcessorInformationEx(kernel32.RelationAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *dump != "" {
+		if err := os.WriteFile(*dump, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topo, err := Decode(b, nativeMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(topo.Groups) == 0 || int(topo.MaximumGroups) < len(topo.Groups) {
+		t.Fatalf("got %d active groups out of %d", len(topo.Groups), topo.MaximumGroups)
+	}
+
+	cores := 0
+	for _, c := range topo.Cores {
+		cores += c.Processors.Count()
+	}
+
+	if n := topo.LogicalProcessors(); n == 0 || cores != n {
+		t.Errorf("cores span %d logical processors, groups have %d", cores, n)
+	}
+
+	for i, g := range topo.Groups {
+		if int(g.ActiveProcessors) != bits.OnesCount64(g.ActiveMask) || g.ActiveProcessors > g.MaximumProcessors {
+			t.Errorf("group %d: %d of %d processors active, mask %#x", i, g.ActiveProcessors, g.MaximumProcessors, g.ActiveMask)
+		}
+
+		for number := 0; number < 64; number++ {
+			if g.ActiveMask&(1<<uint(number)) == 0 {
+				continue
+			}
+
+			if topo.CoreOf(uint16(i), number) < 0 || topo.PackageOf(uint16(i), number) < 0 {
+				t.Errorf("processor %d of group %d has no core or package", number, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package unwind
+
+import (
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	FromContext converts a thread context retrieved with GetThreadContext
+	into the register state used by the unwinder. The context must contain
+	at least CONTEXT_AMD64_CONTROL and CONTEXT_AMD64_INTEGER.
+*/
+func FromContext(c *win32.ContextAMD64) Context {
+	return Context{
+		Rip: c.Rip,
+		Regs: [16]uint64{
+			RAX: c.Rax,
+			RCX: c.Rcx,
+			RDX: c.Rdx,
+			RBX: c.Rbx,
+			RSP: c.Rsp,
+			RBP: c.Rbp,
+			RSI: c.Rsi,
+			RDI: c.Rdi,
+			R8:  c.R8,
+			R9:  c.R9,
+			R10: c.R10,
+			R11: c.R11,
+			R12: c.R12,
+			R13: c.R13,
+			R14: c.R14,
+			R15: c.R15,
+		},
+	}
+}
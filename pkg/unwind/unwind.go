@@ -0,0 +1,459 @@
+/*
+	Package unwind walks the stack of x64 threads using the unwind data
+	recorded in the exception directory of the loaded images.
+
+	The unwinder only needs a way to read the target's memory and the list
+	of loaded images, so it can run against a live process as well as
+	against a synthetic memory image.
+*/
+package unwind
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/process"
+)
+
+/*
+	Register numbers as used by unwind codes and by Context.Regs.
+*/
+const (
+	RAX = iota
+	RCX
+	RDX
+	RBX
+	RSP
+	RBP
+	RSI
+	RDI
+	R8
+	R9
+	R10
+	R11
+	R12
+	R13
+	R14
+	R15
+)
+
+var ErrEndOfStack = errors.New("unwind: end of stack")
+
+/*
+	Context is the integer register state of a frame.
+	Non-volatile XMM registers are not tracked.
+*/
+type Context struct {
+	Rip  uint64
+	Regs [16]uint64
+}
+
+/*
+	Rsp returns the stack pointer of the frame.
+*/
+func (c *Context) Rsp() uint64 {
+	return c.Regs[RSP]
+}
+
+/*
+	Frame is a single frame of a stack walk.
+*/
+type Frame struct {
+	PC uint64
+	SP uint64
+}
+
+/*
+	Module is an image loaded at Base in the target whose
+	exception directory is used to unwind through it.
+*/
+type Module struct {
+	Base  uintptr
+	Size  uint32
+	Image *pe.Image
+}
+
+type module struct {
+	Module
+	funcs  []pe.RuntimeFunction
+	loaded bool
+}
+
+/*
+	Unwinder performs virtual unwinding of x64 stacks.
+*/
+type Unwinder struct {
+	mem     process.Reader
+	modules []*module
+}
+
+/*
+	New creates an Unwinder that reads stack memory and code through mem.
+	Runtime function tables are read lazily the first time a module is needed.
+*/
+func New(mem process.Reader, modules []Module) *Unwinder {
+	u := &Unwinder{mem: mem}
+	for _, m := range modules {
+		u.modules = append(u.modules, &module{Module: m})
+	}
+
+	sort.Slice(u.modules, func(i, j int) bool {
+		return u.modules[i].Base < u.modules[j].Base
+	})
+
+	return u
+}
+
+func (u *Unwinder) moduleAt(pc uint64) *module {
+	i := sort.Search(len(u.modules), func(i int) bool {
+		return uint64(u.modules[i].Base) > pc
+	})
+
+	if i == 0 {
+		return nil
+	}
+
+	m := u.modules[i-1]
+	if pc-uint64(m.Base) >= uint64(m.Size) {
+		return nil
+	}
+
+	return m
+}
+
+func (u *Unwinder) lookup(pc uint64) (*module, *pe.RuntimeFunction, error) {
+	m := u.moduleAt(pc)
+	if m == nil {
+		return nil, nil, nil
+	}
+
+	if !m.loaded {
+		funcs, err := m.Image.RuntimeFunctions()
+		if err != nil && !errors.Is(err, pe.ErrNoDirectory) {
+			return nil, nil, err
+		}
+		m.funcs, m.loaded = funcs, true
+	}
+
+	fn, ok := pe.LookupRuntimeFunction(m.funcs, uint32(pc-uint64(m.Base)))
+	if !ok {
+		return m, nil, nil
+	}
+
+	return m, &fn, nil
+}
+
+func (u *Unwinder) read64(addr uint64) (uint64, error) {
+	var b [8]byte
+	if err := u.mem.ReadMemory(uintptr(addr), b[:]); err != nil {
+		return 0, fmt.Errorf("unwind: reading stack at %#x: %w", addr, err)
+	}
+
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+/*
+	Step unwinds ctx by one frame, leaving the caller's register state in ctx.
+	Functions without unwind data are treated as leaf functions whose
+	return address is at the top of the stack.
+	It returns ErrEndOfStack once the return address is zero.
+*/
+func (u *Unwinder) Step(ctx *Context) error {
+	m, fn, err := u.lookup(ctx.Rip)
+	if err != nil {
+		return err
+	}
+
+	if fn == nil {
+		return u.popReturn(ctx)
+	}
+
+	offset := uint32(ctx.Rip-uint64(m.Base)) - fn.BeginAddress
+
+	info, err := m.Image.UnwindInfo(fn.UnwindInfoAddress)
+	if err != nil {
+		return err
+	}
+
+	if offset >= uint32(info.SizeOfProlog) {
+		begin := uint64(m.Base) + uint64(fn.BeginAddress)
+		end := uint64(m.Base) + uint64(fn.EndAddress)
+		done, err := u.unwindEpilog(ctx, info, begin, end)
+		if err != nil || done {
+			return err
+		}
+	}
+
+	machframe, err := u.applyCodes(ctx, info, offset)
+	if err != nil {
+		return err
+	}
+
+	for depth := 0; info.Chained != nil && !machframe; depth++ {
+		if depth > 32 {
+			return errors.New("unwind: chained unwind info is too deep")
+		}
+
+		if info, err = m.Image.UnwindInfo(info.Chained.UnwindInfoAddress); err != nil {
+			return err
+		}
+
+		if machframe, err = u.applyCodes(ctx, info, ^uint32(0)); err != nil {
+			return err
+		}
+	}
+
+	if machframe {
+		if ctx.Rip == 0 {
+			return ErrEndOfStack
+		}
+		return nil
+	}
+
+	return u.popReturn(ctx)
+}
+
+func (u *Unwinder) popReturn(ctx *Context) error {
+	rip, err := u.read64(ctx.Regs[RSP])
+	if err != nil {
+		return err
+	}
+
+	ctx.Rip = rip
+	ctx.Regs[RSP] += 8
+
+	if rip == 0 {
+		return ErrEndOfStack
+	}
+
+	return nil
+}
+
+/*
+	applyCodes reverses the effect of the prolog operations that have
+	executed by offset bytes into the function. It reports whether a
+	machine frame was popped, in which case Rip has already been restored.
+*/
+func (u *Unwinder) applyCodes(ctx *Context, info *pe.UnwindInfo, offset uint32) (bool, error) {
+	executed := func(code pe.UnwindCode) bool {
+		return uint32(code.CodeOffset) <= offset || offset >= uint32(info.SizeOfProlog)
+	}
+
+	// Registers saved with a mov are addressed relative to the establisher frame,
+	// which differs from RSP once the function has made dynamic stack allocations.
+	frame := ctx.Regs[RSP]
+	for _, code := range info.Codes {
+		if code.Op == pe.UWOP_SET_FPREG && executed(code) {
+			frame = ctx.Regs[info.FrameRegister] - uint64(info.FrameOffset)
+		}
+	}
+
+	for _, code := range info.Codes {
+		if !executed(code) {
+			continue
+		}
+
+		switch code.Op {
+		case pe.UWOP_PUSH_NONVOL:
+			v, err := u.read64(ctx.Regs[RSP])
+			if err != nil {
+				return false, err
+			}
+			ctx.Regs[code.OpInfo] = v
+			ctx.Regs[RSP] += 8
+
+		case pe.UWOP_ALLOC_SMALL, pe.UWOP_ALLOC_LARGE:
+			ctx.Regs[RSP] += uint64(code.Value)
+
+		case pe.UWOP_SET_FPREG:
+			ctx.Regs[RSP] = ctx.Regs[info.FrameRegister] - uint64(info.FrameOffset)
+
+		case pe.UWOP_SAVE_NONVOL, pe.UWOP_SAVE_NONVOL_FAR:
+			v, err := u.read64(frame + uint64(code.Value))
+			if err != nil {
+				return false, err
+			}
+			ctx.Regs[code.OpInfo] = v
+
+		case pe.UWOP_SAVE_XMM128, pe.UWOP_SAVE_XMM128_FAR, pe.UWOP_EPILOG, pe.UWOP_SPARE_CODE:
+
+		case pe.UWOP_PUSH_MACHFRAME:
+			sp := ctx.Regs[RSP]
+			if code.OpInfo == 1 {
+				sp += 8
+			}
+
+			rip, err := u.read64(sp)
+			if err != nil {
+				return false, err
+			}
+
+			rsp, err := u.read64(sp + 24)
+			if err != nil {
+				return false, err
+			}
+
+			ctx.Rip = rip
+			ctx.Regs[RSP] = rsp
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+/*
+	unwindEpilog detects whether Rip is inside an epilog by decoding the
+	instructions at Rip, and if so emulates the rest of the epilog.
+	Epilogs are restricted by the x64 ABI to an optional add or lea adjusting
+	RSP, a sequence of register pops, and a ret or jmp. A relative jmp only
+	ends an epilog when its target lies outside the function [begin, end).
+*/
+func (u *Unwinder) unwindEpilog(ctx *Context, info *pe.UnwindInfo, begin, end uint64) (bool, error) {
+	var code [64]byte
+	n := len(code)
+	for n > 0 && u.mem.ReadMemory(uintptr(ctx.Rip), code[:n]) != nil {
+		n /= 2
+	}
+
+	if n == 0 {
+		return false, nil
+	}
+
+	b := code[:n]
+	regs := ctx.Regs
+	i := 0
+
+	switch {
+	case len(b) >= 4 && b[0] == 0x48 && b[1] == 0x83 && b[2] == 0xC4:
+		regs[RSP] += uint64(int64(int8(b[3])))
+		i = 4
+
+	case len(b) >= 7 && b[0] == 0x48 && b[1] == 0x81 && b[2] == 0xC4:
+		regs[RSP] += uint64(int64(int32(binary.LittleEndian.Uint32(b[3:]))))
+		i = 7
+
+	case len(b) >= 4 && (b[0] == 0x48 || b[0] == 0x49) && b[1] == 0x8D && (b[2]>>3)&7 == RSP && b[2]&7 != 4:
+		base := int(b[2] & 7)
+		if b[0] == 0x49 {
+			base += 8
+		}
+
+		if info.FrameRegister == 0 || base != int(info.FrameRegister) {
+			return false, nil
+		}
+
+		switch b[2] >> 6 {
+		case 1:
+			regs[RSP] = regs[base] + uint64(int64(int8(b[3])))
+			i = 4
+		case 2:
+			if len(b) < 7 {
+				return false, nil
+			}
+			regs[RSP] = regs[base] + uint64(int64(int32(binary.LittleEndian.Uint32(b[3:]))))
+			i = 7
+		default:
+			return false, nil
+		}
+	}
+
+	var pops []int
+	for i < len(b) {
+		switch {
+		case b[i] >= 0x58 && b[i] <= 0x5F:
+			pops = append(pops, int(b[i]-0x58))
+			i++
+			continue
+		case b[i] == 0x41 && i+1 < len(b) && b[i+1] >= 0x58 && b[i+1] <= 0x5F:
+			pops = append(pops, int(b[i+1]-0x58)+8)
+			i += 2
+			continue
+		}
+		break
+	}
+
+	if i >= len(b) {
+		return false, nil
+	}
+
+	switch {
+	case b[i] == 0xC3, b[i] == 0xC2:
+	case b[i] == 0xF3 && i+1 < len(b) && b[i+1] == 0xC3:
+	case b[i] == 0xEB && i+2 <= len(b):
+		target := ctx.Rip + uint64(i+2) + uint64(int64(int8(b[i+1])))
+		if target >= begin && target < end {
+			return false, nil
+		}
+	case b[i] == 0xE9 && i+5 <= len(b):
+		target := ctx.Rip + uint64(i+5) + uint64(int64(int32(binary.LittleEndian.Uint32(b[i+1:]))))
+		if target >= begin && target < end {
+			return false, nil
+		}
+	case b[i] == 0xFF && i+1 < len(b) && b[i+1] == 0x25:
+	case b[i] == 0x48 && i+2 < len(b) && b[i+1] == 0xFF && b[i+2] == 0x25:
+	default:
+		return false, nil
+	}
+
+	for _, reg := range pops {
+		v, err := u.read64(regs[RSP])
+		if err != nil {
+			return false, err
+		}
+		regs[reg] = v
+		regs[RSP] += 8
+	}
+
+	ctx.Regs = regs
+	return true, u.popReturn(ctx)
+}
+
+/*
+	Walk unwinds from ctx until the end of the stack, an error, or max frames.
+	The first frame is the one described by ctx itself. Walking stops with
+	an error if the stack pointer fails to increase between frames.
+*/
+func (u *Unwinder) Walk(ctx Context, max int) ([]Frame, error) {
+	frames := []Frame{{PC: ctx.Rip, SP: ctx.Regs[RSP]}}
+
+	for len(frames) < max {
+		prev := ctx.Regs[RSP]
+
+		if err := u.Step(&ctx); err != nil {
+			if errors.Is(err, ErrEndOfStack) {
+				return frames, nil
+			}
+			return frames, err
+		}
+
+		if ctx.Regs[RSP] <= prev {
+			return frames, fmt.Errorf("unwind: stack pointer did not advance at %#x", ctx.Rip)
+		}
+
+		frames = append(frames, Frame{PC: ctx.Rip, SP: ctx.Regs[RSP]})
+	}
+
+	return frames, nil
+}
+
+/*
+	ModulesFrom opens the x64 images among mods through r for use with New.
+	Modules that cannot be parsed or are not x64 images are skipped.
+*/
+func ModulesFrom(r process.Reader, mods []process.Module) []Module {
+	var modules []Module
+	for _, m := range mods {
+		img, err := process.OpenModule(r, m)
+		if err != nil || img.FileHeader.Machine != pe.IMAGE_FILE_MACHINE_AMD64 {
+			continue
+		}
+
+		modules = append(modules, Module{Base: m.Base, Size: m.Size, Image: img})
+	}
+
+	return modules
+}
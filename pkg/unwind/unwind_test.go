@@ -0,0 +1,465 @@
+package unwind
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/pe"
+	"github.com/warrenulrich/win32-go/pkg/pe/petest"
+	"github.com/warrenulrich/win32-go/pkg/process"
+	"github.com/warrenulrich/win32-go/pkg/process/processtest"
+)
+
+const (
+	imageBase = 0x40000000
+	stackBase = 0x100000
+	stackSize = 0x4000
+
+	// outer: push rbp; push rbx; sub rsp, 0x28; ...; add rsp, 0x28; pop rbx; pop rbp; ret
+	outer        = 0x1000
+	outerBody    = outer + 0x08
+	outerEpilog  = outer + 0x10
+	outerEnd     = outer + 0x17
+	outerPopRbx  = outer + 0x14
+	outerRet     = outer + 0x16
+	outerPushRbx = outer + 0x01
+	outerPushed  = outer + 0x02
+
+	// framed: push rbp; sub rsp, 0x40; lea rbp, [rsp+0x20]; mov [rsp+0x38], rsi; ...;
+	// lea rsp, [rbp+0x20]; pop rbp; ret
+	framed       = 0x1020
+	framedBody   = framed + 0x15
+	framedEpilog = framed + 0x20
+	framedEnd    = framed + 0x26
+
+	// large: sub rsp, 0x1000; ..., continued by a chained fragment that saves rbx.
+	large         = 0x1060
+	largeEnd      = 0x1070
+	fragment      = 0x1070
+	fragmentEnd   = 0x1080
+	fragmentInner = fragment + 4
+
+	// trap: an interrupt-style function that starts with a machine frame.
+	trap    = 0x1080
+	trapEnd = 0x1090
+
+	// leaf has no unwind data.
+	leaf = 0x10A0
+
+	// spin: push rbx; sub rsp, 0x30; ...; add rsp, 0x30; jmp $
+	spin     = 0x10B0
+	spinLoop = spin + 0x05
+	spinEnd  = spin + 0x10
+
+	// tail: push rbx; sub rsp, 0x20; ...; add rsp, 0x20; pop rbx; jmp outer
+	tail       = 0x10C0
+	tailEpilog = tail + 0x08
+	tailPopRbx = tail + 0x0C
+	tailEnd    = tail + 0x12
+)
+
+func code(offset uint8, op pe.UnwindOp, info uint8) uint16 {
+	return uint16(offset) | uint16(op)<<8 | uint16(info)<<12
+}
+
+/*
+	unwindInfo encodes an UNWIND_INFO structure followed by trailer.
+*/
+func unwindInfo(flags, prolog, frameReg, frameOffset uint8, codes []uint16, trailer []byte) []byte {
+	b := []byte{1 | flags<<3, prolog, uint8(len(codes)), frameReg | (frameOffset/16)<<4}
+	for _, c := range codes {
+		b = append(b, byte(c), byte(c>>8))
+	}
+
+	if len(codes)%2 != 0 {
+		b = append(b, 0, 0)
+	}
+
+	return append(b, trailer...)
+}
+
+func runtimeFunction(begin, end, info uint32) []byte {
+	b := make([]byte, 12)
+	binary.LittleEndian.PutUint32(b, begin)
+	binary.LittleEndian.PutUint32(b[4:], end)
+	binary.LittleEndian.PutUint32(b[8:], info)
+	return b
+}
+
+func buildImage() []byte {
+	text := make([]byte, 0x100)
+	for i := range text {
+		text[i] = 0x90
+	}
+
+	at := func(rva uint32, code ...byte) {
+		copy(text[rva-0x1000:], code)
+	}
+
+	at(outer, 0x55, 0x53, 0x48, 0x83, 0xEC, 0x28)
+	at(outerEpilog, 0x48, 0x83, 0xC4, 0x28, 0x5B, 0x5D, 0xC3)
+
+	at(framed, 0x55, 0x48, 0x83, 0xEC, 0x40, 0x48, 0x8D, 0x6C, 0x24, 0x20, 0x48, 0x89, 0x74, 0x24, 0x38)
+	at(framedEpilog, 0x48, 0x8D, 0x65, 0x20, 0x5D, 0xC3)
+
+	at(large, 0x48, 0x81, 0xEC, 0x00, 0x10, 0x00, 0x00)
+	at(fragment, 0x48, 0x89, 0x5C, 0x24, 0x10)
+
+	at(spin, 0x53, 0x48, 0x83, 0xEC, 0x30)
+	at(spinLoop, 0x48, 0x83, 0xC4, 0x30, 0xEB, 0xFE)
+
+	at(tail, 0x53, 0x48, 0x83, 0xEC, 0x20)
+	at(tailEpilog, 0x48, 0x83, 0xC4, 0x20, 0x5B, 0xE9)
+	rel := int32(outer - (tailEpilog + 10))
+	binary.LittleEndian.PutUint32(text[tailEpilog+6-0x1000:], uint32(rel))
+
+	b := petest.New(true)
+	b.AddSection(".text", text, pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE|pe.IMAGE_SCN_MEM_READ)
+
+	xdataRVA := b.NextRVA()
+	var xdata []byte
+	add := func(info []byte) uint32 {
+		rva := xdataRVA + uint32(len(xdata))
+		xdata = append(xdata, info...)
+		return rva
+	}
+
+	outerInfo := add(unwindInfo(0, 6, 0, 0, []uint16{
+		code(6, pe.UWOP_ALLOC_SMALL, (0x28-8)/8),
+		code(2, pe.UWOP_PUSH_NONVOL, RBX),
+		code(1, pe.UWOP_PUSH_NONVOL, RBP),
+	}, nil))
+
+	framedInfo := add(unwindInfo(0, 15, RBP, 0x20, []uint16{
+		code(15, pe.UWOP_SAVE_NONVOL, RSI), 0x38 / 8,
+		code(10, pe.UWOP_SET_FPREG, 0),
+		code(5, pe.UWOP_ALLOC_SMALL, (0x40-8)/8),
+		code(1, pe.UWOP_PUSH_NONVOL, RBP),
+	}, nil))
+
+	largeInfo := add(unwindInfo(0, 7, 0, 0, []uint16{
+		code(7, pe.UWOP_ALLOC_LARGE, 0), 0x1000 / 8,
+	}, nil))
+
+	fragmentInfo := add(unwindInfo(pe.UNW_FLAG_CHAININFO, 0, 0, 0, []uint16{
+		code(0, pe.UWOP_SAVE_NONVOL, RBX), 0x10 / 8,
+	}, runtimeFunction(large, largeEnd, largeInfo)))
+
+	trapInfo := add(unwindInfo(0, 0, 0, 0, []uint16{
+		code(0, pe.UWOP_PUSH_MACHFRAME, 0),
+	}, nil))
+
+	spinInfo := add(unwindInfo(0, 5, 0, 0, []uint16{
+		code(5, pe.UWOP_ALLOC_SMALL, (0x30-8)/8),
+		code(1, pe.UWOP_PUSH_NONVOL, RBX),
+	}, nil))
+
+	tailInfo := add(unwindInfo(0, 5, 0, 0, []uint16{
+		code(5, pe.UWOP_ALLOC_SMALL, (0x20-8)/8),
+		code(1, pe.UWOP_PUSH_NONVOL, RBX),
+	}, nil))
+
+	b.AddSection(".xdata", xdata, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+
+	var pdata []byte
+	pdata = append(pdata, runtimeFunction(trap, trapEnd, trapInfo)...)
+	pdata = append(pdata, runtimeFunction(outer, outerEnd, outerInfo)...)
+	pdata = append(pdata, runtimeFunction(framed, framedEnd, framedInfo)...)
+	pdata = append(pdata, runtimeFunction(large, largeEnd, largeInfo)...)
+	pdata = append(pdata, runtimeFunction(fragment, fragmentEnd, fragmentInfo)...)
+	pdata = append(pdata, runtimeFunction(spin, spinEnd, spinInfo)...)
+	pdata = append(pdata, runtimeFunction(tail, tailEnd, tailInfo)...)
+
+	pdataRVA := b.AddSection(".pdata", pdata, pe.IMAGE_SCN_CNT_INITIALIZED_DATA|pe.IMAGE_SCN_MEM_READ)
+	b.SetDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION, pdataRVA, len(pdata))
+
+	return b.Bytes()
+}
+
+type target struct {
+	mem   *processtest.Memory
+	stack []byte
+	u     *Unwinder
+}
+
+func newTarget(t *testing.T) *target {
+	t.Helper()
+
+	mem := processtest.NewMemory()
+	image := mem.Map(imageBase, buildImage())
+
+	img, err := pe.NewImage(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &target{
+		mem:   mem,
+		stack: mem.Alloc(stackBase, stackSize),
+		u:     New(mem, []Module{{Base: imageBase, Size: uint32(len(image)), Image: img}}),
+	}
+}
+
+func (tg *target) put(addr uint64, v uint64) {
+	binary.LittleEndian.PutUint64(tg.stack[addr-stackBase:], v)
+}
+
+func TestStep(t *testing.T) {
+	const sp = stackBase + 0x1000
+
+	tests := []struct {
+		name  string
+		rip   uint32
+		regs  map[int]uint64
+		stack map[uint64]uint64
+		want  map[int]uint64
+		rip2  uint64
+	}{
+		{
+			name:  "function entry",
+			rip:   outer,
+			stack: map[uint64]uint64{sp: 0xCA11E4},
+			want:  map[int]uint64{RSP: sp + 8},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "inside prolog",
+			rip:   outerPushRbx,
+			stack: map[uint64]uint64{sp: 0xB0B, sp + 8: 0xCA11E4},
+			want:  map[int]uint64{RBP: 0xB0B, RSP: sp + 16},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "after pushes",
+			rip:   outerPushed,
+			stack: map[uint64]uint64{sp: 0xB8, sp + 8: 0xB0B, sp + 16: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RBP: 0xB0B, RSP: sp + 24},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "body",
+			rip:   outerBody,
+			stack: map[uint64]uint64{sp + 0x28: 0xB8, sp + 0x30: 0xB0B, sp + 0x38: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RBP: 0xB0B, RSP: sp + 0x40},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "epilog add",
+			rip:   outerEpilog,
+			stack: map[uint64]uint64{sp + 0x28: 0xB8, sp + 0x30: 0xB0B, sp + 0x38: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RBP: 0xB0B, RSP: sp + 0x40},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "epilog pop",
+			rip:   outerPopRbx,
+			stack: map[uint64]uint64{sp: 0xB8, sp + 8: 0xB0B, sp + 16: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RBP: 0xB0B, RSP: sp + 24},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "epilog ret",
+			rip:   outerRet,
+			stack: map[uint64]uint64{sp: 0xCA11E4},
+			want:  map[int]uint64{RSP: sp + 8},
+			rip2:  0xCA11E4,
+		},
+		{
+			// alloca moved RSP 0x100 below the establisher frame RBP-0x20.
+			name: "frame pointer with dynamic allocation",
+			rip:  framedBody,
+			regs: map[int]uint64{RSP: sp, RBP: sp + 0x100 + 0x20},
+			stack: map[uint64]uint64{
+				sp + 0x138: 0x51,
+				sp + 0x140: 0xB0B,
+				sp + 0x148: 0xCA11E4,
+			},
+			want: map[int]uint64{RSI: 0x51, RBP: 0xB0B, RSP: sp + 0x150},
+			rip2: 0xCA11E4,
+		},
+		{
+			name:  "frame pointer epilog",
+			rip:   framedEpilog,
+			regs:  map[int]uint64{RSP: sp, RBP: sp + 0x100 + 0x20},
+			stack: map[uint64]uint64{sp + 0x140: 0xB0B, sp + 0x148: 0xCA11E4},
+			want:  map[int]uint64{RBP: 0xB0B, RSP: sp + 0x150},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "large allocation",
+			rip:   large + 8,
+			stack: map[uint64]uint64{sp + 0x1000: 0xCA11E4},
+			want:  map[int]uint64{RSP: sp + 0x1008},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "chained fragment",
+			rip:   fragmentInner,
+			stack: map[uint64]uint64{sp + 0x10: 0xB8, sp + 0x1000: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RSP: sp + 0x1008},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "machine frame",
+			rip:   trap + 4,
+			stack: map[uint64]uint64{sp: 0xCA11E4, sp + 24: sp + 0x800},
+			want:  map[int]uint64{RSP: sp + 0x800},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "jump inside the function",
+			rip:   spinLoop,
+			stack: map[uint64]uint64{sp + 0x30: 0xB8, sp + 0x38: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RSP: sp + 0x40},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "epilog ending in a tail call",
+			rip:   tailPopRbx,
+			stack: map[uint64]uint64{sp: 0xB8, sp + 8: 0xCA11E4},
+			want:  map[int]uint64{RBX: 0xB8, RSP: sp + 16},
+			rip2:  0xCA11E4,
+		},
+		{
+			name:  "leaf without unwind data",
+			rip:   leaf,
+			stack: map[uint64]uint64{sp: 0xCA11E4},
+			want:  map[int]uint64{RSP: sp + 8},
+			rip2:  0xCA11E4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := newTarget(t)
+			for addr, v := range tt.stack {
+				tg.put(addr, v)
+			}
+
+			ctx := Context{Rip: imageBase + uint64(tt.rip)}
+			ctx.Regs[RSP] = sp
+			for reg, v := range tt.regs {
+				ctx.Regs[reg] = v
+			}
+
+			want := ctx.Regs
+			for reg, v := range tt.want {
+				want[reg] = v
+			}
+
+			if err := tg.u.Step(&ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if ctx.Rip != tt.rip2 {
+				t.Errorf("got rip %#x, want %#x", ctx.Rip, tt.rip2)
+			}
+
+			if ctx.Regs != want {
+				t.Errorf("got registers %#x\nwant %#x", ctx.Regs, want)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tg := newTarget(t)
+
+	// leaf returns into the body of framed, which made a dynamic allocation
+	// and returns into the body of outer, the bottom of the stack.
+	rsp0 := uint64(stackBase + 0x1000)
+	tg.put(rsp0, imageBase+framedBody)
+
+	rsp1 := rsp0 + 8
+	frame := rsp1 + 0x100
+	tg.put(frame+0x38, 0x51)
+	tg.put(frame+0x40, 0xB0B)
+	tg.put(frame+0x48, imageBase+outerBody)
+
+	rsp2 := frame + 0x50
+	tg.put(rsp2+0x28, 0xB8)
+	tg.put(rsp2+0x30, 0xB0B)
+	tg.put(rsp2+0x38, 0)
+
+	ctx := Context{Rip: imageBase + leaf}
+	ctx.Regs[RSP] = rsp0
+	ctx.Regs[RBP] = frame + 0x20
+
+	frames, err := tg.u.Walk(ctx, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Frame{
+		{PC: imageBase + leaf, SP: rsp0},
+		{PC: imageBase + framedBody, SP: rsp1},
+		{PC: imageBase + outerBody, SP: rsp2},
+	}
+
+	if len(frames) != len(want) {
+		t.Fatalf("got frames %#x, want %#x", frames, want)
+	}
+
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Errorf("frame %d: got %#x, want %#x", i, frames[i], want[i])
+		}
+	}
+
+	if frames, _ := tg.u.Walk(ctx, 2); len(frames) != 2 {
+		t.Errorf("Walk with max 2 returned %d frames", len(frames))
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	tg := newTarget(t)
+
+	// A machine frame that does not move the stack pointer.
+	sp := uint64(stackBase + 0x1000)
+	tg.put(sp, imageBase+trap)
+	tg.put(sp+24, sp)
+
+	ctx := Context{Rip: imageBase + trap}
+	ctx.Regs[RSP] = sp
+
+	if _, err := tg.u.Walk(ctx, 16); err == nil || !strings.Contains(err.Error(), "did not advance") {
+		t.Errorf("got %v, want a stack pointer error", err)
+	}
+
+	// A stack pointer outside of mapped memory.
+	ctx.Regs[RSP] = stackBase + stackSize
+	ctx.Rip = imageBase + leaf
+	frames, err := tg.u.Walk(ctx, 16)
+	if err == nil || len(frames) != 1 {
+		t.Errorf("got %d frames and %v, want a read error", len(frames), err)
+	}
+
+	// Stepping from a zero return address ends the stack.
+	tg.put(stackBase, 0)
+	ctx.Regs[RSP] = stackBase
+	if err := tg.u.Step(&ctx); !errors.Is(err, ErrEndOfStack) {
+		t.Errorf("got %v, want ErrEndOfStack", err)
+	}
+}
+
+func TestModulesFrom(t *testing.T) {
+	mem := processtest.NewMemory()
+	x64 := mem.Map(imageBase, buildImage())
+
+	b := petest.New(false)
+	b.AddSection(".text", []byte{0xC3}, pe.IMAGE_SCN_CNT_CODE)
+	x86 := mem.Map(0x400000, b.Bytes())
+
+	modules := ModulesFrom(mem, []process.Module{
+		{Name: "x86.dll", Base: 0x400000, Size: uint32(len(x86))},
+		{Name: "x64.dll", Base: imageBase, Size: uint32(len(x64))},
+		{Name: "unmapped.dll", Base: 0x10000000, Size: 0x1000},
+	})
+
+	if len(modules) != 1 || modules[0].Base != imageBase {
+		t.Fatalf("got modules %+v", modules)
+	}
+}
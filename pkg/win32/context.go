@@ -0,0 +1,243 @@
+package win32
+
+type ContextFlags uint32
+
+const (
+	CONTEXT_AMD64 ContextFlags = 0x00100000
+	CONTEXT_i386  ContextFlags = 0x00010000
+)
+
+const (
+	/*
+		CONTEXT_AMD64_CONTROL selects SegSs, Rsp, SegCs, Rip and EFlags of a ContextAMD64.
+	*/
+	CONTEXT_AMD64_CONTROL ContextFlags = CONTEXT_AMD64 | 0x1
+
+	/*
+		CONTEXT_AMD64_INTEGER selects Rax, Rcx, Rdx, Rbx, Rbp, Rsi, Rdi and R8-R15 of a ContextAMD64.
+	*/
+	CONTEXT_AMD64_INTEGER ContextFlags = CONTEXT_AMD64 | 0x2
+
+	/*
+		CONTEXT_AMD64_SEGMENTS selects SegDs, SegEs, SegFs and SegGs of a ContextAMD64.
+	*/
+	CONTEXT_AMD64_SEGMENTS ContextFlags = CONTEXT_AMD64 | 0x4
+
+	/*
+		CONTEXT_AMD64_FLOATING_POINT selects the Xmm0-Xmm15 and the legacy floating point state of a ContextAMD64.
+	*/
+	CONTEXT_AMD64_FLOATING_POINT ContextFlags = CONTEXT_AMD64 | 0x8
+
+	/*
+		CONTEXT_AMD64_DEBUG_REGISTERS selects Dr0-Dr3, Dr6 and Dr7 of a ContextAMD64.
+	*/
+	CONTEXT_AMD64_DEBUG_REGISTERS ContextFlags = CONTEXT_AMD64 | 0x10
+
+	CONTEXT_AMD64_FULL ContextFlags = CONTEXT_AMD64_CONTROL | CONTEXT_AMD64_INTEGER | CONTEXT_AMD64_FLOATING_POINT
+	CONTEXT_AMD64_ALL  ContextFlags = CONTEXT_AMD64_CONTROL | CONTEXT_AMD64_INTEGER | CONTEXT_AMD64_SEGMENTS | CONTEXT_AMD64_FLOATING_POINT | CONTEXT_AMD64_DEBUG_REGISTERS
+)
+
+const (
+	/*
+		CONTEXT_i386_CONTROL selects SegSs, Esp, SegCs, Eip, EFlags and Ebp of a Context386.
+	*/
+	CONTEXT_i386_CONTROL ContextFlags = CONTEXT_i386 | 0x1
+
+	/*
+		CONTEXT_i386_INTEGER selects Edi, Esi, Ebx, Edx, Ecx and Eax of a Context386.
+	*/
+	CONTEXT_i386_INTEGER ContextFlags = CONTEXT_i386 | 0x2
+
+	/*
+		CONTEXT_i386_SEGMENTS selects SegDs, SegEs, SegFs and SegGs of a Context386.
+	*/
+	CONTEXT_i386_SEGMENTS ContextFlags = CONTEXT_i386 | 0x4
+
+	/*
+		CONTEXT_i386_FLOATING_POINT selects FloatSave of a Context386.
+	*/
+	CONTEXT_i386_FLOATING_POINT ContextFlags = CONTEXT_i386 | 0x8
+
+	/*
+		CONTEXT_i386_DEBUG_REGISTERS selects Dr0-Dr3, Dr6 and Dr7 of a Context386.
+	*/
+	CONTEXT_i386_DEBUG_REGISTERS ContextFlags = CONTEXT_i386 | 0x10
+
+	/*
+		CONTEXT_i386_EXTENDED_REGISTERS selects ExtendedRegisters of a Context386.
+	*/
+	CONTEXT_i386_EXTENDED_REGISTERS ContextFlags = CONTEXT_i386 | 0x20
+
+	CONTEXT_i386_FULL ContextFlags = CONTEXT_i386_CONTROL | CONTEXT_i386_INTEGER | CONTEXT_i386_SEGMENTS
+	CONTEXT_i386_ALL  ContextFlags = CONTEXT_i386_FULL | CONTEXT_i386_FLOATING_POINT | CONTEXT_i386_DEBUG_REGISTERS | CONTEXT_i386_EXTENDED_REGISTERS
+)
+
+/*
+	WOW64_CONTEXT flags select parts of a Wow64Context. They have the same values as the CONTEXT_i386 flags.
+*/
+const (
+	WOW64_CONTEXT_i386               ContextFlags = CONTEXT_i386
+	WOW64_CONTEXT_CONTROL            ContextFlags = CONTEXT_i386_CONTROL
+	WOW64_CONTEXT_INTEGER            ContextFlags = CONTEXT_i386_INTEGER
+	WOW64_CONTEXT_SEGMENTS           ContextFlags = CONTEXT_i386_SEGMENTS
+	WOW64_CONTEXT_FLOATING_POINT     ContextFlags = CONTEXT_i386_FLOATING_POINT
+	WOW64_CONTEXT_DEBUG_REGISTERS    ContextFlags = CONTEXT_i386_DEBUG_REGISTERS
+	WOW64_CONTEXT_EXTENDED_REGISTERS ContextFlags = CONTEXT_i386_EXTENDED_REGISTERS
+	WOW64_CONTEXT_FULL               ContextFlags = CONTEXT_i386_FULL
+	WOW64_CONTEXT_ALL                ContextFlags = CONTEXT_i386_ALL
+)
+
+/*
+	M128A is a 128-bit value as stored in a context record.
+*/
+type M128A struct {
+	Low  uint64
+	High int64
+}
+
+/*
+	XmmSaveArea32 is the legacy FXSAVE area of an x64 context (XMM_SAVE_AREA32).
+*/
+type XmmSaveArea32 struct {
+	ControlWord    uint16
+	StatusWord     uint16
+	TagWord        uint8
+	Reserved1      uint8
+	ErrorOpcode    uint16
+	ErrorOffset    uint32
+	ErrorSelector  uint16
+	Reserved2      uint16
+	DataOffset     uint32
+	DataSelector   uint16
+	Reserved3      uint16
+	MxCsr          uint32
+	MxCsrMask      uint32
+	FloatRegisters [8]M128A
+	XmmRegisters   [16]M128A
+	Reserved4      [96]byte
+}
+
+/*
+	ContextAMD64 is the processor-specific register state of an x64 thread (CONTEXT on x64).
+
+	The structure must be 16-byte aligned when passed to GetThreadContext and SetThreadContext.
+	The kernel32 wrappers take care of this, so a ContextAMD64 may be declared anywhere.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-context
+*/
+type ContextAMD64 struct {
+	P1Home uint64
+	P2Home uint64
+	P3Home uint64
+	P4Home uint64
+	P5Home uint64
+	P6Home uint64
+
+	ContextFlags ContextFlags
+	MxCsr        uint32
+
+	SegCs  uint16
+	SegDs  uint16
+	SegEs  uint16
+	SegFs  uint16
+	SegGs  uint16
+	SegSs  uint16
+	EFlags uint32
+
+	Dr0 uint64
+	Dr1 uint64
+	Dr2 uint64
+	Dr3 uint64
+	Dr6 uint64
+	Dr7 uint64
+
+	Rax uint64
+	Rcx uint64
+	Rdx uint64
+	Rbx uint64
+	Rsp uint64
+	Rbp uint64
+	Rsi uint64
+	Rdi uint64
+	R8  uint64
+	R9  uint64
+	R10 uint64
+	R11 uint64
+	R12 uint64
+	R13 uint64
+	R14 uint64
+	R15 uint64
+
+	Rip uint64
+
+	FltSave XmmSaveArea32
+
+	VectorRegister [26]M128A
+	VectorControl  uint64
+
+	DebugControl         uint64
+	LastBranchToRip      uint64
+	LastBranchFromRip    uint64
+	LastExceptionToRip   uint64
+	LastExceptionFromRip uint64
+}
+
+/*
+	FloatingSaveArea is the x87 floating point state of an x86 context (FLOATING_SAVE_AREA).
+*/
+type FloatingSaveArea struct {
+	ControlWord   uint32
+	StatusWord    uint32
+	TagWord       uint32
+	ErrorOffset   uint32
+	ErrorSelector uint32
+	DataOffset    uint32
+	DataSelector  uint32
+	RegisterArea  [80]byte
+	Cr0NpxState   uint32
+}
+
+/*
+	Context386 is the processor-specific register state of an x86 thread (CONTEXT on x86).
+	It has the same layout as WOW64_CONTEXT.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-wow64_context
+*/
+type Context386 struct {
+	ContextFlags ContextFlags
+
+	Dr0 uint32
+	Dr1 uint32
+	Dr2 uint32
+	Dr3 uint32
+	Dr6 uint32
+	Dr7 uint32
+
+	FloatSave FloatingSaveArea
+
+	SegGs uint32
+	SegFs uint32
+	SegEs uint32
+	SegDs uint32
+
+	Edi uint32
+	Esi uint32
+	Ebx uint32
+	Edx uint32
+	Ecx uint32
+	Eax uint32
+
+	Ebp    uint32
+	Eip    uint32
+	SegCs  uint32
+	EFlags uint32
+	Esp    uint32
+	SegSs  uint32
+
+	ExtendedRegisters [512]byte
+}
+
+/*
+	Wow64Context is the register state of a 32-bit thread running under WOW64 (WOW64_CONTEXT).
+*/
+type Wow64Context = Context386
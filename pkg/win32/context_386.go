@@ -0,0 +1,17 @@
+package win32
+
+/*
+	Context is the CONTEXT structure of the architecture the program is compiled for.
+*/
+type Context = Context386
+
+const (
+	CONTEXT_CONTROL            = CONTEXT_i386_CONTROL
+	CONTEXT_INTEGER            = CONTEXT_i386_INTEGER
+	CONTEXT_SEGMENTS           = CONTEXT_i386_SEGMENTS
+	CONTEXT_FLOATING_POINT     = CONTEXT_i386_FLOATING_POINT
+	CONTEXT_DEBUG_REGISTERS    = CONTEXT_i386_DEBUG_REGISTERS
+	CONTEXT_EXTENDED_REGISTERS = CONTEXT_i386_EXTENDED_REGISTERS
+	CONTEXT_FULL               = CONTEXT_i386_FULL
+	CONTEXT_ALL                = CONTEXT_i386_ALL
+)
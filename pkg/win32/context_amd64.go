@@ -0,0 +1,16 @@
+package win32
+
+/*
+	Context is the CONTEXT structure of the architecture the program is compiled for.
+*/
+type Context = ContextAMD64
+
+const (
+	CONTEXT_CONTROL         = CONTEXT_AMD64_CONTROL
+	CONTEXT_INTEGER         = CONTEXT_AMD64_INTEGER
+	CONTEXT_SEGMENTS        = CONTEXT_AMD64_SEGMENTS
+	CONTEXT_FLOATING_POINT  = CONTEXT_AMD64_FLOATING_POINT
+	CONTEXT_DEBUG_REGISTERS = CONTEXT_AMD64_DEBUG_REGISTERS
+	CONTEXT_FULL            = CONTEXT_AMD64_FULL
+	CONTEXT_ALL             = CONTEXT_AMD64_ALL
+)
@@ -0,0 +1,109 @@
+package win32
+
+import (
+	"testing"
+	"unsafe"
+)
+
+type fieldOffset struct {
+	name   string
+	got    uintptr
+	offset uintptr
+}
+
+func checkLayout(t *testing.T, size, wantSize uintptr, fields []fieldOffset) {
+	t.Helper()
+
+	if size != wantSize {
+		t.Errorf("size is %#x, want %#x", size, wantSize)
+	}
+
+	for _, f := range fields {
+		if f.got != f.offset {
+			t.Errorf("%s is at %#x, want %#x", f.name, f.got, f.offset)
+		}
+	}
+}
+
+/*
+	The expected offsets are those of CONTEXT and WOW64_CONTEXT in winnt.h.
+*/
+func TestContextAMD64Layout(t *testing.T) {
+	var c ContextAMD64
+
+	checkLayout(t, unsafe.Sizeof(c), 0x4D0, []fieldOffset{
+		{"P1Home", unsafe.Offsetof(c.P1Home), 0x00},
+		{"P6Home", unsafe.Offsetof(c.P6Home), 0x28},
+		{"ContextFlags", unsafe.Offsetof(c.ContextFlags), 0x30},
+		{"MxCsr", unsafe.Offsetof(c.MxCsr), 0x34},
+		{"SegCs", unsafe.Offsetof(c.SegCs), 0x38},
+		{"SegSs", unsafe.Offsetof(c.SegSs), 0x42},
+		{"EFlags", unsafe.Offsetof(c.EFlags), 0x44},
+		{"Dr0", unsafe.Offsetof(c.Dr0), 0x48},
+		{"Dr6", unsafe.Offsetof(c.Dr6), 0x68},
+		{"Dr7", unsafe.Offsetof(c.Dr7), 0x70},
+		{"Rax", unsafe.Offsetof(c.Rax), 0x78},
+		{"Rsp", unsafe.Offsetof(c.Rsp), 0x98},
+		{"Rbp", unsafe.Offsetof(c.Rbp), 0xA0},
+		{"R8", unsafe.Offsetof(c.R8), 0xB8},
+		{"R15", unsafe.Offsetof(c.R15), 0xF0},
+		{"Rip", unsafe.Offsetof(c.Rip), 0xF8},
+		{"FltSave", unsafe.Offsetof(c.FltSave), 0x100},
+		{"VectorRegister", unsafe.Offsetof(c.VectorRegister), 0x300},
+		{"VectorControl", unsafe.Offsetof(c.VectorControl), 0x4A0},
+		{"DebugControl", unsafe.Offsetof(c.DebugControl), 0x4A8},
+		{"LastExceptionFromRip", unsafe.Offsetof(c.LastExceptionFromRip), 0x4C8},
+	})
+
+	var x XmmSaveArea32
+	checkLayout(t, unsafe.Sizeof(x), 0x200, []fieldOffset{
+		{"MxCsr", unsafe.Offsetof(x.MxCsr), 0x18},
+		{"FloatRegisters", unsafe.Offsetof(x.FloatRegisters), 0x20},
+		{"XmmRegisters", unsafe.Offsetof(x.XmmRegisters), 0xA0},
+		{"Reserved4", unsafe.Offsetof(x.Reserved4), 0x1A0},
+	})
+}
+
+func TestContext386Layout(t *testing.T) {
+	var c Context386
+
+	checkLayout(t, unsafe.Sizeof(c), 0x2CC, []fieldOffset{
+		{"ContextFlags", unsafe.Offsetof(c.ContextFlags), 0x00},
+		{"Dr0", unsafe.Offsetof(c.Dr0), 0x04},
+		{"Dr6", unsafe.Offsetof(c.Dr6), 0x14},
+		{"Dr7", unsafe.Offsetof(c.Dr7), 0x18},
+		{"FloatSave", unsafe.Offsetof(c.FloatSave), 0x1C},
+		{"SegGs", unsafe.Offsetof(c.SegGs), 0x8C},
+		{"SegDs", unsafe.Offsetof(c.SegDs), 0x98},
+		{"Edi", unsafe.Offsetof(c.Edi), 0x9C},
+		{"Eax", unsafe.Offsetof(c.Eax), 0xB0},
+		{"Ebp", unsafe.Offsetof(c.Ebp), 0xB4},
+		{"Eip", unsafe.Offsetof(c.Eip), 0xB8},
+		{"SegCs", unsafe.Offsetof(c.SegCs), 0xBC},
+		{"EFlags", unsafe.Offsetof(c.EFlags), 0xC0},
+		{"Esp", unsafe.Offsetof(c.Esp), 0xC4},
+		{"SegSs", unsafe.Offsetof(c.SegSs), 0xC8},
+		{"ExtendedRegisters", unsafe.Offsetof(c.ExtendedRegisters), 0xCC},
+	})
+
+	var f FloatingSaveArea
+	checkLayout(t, unsafe.Sizeof(f), 0x70, []fieldOffset{
+		{"RegisterArea", unsafe.Offsetof(f.RegisterArea), 0x1C},
+		{"Cr0NpxState", unsafe.Offsetof(f.Cr0NpxState), 0x6C},
+	})
+}
+
+func TestWow64ContextLayout(t *testing.T) {
+	var c Wow64Context
+
+	checkLayout(t, unsafe.Sizeof(c), 0x2CC, []fieldOffset{
+		{"ContextFlags", unsafe.Offsetof(c.ContextFlags), 0x00},
+		{"Dr7", unsafe.Offsetof(c.Dr7), 0x18},
+		{"FloatSave", unsafe.Offsetof(c.FloatSave), 0x1C},
+		{"Edi", unsafe.Offsetof(c.Edi), 0x9C},
+		{"Eip", unsafe.Offsetof(c.Eip), 0xB8},
+		{"EFlags", unsafe.Offsetof(c.EFlags), 0xC0},
+		{"Esp", unsafe.Offsetof(c.Esp), 0xC4},
+		{"ExtendedRegisters", unsafe.Offsetof(c.ExtendedRegisters), 0xCC},
+	})
+}
@@ -0,0 +1,29 @@
+package win32
+
+import "unsafe"
+
+const ptrSize = unsafe.Sizeof(uintptr(0))
+
+/*
+	DebugEvent is the raw DEBUG_EVENT structure filled in by WaitForDebugEvent.
+
+	Info holds the union of the event specific structures. Its size depends on
+	the pointer size because EXCEPTION_RECORD is made of pointer sized fields:
+	84 bytes on x86 and 160 bytes on x64.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/minwinbase/ns-minwinbase-debug_event
+*/
+type DebugEvent struct {
+	DebugEventCode uint32
+	ProcessID      uint32
+	ThreadID       uint32
+	Info           [(84 + 76*(ptrSize/8)) / ptrSize]uintptr
+}
+
+/*
+	Bytes returns the in-memory representation of the event, as expected by
+	the decoding functions of the debugger package.
+*/
+func (e *DebugEvent) Bytes() []byte {
+	return (*[unsafe.Sizeof(DebugEvent{})]byte)(unsafe.Pointer(e))[:]
+}
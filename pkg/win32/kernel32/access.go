@@ -0,0 +1,187 @@
+package kernel32
+
+import (
+	"errors"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	ProcessOperation is a set of operations to perform on a process,
+	used to compute the access rights a process handle needs.
+*/
+type ProcessOperation uint32
+
+const (
+	/*
+		OperationReadMemory reads memory with ReadProcessMemory.
+	*/
+	OperationReadMemory ProcessOperation = 1 << iota
+
+	/*
+		OperationWriteMemory writes memory with WriteProcessMemory.
+	*/
+	OperationWriteMemory
+
+	/*
+		OperationManageMemory allocates, frees and protects memory with VirtualAllocEx,
+		VirtualFreeEx and VirtualProtectEx.
+	*/
+	OperationManageMemory
+
+	/*
+		OperationQueryLimitedInformation queries the exit code, times, image name and priority class.
+	*/
+	OperationQueryLimitedInformation
+
+	/*
+		OperationQueryInformation queries information that needs more than limited access,
+		such as the modules and memory regions of the process.
+	*/
+	OperationQueryInformation
+
+	/*
+		OperationCreateThread creates threads with CreateRemoteThread.
+	*/
+	OperationCreateThread
+
+	/*
+		OperationSuspendResume suspends and resumes the whole process.
+	*/
+	OperationSuspendResume
+
+	/*
+		OperationTerminate terminates the process with TerminateProcess.
+	*/
+	OperationTerminate
+
+	/*
+		OperationWait waits for the process to exit.
+	*/
+	OperationWait
+
+	/*
+		OperationDuplicateHandles duplicates handles from or into the process with DuplicateHandle.
+	*/
+	OperationDuplicateHandles
+
+	/*
+		OperationSetInformation sets the priority class and affinity of the process.
+	*/
+	OperationSetInformation
+
+	/*
+		OperationAssignToJob assigns the process to a job object.
+	*/
+	OperationAssignToJob
+)
+
+/*
+	operationAccess lists the rights each operation needs, as documented for the functions it uses.
+*/
+var operationAccess = []struct {
+	operation ProcessOperation
+	name      string
+	access    ProcessAccess
+}{
+	{OperationReadMemory, "ReadMemory", PROCESS_VM_READ},
+	{OperationWriteMemory, "WriteMemory", PROCESS_VM_WRITE | PROCESS_VM_OPERATION},
+	{OperationManageMemory, "ManageMemory", PROCESS_VM_OPERATION},
+	{OperationQueryLimitedInformation, "QueryLimitedInformation", PROCESS_QUERY_LIMITED_INFORMATION},
+	{OperationQueryInformation, "QueryInformation", PROCESS_QUERY_INFORMATION},
+	{OperationCreateThread, "CreateThread", PROCESS_CREATE_THREAD | PROCESS_QUERY_INFORMATION | PROCESS_VM_OPERATION | PROCESS_VM_WRITE | PROCESS_VM_READ},
+	{OperationSuspendResume, "SuspendResume", PROCESS_SUSPEND_RESUME},
+	{OperationTerminate, "Terminate", PROCESS_TERMINATE},
+	{OperationWait, "Wait", SYNCHRONIZE},
+	{OperationDuplicateHandles, "DuplicateHandles", PROCESS_DUP_HANDLE},
+	{OperationSetInformation, "SetInformation", PROCESS_SET_INFORMATION},
+	{OperationAssignToJob, "AssignToJob", PROCESS_SET_QUOTA | PROCESS_TERMINATE},
+}
+
+var processOperationNames = func() []flagName {
+	names := make([]flagName, len(operationAccess))
+	for i, op := range operationAccess {
+		names[i] = flagName{name: op.name, value: uint32(op.operation)}
+	}
+	return names
+}()
+
+/*
+	String returns the names of the operations joined by '|', such as "ReadMemory|Wait".
+*/
+func (ops ProcessOperation) String() string {
+	return formatFlags(uint32(ops), processOperationNames)
+}
+
+/*
+	Access returns the minimal access rights needed for the operations.
+	PROCESS_QUERY_LIMITED_INFORMATION is left out when PROCESS_QUERY_INFORMATION
+	is needed, since a handle with the latter is automatically granted the former.
+*/
+func (ops ProcessOperation) Access() ProcessAccess {
+	var access ProcessAccess
+	for _, op := range operationAccess {
+		if ops&op.operation != 0 {
+			access |= op.access
+		}
+	}
+
+	if access&PROCESS_QUERY_INFORMATION != 0 {
+		access &^= PROCESS_QUERY_LIMITED_INFORMATION
+	}
+
+	return access
+}
+
+/*
+	processOpener opens a process with the given rights, as OpenProcess does for one process.
+*/
+type processOpener func(access ProcessAccess, inheritHandle bool) (win32.Handle, error)
+
+/*
+	openProcessFor opens a process with open and closeHandle like OpenProcessFor.
+
+	If the rights for all of ops are denied, each operation is probed with a handle of
+	its own rights only, which is closed right away, and the process is opened again
+	with the merged rights of the operations that were granted.
+*/
+func openProcessFor(ops ProcessOperation, inheritHandle bool, open processOpener, closeHandle func(win32.Handle) error) (win32.Handle, ProcessOperation, error) {
+	handle, err := open(ops.Access(), inheritHandle)
+	if err == nil {
+		return handle, 0, nil
+	}
+
+	// With a single operation there are no reduced rights to fall back to.
+	if !errors.Is(err, ERROR_ACCESS_DENIED) || ops&(ops-1) == 0 {
+		return 0, ops, err
+	}
+
+	var available ProcessOperation
+	for _, op := range operationAccess {
+		if ops&op.operation == 0 {
+			continue
+		}
+
+		h, err := open(op.access, false)
+		if err != nil {
+			if errors.Is(err, ERROR_ACCESS_DENIED) {
+				continue
+			}
+			return 0, ops, err
+		}
+
+		closeHandle(h)
+		available |= op.operation
+	}
+
+	if available == 0 {
+		return 0, ops, err
+	}
+
+	handle, err = open(available.Access(), inheritHandle)
+	if err != nil {
+		return 0, ops, err
+	}
+
+	return handle, ops &^ available, nil
+}
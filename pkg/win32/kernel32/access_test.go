@@ -0,0 +1,203 @@
+package kernel32
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+func TestOperationAccess(t *testing.T) {
+	tests := []struct {
+		op     ProcessOperation
+		name   string
+		access ProcessAccess
+	}{
+		{OperationReadMemory, "ReadMemory", PROCESS_VM_READ},
+		{OperationWriteMemory, "WriteMemory", PROCESS_VM_WRITE | PROCESS_VM_OPERATION},
+		{OperationManageMemory, "ManageMemory", PROCESS_VM_OPERATION},
+		{OperationQueryLimitedInformation, "QueryLimitedInformation", PROCESS_QUERY_LIMITED_INFORMATION},
+		{OperationQueryInformation, "QueryInformation", PROCESS_QUERY_INFORMATION},
+		{OperationCreateThread, "CreateThread", PROCESS_CREATE_THREAD | PROCESS_QUERY_INFORMATION | PROCESS_VM_OPERATION | PROCESS_VM_WRITE | PROCESS_VM_READ},
+		{OperationSuspendResume, "SuspendResume", PROCESS_SUSPEND_RESUME},
+		{OperationTerminate, "Terminate", PROCESS_TERMINATE},
+		{OperationWait, "Wait", SYNCHRONIZE},
+		{OperationDuplicateHandles, "DuplicateHandles", PROCESS_DUP_HANDLE},
+		{OperationSetInformation, "SetInformation", PROCESS_SET_INFORMATION},
+		{OperationAssignToJob, "AssignToJob", PROCESS_SET_QUOTA | PROCESS_TERMINATE},
+	}
+
+	var all ProcessOperation
+	for _, tc := range tests {
+		if got := tc.op.Access(); got != tc.access {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.access)
+		}
+
+		if got := tc.op.String(); got != tc.name {
+			t.Errorf("%#x: got %s, want %s", uint32(tc.op), got, tc.name)
+		}
+
+		all |= tc.op
+	}
+
+	// Every operation is covered above and has a single bit of its own.
+	if all != OperationAssignToJob<<1-1 || len(tests) != len(operationAccess) {
+		t.Errorf("operations %#x in the test, %d in the table", uint32(all), len(operationAccess))
+	}
+}
+
+func TestOperationAccessMerging(t *testing.T) {
+	tests := []struct {
+		ops  ProcessOperation
+		want ProcessAccess
+	}{
+		{0, 0},
+		{OperationReadMemory | OperationWriteMemory, PROCESS_VM_READ | PROCESS_VM_WRITE | PROCESS_VM_OPERATION},
+		{OperationWriteMemory | OperationManageMemory, PROCESS_VM_WRITE | PROCESS_VM_OPERATION},
+		{OperationTerminate | OperationAssignToJob, PROCESS_TERMINATE | PROCESS_SET_QUOTA},
+		{OperationReadMemory | OperationWait, PROCESS_VM_READ | SYNCHRONIZE},
+
+		// PROCESS_QUERY_INFORMATION implies PROCESS_QUERY_LIMITED_INFORMATION.
+		{OperationQueryLimitedInformation | OperationQueryInformation, PROCESS_QUERY_INFORMATION},
+		{OperationQueryLimitedInformation | OperationCreateThread, OperationCreateThread.Access()},
+		{OperationQueryLimitedInformation | OperationTerminate, PROCESS_QUERY_LIMITED_INFORMATION | PROCESS_TERMINATE},
+
+		// Bits without an operation need no rights.
+		{1 << 31, 0},
+		{OperationWait | 1<<31, SYNCHRONIZE},
+	}
+
+	for _, tc := range tests {
+		if got := tc.ops.Access(); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.ops, got, tc.want)
+		}
+	}
+
+	if got := (OperationReadMemory | OperationWait | 1<<31).String(); got != "ReadMemory|Wait|0x80000000" {
+		t.Errorf("String: got %s", got)
+	}
+}
+
+/*
+	fakeProcess grants the rights in granted and records the calls made by openProcessFor.
+*/
+type fakeProcess struct {
+	granted ProcessAccess
+	err     error
+
+	opened  []ProcessAccess
+	inherit []bool
+	closed  []win32.Handle
+}
+
+func (p *fakeProcess) open(access ProcessAccess, inheritHandle bool) (win32.Handle, error) {
+	p.opened = append(p.opened, access)
+	p.inherit = append(p.inherit, inheritHandle)
+
+	if p.err != nil {
+		return 0, p.err
+	}
+	if access&^p.granted != 0 {
+		return 0, ERROR_ACCESS_DENIED
+	}
+
+	return win32.Handle(len(p.opened)), nil
+}
+
+func (p *fakeProcess) close(h win32.Handle) error {
+	p.closed = append(p.closed, h)
+	return nil
+}
+
+func TestOpenProcessFor(t *testing.T) {
+	p := &fakeProcess{granted: ^ProcessAccess(0)}
+	ops := OperationReadMemory | OperationQueryLimitedInformation | OperationWait
+
+	h, missing, err := openProcessFor(ops, true, p.open, p.close)
+	if err != nil || h != 1 || missing != 0 {
+		t.Fatalf("got %v, %v, %v", h, missing, err)
+	}
+
+	if len(p.opened) != 1 || p.opened[0] != ops.Access() || !p.inherit[0] {
+		t.Errorf("opened %v, inherit %v", p.opened, p.inherit)
+	}
+}
+
+func TestOpenProcessForProtected(t *testing.T) {
+	// The rights granted on protected processes.
+	p := &fakeProcess{granted: PROCESS_QUERY_LIMITED_INFORMATION | PROCESS_SUSPEND_RESUME | PROCESS_TERMINATE | SYNCHRONIZE}
+	ops := OperationReadMemory | OperationQueryLimitedInformation | OperationWait | OperationTerminate
+
+	h, missing, err := openProcessFor(ops, true, p.open, p.close)
+	if err != nil || missing != OperationReadMemory {
+		t.Fatalf("got %v, %v, %v; want ReadMemory missing", h, missing, err)
+	}
+
+	want := []ProcessAccess{
+		ops.Access(),
+		PROCESS_VM_READ,
+		PROCESS_QUERY_LIMITED_INFORMATION,
+		PROCESS_TERMINATE,
+		SYNCHRONIZE,
+		PROCESS_QUERY_LIMITED_INFORMATION | PROCESS_TERMINATE | SYNCHRONIZE,
+	}
+
+	if len(p.opened) != len(want) {
+		t.Fatalf("opened %v, want %v", p.opened, want)
+	}
+
+	for i := range want {
+		if p.opened[i] != want[i] {
+			t.Errorf("open %d: got %v, want %v", i, p.opened[i], want[i])
+		}
+
+		// Only the handles returned to the caller are inheritable.
+		if inherit := i == 0 || i == len(want)-1; p.inherit[i] != inherit {
+			t.Errorf("open %d: inherit %v", i, p.inherit[i])
+		}
+	}
+
+	// The probe handles are closed, the one returned is not.
+	if h != 6 || len(p.closed) != 3 || p.closed[0] != 3 || p.closed[1] != 4 || p.closed[2] != 5 {
+		t.Errorf("returned %v, closed %v", h, p.closed)
+	}
+}
+
+func TestOpenProcessForErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     *fakeProcess
+		ops   ProcessOperation
+		want  error
+		opens int
+	}{
+		{"single operation denied", &fakeProcess{}, OperationReadMemory, ERROR_ACCESS_DENIED, 1},
+		{"all operations denied", &fakeProcess{}, OperationReadMemory | OperationTerminate, ERROR_ACCESS_DENIED, 3},
+		{"process not found", &fakeProcess{err: ERROR_INVALID_PARAMETER}, OperationReadMemory | OperationTerminate, ERROR_INVALID_PARAMETER, 1},
+	}
+
+	for _, tc := range tests {
+		h, missing, err := openProcessFor(tc.ops, false, tc.p.open, tc.p.close)
+		if !errors.Is(err, tc.want) || h != 0 || missing != tc.ops {
+			t.Errorf("%s: got %v, %v, %v; want %v", tc.name, h, missing, err, tc.want)
+		}
+
+		if len(tc.p.opened) != tc.opens || len(tc.p.closed) != 0 {
+			t.Errorf("%s: opened %v, closed %v", tc.name, tc.p.opened, tc.p.closed)
+		}
+	}
+
+	// A probe failing for another reason than the rights stops the fallback.
+	p := &fakeProcess{}
+	calls := 0
+	open := func(access ProcessAccess, inheritHandle bool) (win32.Handle, error) {
+		if calls++; calls == 2 {
+			return 0, ERROR_INVALID_HANDLE
+		}
+		return p.open(access, inheritHandle)
+	}
+
+	if _, _, err := openProcessFor(OperationReadMemory|OperationTerminate, false, open, p.close); !errors.Is(err, ERROR_INVALID_HANDLE) || calls != 2 {
+		t.Errorf("probe error: got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build amd64 || 386
+
+package kernel32
+
+/*
+	#include <windows.h>
+	#include <processthreadsapi.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	alignedContext returns a 16-byte aligned copy of ctx backed by Go memory.
+	CONTEXT must be 16-byte aligned on x64, which Go does not guarantee for any variable.
+*/
+func alignedContext(ctx *win32.Context) *win32.Context {
+	buf := make([]byte, unsafe.Sizeof(*ctx)+15)
+	pad := (16 - uintptr(unsafe.Pointer(&buf[0]))%16) % 16
+
+	aligned := (*win32.Context)(unsafe.Pointer(&buf[pad]))
+	*aligned = *ctx
+	return aligned
+}
+
+/*
+	GetThreadContext retrieves the context of the specified thread.
+	Set ctx.ContextFlags to select which parts of the context are retrieved.
+
+	The handle must have THREAD_GET_CONTEXT access to the thread.
+	The thread should be suspended, or be the subject of a debug event,
+	for the context to be meaningful.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getthreadcontext
+*/
+func GetThreadContext(thread win32.Handle, ctx *win32.Context) error {
+	aligned := alignedContext(ctx)
+	if C.GetThreadContext(C.HANDLE(unsafe.Pointer(thread)), (*C.CONTEXT)(unsafe.Pointer(aligned))) == 0 {
+		return GetLastError()
+	}
+
+	*ctx = *aligned
+	return nil
+}
+
+/*
+	SetThreadContext sets the context of the specified thread.
+	Only the parts of the context selected by ctx.ContextFlags are written.
+
+	The handle must have THREAD_SET_CONTEXT access to the thread.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-setthreadcontext
+*/
+func SetThreadContext(thread win32.Handle, ctx *win32.Context) error {
+	aligned := alignedContext(ctx)
+	if C.SetThreadContext(C.HANDLE(unsafe.Pointer(thread)), (*C.CONTEXT)(unsafe.Pointer(aligned))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package kernel32
+
+/*
+	#include <windows.h>
+	#include <debugapi.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+type ContinueStatus uint32
+
+const (
+	/*
+		DBG_CONTINUE continues the thread. If the event was an exception,
+		the exception is considered handled and execution resumes.
+	*/
+	DBG_CONTINUE ContinueStatus = 0x00010002
+
+	/*
+		DBG_EXCEPTION_NOT_HANDLED continues the thread and passes the exception
+		on to the exception handlers of the debuggee.
+	*/
+	DBG_EXCEPTION_NOT_HANDLED ContinueStatus = 0x80010001
+
+	/*
+		DBG_REPLY_LATER suspends the faulting thread and replays the exception
+		once it is resumed. Supported starting with Windows 10 version 1507.
+	*/
+	DBG_REPLY_LATER ContinueStatus = 0x40010001
+)
+
+/*
+	DebugActiveProcess enables a debugger to attach to an active process and debug it.
+
+	The debugger must have PROCESS_ALL_ACCESS to the process, and the calling
+	thread becomes the thread that must wait for and continue debug events.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/debugapi/nf-debugapi-debugactiveprocess
+*/
+func DebugActiveProcess(processId uint32) error {
+	if C.DebugActiveProcess(C.DWORD(processId)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	DebugActiveProcessStop stops the debugger from debugging the specified process.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/debugapi/nf-debugapi-debugactiveprocessstop
+*/
+func DebugActiveProcessStop(processId uint32) error {
+	if C.DebugActiveProcessStop(C.DWORD(processId)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	DebugSetProcessKillOnExit sets the action to be performed when the calling thread exits.
+	If killOnExit is false, the debugger detaches from all processes being debugged when it exits.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-debugsetprocesskillonexit
+*/
+func DebugSetProcessKillOnExit(killOnExit bool) error {
+	var kill C.BOOL
+	if killOnExit {
+		kill = 1
+	}
+
+	if C.DebugSetProcessKillOnExit(kill) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	WaitForDebugEvent waits for a debugging event to occur in a process being debugged.
+	Only the thread that created the process being debugged or attached to it can call it.
+
+	If no event occurs within milliseconds, ERROR_SEM_TIMEOUT is returned.
+	Pass INFINITE to wait until an event occurs.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/debugapi/nf-debugapi-waitfordebugevent
+*/
+func WaitForDebugEvent(event *win32.DebugEvent, milliseconds uint32) error {
+	if C.WaitForDebugEvent((*C.DEBUG_EVENT)(unsafe.Pointer(event)), C.DWORD(milliseconds)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	WaitForDebugEventEx is identical to WaitForDebugEvent except that
+	OUTPUT_DEBUG_STRING_EVENT events report Unicode strings when the debuggee
+	used OutputDebugStringW.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/debugapi/nf-debugapi-waitfordebugeventex
+*/
+func WaitForDebugEventEx(event *win32.DebugEvent, milliseconds uint32) error {
+	if C.WaitForDebugEventEx((*C.DEBUG_EVENT)(unsafe.Pointer(event)), C.DWORD(milliseconds)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	ContinueDebugEvent enables a debugger to continue a thread that previously reported a debugging event.
+	Only the thread that created the process being debugged or attached to it can call it.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/debugapi/nf-debugapi-continuedebugevent
+*/
+func ContinueDebugEvent(processId uint32, threadId uint32, status ContinueStatus) error {
+	if C.ContinueDebugEvent(C.DWORD(processId), C.DWORD(threadId), C.DWORD(status)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
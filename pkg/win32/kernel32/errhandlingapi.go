@@ -5,16 +5,6 @@ package kernel32
 */
 import "C"
 
-import (
-	"strconv"
-)
-
-type ErrorCode uint32
-
-func (e ErrorCode) Error() string {
-	return "win32 error code: " + strconv.Itoa(int(e))
-}
-
 /*
 	GetLastError retrieves the calling thread's last-error code value.
 	The last-error code is maintained on a per-thread basis.
@@ -0,0 +1,89 @@
+package kernel32
+
+/*
+	#include <stdlib.h>
+	#include <windows.h>
+	#include <fileapi.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"unicode/utf16"
+	"unsafe"
+)
+
+/*
+	splitMultiString splits a list of NUL terminated UTF-16 strings ended by an empty string.
+*/
+func splitMultiString(buf []uint16) []string {
+	var list []string
+	for len(buf) > 0 && buf[0] != 0 {
+		end := 0
+		for end < len(buf) && buf[end] != 0 {
+			end++
+		}
+
+		list = append(list, string(utf16.Decode(buf[:end])))
+		if end == len(buf) {
+			break
+		}
+		buf = buf[end+1:]
+	}
+
+	return list
+}
+
+/*
+	QueryDosDevice retrieves the current mapping of an MS-DOS device name, such as "C:",
+	followed by its previous mappings that have not been removed. For a drive, the mapping
+	is usually an NT device path such as "\Device\HarddiskVolume3". If deviceName is empty,
+	it retrieves the list of all existing MS-DOS device names.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-querydosdevicew
+*/
+func QueryDosDevice(deviceName string) ([]string, error) {
+	name := wideString(deviceName)
+	defer C.free(unsafe.Pointer(name))
+
+	for size := 1024; ; size *= 2 {
+		buf := make([]uint16, size)
+
+		n := C.QueryDosDeviceW(name, (*C.WCHAR)(unsafe.Pointer(&buf[0])), C.DWORD(size))
+		if n == 0 {
+			err := GetLastError()
+			if errors.Is(err, ERROR_INSUFFICIENT_BUFFER) && size < 1<<20 {
+				continue
+			}
+			return nil, err
+		}
+
+		return splitMultiString(buf[:n]), nil
+	}
+}
+
+/*
+	GetLogicalDriveStrings retrieves the root directories of the valid drives in the system,
+	such as "C:\".
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-getlogicaldrivestringsw
+*/
+func GetLogicalDriveStrings() ([]string, error) {
+	size := C.DWORD(128)
+	for {
+		buf := make([]uint16, size+1)
+
+		n := C.GetLogicalDriveStringsW(size, (*C.WCHAR)(unsafe.Pointer(&buf[0])))
+		if n == 0 {
+			return nil, GetLastError()
+		}
+
+		// When the buffer is too small, the required size is returned.
+		if n > size {
+			size = n
+			continue
+		}
+
+		return splitMultiString(buf[:n]), nil
+	}
+}
@@ -0,0 +1,228 @@
+package kernel32
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownFlag       = errors.New("kernel32: unknown flag")
+	ErrInvalidProtection = errors.New("kernel32: invalid page protection")
+)
+
+/*
+	flagName names a flag, or a combination of flags, of a flags type.
+*/
+type flagName struct {
+	name  string
+	value uint32
+
+	/*
+		alias marks names that are accepted by parseFlags but never produced by
+		formatFlags: names sharing their value with an earlier name, and
+		combinations that are clearer as their parts.
+	*/
+	alias bool
+}
+
+/*
+	formatFlags decomposes v into the names of its flags joined by '|'.
+	Names are tried in order, so combinations listed before their parts are
+	preferred over them. Bits without a name are appended in hexadecimal.
+*/
+func formatFlags(v uint32, names []flagName) string {
+	if v == 0 {
+		return "0"
+	}
+
+	var parts []string
+	rest := v
+	for _, n := range names {
+		if n.alias || n.value == 0 || v&n.value != n.value || rest&n.value == 0 {
+			continue
+		}
+
+		parts = append(parts, n.name)
+		rest &^= n.value
+	}
+
+	if rest != 0 {
+		parts = append(parts, "0x"+strconv.FormatUint(uint64(rest), 16))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+/*
+	parseFlags parses names and numbers separated by '|', as produced by formatFlags.
+*/
+func parseFlags(s string, names []flagName) (uint32, error) {
+	var v uint32
+	for _, part := range strings.Split(s, "|") {
+		part = strings.TrimSpace(part)
+
+		n, ok := lookupFlag(part, names)
+		if !ok {
+			x, err := strconv.ParseUint(part, 0, 32)
+			if err != nil {
+				return 0, fmt.Errorf("%w %q", ErrUnknownFlag, part)
+			}
+			n = uint32(x)
+		}
+
+		v |= n
+	}
+
+	return v, nil
+}
+
+func lookupFlag(name string, names []flagName) (uint32, bool) {
+	for _, n := range names {
+		if n.name == name {
+			return n.value, true
+		}
+	}
+
+	return 0, false
+}
+
+var processAccessNames = []flagName{
+	{"PROCESS_ALL_ACCESS", uint32(PROCESS_ALL_ACCESS), false},
+	{"PROCESS_TERMINATE", uint32(PROCESS_TERMINATE), false},
+	{"PROCESS_CREATE_THREAD", uint32(PROCESS_CREATE_THREAD), false},
+	{"PROCESS_VM_OPERATION", uint32(PROCESS_VM_OPERATION), false},
+	{"PROCESS_VM_READ", uint32(PROCESS_VM_READ), false},
+	{"PROCESS_VM_WRITE", uint32(PROCESS_VM_WRITE), false},
+	{"PROCESS_DUP_HANDLE", uint32(PROCESS_DUP_HANDLE), false},
+	{"PROCESS_CREATE_PROCESS", uint32(PROCESS_CREATE_PROCESS), false},
+	{"PROCESS_SET_QUOTA", uint32(PROCESS_SET_QUOTA), false},
+	{"PROCESS_SET_INFORMATION", uint32(PROCESS_SET_INFORMATION), false},
+	{"PROCESS_QUERY_INFORMATION", uint32(PROCESS_QUERY_INFORMATION), false},
+	{"PROCESS_SUSPEND_RESUME", uint32(PROCESS_SUSPEND_RESUME), false},
+	{"PROCESS_QUERY_LIMITED_INFORMATION", uint32(PROCESS_QUERY_LIMITED_INFORMATION), false},
+	{"DELETE", uint32(DELETE), false},
+	{"READ_CONTROL", uint32(READ_CONTROL), false},
+	{"WRITE_DAC", uint32(WRITE_DAC), false},
+	{"WRITE_OWNER", uint32(WRITE_OWNER), false},
+	{"SYNCHRONIZE", uint32(SYNCHRONIZE), false},
+	{"STANDARD_RIGHTS_ALL", uint32(STANDARD_RIGHTS_ALL), true},
+	{"STANDARD_RIGHTS_REQUIRED", uint32(STANDARD_RIGHTS_REQUIRED), true},
+	{"STANDARD_RIGHTS_READ", uint32(STANDARD_RIGHTS_READ), true},
+	{"STANDARD_RIGHTS_WRITE", uint32(STANDARD_RIGHTS_WRITE), true},
+	{"STANDARD_RIGHTS_EXECUTE", uint32(STANDARD_RIGHTS_EXECUTE), true},
+}
+
+/*
+	String returns the names of the access rights joined by '|', such as
+	"PROCESS_VM_READ|PROCESS_QUERY_INFORMATION". PROCESS_ALL_ACCESS is used when all its rights are set.
+*/
+func (a ProcessAccess) String() string {
+	return formatFlags(uint32(a), processAccessNames)
+}
+
+/*
+	ParseProcessAccess parses access rights in the form returned by ProcessAccess.String.
+	The STANDARD_RIGHTS_* combinations and numbers such as 0x1000 are accepted as well.
+*/
+func ParseProcessAccess(s string) (ProcessAccess, error) {
+	v, err := parseFlags(s, processAccessNames)
+	return ProcessAccess(v), err
+}
+
+/*
+	pageProtections are the base protections, of which a protection has exactly one.
+*/
+const pageProtections = PAGE_NOACCESS | PAGE_READONLY | PAGE_READWRITE | PAGE_WRITECOPY |
+	PAGE_EXECUTE | PAGE_EXECUTE_READ | PAGE_EXECUTE_READWRITE | PAGE_EXECUTE_WRITECOPY
+
+const pageExecute = PAGE_EXECUTE | PAGE_EXECUTE_READ | PAGE_EXECUTE_READWRITE | PAGE_EXECUTE_WRITECOPY
+
+var pageAccessNames = []flagName{
+	{"PAGE_NOACCESS", uint32(PAGE_NOACCESS), false},
+	{"PAGE_READONLY", uint32(PAGE_READONLY), false},
+	{"PAGE_READWRITE", uint32(PAGE_READWRITE), false},
+	{"PAGE_WRITECOPY", uint32(PAGE_WRITECOPY), false},
+	{"PAGE_EXECUTE", uint32(PAGE_EXECUTE), false},
+	{"PAGE_EXECUTE_READ", uint32(PAGE_EXECUTE_READ), false},
+	{"PAGE_EXECUTE_READWRITE", uint32(PAGE_EXECUTE_READWRITE), false},
+	{"PAGE_EXECUTE_WRITECOPY", uint32(PAGE_EXECUTE_WRITECOPY), false},
+	{"PAGE_GUARD", uint32(PAGE_GUARD), false},
+	{"PAGE_NOCACHE", uint32(PAGE_NOCACHE), false},
+	{"PAGE_WRITECOMBINE", uint32(PAGE_WRITECOMBINE), false},
+	{"PAGE_ENCLAVE_DECOMMIT", uint32(PAGE_ENCLAVE_DECOMMIT), false},
+	{"PAGE_ENCLAVE_UNVALIDATED", uint32(PAGE_ENCLAVE_UNVALIDATED), false},
+	{"PAGE_TARGETS_INVALID", uint32(PAGE_TARGETS_INVALID), false},
+	{"PAGE_TARGETS_NO_UPDATE", uint32(PAGE_TARGETS_NO_UPDATE), true},
+	{"PAGE_ENCLAVE_THREAD_CONTROL", uint32(PAGE_ENCLAVE_THREAD_CONTROL), false},
+}
+
+/*
+	String returns the names of the protection flags joined by '|', such as "PAGE_READWRITE|PAGE_GUARD".
+	The bit shared by PAGE_TARGETS_INVALID and PAGE_TARGETS_NO_UPDATE is named PAGE_TARGETS_INVALID.
+*/
+func (p PageAccess) String() string {
+	return formatFlags(uint32(p), pageAccessNames)
+}
+
+/*
+	ParsePageAccess parses a protection in the form returned by PageAccess.String.
+	Numbers such as 0x40 are accepted as well. The result is not validated, see PageAccess.Validate.
+*/
+func ParsePageAccess(s string) (PageAccess, error) {
+	v, err := parseFlags(s, pageAccessNames)
+	return PageAccess(v), err
+}
+
+/*
+	Validate reports whether p is a protection that memory can be allocated or protected with:
+	exactly one base protection such as PAGE_READWRITE, modifiers that may be combined with it
+	and each other, and no unknown bits. PAGE_ENCLAVE_DECOMMIT is only valid on its own.
+
+	The error wraps ErrInvalidProtection.
+*/
+func (p PageAccess) Validate() error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %v: %s", ErrInvalidProtection, p, reason)
+	}
+
+	known := pageProtections | PAGE_GUARD | PAGE_NOCACHE | PAGE_WRITECOMBINE | PAGE_TARGETS_INVALID |
+		PAGE_ENCLAVE_DECOMMIT | PAGE_ENCLAVE_THREAD_CONTROL | PAGE_ENCLAVE_UNVALIDATED
+	if p&^known != 0 {
+		return invalid("unknown flags")
+	}
+
+	if p&PAGE_ENCLAVE_DECOMMIT != 0 {
+		if p != PAGE_ENCLAVE_DECOMMIT {
+			return invalid("PAGE_ENCLAVE_DECOMMIT cannot be combined with other flags")
+		}
+		return nil
+	}
+
+	base := p & pageProtections
+	switch {
+	case base == 0:
+		return invalid("no base protection")
+	case base&(base-1) != 0:
+		return invalid("more than one base protection")
+	}
+
+	if base == PAGE_NOACCESS && p&(PAGE_GUARD|PAGE_NOCACHE|PAGE_WRITECOMBINE) != 0 {
+		return invalid("PAGE_NOACCESS cannot be combined with PAGE_GUARD, PAGE_NOCACHE or PAGE_WRITECOMBINE")
+	}
+
+	if p&PAGE_NOCACHE != 0 && p&(PAGE_GUARD|PAGE_WRITECOMBINE) != 0 {
+		return invalid("PAGE_NOCACHE cannot be combined with PAGE_GUARD or PAGE_WRITECOMBINE")
+	}
+
+	if p&PAGE_WRITECOMBINE != 0 && p&PAGE_GUARD != 0 {
+		return invalid("PAGE_WRITECOMBINE cannot be combined with PAGE_GUARD")
+	}
+
+	if p&PAGE_TARGETS_INVALID != 0 && base&pageExecute == 0 {
+		return invalid("PAGE_TARGETS_INVALID and PAGE_TARGETS_NO_UPDATE require an executable protection")
+	}
+
+	return nil
+}
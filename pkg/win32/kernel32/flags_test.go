@@ -0,0 +1,185 @@
+package kernel32
+
+import (
+	"errors"
+	"testing"
+)
+
+var flagTables = []struct {
+	name  string
+	names []flagName
+}{
+	{"ProcessAccess", processAccessNames},
+	{"PageAccess", pageAccessNames},
+}
+
+/*
+	TestFlagNamesRoundTrip checks that every name formats and parses back to
+	itself. A name sharing its value with an earlier one without being marked
+	as an alias formats as the earlier name, so duplicate values fail here.
+*/
+func TestFlagNamesRoundTrip(t *testing.T) {
+	for _, table := range flagTables {
+		for _, n := range table.names {
+			v, err := parseFlags(n.name, table.names)
+			if err != nil || v != n.value {
+				t.Errorf("%s: parse %s: got %#x, %v; want %#x", table.name, n.name, v, err, n.value)
+			}
+
+			s := formatFlags(n.value, table.names)
+			if n.alias {
+				if s == n.name {
+					t.Errorf("%s: alias %s is produced by formatFlags", table.name, n.name)
+				}
+			} else if s != n.name {
+				t.Errorf("%s: %#x formats as %s, not %s; is it a duplicate value?", table.name, n.value, s, n.name)
+			}
+
+			if back, err := parseFlags(s, table.names); err != nil || back != n.value {
+				t.Errorf("%s: %s formatted as %s parses to %#x, %v", table.name, n.name, s, back, err)
+			}
+		}
+	}
+}
+
+/*
+	TestFlagsRoundTripBits round trips every single bit and pair of bits,
+	named or not.
+*/
+func TestFlagsRoundTripBits(t *testing.T) {
+	for _, table := range flagTables {
+		for i := 0; i < 32; i++ {
+			for j := i; j < 32; j++ {
+				v := uint32(1)<<i | uint32(1)<<j
+				s := formatFlags(v, table.names)
+				if back, err := parseFlags(s, table.names); err != nil || back != v {
+					t.Errorf("%s: %#x formatted as %s parses to %#x, %v", table.name, v, s, back, err)
+				}
+			}
+		}
+	}
+}
+
+func TestProcessAccessString(t *testing.T) {
+	tests := []struct {
+		access ProcessAccess
+		want   string
+	}{
+		{0, "0"},
+		{PROCESS_VM_READ, "PROCESS_VM_READ"},
+		{PROCESS_QUERY_INFORMATION | PROCESS_VM_READ, "PROCESS_VM_READ|PROCESS_QUERY_INFORMATION"},
+		{PROCESS_ALL_ACCESS, "PROCESS_ALL_ACCESS"},
+		{PROCESS_ALL_ACCESS | 0x01000000, "PROCESS_ALL_ACCESS|0x1000000"},
+		{STANDARD_RIGHTS_ALL, "DELETE|READ_CONTROL|WRITE_DAC|WRITE_OWNER|SYNCHRONIZE"},
+		{SYNCHRONIZE | 0x8000, "SYNCHRONIZE|0x8000"},
+		{0x8000, "0x8000"},
+	}
+
+	for _, tc := range tests {
+		if got := tc.access.String(); got != tc.want {
+			t.Errorf("%#x: got %s, want %s", uint32(tc.access), got, tc.want)
+		}
+
+		if back, err := ParseProcessAccess(tc.want); err != nil || back != tc.access {
+			t.Errorf("%s: parsed as %#x, %v", tc.want, uint32(back), err)
+		}
+	}
+}
+
+func TestParseProcessAccess(t *testing.T) {
+	tests := []struct {
+		s    string
+		want ProcessAccess
+	}{
+		{" PROCESS_VM_READ | 0x10 ", PROCESS_VM_READ},
+		{"16", PROCESS_VM_READ},
+		{"STANDARD_RIGHTS_REQUIRED|SYNCHRONIZE|0xFFF", PROCESS_ALL_ACCESS},
+		{"STANDARD_RIGHTS_READ", READ_CONTROL},
+		{"PROCESS_VM_READ|PROCESS_VM_READ", PROCESS_VM_READ},
+	}
+
+	for _, tc := range tests {
+		if got, err := ParseProcessAccess(tc.s); err != nil || got != tc.want {
+			t.Errorf("%q: got %v, %v; want %v", tc.s, got, err, tc.want)
+		}
+	}
+
+	for _, s := range []string{"", "process_vm_read", "PROCESS_VM_READ|", "PROCESS_FOO", "0x100000000", "-1"} {
+		if _, err := ParseProcessAccess(s); !errors.Is(err, ErrUnknownFlag) {
+			t.Errorf("%q: got %v, want ErrUnknownFlag", s, err)
+		}
+	}
+}
+
+func TestPageAccessString(t *testing.T) {
+	tests := []struct {
+		access PageAccess
+		want   string
+	}{
+		{PAGE_NOACCESS, "PAGE_NOACCESS"},
+		{PAGE_READWRITE | PAGE_GUARD, "PAGE_READWRITE|PAGE_GUARD"},
+		{PAGE_EXECUTE_READ | PAGE_TARGETS_INVALID, "PAGE_EXECUTE_READ|PAGE_TARGETS_INVALID"},
+		{PAGE_TARGETS_NO_UPDATE, "PAGE_TARGETS_INVALID"},
+		{PAGE_ENCLAVE_THREAD_CONTROL | PAGE_READWRITE, "PAGE_READWRITE|PAGE_ENCLAVE_THREAD_CONTROL"},
+		{PAGE_READONLY | 0x800, "PAGE_READONLY|0x800"},
+	}
+
+	for _, tc := range tests {
+		if got := tc.access.String(); got != tc.want {
+			t.Errorf("%#x: got %s, want %s", uint32(tc.access), got, tc.want)
+		}
+
+		if back, err := ParsePageAccess(tc.want); err != nil || back != tc.access {
+			t.Errorf("%s: parsed as %#x, %v", tc.want, uint32(back), err)
+		}
+	}
+
+	if got, err := ParsePageAccess("PAGE_EXECUTE_READ|PAGE_TARGETS_NO_UPDATE"); err != nil || got != PAGE_EXECUTE_READ|PAGE_TARGETS_INVALID {
+		t.Errorf("alias: got %v, %v", got, err)
+	}
+
+	if _, err := ParsePageAccess("PAGE_READ"); !errors.Is(err, ErrUnknownFlag) {
+		t.Errorf("got %v, want ErrUnknownFlag", err)
+	}
+}
+
+func TestPageAccessValidate(t *testing.T) {
+	valid := []PageAccess{
+		PAGE_NOACCESS,
+		PAGE_READONLY,
+		PAGE_READWRITE | PAGE_GUARD,
+		PAGE_READWRITE | PAGE_NOCACHE,
+		PAGE_EXECUTE_READWRITE | PAGE_WRITECOMBINE,
+		PAGE_EXECUTE_READ | PAGE_TARGETS_INVALID,
+		PAGE_EXECUTE | PAGE_TARGETS_NO_UPDATE | PAGE_GUARD,
+		PAGE_READWRITE | PAGE_ENCLAVE_THREAD_CONTROL,
+		PAGE_EXECUTE_READ | PAGE_ENCLAVE_UNVALIDATED,
+		PAGE_ENCLAVE_DECOMMIT,
+	}
+
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%v: %v", p, err)
+		}
+	}
+
+	invalid := []PageAccess{
+		0,
+		PAGE_GUARD,
+		PAGE_READONLY | PAGE_READWRITE,
+		PAGE_READWRITE | 0x800,
+		PAGE_NOACCESS | PAGE_GUARD,
+		PAGE_NOACCESS | PAGE_WRITECOMBINE,
+		PAGE_READWRITE | PAGE_NOCACHE | PAGE_GUARD,
+		PAGE_READWRITE | PAGE_NOCACHE | PAGE_WRITECOMBINE,
+		PAGE_READWRITE | PAGE_WRITECOMBINE | PAGE_GUARD,
+		PAGE_READWRITE | PAGE_TARGETS_INVALID,
+		PAGE_ENCLAVE_DECOMMIT | PAGE_READWRITE,
+	}
+
+	for _, p := range invalid {
+		if err := p.Validate(); !errors.Is(err, ErrInvalidProtection) {
+			t.Errorf("%v: got %v, want ErrInvalidProtection", p, err)
+		}
+	}
+}
@@ -98,3 +98,46 @@ func CloseHandle(handle win32.Handle) error {
 	}
 	return nil
 }
+
+type HandleFlags uint32
+
+const (
+	/*
+		HANDLE_FLAG_INHERIT makes the handle inherited by child processes created with inheritHandles set.
+	*/
+	HANDLE_FLAG_INHERIT HandleFlags = 0x00000001
+
+	/*
+		HANDLE_FLAG_PROTECT_FROM_CLOSE makes CloseHandle fail for the handle.
+	*/
+	HANDLE_FLAG_PROTECT_FROM_CLOSE HandleFlags = 0x00000002
+)
+
+/*
+	GetHandleInformation retrieves the properties of an object handle.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/handleapi/nf-handleapi-gethandleinformation
+*/
+func GetHandleInformation(handle win32.Handle) (HandleFlags, error) {
+	var flags C.DWORD
+	if C.GetHandleInformation(C.HANDLE(unsafe.Pointer(handle)), &flags) == 0 {
+		return 0, GetLastError()
+	}
+
+	return HandleFlags(flags), nil
+}
+
+/*
+	SetHandleInformation sets the properties in mask of an object handle to flags.
+	For example, it makes a handle inheritable before redirecting the standard
+	handles of a child process to it.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/handleapi/nf-handleapi-sethandleinformation
+*/
+func SetHandleInformation(handle win32.Handle, mask HandleFlags, flags HandleFlags) error {
+	if C.SetHandleInformation(C.HANDLE(unsafe.Pointer(handle)), C.DWORD(mask), C.DWORD(flags)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
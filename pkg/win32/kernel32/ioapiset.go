@@ -0,0 +1,90 @@
+package kernel32
+
+/*
+	#include <windows.h>
+	#include <ioapiset.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	INVALID_HANDLE_VALUE is the value of an invalid file handle.
+	Passing it to CreateIoCompletionPort creates a port not associated with a file.
+*/
+const INVALID_HANDLE_VALUE = ^win32.Handle(0)
+
+/*
+	CreateIoCompletionPort creates an I/O completion port, or associates a file handle with an
+	existing port. To create a port that is not associated with a file, pass INVALID_HANDLE_VALUE
+	as file and 0 as existingPort. concurrentThreads is the number of threads allowed to process
+	completion packets concurrently, or 0 for as many as there are processors.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/fileio/createiocompletionport
+*/
+func CreateIoCompletionPort(file win32.Handle, existingPort win32.Handle, completionKey uintptr, concurrentThreads uint32) (win32.Handle, error) {
+	port := C.CreateIoCompletionPort(C.HANDLE(unsafe.Pointer(file)), C.HANDLE(unsafe.Pointer(existingPort)), C.ULONG_PTR(completionKey), C.DWORD(concurrentThreads))
+	if port == nil {
+		return 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(port)), nil
+}
+
+/*
+	CompletionPacket is a packet dequeued from an I/O completion port.
+	For job object notifications, BytesTransferred holds the message and
+	Overlapped the message specific value, such as a process identifier.
+*/
+type CompletionPacket struct {
+	BytesTransferred uint32
+	CompletionKey    uintptr
+	Overlapped       uintptr
+}
+
+/*
+	GetQueuedCompletionStatus dequeues a completion packet from the specified port,
+	waiting up to milliseconds for one to arrive. Pass INFINITE to wait indefinitely.
+	When no packet arrives in time, the error is ErrorCode(WAIT_TIMEOUT), and once
+	the port is closed it is ERROR_ABANDONED_WAIT_0.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/ioapiset/nf-ioapiset-getqueuedcompletionstatus
+*/
+func GetQueuedCompletionStatus(port win32.Handle, milliseconds uint32) (CompletionPacket, error) {
+	var (
+		bytes      C.DWORD
+		key        C.ULONG_PTR
+		overlapped C.LPOVERLAPPED
+	)
+
+	ok := C.GetQueuedCompletionStatus(C.HANDLE(unsafe.Pointer(port)), &bytes, &key, &overlapped, C.DWORD(milliseconds))
+
+	packet := CompletionPacket{
+		BytesTransferred: uint32(bytes),
+		CompletionKey:    uintptr(key),
+		Overlapped:       uintptr(unsafe.Pointer(overlapped)),
+	}
+
+	if ok == 0 {
+		return packet, GetLastError()
+	}
+
+	return packet, nil
+}
+
+/*
+	PostQueuedCompletionStatus posts a completion packet to the specified port.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/fileio/postqueuedcompletionstatus
+*/
+func PostQueuedCompletionStatus(port win32.Handle, bytesTransferred uint32, completionKey uintptr, overlapped uintptr) error {
+	if C.PostQueuedCompletionStatus(C.HANDLE(unsafe.Pointer(port)), C.DWORD(bytesTransferred), C.ULONG_PTR(completionKey), C.LPOVERLAPPED(unsafe.Pointer(overlapped))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
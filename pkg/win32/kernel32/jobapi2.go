@@ -0,0 +1,359 @@
+package kernel32
+
+/*
+	#include <stdlib.h>
+	#include <windows.h>
+	#include <jobapi2.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	CreateJobObject creates a job object, or opens the existing job object with the given name.
+	If name is empty, the job object is unnamed. The handle is not inherited and has
+	JOB_OBJECT_ALL_ACCESS access.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-createjobobjectw
+*/
+func CreateJobObject(name string) (win32.Handle, error) {
+	n := wideString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	job := C.CreateJobObjectW(nil, n)
+	if job == nil {
+		return 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(job)), nil
+}
+
+/*
+	AssignProcessToJobObject assigns a process to a job object. Processes created
+	by the process afterwards are associated with the same job.
+
+	The process handle must have the PROCESS_SET_QUOTA and PROCESS_TERMINATE access rights.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-assignprocesstojobobject
+*/
+func AssignProcessToJobObject(job win32.Handle, process win32.Handle) error {
+	if C.AssignProcessToJobObject(C.HANDLE(unsafe.Pointer(job)), C.HANDLE(unsafe.Pointer(process))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	TerminateJobObject terminates all processes associated with a job object.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-terminatejobobject
+*/
+func TerminateJobObject(job win32.Handle, exitCode uint32) error {
+	if C.TerminateJobObject(C.HANDLE(unsafe.Pointer(job)), C.UINT(exitCode)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	IsProcessInJob determines whether the process is running in the specified job.
+	If job is 0, it determines whether the process is running in any job.
+
+	The process handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/jobapi/nf-jobapi-isprocessinjob
+*/
+func IsProcessInJob(process win32.Handle, job win32.Handle) (bool, error) {
+	var result C.BOOL
+	if C.IsProcessInJob(C.HANDLE(unsafe.Pointer(process)), C.HANDLE(unsafe.Pointer(job)), &result) == 0 {
+		return false, GetLastError()
+	}
+
+	return result != 0, nil
+}
+
+type JobObjectInfoClass uint32
+
+const (
+	JobObjectBasicAccountingInformation         JobObjectInfoClass = 1
+	JobObjectBasicLimitInformation              JobObjectInfoClass = 2
+	JobObjectBasicProcessIdList                 JobObjectInfoClass = 3
+	JobObjectBasicUIRestrictions                JobObjectInfoClass = 4
+	JobObjectEndOfJobTimeInformation            JobObjectInfoClass = 6
+	JobObjectAssociateCompletionPortInformation JobObjectInfoClass = 7
+	JobObjectBasicAndIoAccountingInformation    JobObjectInfoClass = 8
+	JobObjectExtendedLimitInformation           JobObjectInfoClass = 9
+	JobObjectGroupInformation                   JobObjectInfoClass = 11
+	JobObjectNotificationLimitInformation       JobObjectInfoClass = 12
+	JobObjectLimitViolationInformation          JobObjectInfoClass = 13
+	JobObjectCpuRateControlInformation          JobObjectInfoClass = 15
+)
+
+/*
+	SetInformationJobObject sets limits or other information of a job object.
+	info points to the structure that class selects, of length bytes.
+	Prefer the typed SetJobObject functions.
+
+	The handle must have the JOB_OBJECT_SET_ATTRIBUTES access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-setinformationjobobject
+*/
+func SetInformationJobObject(job win32.Handle, class JobObjectInfoClass, info unsafe.Pointer, length uint32) error {
+	if C.SetInformationJobObject(C.HANDLE(unsafe.Pointer(job)), C.JOBOBJECTINFOCLASS(class), C.LPVOID(info), C.DWORD(length)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	QueryInformationJobObject retrieves limits or accounting information of a job object
+	into the structure that class selects, and returns the number of bytes written.
+	Prefer the typed QueryJobObject functions.
+
+	The handle must have the JOB_OBJECT_QUERY access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-queryinformationjobobject
+*/
+func QueryInformationJobObject(job win32.Handle, class JobObjectInfoClass, info unsafe.Pointer, length uint32) (uint32, error) {
+	var returned C.DWORD
+	if C.QueryInformationJobObject(C.HANDLE(unsafe.Pointer(job)), C.JOBOBJECTINFOCLASS(class), C.LPVOID(info), C.DWORD(length), &returned) == 0 {
+		return uint32(returned), GetLastError()
+	}
+
+	return uint32(returned), nil
+}
+
+type JobObjectLimitFlags uint32
+
+const (
+	JOB_OBJECT_LIMIT_WORKINGSET                 JobObjectLimitFlags = 0x00000001
+	JOB_OBJECT_LIMIT_PROCESS_TIME               JobObjectLimitFlags = 0x00000002
+	JOB_OBJECT_LIMIT_JOB_TIME                   JobObjectLimitFlags = 0x00000004
+	JOB_OBJECT_LIMIT_ACTIVE_PROCESS             JobObjectLimitFlags = 0x00000008
+	JOB_OBJECT_LIMIT_AFFINITY                   JobObjectLimitFlags = 0x00000010
+	JOB_OBJECT_LIMIT_PRIORITY_CLASS             JobObjectLimitFlags = 0x00000020
+	JOB_OBJECT_LIMIT_PRESERVE_JOB_TIME          JobObjectLimitFlags = 0x00000040
+	JOB_OBJECT_LIMIT_SCHEDULING_CLASS           JobObjectLimitFlags = 0x00000080
+	JOB_OBJECT_LIMIT_PROCESS_MEMORY             JobObjectLimitFlags = 0x00000100
+	JOB_OBJECT_LIMIT_JOB_MEMORY                 JobObjectLimitFlags = 0x00000200
+	JOB_OBJECT_LIMIT_DIE_ON_UNHANDLED_EXCEPTION JobObjectLimitFlags = 0x00000400
+	JOB_OBJECT_LIMIT_BREAKAWAY_OK               JobObjectLimitFlags = 0x00000800
+	JOB_OBJECT_LIMIT_SILENT_BREAKAWAY_OK        JobObjectLimitFlags = 0x00001000
+
+	/*
+		JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE terminates all processes of the job
+		when the last handle to the job is closed.
+	*/
+	JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE JobObjectLimitFlags = 0x00002000
+	JOB_OBJECT_LIMIT_SUBSET_AFFINITY   JobObjectLimitFlags = 0x00004000
+)
+
+/*
+	JobBasicLimitInformation mirrors JOBOBJECT_BASIC_LIMIT_INFORMATION.
+	Times are in 100-nanosecond intervals. Only the limits selected by LimitFlags apply.
+*/
+type JobBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              JobObjectLimitFlags
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           PriorityClass
+	SchedulingClass         uint32
+}
+
+/*
+	JobExtendedLimitInformation mirrors JOBOBJECT_EXTENDED_LIMIT_INFORMATION.
+	ProcessMemoryLimit and JobMemoryLimit are limits on committed memory in bytes.
+	IoInfo and the peak values are only filled in by queries.
+*/
+type JobExtendedLimitInformation struct {
+	BasicLimitInformation JobBasicLimitInformation
+
+	// JOBOBJECT_BASIC_LIMIT_INFORMATION is 8 byte aligned in C, which adds tail padding on 32-bit Windows.
+	_ [8 - unsafe.Sizeof(uintptr(0))]byte
+
+	IoInfo                IoCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+/*
+	SetJobObjectExtendedLimitInformation sets the limits of a job object, replacing all previous basic
+	and extended limits. It is the function to use for JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE and memory limits.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_extended_limit_information
+*/
+func SetJobObjectExtendedLimitInformation(job win32.Handle, info *JobExtendedLimitInformation) error {
+	return SetInformationJobObject(job, JobObjectExtendedLimitInformation, unsafe.Pointer(info), uint32(unsafe.Sizeof(*info)))
+}
+
+/*
+	QueryJobObjectExtendedLimitInformation retrieves the limits of a job object and its peak memory usage.
+*/
+func QueryJobObjectExtendedLimitInformation(job win32.Handle) (*JobExtendedLimitInformation, error) {
+	var info JobExtendedLimitInformation
+	if _, err := QueryInformationJobObject(job, JobObjectExtendedLimitInformation, unsafe.Pointer(&info), uint32(unsafe.Sizeof(info))); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+type JobObjectCpuRateControlFlags uint32
+
+const (
+	JOB_OBJECT_CPU_RATE_CONTROL_ENABLE       JobObjectCpuRateControlFlags = 0x1
+	JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED JobObjectCpuRateControlFlags = 0x2
+	JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP     JobObjectCpuRateControlFlags = 0x4
+	JOB_OBJECT_CPU_RATE_CONTROL_NOTIFY       JobObjectCpuRateControlFlags = 0x8
+	JOB_OBJECT_CPU_RATE_CONTROL_MIN_MAX_RATE JobObjectCpuRateControlFlags = 0x10
+)
+
+/*
+	JobCpuRateControlInformation mirrors JOBOBJECT_CPU_RATE_CONTROL_INFORMATION.
+
+	Rates are in hundredths of a percent of the processor cycles of the system, from 1 to 10000.
+	Rate is interpreted according to ControlFlags: as the CPU rate, as a weight from 1 to 9 with
+	JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED, or as the minimum rate in the low word and the
+	maximum rate in the high word with JOB_OBJECT_CPU_RATE_CONTROL_MIN_MAX_RATE.
+*/
+type JobCpuRateControlInformation struct {
+	ControlFlags JobObjectCpuRateControlFlags
+	Rate         uint32
+}
+
+/*
+	MinMaxRate returns the Rate for JOB_OBJECT_CPU_RATE_CONTROL_MIN_MAX_RATE.
+*/
+func MinMaxRate(min uint16, max uint16) uint32 {
+	return uint32(min) | uint32(max)<<16
+}
+
+/*
+	SetJobObjectCpuRateControlInformation sets the CPU rate control of a job object.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_cpu_rate_control_information
+*/
+func SetJobObjectCpuRateControlInformation(job win32.Handle, info *JobCpuRateControlInformation) error {
+	return SetInformationJobObject(job, JobObjectCpuRateControlInformation, unsafe.Pointer(info), uint32(unsafe.Sizeof(*info)))
+}
+
+type JobObjectUILimit uint32
+
+const (
+	JOB_OBJECT_UILIMIT_HANDLES          JobObjectUILimit = 0x00000001
+	JOB_OBJECT_UILIMIT_READCLIPBOARD    JobObjectUILimit = 0x00000002
+	JOB_OBJECT_UILIMIT_WRITECLIPBOARD   JobObjectUILimit = 0x00000004
+	JOB_OBJECT_UILIMIT_SYSTEMPARAMETERS JobObjectUILimit = 0x00000008
+	JOB_OBJECT_UILIMIT_DISPLAYSETTINGS  JobObjectUILimit = 0x00000010
+	JOB_OBJECT_UILIMIT_GLOBALATOMS      JobObjectUILimit = 0x00000020
+	JOB_OBJECT_UILIMIT_DESKTOP          JobObjectUILimit = 0x00000040
+	JOB_OBJECT_UILIMIT_EXITWINDOWS      JobObjectUILimit = 0x00000080
+
+	JOB_OBJECT_UILIMIT_ALL JobObjectUILimit = 0x000000FF
+)
+
+/*
+	SetJobObjectBasicUIRestrictions restricts the user interface operations of the processes of a job object.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_basic_ui_restrictions
+*/
+func SetJobObjectBasicUIRestrictions(job win32.Handle, restrictions JobObjectUILimit) error {
+	return SetInformationJobObject(job, JobObjectBasicUIRestrictions, unsafe.Pointer(&restrictions), uint32(unsafe.Sizeof(restrictions)))
+}
+
+/*
+	SetJobObjectAssociateCompletionPort associates a completion port with a job object.
+	Notifications about the job are posted to the port with the given completion key,
+	the message in the number of bytes transferred and the process identifier, if any,
+	in the overlapped pointer. A job can only be associated with a single port.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_associate_completion_port
+*/
+func SetJobObjectAssociateCompletionPort(job win32.Handle, port win32.Handle, completionKey uintptr) error {
+	info := struct {
+		CompletionKey  uintptr
+		CompletionPort win32.Handle
+	}{completionKey, port}
+
+	return SetInformationJobObject(job, JobObjectAssociateCompletionPortInformation, unsafe.Pointer(&info), uint32(unsafe.Sizeof(info)))
+}
+
+/*
+	JobBasicAccountingInformation mirrors JOBOBJECT_BASIC_ACCOUNTING_INFORMATION.
+	Times are in 100-nanosecond intervals and include processes that already exited.
+*/
+type JobBasicAccountingInformation struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+}
+
+/*
+	JobBasicAndIoAccountingInformation mirrors JOBOBJECT_BASIC_AND_IO_ACCOUNTING_INFORMATION.
+*/
+type JobBasicAndIoAccountingInformation struct {
+	BasicInfo JobBasicAccountingInformation
+	IoInfo    IoCounters
+}
+
+/*
+	QueryJobObjectAccountingInformation retrieves the CPU time and I/O accounting of a job object.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_basic_and_io_accounting_information
+*/
+func QueryJobObjectAccountingInformation(job win32.Handle) (*JobBasicAndIoAccountingInformation, error) {
+	var info JobBasicAndIoAccountingInformation
+	if _, err := QueryInformationJobObject(job, JobObjectBasicAndIoAccountingInformation, unsafe.Pointer(&info), uint32(unsafe.Sizeof(info))); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+/*
+	QueryJobObjectProcessIds retrieves the identifiers of the active processes of a job object.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_basic_process_id_list
+*/
+func QueryJobObjectProcessIds(job win32.Handle) ([]uint32, error) {
+	for count := 64; ; count *= 2 {
+		// JOBOBJECT_BASIC_PROCESS_ID_LIST: two DWORD counts followed by pointer sized identifiers.
+		first := 8 / int(unsafe.Sizeof(uintptr(0)))
+		buf := make([]uintptr, first+count)
+		header := (*[2]uint32)(unsafe.Pointer(&buf[0]))
+
+		_, err := QueryInformationJobObject(job, JobObjectBasicProcessIdList, unsafe.Pointer(&buf[0]), uint32(len(buf))*uint32(unsafe.Sizeof(buf[0])))
+		if errors.Is(err, ERROR_MORE_DATA) && count < 1<<20 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make([]uint32, header[1])
+		for i := range ids {
+			ids[i] = uint32(buf[first+i])
+		}
+
+		return ids, nil
+	}
+}
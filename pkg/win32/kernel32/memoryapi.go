@@ -125,210 +125,123 @@ const (
 	MEM_WRITE_WATCH AllocType = 0x00200000
 )
 
-type PageAccess uint32
-
-const (
-	/*
-		PAGE_EXECUTE enables execute access to the committed region of pages.
-		An attempt to write to the committed region results in an access violation.
-		This flag is not supported by the CreateFileMapping function.
-	*/
-	PAGE_EXECUTE PageAccess = 0x10
-
-	/*
-		PAGE_EXECUTE_READ Enables execute or read-only access to
-		the committed region of pages. An attempt to write to
-		the committed region results in an access violation.
-
-		Windows Server 2003 and Windows XP: This attribute
-		is not supported by the CreateFileMapping function
-		until Windows XP with SP2 and Windows Server 2003 with SP1.
-	*/
-	PAGE_EXECUTE_READ PageAccess = 0x20
+/*
+	VirtualAlloc reserves, commits, or changes the state of a region of
+	pages in the virtual address space of the calling process.
+	Memory allocated by this function is automatically initialized to zero.
 
-	/*
-		PAGE_EXECUTE_READWRITE enables execute, read-only, or read/write
-		access to the committed region of pages.
+	To allocate memory in the address space of another process, use the VirtualAllocEx function
 
-		Windows Server 2003 and Windows XP: This attribute is
-		not supported by the CreateFileMapping function until
-		Windows XP with SP2 and Windows Server 2003 with SP1.
-	*/
-	PAGE_EXECUTE_READWRITE PageAccess = 0x40
+	If the function succeeds, the return value is the base address of the allocated region of pages.
 
-	/*
-		PAGE_EXECUTE_WRITECOPY enables execute, read-only, or copy-on-write
-		access to a mapped view of a file mapping object. An attempt to
-		write to a committed copy-on-write page results in a private
-		copy of the page being made for the process. The private page is
-		marked as PAGE_EXECUTE_READWRITE, and the change is written to the new page.
-
-		This flag is not supported by the VirtualAlloc or VirtualAllocEx functions.
-		Windows Vista, Windows Server 2003 and Windows XP:
-		This attribute is not supported by the CreateFileMapping function until
-		Windows Vista with SP1 and Windows Server 2008.
-	*/
-	PAGE_EXECUTE_WRITECOPY PageAccess = 0x80
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualalloc
+*/
+func VirtualAlloc(addr uintptr, size uintptr, allocType AllocType, flProtect PageAccess) (uintptr, error) {
+	baseAddr := C.VirtualAlloc(C.LPVOID(addr), C.SIZE_T(size), C.DWORD(allocType), C.DWORD(flProtect))
+	if baseAddr == nil {
+		return 0, GetLastError()
+	}
 
-	/*
-		PAGE_NOACCESS disables all access to the committed region of pages.
-		An attempt to read from, write to, or execute the committed
-		region results in an access violation.
+	return uintptr(baseAddr), nil
+}
 
-		This flag is not supported by the CreateFileMapping function.
-	*/
-	PAGE_NOACCESS PageAccess = 0x01
+/*
+	VirtualAllocEx reserves, commits, or changes the state of a region
+	of memory within the virtual address space of a specified process.
+	The function initializes the memory it allocates to zero.
 
-	/*
-		PAGE_READONLY enables read-only access to the committed region of pages.
-		An attempt to write to the committed region results in an access violation.
-		If Data Execution Prevention is enabled, an attempt to execute code
-		in the committed region results in an access violation.
-	*/
-	PAGE_READONLY PageAccess = 0x02
+	If the function succeeds, the return value is the base address of the allocated region of pages.
 
-	/*
-		PAGE_READWRITE enables read-only or read/write access to the
-		committed region of pages. If Data Execution Prevention is
-		enabled, attempting to execute code in the committed
-		region results in an access violation.
-	*/
-	PAGE_READWRITE PageAccess = 0x04
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualallocex
+*/
+func VirtualAllocEx(ph win32.Handle, baseAddr uintptr, size uintptr, allocType AllocType, flProtect PageAccess) (uintptr, error) {
+	addr := C.VirtualAllocEx(C.HANDLE(unsafe.Pointer(ph)), C.LPVOID(baseAddr), C.SIZE_T(size), C.DWORD(allocType), C.DWORD(flProtect))
+	if addr == nil {
+		return 0, GetLastError()
+	}
 
-	/*
-		PAGE_WRITECOPY enables read-only or copy-on-write access to a
-		mapped view of a file mapping object. An attempt to write to a
-		committed copy-on-write page results in a private copy of the
-		page being made for the process. The private page is marked as
-		PAGE_READWRITE, and the change is written to the new page.
-		If Data Execution Prevention is enabled, attempting to execute
-		code in the committed region results in an access violation.
-
-		This flag is not supported by the VirtualAlloc or VirtualAllocEx functions.
-	*/
-	PAGE_WRITECOPY PageAccess = 0x08
+	return uintptr(addr), nil
+}
 
-	/*
-		PAGE_TARGETS_INVALID sets all locations in the pages as invalid targets for CFG.
-		Used along with any execute page protection like PAGE_EXECUTE,
-		PAGE_EXECUTE_READ, PAGE_EXECUTE_READWRITE and PAGE_EXECUTE_WRITECOPY.
-		Any indirect call to locations in those pages will fail CFG checks and
-		the process will be terminated. The default behavior for executable
-		pages allocated is to be marked valid call targets for CFG.
-
-		This flag is not supported by the VirtualProtect or CreateFileMapping functions.
-	*/
-	PAGE_TARGETS_INVALID PageAccess = 0x40000000
+type FreeType uint32
 
+const (
 	/*
-		PAGE_TARGETS_NO_UPDATE pages in the region will not have their CFG information
-		updated while the protection changes for VirtualProtect. For example, if the
-		pages in the region was allocated using PAGE_TARGETS_INVALID, then the invalid
-		information will be maintained while the page protection changes.
-		This flag is only valid when the protection changes to an executable type like
-		PAGE_EXECUTE, PAGE_EXECUTE_READ, PAGE_EXECUTE_READWRITE and PAGE_EXECUTE_WRITECOPY.
-		The default behavior for VirtualProtect protection change to executable
-		is to mark all locations as valid call targets for CFG.
+		MEM_DECOMMIT decommits the specified region of committed pages.
+		After the operation, the pages are in the reserved state.
 	*/
-	PAGE_TARGETS_NO_UPDATE PageAccess = 0x40000000
+	MEM_DECOMMIT FreeType = 0x00004000
 
 	/*
-		PAGE_GUARD pages in the region become guard pages. Any attempt to access a
-		guard page causes the system to raise a STATUS_GUARD_PAGE_VIOLATION exception
-		and turn off the guard page status. Guard pages thus act as a one-time access
-		alarm. For more information, see Creating Guard Pages. When an access attempt
-		leads the system to turn off guard page status, the underlying page protection takes over.
-		If a guard page exception occurs during a system service, the service typically
-		returns a failure status indicator. This value cannot be used with PAGE_NOACCESS.
-
-		This flag is not supported by the CreateFileMapping function.
+		MEM_RELEASE releases the specified region of pages. After the operation, the pages are in the free state.
+		The size must be 0 and the address must be the base address returned by VirtualAllocEx.
 	*/
-	PAGE_GUARD PageAccess = 0x100
+	MEM_RELEASE FreeType = 0x00008000
+)
 
-	/*
-		PAGE_NOCACHE Sets all pages to be non-cachable. Applications should not use
-		this attribute except when explicitly required for a device. Using the interlocked
-		functions with memory that is mapped with SEC_NOCACHE can result in an EXCEPTION_ILLEGAL_INSTRUCTION exception.
-		The PAGE_NOCACHE flag cannot be used with the PAGE_GUARD, PAGE_NOACCESS, or PAGE_WRITECOMBINE flags.
-		The PAGE_NOCACHE flag can be used only when allocating private memory with the VirtualAlloc,
-		VirtualAllocEx, or VirtualAllocExNuma functions. To enable non-cached memory
-		access for shared memory, specify the SEC_NOCACHE flag when calling the CreateFileMapping function.
-	*/
-	PAGE_NOCACHE PageAccess = 0x200
+/*
+	VirtualFreeEx releases, decommits, or releases and decommits a region
+	of memory within the virtual address space of a specified process.
+	The handle must have the PROCESS_VM_OPERATION access right.
 
-	/*
-		PAGE_WRITECOMBINE Sets all pages to be write-combined.
-		Applications should not use this attribute except when
-		explicitly required for a device. Using the interlocked
-		functions with memory that is mapped as write-combined
-		can result in an EXCEPTION_ILLEGAL_INSTRUCTION exception.
-
-		The PAGE_WRITECOMBINE flag cannot be specified with the
-		PAGE_NOACCESS, PAGE_GUARD, and PAGE_NOCACHE flags.
-		The PAGE_WRITECOMBINE flag can be used only when allocating
-		private memory with the VirtualAlloc, VirtualAllocEx,
-		or VirtualAllocExNuma functions. To enable write-combined
-		memory access for shared memory, specify the SEC_WRITECOMBINE
-		flag when calling the CreateFileMapping function.
-	*/
-	PAGE_WRITECOMBINE PageAccess = 0x400
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualfreeex
+*/
+func VirtualFreeEx(ph win32.Handle, addr uintptr, size uintptr, freeType FreeType) error {
+	if C.VirtualFreeEx(C.HANDLE(unsafe.Pointer(ph)), C.LPVOID(addr), C.SIZE_T(size), C.DWORD(freeType)) == 0 {
+		return GetLastError()
+	}
 
-	/*
-		PAGE_ENCLAVE_DECOMMIT indicates that the page will be
-		protected to prevent further use in an enclave.
-		This flag must not be combined with any other flags.
-		This flag is only valid for SGX2 enclaves.
-	*/
-	PAGE_ENCLAVE_DECOMMIT PageAccess = 0x80000000
+	return nil
+}
 
-	/*
-		PAGE_ENCLAVE_THREAD_CONTROL The page contains a thread control structure (TCS).
-	*/
-	PAGE_ENCLAVE_THREAD_CONTROL PageAccess = 0x80000000
+/*
+	State and type of the pages of a region, as found in MemoryBasicInformation.
+	A committed region has the State MEM_COMMIT and a reserved one MEM_RESERVE.
+*/
+const (
+	MEM_FREE uint32 = 0x00010000
 
-	/*
-		PAGE_ENCLAVE_UNVALIDATED The page contents that you supply
-		are excluded from measurement with the EEXTEND instruction
-		of the Intel SGX programming model.
-	*/
-	PAGE_ENCLAVE_UNVALIDATED PageAccess = 0x80000000
+	MEM_IMAGE   uint32 = 0x01000000
+	MEM_MAPPED  uint32 = 0x00040000
+	MEM_PRIVATE uint32 = 0x00020000
 )
 
 /*
-	VirtualAlloc reserves, commits, or changes the state of a region of
-	pages in the virtual address space of the calling process.
-	Memory allocated by this function is automatically initialized to zero.
-
-	To allocate memory in the address space of another process, use the VirtualAllocEx function
-
-	If the function succeeds, the return value is the base address of the allocated region of pages.
-
-	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualalloc
+	MemoryBasicInformation contains information about a range of pages in
+	the virtual address space of a process (MEMORY_BASIC_INFORMATION).
 */
-func VirtualAlloc(addr uintptr, size uintptr, allocType AllocType, flProtect PageAccess) (uintptr, error) {
-	var baseAddr uintptr
-	if C.VirtualAlloc(C.LPVOID(addr), C.SIZE_T(size), C.DWORD(allocType), C.DWORD(flProtect)) == nil {
-		return baseAddr, GetLastError()
-	}
-
-	return baseAddr, nil
+type MemoryBasicInformation struct {
+	BaseAddress       uintptr
+	AllocationBase    uintptr
+	AllocationProtect PageAccess
+	RegionSize        uintptr
+	State             uint32
+	Protect           PageAccess
+	Type              uint32
 }
 
 /*
-	VirtualAllocEx reserves, commits, or changes the state of a region
-	of memory within the virtual address space of a specified process.
-	The function initializes the memory it allocates to zero.
+	VirtualQueryEx retrieves information about the range of pages
+	containing addr within the virtual address space of a specified process.
+	The handle must have the PROCESS_QUERY_INFORMATION access right.
 
-	If the function succeeds, the return value is the base address of the allocated region of pages.
-
-	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualallocex
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualqueryex
 */
-func VirtualAllocEx(ph win32.Handle, baseAddr uintptr, size uintptr, allocType AllocType, flProtect PageAccess) (uintptr, error) {
-	if C.VirtualAllocEx(C.HANDLE(unsafe.Pointer(ph)), C.LPVOID(baseAddr), C.SIZE_T(size), C.DWORD(allocType), C.DWORD(flProtect)) == nil {
-		return baseAddr, GetLastError()
+func VirtualQueryEx(ph win32.Handle, addr uintptr) (MemoryBasicInformation, error) {
+	var mbi C.MEMORY_BASIC_INFORMATION
+	if C.VirtualQueryEx(C.HANDLE(unsafe.Pointer(ph)), C.LPCVOID(addr), &mbi, C.SIZE_T(unsafe.Sizeof(mbi))) == 0 {
+		return MemoryBasicInformation{}, GetLastError()
 	}
 
-	return baseAddr, nil
+	return MemoryBasicInformation{
+		BaseAddress:       uintptr(mbi.BaseAddress),
+		AllocationBase:    uintptr(mbi.AllocationBase),
+		AllocationProtect: PageAccess(mbi.AllocationProtect),
+		RegionSize:        uintptr(mbi.RegionSize),
+		State:             uint32(mbi.State),
+		Protect:           PageAccess(mbi.Protect),
+		Type:              uint32(mbi.Type),
+	}, nil
 }
 
 /*
@@ -373,3 +286,20 @@ func WriteProcessMemory[T any](ph win32.Handle, baseAddr uintptr, src *T, size u
 
 	return bytesWritten, nil
 }
+
+/*
+	VirtualProtectEx changes the protection on a region of committed pages
+	in the virtual address space of a specified process, returning the
+	previous protection of the first page of the region.
+	The handle must have the PROCESS_VM_OPERATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/memoryapi/nf-memoryapi-virtualprotectex
+*/
+func VirtualProtectEx(ph win32.Handle, addr uintptr, size uintptr, newProtect PageAccess) (PageAccess, error) {
+	var old C.DWORD
+	if C.VirtualProtectEx(C.HANDLE(unsafe.Pointer(ph)), C.LPVOID(addr), C.SIZE_T(size), C.DWORD(newProtect), &old) == 0 {
+		return 0, GetLastError()
+	}
+
+	return PageAccess(old), nil
+}
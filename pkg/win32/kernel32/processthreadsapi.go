@@ -1,174 +1,1055 @@
 package kernel32
 
 /*
+	#include <stdlib.h>
+	#include <string.h>
 	#include <processthreadsapi.h>
 */
 import "C"
 
 import (
+	"errors"
+	"unicode/utf16"
 	"unsafe"
 
 	"github.com/warrenulrich/win32-go/pkg/win32"
 )
 
-type ProcessAccess uint32
+type ThreadAccess uint32
 
 const (
 	/*
-		DELETE is the right to delete the object.
+		THREAD_TERMINATE is required to terminate a thread using TerminateThread.
 	*/
-	DELETE ProcessAccess = 0x00010000
+	THREAD_TERMINATE ThreadAccess = 0x0001
 
 	/*
-		SYNCHRONIZE is the right to use the object for synchronization.
-		This enables a thread to wait until the object is in the signaled state.
-		Some object types do not support this access right.
+		THREAD_SUSPEND_RESUME is required to suspend or resume a thread (see SuspendThread and ResumeThread).
 	*/
-	SYNCHRONIZE ProcessAccess = 0x00100000
+	THREAD_SUSPEND_RESUME ThreadAccess = 0x0002
 
 	/*
-		READ_CONTROL is the right to read the information in the object's security descriptor,
-		not including the information in the system access control list (SACL).
+		THREAD_GET_CONTEXT is required to read the context of a thread using GetThreadContext.
 	*/
-	READ_CONTROL ProcessAccess = 0x00020000
+	THREAD_GET_CONTEXT ThreadAccess = 0x0008
 
 	/*
-		WRITE_DAC is the right to modify the discretionary access control list (DACL) in the object's security descriptor.
+		THREAD_SET_CONTEXT is required to write the context of a thread using SetThreadContext.
 	*/
-	WRITE_DAC ProcessAccess = 0x00040000
+	THREAD_SET_CONTEXT ThreadAccess = 0x0010
 
 	/*
-		WRITE_OWNER is the right to change the owner in the object's security descriptor.
+		THREAD_SET_INFORMATION is required to set certain information in the thread object.
 	*/
-	WRITE_OWNER ProcessAccess = 0x00080000
+	THREAD_SET_INFORMATION ThreadAccess = 0x0020
 
 	/*
-		STANDARD_RIGHTS_ALL combines DELETE, READ_CONTROL, WRITE_DAC, WRITE_OWNER, and SYNCHRONIZE access.
+		THREAD_QUERY_INFORMATION is required to read certain information from the thread object,
+		such as the exit code (see GetExitCodeThread).
 	*/
-	STANDARD_RIGHTS_ALL ProcessAccess = DELETE | READ_CONTROL | WRITE_DAC | WRITE_OWNER | SYNCHRONIZE
+	THREAD_QUERY_INFORMATION ThreadAccess = 0x0040
 
 	/*
-		STANDARD_RIGHTS_EXECUTE is currently defined to equal READ_CONTROL.
+		THREAD_SET_THREAD_TOKEN is required to set the impersonation token for a thread using SetThreadToken.
 	*/
-	STANDARD_RIGHTS_EXECUTE ProcessAccess = READ_CONTROL
+	THREAD_SET_THREAD_TOKEN ThreadAccess = 0x0080
 
 	/*
-		STANDARD_RIGHTS_READ is currently defined to equal READ_CONTROL.
+		THREAD_IMPERSONATE is required to use a thread's security information directly without
+		calling it by using a communication mechanism that provides impersonation services.
 	*/
-	STANDARD_RIGHTS_READ ProcessAccess = READ_CONTROL
+	THREAD_IMPERSONATE ThreadAccess = 0x0100
 
 	/*
-		STANDARD_RIGHTS_REQUIRED combines DELETE, READ_CONTROL, WRITE_DAC, and WRITE_OWNER access.
+		THREAD_DIRECT_IMPERSONATION is required for a server thread that impersonates a client.
 	*/
-	STANDARD_RIGHTS_REQUIRED ProcessAccess = DELETE | READ_CONTROL | WRITE_DAC | WRITE_OWNER
+	THREAD_DIRECT_IMPERSONATION ThreadAccess = 0x0200
 
 	/*
-		STANDARD_RIGHTS_WRITE is currently defined to equal READ_CONTROL.
+		THREAD_SET_LIMITED_INFORMATION is required to set certain information in the thread object.
+		A handle that has the THREAD_SET_INFORMATION access right is automatically granted THREAD_SET_LIMITED_INFORMATION.
 	*/
-	STANDARD_RIGHTS_WRITE ProcessAccess = READ_CONTROL
+	THREAD_SET_LIMITED_INFORMATION ThreadAccess = 0x0400
 
 	/*
-		PROCESS_ALL_ACCESS is all possible access rights for a process object.Windows Server 2003 and Windows XP:
-		The size of the PROCESS_ALL_ACCESS flag increased on Windows Server 2008 and Windows Vista.
-		If an application compiled for Windows Server 2008 and Windows Vista is run on Windows Server 2003 or Windows XP,
-		the PROCESS_ALL_ACCESS flag is too large and the function specifying this flag fails with ERROR_ACCESS_DENIED.
-		To avoid this problem, specify the minimum set of access rights required for the operation.
-		If PROCESS_ALL_ACCESS must be used, set _WIN32_WINNT to the minimum operating system targeted by your application
-		(for example, #define _WIN32_WINNT _WIN32_WINNT_WINXP).
-		For more information, see Using the Windows Headers.
+		THREAD_QUERY_LIMITED_INFORMATION is required to read certain information from the thread objects.
+		A handle that has the THREAD_QUERY_INFORMATION access right is automatically granted THREAD_QUERY_LIMITED_INFORMATION.
 	*/
-	PROCESS_ALL_ACCESS ProcessAccess = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0xFFF
+	THREAD_QUERY_LIMITED_INFORMATION ThreadAccess = 0x0800
 
 	/*
-		PROCESS_CREATE_PROCESS is required to use this process as the parent process with PROC_THREAD_ATTRIBUTE_PARENT_PROCESS.
+		THREAD_ALL_ACCESS is all possible access rights for a thread object.
 	*/
-	PROCESS_CREATE_PROCESS ProcessAccess = 0x0080
+	THREAD_ALL_ACCESS ThreadAccess = ThreadAccess(STANDARD_RIGHTS_REQUIRED|SYNCHRONIZE) | 0xFFFF
+)
+
+/*
+	OpenProcess opens an existing local process object.
+*/
+func OpenProcess(desiredAccess ProcessAccess, inheritHandle bool, processId uint32) (win32.Handle, error) {
+	var inherit C.BOOL
+	if inheritHandle {
+		inherit = 1
+	}
+
+	handle := C.OpenProcess(C.DWORD(desiredAccess), inherit, C.DWORD(processId))
+	if handle == nil {
+		return 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(handle)), nil
+}
+
+/*
+	OpenProcessFor opens a process with the minimal access rights needed for ops.
+
+	If those rights are denied, as they are for protected processes, the process is opened
+	with the rights of the operations that can be granted on their own instead, and the
+	operations left out are returned. The error is only set if none of the operations
+	can be performed, or the process cannot be opened for another reason.
+*/
+func OpenProcessFor(ops ProcessOperation, inheritHandle bool, processId uint32) (win32.Handle, ProcessOperation, error) {
+	open := func(access ProcessAccess, inheritHandle bool) (win32.Handle, error) {
+		return OpenProcess(access, inheritHandle, processId)
+	}
+
+	return openProcessFor(ops, inheritHandle, open, CloseHandle)
+}
+
+/*
+	TerminateProcess terminates the specified process and all of its threads.
+*/
+func TerminateProcess(process win32.Handle, exitCode uint32) error {
+	if C.TerminateProcess(C.HANDLE(unsafe.Pointer(process)), C.UINT(exitCode)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	OpenThread opens an existing thread object.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-openthread
+*/
+func OpenThread(desiredAccess ThreadAccess, inheritHandle bool, threadId uint32) (win32.Handle, error) {
+	var inherit C.BOOL
+	if inheritHandle {
+		inherit = 1
+	}
+
+	handle := C.OpenThread(C.DWORD(desiredAccess), inherit, C.DWORD(threadId))
+	if handle == nil {
+		return 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(handle)), nil
+}
+
+/*
+	SuspendThread suspends the specified thread.
+	The handle must have the THREAD_SUSPEND_RESUME access right.
+
+	If the function succeeds, the return value is the thread's previous suspend count.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-suspendthread
+*/
+func SuspendThread(thread win32.Handle) (uint32, error) {
+	count := C.SuspendThread(C.HANDLE(unsafe.Pointer(thread)))
+	if count == C.DWORD(0xFFFFFFFF) {
+		return 0, GetLastError()
+	}
+
+	return uint32(count), nil
+}
+
+/*
+	ResumeThread decrements a thread's suspend count. When the suspend count
+	is decremented to zero, the execution of the thread is resumed.
+	The handle must have the THREAD_SUSPEND_RESUME access right.
+
+	If the function succeeds, the return value is the thread's previous suspend count.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-resumethread
+*/
+func ResumeThread(thread win32.Handle) (uint32, error) {
+	count := C.ResumeThread(C.HANDLE(unsafe.Pointer(thread)))
+	if count == C.DWORD(0xFFFFFFFF) {
+		return 0, GetLastError()
+	}
+
+	return uint32(count), nil
+}
+
+/*
+	GetCurrentThreadId returns the thread identifier of the calling thread.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getcurrentthreadid
+*/
+func GetCurrentThreadId() uint32 {
+	return uint32(C.GetCurrentThreadId())
+}
+
+/*
+	FlushInstructionCache flushes the instruction cache for the specified process.
+	Applications should call it after modifying code in memory.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-flushinstructioncache
+*/
+func FlushInstructionCache(process win32.Handle, baseAddr uintptr, size uintptr) error {
+	if C.FlushInstructionCache(C.HANDLE(unsafe.Pointer(process)), C.LPCVOID(baseAddr), C.SIZE_T(size)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	CreationFlags control the creation of processes and threads.
+	Only CREATE_SUSPENDED and STACK_SIZE_PARAM_IS_A_RESERVATION apply to threads.
+*/
+type CreationFlags uint32
+
+/*
+	ThreadCreationFlags is the former name of CreationFlags, kept for existing callers.
+*/
+type ThreadCreationFlags = CreationFlags
+
+const (
+	/*
+		DEBUG_PROCESS makes the calling thread the debugger of the new process and
+		of all the child processes it creates. The thread must call WaitForDebugEvent.
+	*/
+	DEBUG_PROCESS CreationFlags = 0x00000001
+
+	/*
+		DEBUG_ONLY_THIS_PROCESS makes the calling thread the debugger of the new process only.
+	*/
+	DEBUG_ONLY_THIS_PROCESS CreationFlags = 0x00000002
+
+	/*
+		CREATE_SUSPENDED creates the primary thread of the process, or the thread,
+		in a suspended state. It does not run until ResumeThread is called.
+	*/
+	CREATE_SUSPENDED CreationFlags = 0x00000004
+
+	/*
+		DETACHED_PROCESS makes a console process start without access to the console of its parent.
+	*/
+	DETACHED_PROCESS CreationFlags = 0x00000008
+
+	/*
+		CREATE_NEW_CONSOLE gives the new process a new console instead of inheriting the console of its parent.
+	*/
+	CREATE_NEW_CONSOLE CreationFlags = 0x00000010
 
 	/*
-		PROCESS_CREATE_THREAD is required to create a thread in the process.
+		CREATE_NEW_PROCESS_GROUP makes the new process the root of a new process group,
+		which receives CTRL+BREAK signals.
 	*/
-	PROCESS_CREATE_THREAD ProcessAccess = 0x0002
+	CREATE_NEW_PROCESS_GROUP CreationFlags = 0x00000200
 
 	/*
-		PROCESS_DUP_HANDLE is required to duplicate a handle using DuplicateHandle.
+		CREATE_UNICODE_ENVIRONMENT indicates that the environment block uses Unicode characters.
 	*/
-	PROCESS_DUP_HANDLE ProcessAccess = 0x0040
+	CREATE_UNICODE_ENVIRONMENT CreationFlags = 0x00000400
 
 	/*
-		PROCESS_QUERY_INFORMATION is required to retrieve certain information about a process,
-		 such as its token, exit code, and priority class (see OpenProcessToken).
+		INHERIT_PARENT_AFFINITY makes the process inherit the affinity of its parent.
 	*/
-	PROCESS_QUERY_INFORMATION ProcessAccess = 0x0400
+	INHERIT_PARENT_AFFINITY CreationFlags = 0x00010000
 
 	/*
-		PROCESS_QUERY_LIMITED_INFORMATION is required to retrieve certain information about a process
-		(see GetExitCodeProcess, GetPriorityClass, IsProcessInJob, QueryFullProcessImageName).
-		A handle that has the PROCESS_QUERY_INFORMATION access right is automatically
-		granted PROCESS_QUERY_LIMITED_INFORMATION.Windows Server 2003 and Windows XP:
-		This access right is not supported.
+		STACK_SIZE_PARAM_IS_A_RESERVATION makes the stack size the initial reserve size of the stack.
+		Otherwise, it specifies the commit size.
 	*/
-	PROCESS_QUERY_LIMITED_INFORMATION ProcessAccess = 0x1000
+	STACK_SIZE_PARAM_IS_A_RESERVATION CreationFlags = 0x00010000
 
 	/*
-		PROCESS_SET_INFORMATION is required to set certain information about a process, such as its priority class (see SetPriorityClass).
+		CREATE_PROTECTED_PROCESS creates a protected process.
 	*/
-	PROCESS_SET_INFORMATION ProcessAccess = 0x0200
+	CREATE_PROTECTED_PROCESS CreationFlags = 0x00040000
 
 	/*
-		PROCESS_SET_QUOTA is required to set memory limits using SetProcessWorkingSetSize.
+		EXTENDED_STARTUPINFO_PRESENT indicates that the startup information is a STARTUPINFOEX structure.
 	*/
-	PROCESS_SET_QUOTA ProcessAccess = 0x0100
+	EXTENDED_STARTUPINFO_PRESENT CreationFlags = 0x00080000
 
 	/*
-		PROCESS_SUSPEND_RESUME is required to suspend or resume a process.
+		CREATE_SECURE_PROCESS creates a process running in the isolated user mode.
 	*/
-	PROCESS_SUSPEND_RESUME ProcessAccess = 0x080
+	CREATE_SECURE_PROCESS CreationFlags = 0x00400000
 
 	/*
-		PROCESS_TERMINATE is required to terminate a process using TerminateProcess.
+		CREATE_BREAKAWAY_FROM_JOB makes the process not part of the job of its parent,
+		if the job allows breaking away.
 	*/
-	PROCESS_TERMINATE ProcessAccess = 0x0001
+	CREATE_BREAKAWAY_FROM_JOB CreationFlags = 0x01000000
 
 	/*
-		PROCESS_VM_OPERATION is required to perform an operation on the address space of a process (see VirtualProtectEx and WriteProcessMemory).
+		CREATE_PRESERVE_CODE_AUTHZ_LEVEL allows the caller to execute a child process
+		that bypasses the process restrictions that would normally be applied automatically.
 	*/
-	PROCESS_VM_OPERATION ProcessAccess = 0x0008
+	CREATE_PRESERVE_CODE_AUTHZ_LEVEL CreationFlags = 0x02000000
 
 	/*
-		PROCESS_VM_READ is required to read memory in a process using ReadProcessMemory.
+		CREATE_DEFAULT_ERROR_MODE makes the process use the default error mode instead of inheriting the mode of its parent.
 	*/
-	PROCESS_VM_READ ProcessAccess = 0x0010
+	CREATE_DEFAULT_ERROR_MODE CreationFlags = 0x04000000
 
 	/*
-		PROCESS_VM_WRITE is required to write to memory in a process using WriteProcessMemory.
+		CREATE_NO_WINDOW runs a console process without a console window.
 	*/
-	PROCESS_VM_WRITE ProcessAccess = 0x0020
+	CREATE_NO_WINDOW CreationFlags = 0x08000000
 )
 
 /*
-	OpenProcess opens an existing local process object.
+	STILL_ACTIVE is the exit code reported for a thread or process that has not terminated.
 */
-func OpenProcess(desiredAccess ProcessAccess, inheritHandle bool, processId uint32) (win32.Handle, error) {
+const STILL_ACTIVE uint32 = 259
+
+/*
+	CreateRemoteThread creates a thread that runs in the virtual address space of another process,
+	starting at startAddress with parameter as its single argument.
+	It returns the thread handle and the thread identifier.
+
+	The process handle must have the PROCESS_CREATE_THREAD, PROCESS_QUERY_INFORMATION,
+	PROCESS_VM_OPERATION, PROCESS_VM_WRITE, and PROCESS_VM_READ access rights.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-createremotethread
+*/
+func CreateRemoteThread(process win32.Handle, stackSize uintptr, startAddress uintptr, parameter uintptr, flags CreationFlags) (win32.Handle, uint32, error) {
+	var threadId C.DWORD
+	handle := C.CreateRemoteThread(
+		C.HANDLE(unsafe.Pointer(process)),
+		nil,
+		C.SIZE_T(stackSize),
+		C.LPTHREAD_START_ROUTINE(unsafe.Pointer(startAddress)),
+		C.LPVOID(parameter),
+		C.DWORD(flags),
+		&threadId,
+	)
+	if handle == nil {
+		return 0, 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(handle)), uint32(threadId), nil
+}
+
+/*
+	CreateRemoteThreadEx is CreateRemoteThread with an optional list of extended thread attributes,
+	which is the address of an initialized PROC_THREAD_ATTRIBUTE_LIST, such as the Address
+	of a ProcThreadAttributeList, or zero.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-createremotethreadex
+*/
+func CreateRemoteThreadEx(process win32.Handle, stackSize uintptr, startAddress uintptr, parameter uintptr, flags CreationFlags, attributeList uintptr) (win32.Handle, uint32, error) {
+	var threadId C.DWORD
+	handle := C.CreateRemoteThreadEx(
+		C.HANDLE(unsafe.Pointer(process)),
+		nil,
+		C.SIZE_T(stackSize),
+		C.LPTHREAD_START_ROUTINE(unsafe.Pointer(startAddress)),
+		C.LPVOID(parameter),
+		C.DWORD(flags),
+		C.LPPROC_THREAD_ATTRIBUTE_LIST(unsafe.Pointer(attributeList)),
+		&threadId,
+	)
+	if handle == nil {
+		return 0, 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(handle)), uint32(threadId), nil
+}
+
+/*
+	GetExitCodeThread retrieves the termination status of the specified thread.
+	The exit code is STILL_ACTIVE while the thread is running.
+
+	The handle must have the THREAD_QUERY_INFORMATION or THREAD_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getexitcodethread
+*/
+func GetExitCodeThread(thread win32.Handle) (uint32, error) {
+	var code C.DWORD
+	if C.GetExitCodeThread(C.HANDLE(unsafe.Pointer(thread)), &code) == 0 {
+		return 0, GetLastError()
+	}
+
+	return uint32(code), nil
+}
+
+type StartupFlags uint32
+
+const (
+	STARTF_USESHOWWINDOW    StartupFlags = 0x00000001
+	STARTF_USESIZE          StartupFlags = 0x00000002
+	STARTF_USEPOSITION      StartupFlags = 0x00000004
+	STARTF_USECOUNTCHARS    StartupFlags = 0x00000008
+	STARTF_USEFILLATTRIBUTE StartupFlags = 0x00000010
+	STARTF_RUNFULLSCREEN    StartupFlags = 0x00000020
+	STARTF_FORCEONFEEDBACK  StartupFlags = 0x00000040
+	STARTF_FORCEOFFFEEDBACK StartupFlags = 0x00000080
+
+	/*
+		STARTF_USESTDHANDLES makes the process use the StdInput, StdOutput and StdError handles.
+		The handles must be inheritable and CreateProcess must be called with inheritHandles set.
+	*/
+	STARTF_USESTDHANDLES   StartupFlags = 0x00000100
+	STARTF_USEHOTKEY       StartupFlags = 0x00000200
+	STARTF_UNTRUSTEDSOURCE StartupFlags = 0x00008000
+)
+
+/*
+	StartupInfo specifies the window station, desktop, standard handles,
+	and appearance of the main window of a process (STARTUPINFOW).
+	Fields are only used when the matching STARTF flag is set.
+*/
+type StartupInfo struct {
+	Desktop       string
+	Title         string
+	X             uint32
+	Y             uint32
+	XSize         uint32
+	YSize         uint32
+	XCountChars   uint32
+	YCountChars   uint32
+	FillAttribute uint32
+	Flags         StartupFlags
+	ShowWindow    uint16
+	StdInput      win32.Handle
+	StdOutput     win32.Handle
+	StdError      win32.Handle
+}
+
+/*
+	StartupInfoEx is a StartupInfo with a list of extended attributes (STARTUPINFOEXW).
+*/
+type StartupInfoEx struct {
+	StartupInfo
+	AttributeList *ProcThreadAttributeList
+}
+
+/*
+	ProcessInformation receives the handles and identifiers of a new process
+	and of its primary thread. Both handles must be closed with CloseHandle.
+*/
+type ProcessInformation struct {
+	Process   win32.Handle
+	Thread    win32.Handle
+	ProcessId uint32
+	ThreadId  uint32
+}
+
+/*
+	CreateProcess creates a new process and its primary thread, see CreateProcessEx.
+*/
+func CreateProcess(applicationName string, commandLine string, inheritHandles bool, flags CreationFlags, environment []uint16, currentDirectory string, startupInfo *StartupInfo) (*ProcessInformation, error) {
+	var si StartupInfoEx
+	if startupInfo != nil {
+		si.StartupInfo = *startupInfo
+	}
+
+	return createProcess(applicationName, commandLine, inheritHandles, flags, environment, currentDirectory, &si, false)
+}
+
+/*
+	CreateProcessEx creates a new process and its primary thread with the extended
+	attributes of startupInfo. EXTENDED_STARTUPINFO_PRESENT is added to flags.
+
+	The command line is parsed by the new process itself,
+	cmdline.QuoteArgs builds one from a list of arguments.
+	Either applicationName or commandLine may be empty, but not both.
+
+	environment is a block of NUL terminated UTF-16 "name=value" strings ended by
+	an additional NUL, as built by envblock.Encode. CREATE_UNICODE_ENVIRONMENT
+	is added to flags when it is set. If it is nil, the environment of the calling process is inherited.
+
+	If currentDirectory is empty, the process starts in the current directory of the calling process.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-createprocessw
+*/
+func CreateProcessEx(applicationName string, commandLine string, inheritHandles bool, flags CreationFlags, environment []uint16, currentDirectory string, startupInfo *StartupInfoEx) (*ProcessInformation, error) {
+	if startupInfo == nil {
+		startupInfo = &StartupInfoEx{}
+	}
+
+	return createProcess(applicationName, commandLine, inheritHandles, flags, environment, currentDirectory, startupInfo, true)
+}
+
+func createProcess(applicationName string, commandLine string, inheritHandles bool, flags CreationFlags, environment []uint16, currentDirectory string, startupInfo *StartupInfoEx, extended bool) (*ProcessInformation, error) {
+	// Everything CreateProcessW reads through a pointer lives in C memory,
+	// the strings of the startup information in particular.
+	si := (*C.STARTUPINFOEXW)(C.calloc(1, C.sizeof_STARTUPINFOEXW))
+	defer C.free(unsafe.Pointer(si))
+
+	si.StartupInfo.cb = C.DWORD(C.sizeof_STARTUPINFOW)
+	if extended {
+		si.StartupInfo.cb = C.DWORD(C.sizeof_STARTUPINFOEXW)
+		si.lpAttributeList = startupInfo.AttributeList.pointer()
+		flags |= EXTENDED_STARTUPINFO_PRESENT
+	}
+
+	info := &startupInfo.StartupInfo
+	si.StartupInfo.lpDesktop = wideString(info.Desktop)
+	defer C.free(unsafe.Pointer(si.StartupInfo.lpDesktop))
+	si.StartupInfo.lpTitle = wideString(info.Title)
+	defer C.free(unsafe.Pointer(si.StartupInfo.lpTitle))
+
+	si.StartupInfo.dwX = C.DWORD(info.X)
+	si.StartupInfo.dwY = C.DWORD(info.Y)
+	si.StartupInfo.dwXSize = C.DWORD(info.XSize)
+	si.StartupInfo.dwYSize = C.DWORD(info.YSize)
+	si.StartupInfo.dwXCountChars = C.DWORD(info.XCountChars)
+	si.StartupInfo.dwYCountChars = C.DWORD(info.YCountChars)
+	si.StartupInfo.dwFillAttribute = C.DWORD(info.FillAttribute)
+	si.StartupInfo.dwFlags = C.DWORD(info.Flags)
+	si.StartupInfo.wShowWindow = C.WORD(info.ShowWindow)
+	si.StartupInfo.hStdInput = C.HANDLE(unsafe.Pointer(info.StdInput))
+	si.StartupInfo.hStdOutput = C.HANDLE(unsafe.Pointer(info.StdOutput))
+	si.StartupInfo.hStdError = C.HANDLE(unsafe.Pointer(info.StdError))
+
+	appName := wideString(applicationName)
+	defer C.free(unsafe.Pointer(appName))
+
+	// CreateProcessW may modify the command line in place.
+	cmdLine := wideString(commandLine)
+	defer C.free(unsafe.Pointer(cmdLine))
+
+	curDir := wideString(currentDirectory)
+	defer C.free(unsafe.Pointer(curDir))
+
+	var env unsafe.Pointer
+	if environment != nil {
+		env = C.malloc(C.size_t(2 * (len(environment) + 2)))
+		defer C.free(env)
+
+		block := unsafe.Slice((*uint16)(env), len(environment)+2)
+		copy(block, environment)
+		block[len(environment)], block[len(environment)+1] = 0, 0
+		flags |= CREATE_UNICODE_ENVIRONMENT
+	}
+
 	var inherit C.BOOL
-	if inheritHandle {
+	if inheritHandles {
 		inherit = 1
 	}
 
-	handle := C.OpenProcess(C.DWORD(desiredAccess), inherit, C.DWORD(processId))
-	if handle == nil {
+	var pi C.PROCESS_INFORMATION
+	if C.CreateProcessW(appName, cmdLine, nil, nil, inherit, C.DWORD(flags), C.LPVOID(env), curDir, &si.StartupInfo, &pi) == 0 {
+		return nil, GetLastError()
+	}
+
+	return &ProcessInformation{
+		Process:   win32.Handle(unsafe.Pointer(pi.hProcess)),
+		Thread:    win32.Handle(unsafe.Pointer(pi.hThread)),
+		ProcessId: uint32(pi.dwProcessId),
+		ThreadId:  uint32(pi.dwThreadId),
+	}, nil
+}
+
+/*
+	wideString copies s into a NUL terminated UTF-16 string allocated with malloc,
+	or returns nil if s is empty.
+*/
+func wideString(s string) *C.WCHAR {
+	if s == "" {
+		return nil
+	}
+
+	u := utf16.Encode([]rune(s))
+	p := C.malloc(C.size_t(2 * (len(u) + 1)))
+
+	buf := unsafe.Slice((*uint16)(p), len(u)+1)
+	copy(buf, u)
+	buf[len(u)] = 0
+
+	return (*C.WCHAR)(p)
+}
+
+type ProcThreadAttribute uintptr
+
+const (
+	/*
+		PROC_THREAD_ATTRIBUTE_PARENT_PROCESS makes the process inherit from, and appear
+		as a child of, another process. The handle needs the PROCESS_CREATE_PROCESS access right.
+	*/
+	PROC_THREAD_ATTRIBUTE_PARENT_PROCESS ProcThreadAttribute = 0x00020000
+
+	/*
+		PROC_THREAD_ATTRIBUTE_HANDLE_LIST restricts the handles inherited by the process
+		to the listed inheritable handles.
+	*/
+	PROC_THREAD_ATTRIBUTE_HANDLE_LIST ProcThreadAttribute = 0x00020002
+
+	/*
+		PROC_THREAD_ATTRIBUTE_MITIGATION_POLICY sets the exploit mitigation policies of the process.
+	*/
+	PROC_THREAD_ATTRIBUTE_MITIGATION_POLICY ProcThreadAttribute = 0x00020007
+)
+
+/*
+	MitigationPolicy is a combination of PROCESS_CREATION_MITIGATION_POLICY flags.
+*/
+type MitigationPolicy uint64
+
+const (
+	PROCESS_CREATION_MITIGATION_POLICY_DEP_ENABLE                             MitigationPolicy = 0x01
+	PROCESS_CREATION_MITIGATION_POLICY_DEP_ATL_THUNK_ENABLE                   MitigationPolicy = 0x02
+	PROCESS_CREATION_MITIGATION_POLICY_SEHOP_ENABLE                           MitigationPolicy = 0x04
+	PROCESS_CREATION_MITIGATION_POLICY_FORCE_RELOCATE_IMAGES_ALWAYS_ON        MitigationPolicy = 0x1 << 8
+	PROCESS_CREATION_MITIGATION_POLICY_HEAP_TERMINATE_ALWAYS_ON               MitigationPolicy = 0x1 << 12
+	PROCESS_CREATION_MITIGATION_POLICY_BOTTOM_UP_ASLR_ALWAYS_ON               MitigationPolicy = 0x1 << 16
+	PROCESS_CREATION_MITIGATION_POLICY_HIGH_ENTROPY_ASLR_ALWAYS_ON            MitigationPolicy = 0x1 << 20
+	PROCESS_CREATION_MITIGATION_POLICY_STRICT_HANDLE_CHECKS_ALWAYS_ON         MitigationPolicy = 0x1 << 24
+	PROCESS_CREATION_MITIGATION_POLICY_WIN32K_SYSTEM_CALL_DISABLE_ALWAYS_ON   MitigationPolicy = 0x1 << 28
+	PROCESS_CREATION_MITIGATION_POLICY_EXTENSION_POINT_DISABLE_ALWAYS_ON      MitigationPolicy = 0x1 << 32
+	PROCESS_CREATION_MITIGATION_POLICY_PROHIBIT_DYNAMIC_CODE_ALWAYS_ON        MitigationPolicy = 0x1 << 36
+	PROCESS_CREATION_MITIGATION_POLICY_CONTROL_FLOW_GUARD_ALWAYS_ON           MitigationPolicy = 0x1 << 40
+	PROCESS_CREATION_MITIGATION_POLICY_BLOCK_NON_MICROSOFT_BINARIES_ALWAYS_ON MitigationPolicy = 0x1 << 44
+	PROCESS_CREATION_MITIGATION_POLICY_FONT_DISABLE_ALWAYS_ON                 MitigationPolicy = 0x1 << 48
+	PROCESS_CREATION_MITIGATION_POLICY_IMAGE_LOAD_NO_REMOTE_ALWAYS_ON         MitigationPolicy = 0x1 << 52
+	PROCESS_CREATION_MITIGATION_POLICY_IMAGE_LOAD_NO_LOW_LABEL_ALWAYS_ON      MitigationPolicy = 0x1 << 56
+	PROCESS_CREATION_MITIGATION_POLICY_IMAGE_LOAD_PREFER_SYSTEM32_ALWAYS_ON   MitigationPolicy = 0x1 << 60
+)
+
+/*
+	ProcThreadAttributeList is a list of attributes for process and thread creation
+	(PROC_THREAD_ATTRIBUTE_LIST). The list and the attribute values live in C memory,
+	which is released by Delete.
+*/
+type ProcThreadAttributeList struct {
+	list   C.LPPROC_THREAD_ATTRIBUTE_LIST
+	values []unsafe.Pointer
+}
+
+/*
+	NewProcThreadAttributeList creates a list that can hold count attributes.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-initializeprocthreadattributelist
+*/
+func NewProcThreadAttributeList(count int) (*ProcThreadAttributeList, error) {
+	var size C.SIZE_T
+	if C.InitializeProcThreadAttributeList(nil, C.DWORD(count), 0, &size) == 0 {
+		if err := GetLastError(); !errors.Is(err, ERROR_INSUFFICIENT_BUFFER) {
+			return nil, err
+		}
+	}
+
+	list := C.LPPROC_THREAD_ATTRIBUTE_LIST(C.malloc(C.size_t(size)))
+	if C.InitializeProcThreadAttributeList(list, C.DWORD(count), 0, &size) == 0 {
+		err := GetLastError()
+		C.free(unsafe.Pointer(list))
+		return nil, err
+	}
+
+	return &ProcThreadAttributeList{list: list}, nil
+}
+
+func (l *ProcThreadAttributeList) pointer() C.LPPROC_THREAD_ATTRIBUTE_LIST {
+	if l == nil {
+		return nil
+	}
+
+	return l.list
+}
+
+/*
+	Address returns the address of the list for functions taking it as a uintptr,
+	such as CreateRemoteThreadEx. It is zero for a nil list.
+*/
+func (l *ProcThreadAttributeList) Address() uintptr {
+	return uintptr(unsafe.Pointer(l.pointer()))
+}
+
+/*
+	Update sets attribute to the size bytes at value, which are copied into
+	memory owned by the list.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-updateprocthreadattribute
+*/
+func (l *ProcThreadAttributeList) Update(attribute ProcThreadAttribute, value unsafe.Pointer, size uintptr) error {
+	p := C.malloc(C.size_t(size))
+	C.memcpy(p, value, C.size_t(size))
+
+	if C.UpdateProcThreadAttribute(l.list, 0, C.DWORD_PTR(attribute), C.LPVOID(p), C.SIZE_T(size), nil, nil) == 0 {
+		err := GetLastError()
+		C.free(p)
+		return err
+	}
+
+	l.values = append(l.values, p)
+	return nil
+}
+
+/*
+	SetParentProcess sets PROC_THREAD_ATTRIBUTE_PARENT_PROCESS.
+*/
+func (l *ProcThreadAttributeList) SetParentProcess(parent win32.Handle) error {
+	return l.Update(PROC_THREAD_ATTRIBUTE_PARENT_PROCESS, unsafe.Pointer(&parent), unsafe.Sizeof(parent))
+}
+
+/*
+	SetHandleList sets PROC_THREAD_ATTRIBUTE_HANDLE_LIST.
+*/
+func (l *ProcThreadAttributeList) SetHandleList(handles []win32.Handle) error {
+	if len(handles) == 0 {
+		return ERROR_INVALID_PARAMETER
+	}
+
+	return l.Update(PROC_THREAD_ATTRIBUTE_HANDLE_LIST, unsafe.Pointer(&handles[0]), uintptr(len(handles))*unsafe.Sizeof(handles[0]))
+}
+
+/*
+	SetMitigationPolicy sets PROC_THREAD_ATTRIBUTE_MITIGATION_POLICY.
+*/
+func (l *ProcThreadAttributeList) SetMitigationPolicy(policy MitigationPolicy) error {
+	return l.Update(PROC_THREAD_ATTRIBUTE_MITIGATION_POLICY, unsafe.Pointer(&policy), unsafe.Sizeof(policy))
+}
+
+/*
+	Delete deletes the list and frees the memory of the attribute values.
+	The list must not be used afterwards.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-deleteprocthreadattributelist
+*/
+func (l *ProcThreadAttributeList) Delete() {
+	if l.list == nil {
+		return
+	}
+
+	C.DeleteProcThreadAttributeList(l.list)
+	C.free(unsafe.Pointer(l.list))
+	l.list = nil
+
+	for _, p := range l.values {
+		C.free(p)
+	}
+	l.values = nil
+}
+
+/*
+	GetExitCodeProcess retrieves the termination status of the specified process.
+	The exit code is STILL_ACTIVE while the process is running, a process
+	that exits with that code cannot be told apart from a running one,
+	use WaitForSingleObject to find out whether it has terminated.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getexitcodeprocess
+*/
+func GetExitCodeProcess(process win32.Handle) (uint32, error) {
+	var code C.DWORD
+	if C.GetExitCodeProcess(C.HANDLE(unsafe.Pointer(process)), &code) == 0 {
 		return 0, GetLastError()
 	}
 
-	return win32.Handle(unsafe.Pointer(handle)), nil
+	return uint32(code), nil
 }
 
 /*
-	TerminateProcess terminates the specified process and all of its threads.
+	ProcessTimes holds the timing information of a process. CreationTime and
+	ExitTime are FILETIME values, in 100-nanosecond intervals since January 1, 1601 (UTC).
+	KernelTime and UserTime are amounts of time, in 100-nanosecond units.
 */
-func TerminateProcess(process win32.Handle, exitCode uint32) error {
-	if C.TerminateProcess(C.HANDLE(unsafe.Pointer(process)), C.UINT(exitCode)) == 0 {
+type ProcessTimes struct {
+	CreationTime uint64
+
+	/*
+		ExitTime is undefined while the process is running.
+	*/
+	ExitTime   uint64
+	KernelTime uint64
+	UserTime   uint64
+}
+
+func filetime(ft C.FILETIME) uint64 {
+	return uint64(ft.dwHighDateTime)<<32 | uint64(ft.dwLowDateTime)
+}
+
+/*
+	GetProcessTimes retrieves timing information for the specified process.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getprocesstimes
+*/
+func GetProcessTimes(process win32.Handle) (ProcessTimes, error) {
+	var creation, exit, kernel, user C.FILETIME
+	if C.GetProcessTimes(C.HANDLE(unsafe.Pointer(process)), &creation, &exit, &kernel, &user) == 0 {
+		return ProcessTimes{}, GetLastError()
+	}
+
+	return ProcessTimes{
+		CreationTime: filetime(creation),
+		ExitTime:     filetime(exit),
+		KernelTime:   filetime(kernel),
+		UserTime:     filetime(user),
+	}, nil
+}
+
+/*
+	IoCounters contains the I/O accounting of a process (IO_COUNTERS).
+	All I/O operations of the process are counted, not only disk I/O.
+*/
+type IoCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+/*
+	GetProcessIoCounters retrieves accounting information for all I/O operations performed by the specified process.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-getprocessiocounters
+*/
+func GetProcessIoCounters(process win32.Handle) (IoCounters, error) {
+	var c C.IO_COUNTERS
+	if C.GetProcessIoCounters(C.HANDLE(unsafe.Pointer(process)), &c) == 0 {
+		return IoCounters{}, GetLastError()
+	}
+
+	return IoCounters{
+		ReadOperationCount:  uint64(c.ReadOperationCount),
+		WriteOperationCount: uint64(c.WriteOperationCount),
+		OtherOperationCount: uint64(c.OtherOperationCount),
+		ReadTransferCount:   uint64(c.ReadTransferCount),
+		WriteTransferCount:  uint64(c.WriteTransferCount),
+		OtherTransferCount:  uint64(c.OtherTransferCount),
+	}, nil
+}
+
+/*
+	GetProcessHandleCount retrieves the number of open handles of the specified process.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getprocesshandlecount
+*/
+func GetProcessHandleCount(process win32.Handle) (uint32, error) {
+	var count C.DWORD
+	if C.GetProcessHandleCount(C.HANDLE(unsafe.Pointer(process)), &count) == 0 {
+		return 0, GetLastError()
+	}
+
+	return uint32(count), nil
+}
+
+type ProcessNameFormat uint32
+
+const (
+	/*
+		PROCESS_NAME_WIN32 returns the path in Win32 format, such as "C:\Windows\explorer.exe".
+	*/
+	PROCESS_NAME_WIN32 ProcessNameFormat = 0x00000000
+
+	/*
+		PROCESS_NAME_NATIVE returns the path in native system format,
+		such as "\Device\HarddiskVolume3\Windows\explorer.exe".
+	*/
+	PROCESS_NAME_NATIVE ProcessNameFormat = 0x00000001
+)
+
+/*
+	QueryFullProcessImageName retrieves the full path of the executable image of the specified process.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-queryfullprocessimagenamew
+*/
+func QueryFullProcessImageName(process win32.Handle, format ProcessNameFormat) (string, error) {
+	// Paths are limited to 32767 characters.
+	for size := 260; size <= 32768; size *= 2 {
+		buf := make([]uint16, size)
+
+		n := C.DWORD(size)
+		if C.QueryFullProcessImageNameW(C.HANDLE(unsafe.Pointer(process)), C.DWORD(format), (*C.WCHAR)(unsafe.Pointer(&buf[0])), &n) == 0 {
+			err := GetLastError()
+			if errors.Is(err, ERROR_INSUFFICIENT_BUFFER) {
+				continue
+			}
+			return "", err
+		}
+
+		return string(utf16.Decode(buf[:n])), nil
+	}
+
+	return "", ERROR_INSUFFICIENT_BUFFER
+}
+
+type PriorityClass uint32
+
+const (
+	IDLE_PRIORITY_CLASS         PriorityClass = 0x00000040
+	BELOW_NORMAL_PRIORITY_CLASS PriorityClass = 0x00004000
+	NORMAL_PRIORITY_CLASS       PriorityClass = 0x00000020
+	ABOVE_NORMAL_PRIORITY_CLASS PriorityClass = 0x00008000
+	HIGH_PRIORITY_CLASS         PriorityClass = 0x00000080
+
+	/*
+		REALTIME_PRIORITY_CLASS preempts all other threads, including operating system
+		threads. Without the SeIncreaseBasePriorityPrivilege, HIGH_PRIORITY_CLASS is set instead.
+	*/
+	REALTIME_PRIORITY_CLASS PriorityClass = 0x00000100
+
+	/*
+		PROCESS_MODE_BACKGROUND_BEGIN lowers the resource scheduling priorities of the calling process.
+		It can only be set on the current process.
+	*/
+	PROCESS_MODE_BACKGROUND_BEGIN PriorityClass = 0x00100000
+
+	/*
+		PROCESS_MODE_BACKGROUND_END restores the priorities changed by PROCESS_MODE_BACKGROUND_BEGIN.
+	*/
+	PROCESS_MODE_BACKGROUND_END PriorityClass = 0x00200000
+)
+
+/*
+	GetPriorityClass retrieves the priority class of the specified process.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getpriorityclass
+*/
+func GetPriorityClass(process win32.Handle) (PriorityClass, error) {
+	class := C.GetPriorityClass(C.HANDLE(unsafe.Pointer(process)))
+	if class == 0 {
+		return 0, GetLastError()
+	}
+
+	return PriorityClass(class), nil
+}
+
+/*
+	SetPriorityClass sets the priority class of the specified process.
+
+	The handle must have the PROCESS_SET_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-setpriorityclass
+*/
+func SetPriorityClass(process win32.Handle, class PriorityClass) error {
+	if C.SetPriorityClass(C.HANDLE(unsafe.Pointer(process)), C.DWORD(class)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	ThreadPriority is the priority of a thread relative to the priority class of its process.
+*/
+type ThreadPriority int32
+
+const (
+	THREAD_PRIORITY_IDLE          ThreadPriority = -15
+	THREAD_PRIORITY_LOWEST        ThreadPriority = -2
+	THREAD_PRIORITY_BELOW_NORMAL  ThreadPriority = -1
+	THREAD_PRIORITY_NORMAL        ThreadPriority = 0
+	THREAD_PRIORITY_ABOVE_NORMAL  ThreadPriority = 1
+	THREAD_PRIORITY_HIGHEST       ThreadPriority = 2
+	THREAD_PRIORITY_TIME_CRITICAL ThreadPriority = 15
+
+	/*
+		THREAD_MODE_BACKGROUND_BEGIN lowers the resource scheduling priorities of the thread.
+		It can only be set on the current thread.
+	*/
+	THREAD_MODE_BACKGROUND_BEGIN ThreadPriority = 0x00010000
+
+	/*
+		THREAD_MODE_BACKGROUND_END restores the priorities changed by THREAD_MODE_BACKGROUND_BEGIN.
+	*/
+	THREAD_MODE_BACKGROUND_END ThreadPriority = 0x00020000
+)
+
+/*
+	THREAD_PRIORITY_ERROR_RETURN is returned by GetThreadPriority on failure.
+*/
+const THREAD_PRIORITY_ERROR_RETURN = 0x7FFFFFFF
+
+/*
+	GetThreadPriority retrieves the priority of the specified thread.
+
+	The handle must have the THREAD_QUERY_INFORMATION or THREAD_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-getthreadpriority
+*/
+func GetThreadPriority(thread win32.Handle) (ThreadPriority, error) {
+	priority := C.GetThreadPriority(C.HANDLE(unsafe.Pointer(thread)))
+	if priority == THREAD_PRIORITY_ERROR_RETURN {
+		return 0, GetLastError()
+	}
+
+	return ThreadPriority(priority), nil
+}
+
+/*
+	SetThreadPriority sets the priority of the specified thread.
+
+	The handle must have the THREAD_SET_INFORMATION or THREAD_SET_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-setthreadpriority
+*/
+func SetThreadPriority(thread win32.Handle, priority ThreadPriority) error {
+	if C.SetThreadPriority(C.HANDLE(unsafe.Pointer(thread)), C.int(priority)) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	GetProcessAffinityMask retrieves the affinity mask of the specified process and of the system.
+	Each bit is a logical processor of the processor group of the process. If the process
+	has threads in more than one group, the process mask is zero.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-getprocessaffinitymask
+*/
+func GetProcessAffinityMask(process win32.Handle) (processMask uintptr, systemMask uintptr, err error) {
+	var p, s C.DWORD_PTR
+	if C.GetProcessAffinityMask(C.HANDLE(unsafe.Pointer(process)), &p, &s) == 0 {
+		return 0, 0, GetLastError()
+	}
+
+	return uintptr(p), uintptr(s), nil
+}
+
+/*
+	SetProcessAffinityMask sets the affinity mask of the threads of the specified process.
+	The mask must be a subset of the system mask returned by GetProcessAffinityMask.
+
+	The handle must have the PROCESS_SET_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-setprocessaffinitymask
+*/
+func SetProcessAffinityMask(process win32.Handle, mask uintptr) error {
+	if C.SetProcessAffinityMask(C.HANDLE(unsafe.Pointer(process)), C.DWORD_PTR(mask)) == 0 {
 		return GetLastError()
 	}
 
 	return nil
 }
+
+/*
+	SetThreadAffinityMask sets the affinity mask of the specified thread and returns its previous mask.
+	The mask must be a subset of the affinity mask of the process.
+
+	The handle must have the THREAD_SET_INFORMATION or THREAD_SET_LIMITED_INFORMATION access right,
+	and the THREAD_QUERY_INFORMATION or THREAD_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-setthreadaffinitymask
+*/
+func SetThreadAffinityMask(thread win32.Handle, mask uintptr) (uintptr, error) {
+	previous := C.SetThreadAffinityMask(C.HANDLE(unsafe.Pointer(thread)), C.DWORD_PTR(mask))
+	if previous == 0 {
+		return 0, GetLastError()
+	}
+
+	return uintptr(previous), nil
+}
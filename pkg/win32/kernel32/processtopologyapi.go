@@ -0,0 +1,64 @@
+package kernel32
+
+/*
+	#include <windows.h>
+	#include <processtopologyapi.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	GroupAffinity is a set of logical processors of a single processor group (GROUP_AFFINITY).
+*/
+type GroupAffinity struct {
+	Mask  uintptr
+	Group uint16
+}
+
+func (a GroupAffinity) native() C.GROUP_AFFINITY {
+	return C.GROUP_AFFINITY{
+		Mask:  C.KAFFINITY(a.Mask),
+		Group: C.WORD(a.Group),
+	}
+}
+
+/*
+	GetThreadGroupAffinity retrieves the processor group affinity of the specified thread.
+
+	The handle must have the THREAD_QUERY_INFORMATION or THREAD_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processtopologyapi/nf-processtopologyapi-getthreadgroupaffinity
+*/
+func GetThreadGroupAffinity(thread win32.Handle) (GroupAffinity, error) {
+	var affinity C.GROUP_AFFINITY
+	if C.GetThreadGroupAffinity(C.HANDLE(unsafe.Pointer(thread)), &affinity) == 0 {
+		return GroupAffinity{}, GetLastError()
+	}
+
+	return GroupAffinity{Mask: uintptr(affinity.Mask), Group: uint16(affinity.Group)}, nil
+}
+
+/*
+	SetThreadGroupAffinity moves the specified thread to a processor group and sets its affinity
+	within that group, returning the previous group affinity of the thread.
+
+	The handle must have the THREAD_SET_INFORMATION or THREAD_SET_LIMITED_INFORMATION access right,
+	and the THREAD_QUERY_INFORMATION or THREAD_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/processtopologyapi/nf-processtopologyapi-setthreadgroupaffinity
+*/
+func SetThreadGroupAffinity(thread win32.Handle, affinity GroupAffinity) (GroupAffinity, error) {
+	native := affinity.native()
+
+	var previous C.GROUP_AFFINITY
+	if C.SetThreadGroupAffinity(C.HANDLE(unsafe.Pointer(thread)), &native, &previous) == 0 {
+		return GroupAffinity{}, GetLastError()
+	}
+
+	return GroupAffinity{Mask: uintptr(previous.Mask), Group: uint16(previous.Group)}, nil
+}
@@ -8,6 +8,12 @@ package kernel32
 */
 import "C"
 
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
 /*
 	EnumProcesses retrieves the process identifier for each process object in the system.
 */
@@ -38,3 +44,56 @@ func EnumProcesses() ([]uint32, error) {
 
 	return buffer[:needed], nil
 }
+
+/*
+	ProcessMemoryCounters contains the memory statistics of a process (PROCESS_MEMORY_COUNTERS_EX).
+	Sizes are in bytes.
+*/
+type ProcessMemoryCounters struct {
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+
+	/*
+		PagefileUsage is the commit charge of the process.
+	*/
+	PagefileUsage     uintptr
+	PeakPagefileUsage uintptr
+
+	/*
+		PrivateUsage is the amount of private memory committed by the process.
+	*/
+	PrivateUsage uintptr
+}
+
+/*
+	GetProcessMemoryInfo retrieves information about the memory usage of the specified process.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION
+	access right, and PROCESS_VM_READ before Windows 8.1.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/psapi/nf-psapi-getprocessmemoryinfo
+*/
+func GetProcessMemoryInfo(process win32.Handle) (ProcessMemoryCounters, error) {
+	var c C.PROCESS_MEMORY_COUNTERS_EX
+	if C.GetProcessMemoryInfo(C.HANDLE(unsafe.Pointer(process)), (*C.PROCESS_MEMORY_COUNTERS)(unsafe.Pointer(&c)), C.DWORD(unsafe.Sizeof(c))) == 0 {
+		return ProcessMemoryCounters{}, GetLastError()
+	}
+
+	return ProcessMemoryCounters{
+		PageFaultCount:             uint32(c.PageFaultCount),
+		PeakWorkingSetSize:         uintptr(c.PeakWorkingSetSize),
+		WorkingSetSize:             uintptr(c.WorkingSetSize),
+		QuotaPeakPagedPoolUsage:    uintptr(c.QuotaPeakPagedPoolUsage),
+		QuotaPagedPoolUsage:        uintptr(c.QuotaPagedPoolUsage),
+		QuotaPeakNonPagedPoolUsage: uintptr(c.QuotaPeakNonPagedPoolUsage),
+		QuotaNonPagedPoolUsage:     uintptr(c.QuotaNonPagedPoolUsage),
+		PagefileUsage:              uintptr(c.PagefileUsage),
+		PeakPagefileUsage:          uintptr(c.PeakPagefileUsage),
+		PrivateUsage:               uintptr(c.PrivateUsage),
+	}, nil
+}
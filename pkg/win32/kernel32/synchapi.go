@@ -0,0 +1,143 @@
+package kernel32
+
+/*
+	#include <windows.h>
+	#include <synchapi.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	INFINITE makes a wait function return only when the object is signaled.
+*/
+const INFINITE uint32 = 0xFFFFFFFF
+
+type WaitResult uint32
+
+const (
+	/*
+		WAIT_OBJECT_0 is returned when the state of the object is signaled.
+	*/
+	WAIT_OBJECT_0 WaitResult = 0x00000000
+
+	/*
+		WAIT_ABANDONED is returned when the object is a mutex that was not released
+		by the thread that owned it before it terminated. Ownership is granted to the calling thread.
+	*/
+	WAIT_ABANDONED WaitResult = 0x00000080
+
+	/*
+		WAIT_TIMEOUT is returned when the time-out interval elapsed and the object is still nonsignaled.
+	*/
+	WAIT_TIMEOUT WaitResult = 0x00000102
+
+	/*
+		WAIT_FAILED is returned when the function has failed.
+	*/
+	WAIT_FAILED WaitResult = 0xFFFFFFFF
+)
+
+/*
+	WaitForSingleObject waits until the specified object is in the signaled state
+	or the time-out interval, in milliseconds, elapses.
+
+	The handle must have the SYNCHRONIZE access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-waitforsingleobject
+*/
+func WaitForSingleObject(handle win32.Handle, milliseconds uint32) (WaitResult, error) {
+	result := WaitResult(C.WaitForSingleObject(C.HANDLE(unsafe.Pointer(handle)), C.DWORD(milliseconds)))
+	if result == WAIT_FAILED {
+		return result, GetLastError()
+	}
+
+	return result, nil
+}
+
+/*
+	MAXIMUM_WAIT_OBJECTS is the maximum number of handles WaitForMultipleObjects waits on.
+*/
+const MAXIMUM_WAIT_OBJECTS = 64
+
+/*
+	WaitForMultipleObjects waits until one or all of the specified objects are
+	in the signaled state, or the time-out interval, in milliseconds, elapses.
+
+	When waitAll is false and an object is signaled, the result is WAIT_OBJECT_0
+	plus the index of that object in handles, or WAIT_ABANDONED plus the index
+	for an abandoned mutex. If several objects are signaled, the lowest index is returned.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-waitformultipleobjects
+*/
+func WaitForMultipleObjects(handles []win32.Handle, waitAll bool, milliseconds uint32) (WaitResult, error) {
+	if len(handles) == 0 || len(handles) > MAXIMUM_WAIT_OBJECTS {
+		return WAIT_FAILED, ERROR_INVALID_PARAMETER
+	}
+
+	var all C.BOOL
+	if waitAll {
+		all = 1
+	}
+
+	result := WaitResult(C.WaitForMultipleObjects(C.DWORD(len(handles)), (*C.HANDLE)(unsafe.Pointer(&handles[0])), all, C.DWORD(milliseconds)))
+	if result == WAIT_FAILED {
+		return result, GetLastError()
+	}
+
+	return result, nil
+}
+
+/*
+	CreateEvent creates an unnamed event object. A manual-reset event stays
+	signaled until ResetEvent is called, an auto-reset event is reset when a
+	single waiting thread is released.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-createeventw
+*/
+func CreateEvent(manualReset bool, initialState bool) (win32.Handle, error) {
+	var manual, initial C.BOOL
+	if manualReset {
+		manual = 1
+	}
+	if initialState {
+		initial = 1
+	}
+
+	handle := C.CreateEventW(nil, manual, initial, nil)
+	if handle == nil {
+		return 0, GetLastError()
+	}
+
+	return win32.Handle(unsafe.Pointer(handle)), nil
+}
+
+/*
+	SetEvent sets the specified event object to the signaled state.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-setevent
+*/
+func SetEvent(event win32.Handle) error {
+	if C.SetEvent(C.HANDLE(unsafe.Pointer(event))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	ResetEvent sets the specified event object to the nonsignaled state.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-resetevent
+*/
+func ResetEvent(event win32.Handle) error {
+	if C.ResetEvent(C.HANDLE(unsafe.Pointer(event))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
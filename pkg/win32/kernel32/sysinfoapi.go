@@ -0,0 +1,55 @@
+package kernel32
+
+/*
+	#include <stdlib.h>
+	#include <windows.h>
+	#include <sysinfoapi.h>
+*/
+import "C"
+
+import "errors"
+
+type LogicalProcessorRelationship uint32
+
+const (
+	RelationProcessorCore    LogicalProcessorRelationship = 0
+	RelationNumaNode         LogicalProcessorRelationship = 1
+	RelationCache            LogicalProcessorRelationship = 2
+	RelationProcessorPackage LogicalProcessorRelationship = 3
+	RelationGroup            LogicalProcessorRelationship = 4
+	RelationProcessorDie     LogicalProcessorRelationship = 5
+	RelationNumaNodeEx       LogicalProcessorRelationship = 6
+	RelationProcessorModule  LogicalProcessorRelationship = 7
+	RelationAll              LogicalProcessorRelationship = 0xFFFF
+)
+
+/*
+	GetLogicalProcessorInformationEx retrieves the relationships of the logical processors
+	of the system as the raw buffer of variable sized SYSTEM_LOGICAL_PROCESSOR_INFORMATION_EX entries.
+	Use the topology package to decode it.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/sysinfoapi/nf-sysinfoapi-getlogicalprocessorinformationex
+*/
+func GetLogicalProcessorInformationEx(relationship LogicalProcessorRelationship) ([]byte, error) {
+	length := C.DWORD(4096)
+	for {
+		// Allocated in C so that the entries are aligned for the pointer sized affinity masks.
+		buf := C.malloc(C.size_t(length))
+
+		ok := C.GetLogicalProcessorInformationEx(C.LOGICAL_PROCESSOR_RELATIONSHIP(relationship), C.PSYSTEM_LOGICAL_PROCESSOR_INFORMATION_EX(buf), &length)
+		if ok == 0 {
+			C.free(buf)
+
+			err := GetLastError()
+			if errors.Is(err, ERROR_INSUFFICIENT_BUFFER) {
+				continue
+			}
+			return nil, err
+		}
+
+		data := C.GoBytes(buf, C.int(length))
+		C.free(buf)
+
+		return data, nil
+	}
+}
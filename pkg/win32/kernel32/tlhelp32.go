@@ -132,6 +132,50 @@ func (me32 ModuleEntry32) ModulePathString() string {
 	return string(me32.ModulePath[:i])
 }
 
+/*
+	ThreadEntry32 describes an entry from a list of the threads
+	executing in the system when a snapshot was taken.
+*/
+type ThreadEntry32 struct {
+	/*
+		Size is the size of the structure, in bytes.
+		Before calling the Thread32First function,
+		set this member to unsafe.Sizeof(ThreadEntry32).
+		If you do not initialize Size, Thread32First fails.
+	*/
+	Size uint32
+
+	/*
+		Usage is no longer used and is always set to zero.
+	*/
+	Usage uint32
+
+	/*
+		ThreadID is the thread identifier.
+	*/
+	ThreadID uint32
+
+	/*
+		OwnerProcessID is the identifier of the process that created the thread.
+	*/
+	OwnerProcessID uint32
+
+	/*
+		BasePriority is the kernel base priority level assigned to the thread.
+	*/
+	BasePriority int32
+
+	/*
+		DeltaPriority is no longer used and is always set to zero.
+	*/
+	DeltaPriority int32
+
+	/*
+		Flags is no longer used and is always set to zero.
+	*/
+	Flags uint32
+}
+
 const (
 	/*
 		TH32CS_INHERIT indicates that the snapshot handle is to be inheritable.
@@ -292,3 +336,31 @@ func Process32Next(snapshot win32.Handle, pe *ProcessEntry32) error {
 
 	return nil
 }
+
+/*
+	Thread32First retrieves information about the first thread of any process encountered in a system snapshot.
+
+	The calling application must set the Size member of ThreadEntry32 to the size, in bytes, of the structure.
+
+	For more info, see: https://docs.microsoft.com/en-us/windows/win32/api/tlhelp32/nf-tlhelp32-thread32first
+*/
+func Thread32First(snapshot win32.Handle, te *ThreadEntry32) error {
+	if C.Thread32First(C.HANDLE(unsafe.Pointer(snapshot)), (*C.THREADENTRY32)(unsafe.Pointer(te))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	Thread32Next retrieves information about the next thread of any process encountered in the system memory snapshot.
+
+	For more info, see: https://docs.microsoft.com/en-us/windows/win32/api/tlhelp32/nf-tlhelp32-thread32next
+*/
+func Thread32Next(snapshot win32.Handle, te *ThreadEntry32) error {
+	if C.Thread32Next(C.HANDLE(unsafe.Pointer(snapshot)), (*C.THREADENTRY32)(unsafe.Pointer(te))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package kernel32
+
+import (
+	"strconv"
+)
+
+type ErrorCode uint32
+
+func (e ErrorCode) Error() string {
+	return "win32 error code: " + strconv.Itoa(int(e))
+}
+
+/*
+	System error codes returned by GetLastError that callers commonly need to test for.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/debug/system-error-codes--0-499-
+*/
+const (
+	ERROR_SUCCESS             ErrorCode = 0
+	ERROR_ACCESS_DENIED       ErrorCode = 5
+	ERROR_INVALID_HANDLE      ErrorCode = 6
+	ERROR_NOT_ENOUGH_MEMORY   ErrorCode = 8
+	ERROR_NO_MORE_FILES       ErrorCode = 18
+	ERROR_BAD_LENGTH          ErrorCode = 24
+	ERROR_INVALID_PARAMETER   ErrorCode = 87
+	ERROR_SEM_TIMEOUT         ErrorCode = 121
+	ERROR_INSUFFICIENT_BUFFER ErrorCode = 122
+	ERROR_MORE_DATA           ErrorCode = 234
+	ERROR_PARTIAL_COPY        ErrorCode = 299
+	ERROR_ABANDONED_WAIT_0    ErrorCode = 735
+)
@@ -0,0 +1,307 @@
+package kernel32
+
+type ProcessAccess uint32
+
+const (
+	/*
+		DELETE is the right to delete the object.
+	*/
+	DELETE ProcessAccess = 0x00010000
+
+	/*
+		SYNCHRONIZE is the right to use the object for synchronization.
+		This enables a thread to wait until the object is in the signaled state.
+		Some object types do not support this access right.
+	*/
+	SYNCHRONIZE ProcessAccess = 0x00100000
+
+	/*
+		READ_CONTROL is the right to read the information in the object's security descriptor,
+		not including the information in the system access control list (SACL).
+	*/
+	READ_CONTROL ProcessAccess = 0x00020000
+
+	/*
+		WRITE_DAC is the right to modify the discretionary access control list (DACL) in the object's security descriptor.
+	*/
+	WRITE_DAC ProcessAccess = 0x00040000
+
+	/*
+		WRITE_OWNER is the right to change the owner in the object's security descriptor.
+	*/
+	WRITE_OWNER ProcessAccess = 0x00080000
+
+	/*
+		STANDARD_RIGHTS_ALL combines DELETE, READ_CONTROL, WRITE_DAC, WRITE_OWNER, and SYNCHRONIZE access.
+	*/
+	STANDARD_RIGHTS_ALL ProcessAccess = DELETE | READ_CONTROL | WRITE_DAC | WRITE_OWNER | SYNCHRONIZE
+
+	/*
+		STANDARD_RIGHTS_EXECUTE is currently defined to equal READ_CONTROL.
+	*/
+	STANDARD_RIGHTS_EXECUTE ProcessAccess = READ_CONTROL
+
+	/*
+		STANDARD_RIGHTS_READ is currently defined to equal READ_CONTROL.
+	*/
+	STANDARD_RIGHTS_READ ProcessAccess = READ_CONTROL
+
+	/*
+		STANDARD_RIGHTS_REQUIRED combines DELETE, READ_CONTROL, WRITE_DAC, and WRITE_OWNER access.
+	*/
+	STANDARD_RIGHTS_REQUIRED ProcessAccess = DELETE | READ_CONTROL | WRITE_DAC | WRITE_OWNER
+
+	/*
+		STANDARD_RIGHTS_WRITE is currently defined to equal READ_CONTROL.
+	*/
+	STANDARD_RIGHTS_WRITE ProcessAccess = READ_CONTROL
+
+	/*
+		PROCESS_ALL_ACCESS is all possible access rights for a process object.Windows Server 2003 and Windows XP:
+		The size of the PROCESS_ALL_ACCESS flag increased on Windows Server 2008 and Windows Vista.
+		If an application compiled for Windows Server 2008 and Windows Vista is run on Windows Server 2003 or Windows XP,
+		the PROCESS_ALL_ACCESS flag is too large and the function specifying this flag fails with ERROR_ACCESS_DENIED.
+		To avoid this problem, specify the minimum set of access rights required for the operation.
+		If PROCESS_ALL_ACCESS must be used, set _WIN32_WINNT to the minimum operating system targeted by your application
+		(for example, #define _WIN32_WINNT _WIN32_WINNT_WINXP).
+		For more information, see Using the Windows Headers.
+	*/
+	PROCESS_ALL_ACCESS ProcessAccess = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0xFFF
+
+	/*
+		PROCESS_CREATE_PROCESS is required to use this process as the parent process with PROC_THREAD_ATTRIBUTE_PARENT_PROCESS.
+	*/
+	PROCESS_CREATE_PROCESS ProcessAccess = 0x0080
+
+	/*
+		PROCESS_CREATE_THREAD is required to create a thread in the process.
+	*/
+	PROCESS_CREATE_THREAD ProcessAccess = 0x0002
+
+	/*
+		PROCESS_DUP_HANDLE is required to duplicate a handle using DuplicateHandle.
+	*/
+	PROCESS_DUP_HANDLE ProcessAccess = 0x0040
+
+	/*
+		PROCESS_QUERY_INFORMATION is required to retrieve certain information about a process,
+		 such as its token, exit code, and priority class (see OpenProcessToken).
+	*/
+	PROCESS_QUERY_INFORMATION ProcessAccess = 0x0400
+
+	/*
+		PROCESS_QUERY_LIMITED_INFORMATION is required to retrieve certain information about a process
+		(see GetExitCodeProcess, GetPriorityClass, IsProcessInJob, QueryFullProcessImageName).
+		A handle that has the PROCESS_QUERY_INFORMATION access right is automatically
+		granted PROCESS_QUERY_LIMITED_INFORMATION.Windows Server 2003 and Windows XP:
+		This access right is not supported.
+	*/
+	PROCESS_QUERY_LIMITED_INFORMATION ProcessAccess = 0x1000
+
+	/*
+		PROCESS_SET_INFORMATION is required to set certain information about a process, such as its priority class (see SetPriorityClass).
+	*/
+	PROCESS_SET_INFORMATION ProcessAccess = 0x0200
+
+	/*
+		PROCESS_SET_QUOTA is required to set memory limits using SetProcessWorkingSetSize.
+	*/
+	PROCESS_SET_QUOTA ProcessAccess = 0x0100
+
+	/*
+		PROCESS_SUSPEND_RESUME is required to suspend or resume a process.
+	*/
+	PROCESS_SUSPEND_RESUME ProcessAccess = 0x0800
+
+	/*
+		PROCESS_TERMINATE is required to terminate a process using TerminateProcess.
+	*/
+	PROCESS_TERMINATE ProcessAccess = 0x0001
+
+	/*
+		PROCESS_VM_OPERATION is required to perform an operation on the address space of a process (see VirtualProtectEx and WriteProcessMemory).
+	*/
+	PROCESS_VM_OPERATION ProcessAccess = 0x0008
+
+	/*
+		PROCESS_VM_READ is required to read memory in a process using ReadProcessMemory.
+	*/
+	PROCESS_VM_READ ProcessAccess = 0x0010
+
+	/*
+		PROCESS_VM_WRITE is required to write to memory in a process using WriteProcessMemory.
+	*/
+	PROCESS_VM_WRITE ProcessAccess = 0x0020
+)
+
+type PageAccess uint32
+
+const (
+	/*
+		PAGE_EXECUTE enables execute access to the committed region of pages.
+		An attempt to write to the committed region results in an access violation.
+		This flag is not supported by the CreateFileMapping function.
+	*/
+	PAGE_EXECUTE PageAccess = 0x10
+
+	/*
+		PAGE_EXECUTE_READ Enables execute or read-only access to
+		the committed region of pages. An attempt to write to
+		the committed region results in an access violation.
+
+		Windows Server 2003 and Windows XP: This attribute
+		is not supported by the CreateFileMapping function
+		until Windows XP with SP2 and Windows Server 2003 with SP1.
+	*/
+	PAGE_EXECUTE_READ PageAccess = 0x20
+
+	/*
+		PAGE_EXECUTE_READWRITE enables execute, read-only, or read/write
+		access to the committed region of pages.
+
+		Windows Server 2003 and Windows XP: This attribute is
+		not supported by the CreateFileMapping function until
+		Windows XP with SP2 and Windows Server 2003 with SP1.
+	*/
+	PAGE_EXECUTE_READWRITE PageAccess = 0x40
+
+	/*
+		PAGE_EXECUTE_WRITECOPY enables execute, read-only, or copy-on-write
+		access to a mapped view of a file mapping object. An attempt to
+		write to a committed copy-on-write page results in a private
+		copy of the page being made for the process. The private page is
+		marked as PAGE_EXECUTE_READWRITE, and the change is written to the new page.
+
+		This flag is not supported by the VirtualAlloc or VirtualAllocEx functions.
+		Windows Vista, Windows Server 2003 and Windows XP:
+		This attribute is not supported by the CreateFileMapping function until
+		Windows Vista with SP1 and Windows Server 2008.
+	*/
+	PAGE_EXECUTE_WRITECOPY PageAccess = 0x80
+
+	/*
+		PAGE_NOACCESS disables all access to the committed region of pages.
+		An attempt to read from, write to, or execute the committed
+		region results in an access violation.
+
+		This flag is not supported by the CreateFileMapping function.
+	*/
+	PAGE_NOACCESS PageAccess = 0x01
+
+	/*
+		PAGE_READONLY enables read-only access to the committed region of pages.
+		An attempt to write to the committed region results in an access violation.
+		If Data Execution Prevention is enabled, an attempt to execute code
+		in the committed region results in an access violation.
+	*/
+	PAGE_READONLY PageAccess = 0x02
+
+	/*
+		PAGE_READWRITE enables read-only or read/write access to the
+		committed region of pages. If Data Execution Prevention is
+		enabled, attempting to execute code in the committed
+		region results in an access violation.
+	*/
+	PAGE_READWRITE PageAccess = 0x04
+
+	/*
+		PAGE_WRITECOPY enables read-only or copy-on-write access to a
+		mapped view of a file mapping object. An attempt to write to a
+		committed copy-on-write page results in a private copy of the
+		page being made for the process. The private page is marked as
+		PAGE_READWRITE, and the change is written to the new page.
+		If Data Execution Prevention is enabled, attempting to execute
+		code in the committed region results in an access violation.
+
+		This flag is not supported by the VirtualAlloc or VirtualAllocEx functions.
+	*/
+	PAGE_WRITECOPY PageAccess = 0x08
+
+	/*
+		PAGE_TARGETS_INVALID sets all locations in the pages as invalid targets for CFG.
+		Used along with any execute page protection like PAGE_EXECUTE,
+		PAGE_EXECUTE_READ, PAGE_EXECUTE_READWRITE and PAGE_EXECUTE_WRITECOPY.
+		Any indirect call to locations in those pages will fail CFG checks and
+		the process will be terminated. The default behavior for executable
+		pages allocated is to be marked valid call targets for CFG.
+
+		This flag is not supported by the VirtualProtect or CreateFileMapping functions.
+	*/
+	PAGE_TARGETS_INVALID PageAccess = 0x40000000
+
+	/*
+		PAGE_TARGETS_NO_UPDATE pages in the region will not have their CFG information
+		updated while the protection changes for VirtualProtect. For example, if the
+		pages in the region was allocated using PAGE_TARGETS_INVALID, then the invalid
+		information will be maintained while the page protection changes.
+		This flag is only valid when the protection changes to an executable type like
+		PAGE_EXECUTE, PAGE_EXECUTE_READ, PAGE_EXECUTE_READWRITE and PAGE_EXECUTE_WRITECOPY.
+		The default behavior for VirtualProtect protection change to executable
+		is to mark all locations as valid call targets for CFG.
+
+		PAGE_TARGETS_NO_UPDATE has the same value as PAGE_TARGETS_INVALID:
+		which of the two the bit means depends on the function it is passed to.
+	*/
+	PAGE_TARGETS_NO_UPDATE PageAccess = PAGE_TARGETS_INVALID
+
+	/*
+		PAGE_GUARD pages in the region become guard pages. Any attempt to access a
+		guard page causes the system to raise a STATUS_GUARD_PAGE_VIOLATION exception
+		and turn off the guard page status. Guard pages thus act as a one-time access
+		alarm. For more information, see Creating Guard Pages. When an access attempt
+		leads the system to turn off guard page status, the underlying page protection takes over.
+		If a guard page exception occurs during a system service, the service typically
+		returns a failure status indicator. This value cannot be used with PAGE_NOACCESS.
+
+		This flag is not supported by the CreateFileMapping function.
+	*/
+	PAGE_GUARD PageAccess = 0x100
+
+	/*
+		PAGE_NOCACHE Sets all pages to be non-cachable. Applications should not use
+		this attribute except when explicitly required for a device. Using the interlocked
+		functions with memory that is mapped with SEC_NOCACHE can result in an EXCEPTION_ILLEGAL_INSTRUCTION exception.
+		The PAGE_NOCACHE flag cannot be used with the PAGE_GUARD, PAGE_NOACCESS, or PAGE_WRITECOMBINE flags.
+		The PAGE_NOCACHE flag can be used only when allocating private memory with the VirtualAlloc,
+		VirtualAllocEx, or VirtualAllocExNuma functions. To enable non-cached memory
+		access for shared memory, specify the SEC_NOCACHE flag when calling the CreateFileMapping function.
+	*/
+	PAGE_NOCACHE PageAccess = 0x200
+
+	/*
+		PAGE_WRITECOMBINE Sets all pages to be write-combined.
+		Applications should not use this attribute except when
+		explicitly required for a device. Using the interlocked
+		functions with memory that is mapped as write-combined
+		can result in an EXCEPTION_ILLEGAL_INSTRUCTION exception.
+
+		The PAGE_WRITECOMBINE flag cannot be specified with the
+		PAGE_NOACCESS, PAGE_GUARD, and PAGE_NOCACHE flags.
+		The PAGE_WRITECOMBINE flag can be used only when allocating
+		private memory with the VirtualAlloc, VirtualAllocEx,
+		or VirtualAllocExNuma functions. To enable write-combined
+		memory access for shared memory, specify the SEC_WRITECOMBINE
+		flag when calling the CreateFileMapping function.
+	*/
+	PAGE_WRITECOMBINE PageAccess = 0x400
+
+	/*
+		PAGE_ENCLAVE_DECOMMIT indicates that the page will be
+		protected to prevent further use in an enclave.
+		This flag must not be combined with any other flags.
+		This flag is only valid for SGX2 enclaves.
+	*/
+	PAGE_ENCLAVE_DECOMMIT PageAccess = 0x10000000
+
+	/*
+		PAGE_ENCLAVE_THREAD_CONTROL The page contains a thread control structure (TCS).
+	*/
+	PAGE_ENCLAVE_THREAD_CONTROL PageAccess = 0x80000000
+
+	/*
+		PAGE_ENCLAVE_UNVALIDATED The page contents that you supply
+		are excluded from measurement with the EEXTEND instruction
+		of the Intel SGX programming model.
+	*/
+	PAGE_ENCLAVE_UNVALIDATED PageAccess = 0x20000000
+)
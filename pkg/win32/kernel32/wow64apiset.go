@@ -0,0 +1,30 @@
+package kernel32
+
+/*
+	#include <windows.h>
+	#include <wow64apiset.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	IsWow64Process determines whether the specified process is running under WOW64,
+	that is, whether it is a 32-bit process on a 64-bit version of Windows.
+
+	The handle must have the PROCESS_QUERY_INFORMATION or PROCESS_QUERY_LIMITED_INFORMATION access right.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/wow64apiset/nf-wow64apiset-iswow64process
+*/
+func IsWow64Process(process win32.Handle) (bool, error) {
+	var wow64 C.BOOL
+	if C.IsWow64Process(C.HANDLE(unsafe.Pointer(process)), &wow64) == 0 {
+		return false, GetLastError()
+	}
+
+	return wow64 != 0, nil
+}
@@ -0,0 +1,59 @@
+package kernel32
+
+/*
+	#include <windows.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/warrenulrich/win32-go/pkg/win32"
+)
+
+/*
+	Wow64GetThreadContext retrieves the context of the specified WOW64 thread.
+	Set ctx.ContextFlags to a combination of the WOW64_CONTEXT flags.
+
+	The handle must have THREAD_GET_CONTEXT access to the thread.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-wow64getthreadcontext
+*/
+func Wow64GetThreadContext(thread win32.Handle, ctx *win32.Wow64Context) error {
+	if C.Wow64GetThreadContext(C.HANDLE(unsafe.Pointer(thread)), (*C.WOW64_CONTEXT)(unsafe.Pointer(ctx))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	Wow64SetThreadContext sets the context of the specified WOW64 thread.
+
+	The handle must have THREAD_SET_CONTEXT access to the thread.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-wow64setthreadcontext
+*/
+func Wow64SetThreadContext(thread win32.Handle, ctx *win32.Wow64Context) error {
+	if C.Wow64SetThreadContext(C.HANDLE(unsafe.Pointer(thread)), (*C.WOW64_CONTEXT)(unsafe.Pointer(ctx))) == 0 {
+		return GetLastError()
+	}
+
+	return nil
+}
+
+/*
+	Wow64SuspendThread suspends the specified WOW64 thread.
+
+	If the function succeeds, the return value is the thread's previous suspend count.
+
+	For more information, see: https://docs.microsoft.com/en-us/windows/win32/api/wow64apiset/nf-wow64apiset-wow64suspendthread
+*/
+func Wow64SuspendThread(thread win32.Handle) (uint32, error) {
+	count := C.Wow64SuspendThread(C.HANDLE(unsafe.Pointer(thread)))
+	if count == C.DWORD(0xFFFFFFFF) {
+		return 0, GetLastError()
+	}
+
+	return uint32(count), nil
+}
@@ -0,0 +1,723 @@
+package x86
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTruncated = errors.New("x86: truncated instruction")
+	ErrTooLong   = errors.New("x86: instruction exceeds 15 bytes")
+)
+
+/*
+	InvalidOpcodeError is returned for opcodes that are undefined in the decoding mode.
+*/
+type InvalidOpcodeError struct {
+	Map    Map
+	Opcode byte
+	Mode   Mode
+}
+
+func (e *InvalidOpcodeError) Error() string {
+	return fmt.Sprintf("x86: invalid opcode %#02x in map %d for %d-bit mode", e.Opcode, e.Map, e.Mode)
+}
+
+type decoder struct {
+	code []byte
+	pos  int
+	inst Inst
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.pos >= MaxInstLen {
+		return 0, ErrTooLong
+	}
+
+	if d.pos >= len(d.code) {
+		return 0, ErrTruncated
+	}
+
+	return d.code[d.pos], nil
+}
+
+func (d *decoder) next() (byte, error) {
+	b, err := d.peek()
+	if err == nil {
+		d.pos++
+	}
+
+	return b, err
+}
+
+func (d *decoder) skip(n int) error {
+	if d.pos+n > MaxInstLen {
+		return ErrTooLong
+	}
+
+	if d.pos+n > len(d.code) {
+		return ErrTruncated
+	}
+
+	d.pos += n
+	return nil
+}
+
+func (d *decoder) invalid() error {
+	return &InvalidOpcodeError{Map: d.inst.Map, Opcode: d.inst.Opcode, Mode: d.inst.Mode}
+}
+
+/*
+	Decode decodes the instruction at the start of code.
+*/
+func Decode(code []byte, mode Mode) (Inst, error) {
+	if mode != Mode32 && mode != Mode64 {
+		return Inst{}, fmt.Errorf("x86: invalid mode %d", mode)
+	}
+
+	d := decoder{code: code, inst: Inst{Mode: mode}}
+	if err := d.decode(); err != nil {
+		return Inst{}, err
+	}
+
+	d.inst.Len = d.pos
+	return d.inst, nil
+}
+
+/*
+	Length returns the length of the instruction at the start of code.
+*/
+func Length(code []byte, mode Mode) (int, error) {
+	inst, err := Decode(code, mode)
+	return inst.Len, err
+}
+
+/*
+	DecodeAll decodes whole instructions from code until at least n bytes are covered.
+*/
+func DecodeAll(code []byte, mode Mode, n int) ([]Inst, error) {
+	var insts []Inst
+	for off := 0; off < n; {
+		inst, err := Decode(code[off:], mode)
+		if err != nil {
+			return insts, fmt.Errorf("x86: at offset %d: %w", off, err)
+		}
+
+		insts = append(insts, inst)
+		off += inst.Len
+	}
+
+	return insts, nil
+}
+
+func (d *decoder) decode() error {
+	inst := &d.inst
+
+	if err := d.prefixes(); err != nil {
+		return err
+	}
+
+	inst.AddressSize = 4
+	if inst.Mode == Mode64 {
+		inst.AddressSize = 8
+	}
+	if inst.Prefixes&PrefixAddrSize != 0 {
+		inst.AddressSize /= 2
+	}
+
+	inst.OperandSize = 4
+	switch {
+	case inst.REX&0x08 != 0:
+		inst.OperandSize = 8
+	case inst.Prefixes&PrefixOpSize != 0:
+		inst.OperandSize = 2
+	}
+
+	inst.OpcodeOffset = d.pos
+	op, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case 0xC4, 0xC5:
+		if ok, err := d.extended(); err != nil || ok {
+			return err
+		}
+		return d.vex(op)
+
+	case 0x62:
+		if ok, err := d.extended(); err != nil || ok {
+			return err
+		}
+		return d.evex()
+
+	case 0x8F:
+		b, err := d.peek()
+		if err != nil {
+			return err
+		}
+		if b&0x1F >= 8 {
+			return d.xop()
+		}
+
+	case 0x0F:
+		if op, err = d.next(); err != nil {
+			return err
+		}
+
+		switch op {
+		case 0x38:
+			inst.Map = Map0F38
+		case 0x3A:
+			inst.Map = Map0F3A
+		default:
+			inst.Map = Map0F
+			inst.Opcode = op
+			return d.legacy0F()
+		}
+
+		inst.OpcodeOffset = d.pos
+		if inst.Opcode, err = d.next(); err != nil {
+			return err
+		}
+
+		if err := d.modrm(); err != nil {
+			return err
+		}
+
+		if inst.Map == Map0F3A {
+			return d.imm(1)
+		}
+		return nil
+	}
+
+	inst.Map = Map1
+	inst.Opcode = op
+	return d.legacy1()
+}
+
+func (d *decoder) prefixes() error {
+	inst := &d.inst
+
+	for {
+		b, err := d.peek()
+		if err != nil {
+			return err
+		}
+
+		var p Prefix
+		switch b {
+		case 0xF0:
+			p = PrefixLock
+		case 0xF2:
+			p = PrefixREPNE
+		case 0xF3:
+			p = PrefixREP
+		case 0x26:
+			p = PrefixES
+		case 0x2E:
+			p = PrefixCS
+		case 0x36:
+			p = PrefixSS
+		case 0x3E:
+			p = PrefixDS
+		case 0x64:
+			p = PrefixFS
+		case 0x65:
+			p = PrefixGS
+		case 0x66:
+			p = PrefixOpSize
+		case 0x67:
+			p = PrefixAddrSize
+		default:
+			if inst.Mode == Mode64 && b&0xF0 == 0x40 {
+				inst.REX = b
+				d.pos++
+				continue
+			}
+			return nil
+		}
+
+		// A REX prefix is ignored unless it immediately precedes the opcode.
+		inst.REX = 0
+		inst.Prefixes |= p
+		d.pos++
+	}
+}
+
+/*
+	extended reports whether C4, C5 and 62 are LES, LDS and BOUND rather
+	than VEX and EVEX escapes, and decodes them if so. In 32-bit mode
+	they are only escapes when the following byte would be a register ModRM.
+*/
+func (d *decoder) extended() (bool, error) {
+	if d.inst.Mode == Mode64 {
+		return false, nil
+	}
+
+	b, err := d.peek()
+	if err != nil {
+		return false, err
+	}
+
+	if b&0xC0 == 0xC0 {
+		return false, nil
+	}
+
+	d.inst.Map = Map1
+	d.inst.Opcode = d.code[d.pos-1]
+	return true, d.modrm()
+}
+
+func (d *decoder) vex(escape byte) error {
+	inst := &d.inst
+	inst.Encoding = EncodingVEX
+	inst.Map = Map0F
+
+	if escape == 0xC4 {
+		b1, err := d.next()
+		if err != nil {
+			return err
+		}
+
+		if _, err := d.next(); err != nil {
+			return err
+		}
+
+		switch b1 & 0x1F {
+		case 1:
+			inst.Map = Map0F
+		case 2:
+			inst.Map = Map0F38
+		case 3:
+			inst.Map = Map0F3A
+		default:
+			return fmt.Errorf("x86: invalid VEX map %d", b1&0x1F)
+		}
+	} else if _, err := d.next(); err != nil {
+		return err
+	}
+
+	return d.vexOpcode()
+}
+
+func (d *decoder) evex() error {
+	inst := &d.inst
+	inst.Encoding = EncodingEVEX
+
+	p0, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	p1, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.next(); err != nil {
+		return err
+	}
+
+	if p1&0x04 == 0 {
+		return errors.New("x86: invalid EVEX prefix")
+	}
+
+	switch p0 & 0x07 {
+	case 1:
+		inst.Map = Map0F
+	case 2:
+		inst.Map = Map0F38
+	case 3:
+		inst.Map = Map0F3A
+	case 5:
+		inst.Map = MapEVEX5
+	case 6:
+		inst.Map = MapEVEX6
+	default:
+		return fmt.Errorf("x86: invalid EVEX map %d", p0&0x07)
+	}
+
+	return d.vexOpcode()
+}
+
+func (d *decoder) xop() error {
+	inst := &d.inst
+	inst.Encoding = EncodingXOP
+
+	b1, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.next(); err != nil {
+		return err
+	}
+
+	switch b1 & 0x1F {
+	case 8:
+		inst.Map = MapXOP8
+	case 9:
+		inst.Map = MapXOP9
+	case 0xA:
+		inst.Map = MapXOPA
+	default:
+		return fmt.Errorf("x86: invalid XOP map %d", b1&0x1F)
+	}
+
+	return d.vexOpcode()
+}
+
+/*
+	vexOpcode decodes the opcode and operands following a VEX, EVEX or XOP prefix.
+*/
+func (d *decoder) vexOpcode() error {
+	inst := &d.inst
+
+	inst.OpcodeOffset = d.pos
+	op, err := d.next()
+	if err != nil {
+		return err
+	}
+	inst.Opcode = op
+
+	// VZEROUPPER and VZEROALL are the only VEX instructions without ModRM.
+	if inst.Encoding == EncodingVEX && inst.Map == Map0F && op == 0x77 {
+		return nil
+	}
+
+	if err := d.modrm(); err != nil {
+		return err
+	}
+
+	switch inst.Map {
+	case Map0F3A, MapXOP8:
+		return d.imm(1)
+	case MapXOPA:
+		return d.imm(4)
+	case Map0F:
+		switch op {
+		case 0x70, 0x71, 0x72, 0x73, 0xC2, 0xC4, 0xC5, 0xC6:
+			return d.imm(1)
+		}
+	}
+
+	return nil
+}
+
+/*
+	immZ is the size of an immediate whose size follows the operand size,
+	which is never larger than 4 bytes.
+*/
+func (d *decoder) immZ() int {
+	if d.inst.OperandSize == 2 {
+		return 2
+	}
+	return 4
+}
+
+/*
+	relZ is the size of the displacement of near branches. In 64-bit mode
+	it is always 4 bytes, as Intel processors ignore the operand size prefix.
+*/
+func (d *decoder) relZ() int {
+	if d.inst.Mode == Mode64 {
+		return 4
+	}
+	return d.immZ()
+}
+
+func (d *decoder) imm(size int) error {
+	d.inst.ImmOffset = d.pos
+	d.inst.ImmSize = size
+	return d.skip(size)
+}
+
+func (d *decoder) rel(size int, branch Branch) error {
+	d.inst.Branch = branch
+	return d.imm(size)
+}
+
+func (d *decoder) modrm() error {
+	inst := &d.inst
+
+	inst.ModRMOffset = d.pos
+	m, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	inst.HasModRM = true
+	inst.ModRM = m
+
+	mod, rm := m>>6, m&7
+	if mod == 3 {
+		return nil
+	}
+
+	disp := 0
+	if inst.AddressSize == 2 {
+		switch {
+		case mod == 0 && rm == 6:
+			disp = 2
+		case mod == 1:
+			disp = 1
+		case mod == 2:
+			disp = 2
+		}
+	} else {
+		if rm == 4 {
+			sib, err := d.next()
+			if err != nil {
+				return err
+			}
+
+			if mod == 0 && sib&7 == 5 {
+				disp = 4
+			}
+		}
+
+		switch mod {
+		case 0:
+			if rm == 5 {
+				disp = 4
+				inst.RIPRelative = inst.Mode == Mode64
+			}
+		case 1:
+			disp = 1
+		case 2:
+			disp = 4
+		}
+	}
+
+	if disp == 0 {
+		return nil
+	}
+
+	inst.DispOffset = d.pos
+	inst.DispSize = disp
+	return d.skip(disp)
+}
+
+/*
+	reg returns the reg field of the ModRM byte, used as an opcode extension by groups.
+*/
+func (d *decoder) reg() byte {
+	return d.inst.ModRM >> 3 & 7
+}
+
+func (d *decoder) legacy1() error {
+	inst := &d.inst
+	op := inst.Opcode
+	x64 := inst.Mode == Mode64
+
+	switch {
+	case op < 0x40:
+		switch op & 7 {
+		case 0, 1, 2, 3:
+			return d.modrm()
+		case 4:
+			return d.imm(1)
+		case 5:
+			return d.imm(d.immZ())
+		}
+
+		// PUSH/POP of segment registers and the BCD adjustments.
+		if x64 {
+			return d.invalid()
+		}
+		return nil
+
+	case op < 0x60:
+		// INC/DEC (32-bit mode only, REX otherwise), PUSH and POP.
+		return nil
+
+	case op >= 0x70 && op <= 0x7F:
+		return d.rel(1, BranchJcc)
+
+	case op >= 0x84 && op <= 0x8F:
+		return d.modrm()
+
+	case op >= 0x90 && op <= 0x9F && op != 0x9A:
+		return nil
+
+	case op >= 0xB0 && op <= 0xB7:
+		return d.imm(1)
+
+	case op >= 0xB8 && op <= 0xBF:
+		if inst.OperandSize == 8 {
+			return d.imm(8)
+		}
+		return d.imm(d.immZ())
+
+	case op >= 0xD8 && op <= 0xDF:
+		return d.modrm()
+	}
+
+	switch op {
+	case 0x60, 0x61, 0xCE:
+		if x64 {
+			return d.invalid()
+		}
+		return nil
+
+	case 0x62, 0x63, 0xC4, 0xC5, 0xD0, 0xD1, 0xD2, 0xD3, 0xFE, 0xFF:
+		return d.modrm()
+
+	case 0x68:
+		return d.imm(d.immZ())
+
+	case 0x69, 0x81:
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		return d.imm(d.immZ())
+
+	case 0x6A, 0xA8, 0xCD, 0xE4, 0xE5, 0xE6, 0xE7:
+		return d.imm(1)
+
+	case 0x6B, 0x80, 0x83, 0xC0, 0xC1, 0xC6:
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		return d.imm(1)
+
+	case 0x82:
+		if x64 {
+			return d.invalid()
+		}
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		return d.imm(1)
+
+	case 0x6C, 0x6D, 0x6E, 0x6F,
+		0xA4, 0xA5, 0xA6, 0xA7, 0xAA, 0xAB, 0xAC, 0xAD, 0xAE, 0xAF,
+		0xC3, 0xC9, 0xCB, 0xCC, 0xCF, 0xD7,
+		0xEC, 0xED, 0xEE, 0xEF,
+		0xF1, 0xF4, 0xF5, 0xF8, 0xF9, 0xFA, 0xFB, 0xFC, 0xFD:
+		return nil
+
+	case 0x9A, 0xEA:
+		// Far CALL and JMP with an absolute pointer.
+		if x64 {
+			return d.invalid()
+		}
+		return d.imm(d.immZ() + 2)
+
+	case 0xA0, 0xA1, 0xA2, 0xA3:
+		// MOV with an absolute memory offset of the address size.
+		return d.imm(inst.AddressSize)
+
+	case 0xA9:
+		return d.imm(d.immZ())
+
+	case 0xC2, 0xCA:
+		return d.imm(2)
+
+	case 0xC7:
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		if inst.ModRM == 0xF8 {
+			// Unlike near branches, XBEGIN honors the operand size
+			// prefix in 64-bit mode.
+			return d.rel(d.immZ(), BranchXBegin)
+		}
+		return d.imm(d.immZ())
+
+	case 0xC8:
+		// ENTER imm16, imm8
+		return d.imm(3)
+
+	case 0xD4, 0xD5:
+		if x64 {
+			return d.invalid()
+		}
+		return d.imm(1)
+
+	case 0xD6:
+		if x64 {
+			return d.invalid()
+		}
+		return nil
+
+	case 0xE0, 0xE1, 0xE2, 0xE3:
+		return d.rel(1, BranchLoop)
+
+	case 0xE8:
+		return d.rel(d.relZ(), BranchCall)
+
+	case 0xE9:
+		return d.rel(d.relZ(), BranchJmp)
+
+	case 0xEB:
+		return d.rel(1, BranchJmp)
+
+	case 0xF6:
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		if d.reg() < 2 {
+			return d.imm(1)
+		}
+		return nil
+
+	case 0xF7:
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		if d.reg() < 2 {
+			return d.imm(d.immZ())
+		}
+		return nil
+	}
+
+	return d.invalid()
+}
+
+func (d *decoder) legacy0F() error {
+	op := d.inst.Opcode
+
+	switch {
+	case op >= 0x80 && op <= 0x8F:
+		return d.rel(d.relZ(), BranchJcc)
+
+	case op >= 0xC8 && op <= 0xCF:
+		// BSWAP
+		return nil
+	}
+
+	switch op {
+	case 0x04, 0x0A, 0x0C, 0x24, 0x25, 0x26, 0x27, 0x36, 0x39,
+		0x3B, 0x3C, 0x3D, 0x3E, 0x3F, 0x7A, 0x7B, 0xA6, 0xA7:
+		return d.invalid()
+
+	case 0x05, 0x06, 0x07, 0x08, 0x09, 0x0B, 0x0E,
+		0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x37,
+		0x77, 0xA0, 0xA1, 0xA2, 0xA8, 0xA9, 0xAA:
+		return nil
+
+	case 0x20, 0x21, 0x22, 0x23:
+		// MOV to and from control and debug registers ignore the mod
+		// field and always take a register operand.
+		d.inst.ModRMOffset = d.pos
+		m, err := d.next()
+		if err != nil {
+			return err
+		}
+		d.inst.HasModRM = true
+		d.inst.ModRM = m
+		return nil
+
+	case 0x0F, 0x70, 0x71, 0x72, 0x73, 0xA4, 0xAC, 0xBA, 0xC2, 0xC4, 0xC5, 0xC6:
+		// 0F 0F is 3DNow!, whose opcode is an imm8 suffix.
+		if err := d.modrm(); err != nil {
+			return err
+		}
+		return d.imm(1)
+	}
+
+	return d.modrm()
+}
@@ -0,0 +1,318 @@
+package x86
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+/*
+	layout is the part of an Inst that code patching depends on.
+*/
+type layout struct {
+	Len      int
+	Disp     int
+	DispSize int
+	Imm      int
+	ImmSize  int
+	Branch   Branch
+	RIP      bool
+}
+
+func layoutOf(i Inst) layout {
+	return layout{
+		Len:      i.Len,
+		Disp:     i.DispOffset,
+		DispSize: i.DispSize,
+		Imm:      i.ImmOffset,
+		ImmSize:  i.ImmSize,
+		Branch:   i.Branch,
+		RIP:      i.RIPRelative,
+	}
+}
+
+/*
+	corpus lists instructions with their layout as encoded by an assembler.
+*/
+var corpus = []struct {
+	name string
+	mode Mode
+	code string
+	want layout
+}{
+	// Plain one byte opcodes and function prologues.
+	{"nop", Mode64, "90", layout{Len: 1}},
+	{"ret", Mode64, "c3", layout{Len: 1}},
+	{"int3", Mode64, "cc", layout{Len: 1}},
+	{"push rbp", Mode64, "55", layout{Len: 1}},
+	{"mov rbp, rsp", Mode64, "48 89 e5", layout{Len: 3}},
+	{"sub rsp, 0x28", Mode64, "48 83 ec 28", layout{Len: 4, Imm: 3, ImmSize: 1}},
+	{"sub rsp, 0x100", Mode64, "48 81 ec 00 01 00 00", layout{Len: 7, Imm: 3, ImmSize: 4}},
+	{"ret 8", Mode64, "c2 08 00", layout{Len: 3, Imm: 1, ImmSize: 2}},
+	{"enter 0x10, 0", Mode64, "c8 10 00 00", layout{Len: 4, Imm: 1, ImmSize: 3}},
+	{"rep movsq", Mode64, "f3 48 a5", layout{Len: 3}},
+	{"lock cmpxchg [rdx], rcx", Mode64, "f0 48 0f b1 0a", layout{Len: 5}},
+
+	// Immediates sized by the operand size.
+	{"mov eax, 1", Mode64, "b8 01 00 00 00", layout{Len: 5, Imm: 1, ImmSize: 4}},
+	{"mov ax, 1", Mode64, "66 b8 01 00", layout{Len: 4, Imm: 2, ImmSize: 2}},
+	{"mov rax, imm64", Mode64, "48 b8 88 77 66 55 44 33 22 11", layout{Len: 10, Imm: 2, ImmSize: 8}},
+	{"mov rax, [moffs64]", Mode64, "48 a1 00 10 00 00 00 00 00 00", layout{Len: 10, Imm: 2, ImmSize: 8}},
+	{"test byte [rax], 1", Mode64, "f6 00 01", layout{Len: 3, Imm: 2, ImmSize: 1}},
+	{"not dword [rax]", Mode64, "f7 10", layout{Len: 2}},
+
+	// ModRM, SIB and displacements.
+	{"mov rax, [rsp+8]", Mode64, "48 8b 44 24 08", layout{Len: 5, Disp: 4, DispSize: 1}},
+	{"mov rax, [rsp+0x100]", Mode64, "48 8b 84 24 00 01 00 00", layout{Len: 8, Disp: 4, DispSize: 4}},
+	{"mov eax, [abs 0x1000]", Mode64, "8b 04 25 00 10 00 00", layout{Len: 7, Disp: 3, DispSize: 4}},
+	{"nop dword [rax+rax]", Mode64, "0f 1f 44 00 00", layout{Len: 5, Disp: 4, DispSize: 1}},
+	{"nop word [rax+rax+0]", Mode64, "66 0f 1f 84 00 00 00 00 00", layout{Len: 9, Disp: 5, DispSize: 4}},
+
+	// RIP-relative operands.
+	{"mov rax, [rip+0x10]", Mode64, "48 8b 05 10 00 00 00", layout{Len: 7, Disp: 3, DispSize: 4, RIP: true}},
+	{"jmp [rip]", Mode64, "ff 25 00 00 00 00", layout{Len: 6, Disp: 2, DispSize: 4, RIP: true}},
+	{"call [rip+0xff2]", Mode64, "ff 15 f2 0f 00 00", layout{Len: 6, Disp: 2, DispSize: 4, RIP: true}},
+	{"cmp byte [rip], 1", Mode64, "80 3d 00 00 00 00 01", layout{Len: 7, Disp: 2, DispSize: 4, Imm: 6, ImmSize: 1, RIP: true}},
+
+	// Relative branches.
+	{"call rel32", Mode64, "e8 00 00 00 00", layout{Len: 5, Imm: 1, ImmSize: 4, Branch: BranchCall}},
+	{"o16 call rel32", Mode64, "66 e8 00 00 00 00", layout{Len: 6, Imm: 2, ImmSize: 4, Branch: BranchCall}},
+	{"jmp rel32", Mode64, "e9 fb ff ff ff", layout{Len: 5, Imm: 1, ImmSize: 4, Branch: BranchJmp}},
+	{"jmp rel8", Mode64, "eb fe", layout{Len: 2, Imm: 1, ImmSize: 1, Branch: BranchJmp}},
+	{"je rel8", Mode64, "74 05", layout{Len: 2, Imm: 1, ImmSize: 1, Branch: BranchJcc}},
+	{"je rel32", Mode64, "0f 84 00 01 00 00", layout{Len: 6, Imm: 2, ImmSize: 4, Branch: BranchJcc}},
+	{"loop", Mode64, "e2 fe", layout{Len: 2, Imm: 1, ImmSize: 1, Branch: BranchLoop}},
+	{"jrcxz", Mode64, "e3 00", layout{Len: 2, Imm: 1, ImmSize: 1, Branch: BranchLoop}},
+	{"xbegin", Mode64, "c7 f8 00 00 00 00", layout{Len: 6, Imm: 2, ImmSize: 4, Branch: BranchXBegin}},
+	{"o16 xbegin", Mode64, "66 c7 f8 00 00", layout{Len: 5, Imm: 3, ImmSize: 2, Branch: BranchXBegin}},
+
+	// Two and three byte opcode maps.
+	{"syscall", Mode64, "0f 05", layout{Len: 2}},
+	{"cpuid", Mode64, "0f a2", layout{Len: 2}},
+	{"ud2", Mode64, "0f 0b", layout{Len: 2}},
+	{"bt eax, 3", Mode64, "0f ba e0 03", layout{Len: 4, Imm: 3, ImmSize: 1}},
+	{"pshufb xmm0, xmm1", Mode64, "66 0f 38 00 c1", layout{Len: 5}},
+	{"palignr xmm0, xmm1, 8", Mode64, "66 0f 3a 0f c1 08", layout{Len: 6, Imm: 5, ImmSize: 1}},
+
+	// MOV to and from control and debug registers ignore mod.
+	{"mov rax, cr0", Mode64, "0f 20 c0", layout{Len: 3}},
+	{"mov rax, cr0 (mod 0)", Mode64, "0f 20 00", layout{Len: 3}},
+	{"mov cr0, rbp (mod 0, rm 5)", Mode64, "0f 22 05", layout{Len: 3}},
+	{"mov rsp, dr0 (mod 1, rm 4)", Mode64, "0f 21 44", layout{Len: 3}},
+	{"mov dr0, rsp (mod 2, rm 4)", Mode64, "0f 23 84", layout{Len: 3}},
+	{"mov rax, cr0 (mod 2)", Mode64, "0f 20 80", layout{Len: 3}},
+	{"mov cr8, rax", Mode64, "44 0f 22 c0", layout{Len: 4}},
+
+	// VEX, EVEX and XOP.
+	{"vzeroupper", Mode64, "c5 f8 77", layout{Len: 3}},
+	{"vmovdqa ymm0, [rip]", Mode64, "c5 fd 6f 05 00 00 00 00", layout{Len: 8, Disp: 4, DispSize: 4, RIP: true}},
+	{"vinsertf128 ymm0, ymm0, xmm1, 1", Mode64, "c4 e3 7d 18 c1 01", layout{Len: 6, Imm: 5, ImmSize: 1}},
+	{"vmovaps zmm0, zmm1", Mode64, "62 f1 7c 48 28 c1", layout{Len: 6}},
+	{"vmovaps zmm0, [rip]", Mode64, "62 f1 7c 48 28 05 00 00 00 00", layout{Len: 10, Disp: 6, DispSize: 4, RIP: true}},
+	{"vprotb xmm0, xmm1, 5", Mode64, "8f e8 78 c0 c1 05", layout{Len: 6, Imm: 5, ImmSize: 1}},
+	{"pop rax (8F, not XOP)", Mode64, "8f c0", layout{Len: 2}},
+
+	// 32-bit mode.
+	{"push ebp", Mode32, "55", layout{Len: 1}},
+	{"inc eax", Mode32, "40", layout{Len: 1}},
+	{"pushad", Mode32, "60", layout{Len: 1}},
+	{"aam", Mode32, "d4 0a", layout{Len: 2, Imm: 1, ImmSize: 1}},
+	{"mov eax, [0x1000]", Mode32, "8b 05 00 10 00 00", layout{Len: 6, Disp: 2, DispSize: 4}},
+	{"mov eax, [moffs32]", Mode32, "a1 00 10 00 00", layout{Len: 5, Imm: 1, ImmSize: 4}},
+	{"mov eax, [bp+8]", Mode32, "67 8b 46 08", layout{Len: 4, Disp: 3, DispSize: 1}},
+	{"mov eax, [disp16]", Mode32, "67 8b 06 00 10", layout{Len: 5, Disp: 3, DispSize: 2}},
+	{"call rel32", Mode32, "e8 00 00 00 00", layout{Len: 5, Imm: 1, ImmSize: 4, Branch: BranchCall}},
+	{"call rel16", Mode32, "66 e8 00 00", layout{Len: 4, Imm: 2, ImmSize: 2, Branch: BranchCall}},
+	{"jmp rel8", Mode32, "eb 00", layout{Len: 2, Imm: 1, ImmSize: 1, Branch: BranchJmp}},
+	{"call far", Mode32, "9a 00 00 00 00 08 00", layout{Len: 7, Imm: 1, ImmSize: 6}},
+	{"jmp far", Mode32, "ea 00 00 00 00 08 00", layout{Len: 7, Imm: 1, ImmSize: 6}},
+	{"les eax, [eax]", Mode32, "c4 00", layout{Len: 2}},
+	{"bound eax, [eax]", Mode32, "62 00", layout{Len: 2}},
+	{"vzeroupper", Mode32, "c5 f8 77", layout{Len: 3}},
+	{"mov eax, cr0", Mode32, "0f 20 c0", layout{Len: 3}},
+	{"mov cr0, ebp (mod 0, rm 5)", Mode32, "0f 22 05", layout{Len: 3}},
+	{"mov esp, dr7 (mod 0, rm 4)", Mode32, "0f 21 3c", layout{Len: 3}},
+	{"mov dr7, eax (mod 1)", Mode32, "0f 23 78", layout{Len: 3}},
+}
+
+func TestDecodeCorpus(t *testing.T) {
+	for _, tc := range corpus {
+		code := unhex(t, tc.code)
+
+		inst, err := Decode(code, tc.mode)
+		if err != nil {
+			t.Errorf("%d-bit %s: %v", tc.mode, tc.name, err)
+			continue
+		}
+
+		if got := layoutOf(inst); got != tc.want {
+			t.Errorf("%d-bit %s: got %+v, want %+v", tc.mode, tc.name, got, tc.want)
+		}
+
+		// Trailing bytes must not change the result.
+		padded := append(append([]byte(nil), code...), 0xCC, 0xCC, 0xCC, 0xCC)
+		if again, err := Decode(padded, tc.mode); err != nil || layoutOf(again) != tc.want {
+			t.Errorf("%d-bit %s: with trailing bytes got %+v, %v", tc.mode, tc.name, layoutOf(again), err)
+		}
+
+		// Every strict prefix is truncated.
+		for n := 0; n < len(code); n++ {
+			if _, err := Decode(code[:n], tc.mode); !errors.Is(err, ErrTruncated) {
+				t.Errorf("%d-bit %s: %d of %d bytes: got %v, want ErrTruncated", tc.mode, tc.name, n, len(code), err)
+			}
+		}
+	}
+}
+
+func TestDecodeMovCRModRM(t *testing.T) {
+	for op := byte(0x20); op <= 0x23; op++ {
+		for m := 0; m < 0x100; m++ {
+			for _, mode := range []Mode{Mode32, Mode64} {
+				inst, err := Decode([]byte{0x0F, op, byte(m)}, mode)
+				if err != nil {
+					t.Fatalf("%d-bit 0F %02X %02X: %v", mode, op, m, err)
+				}
+
+				if inst.Len != 3 || !inst.HasModRM || inst.ModRM != byte(m) || inst.ModRMOffset != 2 || inst.NeedsRelocation() {
+					t.Errorf("%d-bit 0F %02X %02X: got %+v", mode, op, m, inst)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		code string
+		op   byte
+		m    Map
+	}{
+		{Mode64, "60", 0x60, Map1},
+		{Mode64, "06", 0x06, Map1},
+		{Mode64, "ea 00 00 00 00 08 00", 0xEA, Map1},
+		{Mode64, "d4 0a", 0xD4, Map1},
+		{Mode64, "82 c0 01", 0x82, Map1},
+		{Mode32, "0f 04", 0x04, Map0F},
+		{Mode64, "0f 24 c0", 0x24, Map0F},
+	}
+
+	for _, tc := range tests {
+		_, err := Decode(unhex(t, tc.code), tc.mode)
+
+		var invalid *InvalidOpcodeError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%d-bit %s: got %v, want InvalidOpcodeError", tc.mode, tc.code, err)
+			continue
+		}
+
+		if *invalid != (InvalidOpcodeError{Map: tc.m, Opcode: tc.op, Mode: tc.mode}) {
+			t.Errorf("%d-bit %s: got %+v", tc.mode, tc.code, *invalid)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode([]byte{0x90}, 16); err == nil {
+		t.Error("16-bit mode: no error")
+	}
+
+	for _, code := range []string{"c4 e0 78 00 c0", "62 f0 7c 48 28 c1", "8f ef 78 c0 c1"} {
+		if _, err := Decode(unhex(t, code), Mode64); err == nil {
+			t.Errorf("%s: no error for an invalid map", code)
+		}
+	}
+
+	prefixes := strings.Repeat("66 ", 14)
+	if inst, err := Decode(unhex(t, prefixes+"90"), Mode64); err != nil || inst.Len != MaxInstLen {
+		t.Errorf("15 bytes: got %d, %v", inst.Len, err)
+	}
+
+	if _, err := Decode(unhex(t, "66 "+prefixes+"90"), Mode64); !errors.Is(err, ErrTooLong) {
+		t.Errorf("16 bytes: got %v, want ErrTooLong", err)
+	}
+
+	if _, err := Decode(unhex(t, prefixes+"b8 00 00 00 00"), Mode64); !errors.Is(err, ErrTooLong) {
+		t.Errorf("immediate past 15 bytes: got %v, want ErrTooLong", err)
+	}
+}
+
+func TestREXIgnoredBeforePrefix(t *testing.T) {
+	// A REX prefix followed by another prefix is ignored, so this is mov ax, imm16.
+	inst, err := Decode(unhex(t, "48 66 b8 01 00"), Mode64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inst.REX != 0 || inst.OperandSize != 2 || inst.Len != 5 {
+		t.Errorf("got REX %#x, operand size %d, length %d", inst.REX, inst.OperandSize, inst.Len)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		code string
+		pc   uint64
+		want uint64
+		ok   bool
+	}{
+		{Mode64, "e9 fb ff ff ff", 0x140001000, 0x140001000, true},
+		{Mode64, "eb fe", 0x140001000, 0x140001000, true},
+		{Mode64, "0f 84 00 01 00 00", 0x140001000, 0x140001106, true},
+		{Mode64, "48 8b 05 10 00 00 00", 0x7ff600001000, 0x7ff600001017, true},
+		{Mode64, "67 8b 05 10 00 00 00", 0xFFFFFFF0, 0x7, true},
+		{Mode32, "e8 00 00 00 f0", 0x20000000, 0x10000005, true},
+		{Mode32, "e9 00 00 00 70", 0x90000000, 0x5, true},
+		{Mode64, "48 89 e5", 0x1000, 0, false},
+		{Mode32, "8b 05 00 10 00 00", 0x1000, 0, false},
+	}
+
+	for _, tc := range tests {
+		code := unhex(t, tc.code)
+
+		inst, err := Decode(code, tc.mode)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.code, err)
+		}
+
+		got, ok := inst.Target(code, tc.pc)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%d-bit %s at %#x: got %#x, %v; want %#x, %v", tc.mode, tc.code, tc.pc, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	// push rbp; mov rbp, rsp; sub rsp, 0x20; mov rax, [rip]
+	code := unhex(t, "55 48 89 e5 48 83 ec 20 48 8b 05 00 00 00 00")
+
+	insts, err := DecodeAll(code, Mode64, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lens []int
+	for _, inst := range insts {
+		lens = append(lens, inst.Len)
+	}
+
+	if len(lens) != 3 || lens[0] != 1 || lens[1] != 3 || lens[2] != 4 {
+		t.Errorf("got lengths %v, want [1 3 4]", lens)
+	}
+
+	if _, err := DecodeAll(code[:10], Mode64, 9); !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v, want ErrTruncated", err)
+	}
+}
@@ -0,0 +1,226 @@
+/*
+	Package x86 decodes the length and layout of x86 and x64 instructions.
+
+	It does not disassemble: it finds instruction boundaries, where the
+	displacement and immediate of an instruction are located, and whether
+	the instruction is position dependent, which is what code patching
+	needs to move instructions around safely.
+*/
+package x86
+
+import "strconv"
+
+/*
+	Mode is the processor mode the code is decoded for.
+*/
+type Mode int
+
+const (
+	Mode32 Mode = 32
+	Mode64 Mode = 64
+)
+
+/*
+	MaxInstLen is the architectural limit on the length of an instruction.
+*/
+const MaxInstLen = 15
+
+/*
+	Map is the opcode map an opcode belongs to.
+*/
+type Map uint8
+
+const (
+	Map1     Map = iota // one byte opcodes
+	Map0F               // 0F xx
+	Map0F38             // 0F 38 xx
+	Map0F3A             // 0F 3A xx
+	MapEVEX5            // EVEX map 5
+	MapEVEX6            // EVEX map 6
+	MapXOP8             // AMD XOP map 8
+	MapXOP9             // AMD XOP map 9
+	MapXOPA             // AMD XOP map A
+)
+
+/*
+	Encoding is the encoding scheme of an instruction.
+*/
+type Encoding uint8
+
+const (
+	EncodingLegacy Encoding = iota
+	EncodingVEX
+	EncodingEVEX
+	EncodingXOP
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingLegacy:
+		return "legacy"
+	case EncodingVEX:
+		return "VEX"
+	case EncodingEVEX:
+		return "EVEX"
+	case EncodingXOP:
+		return "XOP"
+	}
+
+	return "Encoding(" + strconv.Itoa(int(e)) + ")"
+}
+
+/*
+	Prefix is a set of legacy prefixes.
+*/
+type Prefix uint16
+
+const (
+	PrefixLock     Prefix = 1 << iota // F0
+	PrefixREPNE                       // F2
+	PrefixREP                         // F3
+	PrefixES                          // 26
+	PrefixCS                          // 2E
+	PrefixSS                          // 36
+	PrefixDS                          // 3E
+	PrefixFS                          // 64
+	PrefixGS                          // 65
+	PrefixOpSize                      // 66
+	PrefixAddrSize                    // 67
+)
+
+/*
+	Branch classifies instructions whose immediate is a displacement
+	relative to the next instruction.
+*/
+type Branch uint8
+
+const (
+	BranchNone Branch = iota
+	BranchJmp         // EB, E9
+	BranchCall        // E8
+	BranchJcc         // 70-7F, 0F 80-8F
+
+	/*
+		BranchLoop is LOOP, LOOPE, LOOPNE and JCXZ, which only exist with
+		an 8-bit displacement.
+	*/
+	BranchLoop
+	BranchXBegin // C7 F8
+)
+
+func (b Branch) String() string {
+	switch b {
+	case BranchNone:
+		return "none"
+	case BranchJmp:
+		return "jmp"
+	case BranchCall:
+		return "call"
+	case BranchJcc:
+		return "jcc"
+	case BranchLoop:
+		return "loop"
+	case BranchXBegin:
+		return "xbegin"
+	}
+
+	return "Branch(" + strconv.Itoa(int(b)) + ")"
+}
+
+/*
+	Inst describes the layout of a decoded instruction.
+	Offsets are relative to the first byte of the instruction.
+*/
+type Inst struct {
+	Len      int
+	Mode     Mode
+	Encoding Encoding
+	Prefixes Prefix
+
+	/*
+		REX is the REX prefix, or 0 if there is none.
+	*/
+	REX byte
+
+	Map          Map
+	Opcode       byte
+	OpcodeOffset int
+
+	HasModRM    bool
+	ModRM       byte
+	ModRMOffset int
+
+	/*
+		OperandSize and AddressSize are in bytes. OperandSize only reflects
+		prefixes and REX.W, not the default of a particular opcode.
+	*/
+	OperandSize int
+	AddressSize int
+
+	DispOffset int
+	DispSize   int
+
+	/*
+		RIPRelative reports that the memory operand is addressed relative to
+		the end of the instruction. The displacement is at DispOffset.
+	*/
+	RIPRelative bool
+
+	/*
+		ImmOffset and ImmSize locate the immediate operands. For instructions
+		with two immediates, such as ENTER, they cover both.
+	*/
+	ImmOffset int
+	ImmSize   int
+
+	/*
+		Branch is set for relative branches, whose displacement is the
+		immediate at ImmOffset.
+	*/
+	Branch Branch
+}
+
+/*
+	NeedsRelocation reports whether the instruction refers to memory relative
+	to its own address and must be fixed up when copied elsewhere.
+*/
+func (i *Inst) NeedsRelocation() bool {
+	return i.RIPRelative || i.Branch != BranchNone
+}
+
+/*
+	Target returns the absolute address referenced by a relative branch or
+	RIP-relative operand, given the instruction bytes and its address pc.
+	It reports false for instructions that are not position dependent.
+*/
+func (i *Inst) Target(code []byte, pc uint64) (uint64, bool) {
+	var rel int64
+	switch {
+	case i.Branch != BranchNone:
+		rel = signExtend(code[i.ImmOffset:], i.ImmSize)
+	case i.RIPRelative:
+		rel = signExtend(code[i.DispOffset:], i.DispSize)
+	default:
+		return 0, false
+	}
+
+	target := pc + uint64(i.Len) + uint64(rel)
+	if i.Mode == Mode32 || (i.Branch == BranchNone && i.AddressSize == 4) {
+		target &= 0xFFFFFFFF
+	}
+
+	return target, true
+}
+
+func signExtend(b []byte, size int) int64 {
+	switch size {
+	case 1:
+		return int64(int8(b[0]))
+	case 2:
+		return int64(int16(uint16(b[0]) | uint16(b[1])<<8))
+	case 4:
+		return int64(int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24))
+	}
+
+	return 0
+}